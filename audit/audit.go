@@ -0,0 +1,406 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SkySingh04/fractal/dsn"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/rbac"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/schema"
+)
+
+const defaultPath = "fractal-audit.jsonl"
+
+// Events recorded for every run, and for every run that access control denied
+const (
+	EventStarted  = "run.started"
+	EventFinished = "run.finished"
+	EventDenied   = "access.denied"
+)
+
+// Entry is a line of the audit log. Hash covers every other field, PrevHash
+// included, so changing, removing or reordering lines breaks the chain.
+type Entry struct {
+	Seq            int64         `json:"seq"`
+	Time           time.Time     `json:"time"`
+	Event          string        `json:"event"`
+	RunID          string        `json:"run_id"`
+	Pipeline       string        `json:"pipeline"`
+	Trigger        string        `json:"trigger"`
+	Caller         string        `json:"caller"`
+	Roles          []string      `json:"roles,omitempty"`     // Roles of a caller that was denied
+	Hosts          []string      `json:"hosts,omitempty"`     // Hosts of the source and destination of a denied action
+	Databases      []string      `json:"databases,omitempty"` // Databases of the source and destination of a denied action
+	Source         Endpoint      `json:"source"`
+	Destinations   []Endpoint    `json:"destinations,omitempty"`
+	RulesHash      string        `json:"rules_hash,omitempty"` // Hash of the validation and transformation rules, when there are any
+	ConfigHash     string        `json:"config_hash"`
+	Outcome        runner.Status `json:"outcome"`
+	Error          string        `json:"error,omitempty"`
+	RecordsRead    int           `json:"records_read"`
+	RecordsWritten int           `json:"records_written"`
+	Quarantined    int           `json:"quarantined,omitempty"`
+	PrevHash       string        `json:"prev_hash"`
+	Hash           string        `json:"hash"`
+}
+
+// Endpoint is a source or destination of a run and the systems it connects to
+type Endpoint struct {
+	Integration string   `json:"integration"`
+	Hosts       []string `json:"hosts,omitempty"`
+	Databases   []string `json:"databases,omitempty"`
+	URLs        []string `json:"urls,omitempty"` // URLs and connection strings without credentials
+}
+
+// Filter narrows down the entries returned by List. Zero values match everything.
+type Filter struct {
+	Pipeline string
+	Caller   string
+	RunID    string
+	Since    time.Time
+	Limit    int
+	Visible  func(Entry) bool // If set, entries it rejects are left out before the limit applies
+}
+
+// Verification is the result of checking the hash chain of the log
+type Verification struct {
+	Valid   bool   `json:"valid"`
+	Entries int    `json:"entries"`
+	Line    int    `json:"line,omitempty"`  // First line that breaks the chain
+	Error   string `json:"error,omitempty"` // How it breaks the chain
+}
+
+// Log is an append-only JSON lines file of audit entries. Appends resume the chain
+// from the last line of the file under an exclusive lock of it, so several
+// processes, such as fractal run and fractal serve, may share it.
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+// Open opens the audit log at path, creating it on the first append
+func Open(path string) *Log {
+	return &Log{path: path}
+}
+
+// OpenFromEnv opens the audit log at FRACTAL_AUDIT_FILE, fractal-audit.jsonl by default
+func OpenFromEnv() *Log {
+	path := os.Getenv("FRACTAL_AUDIT_FILE")
+	if path == "" {
+		path = defaultPath
+	}
+	return Open(path)
+}
+
+// Path returns the location of the log
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append chains entry to the last entry of the log, writes it and returns it with
+// its sequence number and hashes set
+func (l *Log) Append(entry Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return entry, err
+	}
+	defer file.Close()
+	// Another process must not append between reading the last entry and writing
+	if err := lockFile(file); err != nil {
+		return entry, fmt.Errorf("failed to lock %s: %w", l.path, err)
+	}
+	last, err := lastEntry(file)
+	if err != nil {
+		return entry, fmt.Errorf("failed to read the last entry of %s: %w", l.path, err)
+	}
+	entry.Seq, entry.PrevHash = last.Seq+1, last.Hash
+	if entry.Hash, err = hash(entry); err != nil {
+		return entry, err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return entry, err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return entry, err
+	}
+	return entry, file.Sync()
+}
+
+// List returns the entries matching filter, newest first
+func (l *Log) List(filter Filter) ([]Entry, error) {
+	entries := []Entry{}
+	err := l.scan(func(_ int, entry Entry) error {
+		if filter.Pipeline != "" && entry.Pipeline != filter.Pipeline ||
+			filter.Caller != "" && entry.Caller != filter.Caller ||
+			filter.RunID != "" && entry.RunID != filter.RunID ||
+			entry.Time.Before(filter.Since) ||
+			filter.Visible != nil && !filter.Visible(entry) {
+			return nil
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+// Verify checks that every entry follows the one before it and that no entry
+// changed since it was written
+func (l *Log) Verify() (Verification, error) {
+	var result Verification
+	var previous Entry
+	broken := errors.New("broken chain")
+	err := l.scan(func(line int, entry Entry) error {
+		expected, err := hash(entry)
+		switch {
+		case err != nil:
+			return err
+		case entry.Seq != previous.Seq+1:
+			result.Error = fmt.Sprintf("sequence number %d follows %d", entry.Seq, previous.Seq)
+		case entry.PrevHash != previous.Hash:
+			result.Error = "prev_hash does not match the hash of the entry before"
+		case entry.Hash != expected:
+			result.Error = "the entry was changed after it was written"
+		default:
+			result.Entries++
+			previous = entry
+			return nil
+		}
+		result.Line = line
+		return broken
+	})
+	if errors.Is(err, broken) {
+		return result, nil
+	}
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		result.Line, result.Error = result.Entries+1, "the line is not a JSON entry"
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Valid = true
+	return result, nil
+}
+
+// scan calls fn with every entry of the log, in order, numbering lines from 1
+func (l *Log) scan(fn func(line int, entry Entry) error) error {
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("line %d of %s: %w", line, l.path, err)
+		}
+		if err := fn(line, entry); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// lastEntry returns the last entry of file, the zero entry when it is empty
+func lastEntry(file *os.File) (Entry, error) {
+	var entry Entry
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return entry, err
+	}
+	// Read backwards in growing chunks until the chunk holds the whole last line
+	for size := int64(4096); ; size *= 2 {
+		if size > info.Size() {
+			size = info.Size()
+		}
+		chunk := make([]byte, size)
+		if _, err := file.ReadAt(chunk, info.Size()-size); err != nil && err != io.EOF {
+			return entry, err
+		}
+		chunk = bytes.TrimRight(chunk, "\n")
+		start := bytes.LastIndexByte(chunk, '\n')
+		if start >= 0 || size == info.Size() {
+			return entry, json.Unmarshal(chunk[start+1:], &entry)
+		}
+	}
+}
+
+// hash returns the SHA-256 of an entry without its own hash
+func hash(entry Entry) (string, error) {
+	entry.Hash = ""
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// RulesHash returns the hash of a set of validation and transformation rules, or ""
+// when there are none
+func RulesHash(validations, transformations []string) string {
+	if len(validations) == 0 && len(transformations) == 0 {
+		return ""
+	}
+	encoded, _ := json.Marshal(map[string][]string{"validations": validations, "transformations": transformations})
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// EndpointOf describes an integration of a run from the fields of its config that
+// are tagged as endpoints, leaving out credentials
+func EndpointOf(integration string, kind schema.Kind, req interfaces.Request) Endpoint {
+	endpoint := Endpoint{Integration: integration}
+	described, err := schema.ForSource(integration)
+	if kind == schema.KindDestination {
+		described, err = schema.ForDestination(integration)
+	}
+	if err != nil {
+		return endpoint
+	}
+	for _, field := range described.Fields {
+		value := schema.Value(req, field)
+		if value == "" {
+			continue
+		}
+		switch field.Endpoint {
+		case "url":
+			parsed := dsn.Parse(value)
+			endpoint.Hosts = append(endpoint.Hosts, parsed.Hosts()...)
+			endpoint.Databases = append(endpoint.Databases, parsed.Databases...)
+			endpoint.URLs = append(endpoint.URLs, StripCredentials(value))
+		case "database":
+			endpoint.Databases = append(endpoint.Databases, value)
+		}
+	}
+	return endpoint
+}
+
+// StripCredentials removes the user, password and other credentials from a URL, a
+// key=value connection string or a host list, as dsn.StripCredentials does
+func StripCredentials(value string) string {
+	return dsn.StripCredentials(value)
+}
+
+// RecordDenial records a migration that access control denied. It is meant to be
+// the OnDenied function of an rbac.Policy; failures to write are logged.
+func (l *Log) RecordDenial(denial rbac.Denial) {
+	entry := Entry{
+		Time:      time.Now().UTC(),
+		Event:     EventDenied,
+		Pipeline:  denial.Action.Pipeline,
+		Caller:    denial.Principal,
+		Roles:     denial.Roles,
+		Hosts:     denial.Action.Hosts,
+		Databases: denial.Action.Databases,
+		Source:    Endpoint{Integration: denial.Action.Source},
+		Error:     denial.Reason,
+	}
+	if denial.Action.Destination != "" {
+		entry.Destinations = []Endpoint{{Integration: denial.Action.Destination}}
+	}
+	if _, err := l.Append(entry); err != nil {
+		logger.Errorf("Failed to record the denial of %s in the audit log %s: %v", denial.Principal, l.Path(), err)
+	}
+}
+
+// Hook is a runner.DataHook that records the start and the end of every run in
+// the audit log. Failures to write are logged.
+type Hook struct {
+	log *Log
+
+	mu    sync.Mutex
+	specs map[string]runner.Spec // By run ID, until the run finishes
+}
+
+// NewHook returns a hook that records runs in log
+func NewHook(log *Log) *Hook {
+	return &Hook{log: log, specs: map[string]runner.Spec{}}
+}
+
+// Planned implements runner.DataHook
+func (h *Hook) Planned(run *runner.Run, spec runner.Spec) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.specs[run.ID] = spec
+}
+
+// Read implements runner.DataHook
+func (h *Hook) Read(*runner.Run, interface{}) {}
+
+// RunStarted records who started the run and what it connects to
+func (h *Hook) RunStarted(run *runner.Run) {
+	h.record(EventStarted, run, run.StartedAt)
+}
+
+// RunFinished records the outcome of the run
+func (h *Hook) RunFinished(run *runner.Run) {
+	h.record(EventFinished, run, run.FinishedAt)
+	h.mu.Lock()
+	delete(h.specs, run.ID)
+	h.mu.Unlock()
+}
+
+func (h *Hook) record(event string, run *runner.Run, at time.Time) {
+	h.mu.Lock()
+	spec, ok := h.specs[run.ID]
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+	entry := Entry{
+		Time:           at,
+		Event:          event,
+		RunID:          run.ID,
+		Pipeline:       run.Pipeline,
+		Trigger:        run.Trigger,
+		Caller:         run.Caller,
+		Source:         EndpointOf(spec.Source, schema.KindSource, spec.SourceRequest),
+		Destinations:   []Endpoint{EndpointOf(spec.Destination, schema.KindDestination, spec.DestinationRequest)},
+		RulesHash:      RulesHash(spec.Validations, spec.Transformations),
+		ConfigHash:     run.ConfigHash,
+		Outcome:        run.Status,
+		Error:          run.Error,
+		RecordsRead:    run.RecordsRead,
+		RecordsWritten: run.RecordsWritten,
+		Quarantined:    run.Quarantined,
+	}
+	for _, target := range spec.Destinations {
+		entry.Destinations = append(entry.Destinations, EndpointOf(target.Destination, schema.KindDestination, target.Request))
+	}
+	if _, err := h.log.Append(entry); err != nil {
+		logger.Errorf("Failed to record run %s in the audit log %s: %v", run.ID, h.log.Path(), err)
+	}
+}
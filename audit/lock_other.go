@@ -0,0 +1,11 @@
+//go:build !unix
+
+package audit
+
+import "os"
+
+// lockFile does nothing where flock is not available; appends are then only
+// serialized within the process
+func lockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/SkySingh04/fractal/logger"
+)
+
+// Operation is an action a caller may be allowed to perform
+type Operation string
+
+const (
+	OperationRun    Operation = "run"    // Start migrations and jobs
+	OperationRead   Operation = "read"   // Read run history, job progress and metadata
+	OperationCancel Operation = "cancel" // Cancel running jobs
+	OperationManage Operation = "manage" // Create, change, pause and delete saved pipelines
+)
+
+// Scopes are granted to keys and tokens as plain strings:
+//
+//	run, read, cancel, manage  the operation of the same name
+//	integration:<Name>         the source or destination integration <Name>
+//	integration:*              every integration
+//	role:<name>                membership of an access control role
+//	*                          every operation and every integration
+const (
+	ScopeAll          = "*"
+	integrationPrefix = "integration:"
+	rolePrefix        = "role:"
+)
+
+// ErrUnauthenticated is returned when a request carries no usable credentials
+var ErrUnauthenticated = errors.New("authentication required")
+
+// ForbiddenError is returned when a caller lacks the scope for an action. Its
+// StatusCode is picked up by gofr when a handler returns it.
+type ForbiddenError struct {
+	Principal string
+	Scope     string
+}
+
+func (e ForbiddenError) Error() string {
+	return fmt.Sprintf("%s is missing scope %s", e.Principal, e.Scope)
+}
+
+// StatusCode implements gofr's status code responder
+func (e ForbiddenError) StatusCode() int {
+	return http.StatusForbidden
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Name   string   // Key name, HMAC key id or token subject
+	Method string   // api_key, hmac or jwt
+	Scopes []string // Granted scopes
+}
+
+func (p *Principal) has(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == ScopeAll || granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the principal may perform op
+func (p *Principal) Can(op Operation) bool {
+	return p != nil && p.has(string(op))
+}
+
+// CanUse reports whether the principal may use the named integration
+func (p *Principal) CanUse(integration string) bool {
+	return p != nil && (p.has(integrationPrefix+"*") || p.has(integrationPrefix+integration))
+}
+
+// Roles returns the access control roles granted to the principal
+func (p *Principal) Roles() []string {
+	if p == nil {
+		return nil
+	}
+	var roles []string
+	for _, scope := range p.Scopes {
+		if role, ok := strings.CutPrefix(scope, rolePrefix); ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Authorize checks that the principal may perform op with every listed integration.
+// A nil principal is only returned by FromContext when authentication is disabled,
+// so it is allowed everything.
+func (p *Principal) Authorize(op Operation, integrations ...string) error {
+	if p == nil {
+		return nil
+	}
+	if !p.Can(op) {
+		return p.deny(string(op))
+	}
+	for _, integration := range integrations {
+		if integration != "" && !p.CanUse(integration) {
+			return p.deny(integrationPrefix + integration)
+		}
+	}
+	return nil
+}
+
+func (p *Principal) deny(scope string) error {
+	logger.Logf("Access denied: %s %s is missing scope %s", p.Method, p.Name, scope)
+	return ForbiddenError{Principal: p.Name, Scope: scope}
+}
+
+// Authenticator identifies the caller of a request. It returns a nil principal and
+// a nil error when the request does not carry its kind of credentials, so that the
+// next authenticator can be tried, and an error when the credentials are invalid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain tries each authenticator in turn and returns the first principal found
+type Chain []Authenticator
+
+// Authenticate implements Authenticator
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range c {
+		principal, err := authenticator.Authenticate(r)
+		if err != nil || principal != nil {
+			return principal, err
+		}
+	}
+	return nil, ErrUnauthenticated
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a context that carries principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// FromContext returns the principal carried by ctx, or nil when authentication is disabled
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(contextKey{}).(*Principal)
+	return principal
+}
+
+// Middleware authenticates every request with authenticator and checks the operation
+// returned by operationOf. Requests for which operationOf returns false are public.
+func Middleware(authenticator Authenticator, operationOf func(r *http.Request) (Operation, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op, protected := operationOf(r)
+			if !protected {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := authenticator.Authenticate(r)
+			if err != nil {
+				logger.Warnf("Authentication failed for %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="fractal"`)
+				writeError(w, http.StatusUnauthorized, err)
+				return
+			}
+			if err := principal.Authorize(op); err != nil {
+				writeError(w, http.StatusForbidden, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// writeError responds in the same shape as gofr's error responses
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"message": err.Error()},
+	})
+}
+
+// ParseScopes splits a space or comma separated scope list
+func ParseScopes(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' })
+}
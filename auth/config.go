@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const defaultConfigFile = "config.yaml"
+
+// Config is the auth section of the configuration file:
+//
+//	auth:
+//	  api_keys:
+//	    - name: ci
+//	      key: 8f1c...
+//	      scopes: [run, read, "integration:CSV", "role:analytics"]
+//	  hmac_keys:
+//	    - id: partner
+//	      secret: 3b7e...
+//	      scopes: [read]
+//	  hmac_max_skew: 5m
+//	  jwt:
+//	    jwks_file: jwks.json
+//	    issuer: https://idp.example.com
+//	    audience: fractal
+type Config struct {
+	APIKeys     []APIKey      `mapstructure:"api_keys"`
+	HMACKeys    []HMACKey     `mapstructure:"hmac_keys"`
+	HMACMaxSkew time.Duration `mapstructure:"hmac_max_skew"`
+	JWT         *JWTConfig    `mapstructure:"jwt"`
+}
+
+// Build returns the authenticators enabled by the config. An empty chain means
+// that authentication is disabled.
+func (c Config) Build() (Chain, error) {
+	var chain Chain
+	if len(c.APIKeys) > 0 {
+		keys, err := NewAPIKeys(c.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, keys)
+	}
+	if len(c.HMACKeys) > 0 {
+		keys, err := NewHMAC(c.HMACKeys, c.HMACMaxSkew)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, keys)
+	}
+	if c.JWT != nil {
+		tokens, err := NewJWT(*c.JWT)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, tokens)
+	}
+	return chain, nil
+}
+
+// LoadConfig reads the auth section of a YAML configuration file
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return config, fmt.Errorf("failed to read auth config %s: %w", path, err)
+	}
+	if err := v.UnmarshalKey("auth", &config); err != nil {
+		return config, fmt.Errorf("invalid auth config in %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ConfigFile returns the file that holds the access configuration: FRACTAL_AUTH_CONFIG,
+// or config.yaml if it exists. It returns "" when there is none.
+func ConfigFile() string {
+	if path := os.Getenv("FRACTAL_AUTH_CONFIG"); path != "" {
+		return path
+	}
+	if _, err := os.Stat(defaultConfigFile); err == nil {
+		return defaultConfigFile
+	}
+	return ""
+}
+
+// LoadFromEnv builds the authenticators from the auth section of ConfigFile and
+// the API keys listed in FRACTAL_API_KEYS
+func LoadFromEnv() (Chain, error) {
+	config := Config{}
+	if path := ConfigFile(); path != "" {
+		var err error
+		if config, err = LoadConfig(path); err != nil {
+			return nil, err
+		}
+	}
+
+	keys, err := ParseAPIKeys(os.Getenv("FRACTAL_API_KEYS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid FRACTAL_API_KEYS: %w", err)
+	}
+	config.APIKeys = append(config.APIKeys, keys...)
+	return config.Build()
+}
+
+// ParseAPIKeys parses a comma separated list of name:key:scopes entries, where
+// scopes is a space separated scope list, e.g. "ci:8f1c:run read integration:CSV"
+func ParseAPIKeys(value string) ([]APIKey, error) {
+	var keys []APIKey
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New("entries must look like name:key:scopes")
+		}
+		keys = append(keys, APIKey{Name: parts[0], Key: parts[1], Scopes: ParseScopes(parts[2])})
+	}
+	return keys, nil
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTConfig configures bearer token validation
+type JWTConfig struct {
+	JWKSFile string `mapstructure:"jwks_file"` // Local JSON Web Key Set used to verify signatures
+	Issuer   string `mapstructure:"issuer"`    // Expected iss claim, if set
+	Audience string `mapstructure:"audience"`  // Expected aud claim, if set
+}
+
+// JWT authenticates "Authorization: Bearer" tokens signed by a key of a local JWKS file.
+// Scopes are read from the space separated "scope" claim or the "scp" list, and roles
+// from the "roles" list. The file is reloaded when it changes, so keys can be rotated
+// without a restart.
+type JWT struct {
+	config JWTConfig
+	parser *jwt.Parser
+
+	mu       sync.Mutex
+	keys     map[string]interface{}
+	modified time.Time
+}
+
+// NewJWT returns an authenticator that validates tokens against config.JWKSFile
+func NewJWT(config JWTConfig) (*JWT, error) {
+	if config.JWKSFile == "" {
+		return nil, errors.New("jwt authentication needs a jwks_file")
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
+	j := &JWT{config: config, parser: jwt.NewParser(options...)}
+	if _, err := j.loadKeys(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope string   `json:"scope"`
+	Scp   []string `json:"scp"`
+	Roles []string `json:"roles"`
+}
+
+// Authenticate implements Authenticator
+func (j *JWT) Authenticate(r *http.Request) (*Principal, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, nil
+	}
+
+	claims := &tokenClaims{}
+	_, err := j.parser.ParseWithClaims(strings.TrimSpace(token), claims, j.keyFor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bearer token: %w", err)
+	}
+
+	scopes := append(ParseScopes(claims.Scope), claims.Scp...)
+	for _, role := range claims.Roles {
+		scopes = append(scopes, rolePrefix+role)
+	}
+	return &Principal{Name: claims.Subject, Method: "jwt", Scopes: scopes}, nil
+}
+
+// keyFor returns the verification key named by the token's kid header
+func (j *JWT) keyFor(token *jwt.Token) (interface{}, error) {
+	keys, err := j.loadKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// loadKeys returns the keys of the JWKS file, reading it again if it has changed
+func (j *JWT) loadKeys() (map[string]interface{}, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info, err := os.Stat(j.config.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	if j.keys != nil && info.ModTime().Equal(j.modified) {
+		return j.keys, nil
+	}
+
+	content, err := os.ReadFile(j.config.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	keys, err := ParseJWKS(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS file %s: %w", j.config.JWKSFile, err)
+	}
+	j.keys, j.modified = keys, info.ModTime()
+	return keys, nil
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS decodes the RSA, EC and Ed25519 public keys of a JSON Web Key Set, keyed by kid.
+// Keys meant for encryption are skipped.
+func ParseJWKS(content []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys found")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SkySingh04/fractal/config"
+	"github.com/SkySingh04/fractal/health"
+	"github.com/spf13/cobra"
+)
+
+func checkCommand() *cobra.Command {
+	var configFile, pipelineName string
+	var timeout time.Duration
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Test the connections of the pipelines of a config file",
+		Long: `Test the connections of the pipelines of a config file before deploying them.
+
+The source and every destination of each pipeline are probed at once, as the
+/health/ready endpoint of the server does: a PostgreSQL ping, a MongoDB ping, a Kafka
+metadata request, an AMQP, FTP or SFTP login, a check of Firebase credentials and so
+on. No data is read or written. Integrations that cannot test their connection are
+skipped. The exit code is 1 if any check fails.`,
+		Example: `  fractal check -c pipeline.yaml
+  fractal check -c pipelines.yaml --pipeline users --timeout 10s`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			definitions, err := config.LoadPipelines(configFile)
+			if err != nil {
+				return err
+			}
+			if pipelineName != "" {
+				if definitions, err = selectPipeline(definitions, pipelineName, configFile); err != nil {
+					return err
+				}
+			}
+			report := health.Probe(context.Background(), definitions, timeout, health.ResolveAll)
+
+			if asJSON {
+				encoded, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(encoded))
+			} else {
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "PIPELINE\tROLE\tINTEGRATION\tSTATUS\tDURATION\tERROR")
+				for _, check := range report.Checks {
+					duration := time.Duration(check.DurationSeconds * float64(time.Second)).Round(time.Millisecond)
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", check.Pipeline, check.Role, check.Integration, check.Status, duration, check.Error)
+				}
+				if err := w.Flush(); err != nil {
+					return err
+				}
+			}
+
+			failed := 0
+			for _, check := range report.Checks {
+				if check.Status == health.StatusFailed {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d checks failed", failed, len(report.Checks))
+			}
+			return nil
+		},
+	}
+	configFlag(cmd, &configFile)
+	cmd.Flags().StringVarP(&pipelineName, "pipeline", "p", "", "check only the pipeline with this name")
+	cmd.Flags().DurationVar(&timeout, "timeout", health.DefaultTimeout, "how long every check may take")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the results as JSON")
+	return cmd
+}
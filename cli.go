@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/SkySingh04/fractal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+// envAnnotation links a flag to the environment variable that can set it instead
+const envAnnotation = "fractal_env"
+
+// newRootCommand builds the fractal command tree
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:   "fractal",
+		Short: "Move, validate and transform data between integrations",
+		Long: `Fractal moves data from a source integration to one or more destinations,
+validating and transforming it on the way.
+
+Every flag can also be set through the environment variable shown in its help.
+Run without a command in a terminal to choose between the server and the CLI interactively.`,
+		Args:              cobra.NoArgs,
+		SilenceUsage:      true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return applyEnv(cmd) },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !term.IsTerminal(int(os.Stdin.Fd())) {
+				return cmd.Help()
+			}
+			return interactive()
+		},
+	}
+
+	root.PersistentFlags().String("db-path", "", "run history database file")
+	bindEnv(root.PersistentFlags(), "db-path", "FRACTAL_DB_PATH")
+
+	root.AddCommand(
+		serveCommand(),
+		runCommand(),
+		validateCommand(),
+		checkCommand(),
+		profileCommand(),
+		initCommand(),
+		integrationsCommand(),
+		runsCommand(),
+		openAPICommand(),
+	)
+	return root
+}
+
+// bindEnv makes the environment variable env an alternative to the flag. A flag given
+// on the command line wins and is exported to env, so packages that read env see it.
+func bindEnv(flags *pflag.FlagSet, name, env string) {
+	if err := flags.SetAnnotation(name, envAnnotation, []string{env}); err != nil {
+		panic(err)
+	}
+	flag := flags.Lookup(name)
+	flag.Usage = fmt.Sprintf("%s [$%s]", flag.Usage, env)
+}
+
+// applyEnv reconciles every flag of cmd with its environment variable
+func applyEnv(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		envs := flag.Annotations[envAnnotation]
+		if len(envs) == 0 || err != nil {
+			return
+		}
+		env := envs[0]
+		if flag.Changed {
+			err = os.Setenv(env, flag.Value.String())
+			return
+		}
+		if value, ok := os.LookupEnv(env); ok && value != "" {
+			if setErr := flag.Value.Set(value); setErr != nil {
+				err = fmt.Errorf("invalid $%s %q: %w", env, value, setErr)
+			}
+		}
+	})
+	return err
+}
+
+// configFlag adds the -c/--config flag shared by the commands that read a pipeline config
+func configFlag(cmd *cobra.Command, path *string) {
+	cmd.Flags().StringVarP(path, "config", "c", "config.yaml", "pipeline config file")
+	bindEnv(cmd.Flags(), "config", "FRACTAL_CONFIG")
+}
+
+// reloadFlag adds --reload to a command that keeps running the pipelines of a config file
+func reloadFlag(cmd *cobra.Command, reload *bool) {
+	cmd.Flags().BoolVar(reload, "reload", true, "apply changes to the config file, saved or signalled with SIGHUP, without a restart")
+	bindEnv(cmd.Flags(), "reload", "FRACTAL_RELOAD")
+}
+
+// interactive is the original prompt-driven flow: pick the server or the CLI, then
+// set up config.yaml with the wizard if it does not exist yet
+func interactive() error {
+	fmt.Print(logo)
+	mode, err := config.AskForMode()
+	if err != nil {
+		return err
+	}
+	if mode == "Start HTTP Server" {
+		return serve("", false, defaultGracePeriod)
+	}
+	return run(runOptions{configFile: "config.yaml", gracePeriod: defaultGracePeriod, interactive: true})
+}
@@ -1,11 +1,12 @@
 package config
 
 import (
-	"errors"
 	"fmt"
-	"reflect"
+	"path/filepath"
+	"strings"
 
 	"github.com/SkySingh04/fractal/registry"
+	"github.com/SkySingh04/fractal/schema"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/viper"
 )
@@ -25,8 +26,16 @@ func AskForMode() (string, error) {
 	return mode, nil
 }
 
-// LoadConfig attempts to read the configuration from a file
+// LoadConfig reads the configuration from a file. YAML files are checked with
+// Validate first, so a mistake is reported with its location instead of turning
+// into an empty value.
 func LoadConfig(configFile string) (map[string]interface{}, error) {
+	if ext := strings.ToLower(filepath.Ext(configFile)); ext == ".yaml" || ext == ".yml" {
+		if err := Validate(configFile); err != nil {
+			return nil, err
+		}
+	}
+
 	viper.SetConfigFile(configFile)
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
@@ -37,22 +46,21 @@ func LoadConfig(configFile string) (map[string]interface{}, error) {
 		"outputMethod": viper.GetString("outputMethod"),
 		"inputconfig":  viper.GetStringMap("inputconfig"),
 		"outputconfig": viper.GetStringMap("outputconfig"),
+		"cronjob":      viper.GetStringMap("cronjob"),
 	}
 
 	return config, nil
 }
 
 // SetupConfigInteractively prompts the user to set up input and output methods interactively,
-// including all required fields for the selected integrations.
-func SetupConfigInteractively() (map[string]interface{}, error) {
-	// Dynamically retrieve registered input and output options
-	inputMethods := getRegisteredDataSources()
-	outputMethods := getRegisteredDataDestinations()
-
+// including all required fields for the selected integrations, and saves the result to configFile.
+// The connections can be tested before the configuration is saved.
+func SetupConfigInteractively(configFile string) (map[string]interface{}, error) {
 	// Prompt for Input Method
 	inputPrompt := promptui.Select{
 		Label: "Select Input Method",
-		Items: inputMethods,
+		Items: integrationNames(schema.KindSource),
+		Size:  10,
 	}
 	_, inputMethod, err := inputPrompt.Run()
 	if err != nil {
@@ -60,7 +68,7 @@ func SetupConfigInteractively() (map[string]interface{}, error) {
 	}
 
 	// Read additional fields for the input method
-	inputconfig, err := readIntegrationFields(inputMethod, true)
+	inputconfig, err := readIntegrationFields(inputMethod, schema.KindSource, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get fields for input method: %w", err)
 	}
@@ -68,7 +76,8 @@ func SetupConfigInteractively() (map[string]interface{}, error) {
 	// Prompt for Output Method
 	outputPrompt := promptui.Select{
 		Label: "Select Output Method",
-		Items: outputMethods,
+		Items: integrationNames(schema.KindDestination),
+		Size:  10,
 	}
 	_, outputMethod, err := outputPrompt.Run()
 	if err != nil {
@@ -76,7 +85,7 @@ func SetupConfigInteractively() (map[string]interface{}, error) {
 	}
 
 	// Read additional fields for the output method
-	outputconfig, err := readIntegrationFields(outputMethod, false)
+	outputconfig, err := readIntegrationFields(outputMethod, schema.KindDestination, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get fields for output method: %w", err)
 	}
@@ -88,73 +97,40 @@ func SetupConfigInteractively() (map[string]interface{}, error) {
 		"inputconfig":  inputconfig,
 		"outputconfig": outputconfig,
 	}
-	//TODO : FIX THIS BUG OF MISSING INPUT CONFIG IN CONFIGURATION
-	saveConfig(config)
-
-	//wait for 2
-	// time.Sleep(5 * time.Second)
+	if err := testConnections(config); err != nil {
+		return nil, err
+	}
+	saveConfig(configFile, config)
 
 	return config, nil
 }
 
-// readIntegrationFields dynamically prompts for and reads all fields in the selected integration struct
-func readIntegrationFields(method string, isSource bool) (map[string]interface{}, error) {
-	var integration interface{}
-	var found bool
-
-	// Get the appropriate integration
-	if isSource {
-		integration, found = registry.GetSource(method)
-	} else {
-		integration, found = registry.GetDestination(method)
-	}
-
-	if !found {
-		return nil, errors.New("integration not found in registry")
-	}
-
-	// Use reflection to inspect the integration struct
-	val := reflect.ValueOf(integration)
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem() // Dereference if it's a pointer
+// saveConfig writes the configuration to configFile
+func saveConfig(configFile string, config map[string]interface{}) {
+	envs, err := WriteConfig(configFile, config)
+	if err != nil {
+		fmt.Println("Failed to save configuration:", err)
+		return
 	}
-	if val.Kind() != reflect.Struct {
-		return nil, errors.New("integration is not a struct")
+	fmt.Println("Configuration saved to", configFile)
+	if len(envs) > 0 {
+		fmt.Printf("Secrets were not saved. Set %s before the next run, or replace the references with secret:// references\n", strings.Join(envs, ", "))
 	}
-
-	config := make(map[string]interface{})
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Type().Field(i)
-		fieldName := field.Name
-		fieldType := field.Type
-
-		// Prompt the user for the field value
-		prompt := promptui.Prompt{
-			Label: fmt.Sprintf("Enter %s (%s)", fieldName, fieldType),
-		}
-		value, err := prompt.Run()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get value for field %s: %w", fieldName, err)
-		}
-
-		// Assign the value to the config
-		config[fieldName] = value
+	if err := Validate(configFile); err != nil {
+		fmt.Println("The saved configuration needs attention:", err)
 	}
-
-	return config, nil
 }
 
-// saveConfig writes the configuration to a config.yaml file
-func saveConfig(config map[string]interface{}) {
+// WriteConfig writes a configuration in the format read by LoadConfig. Secret fields
+// are never written: plain values are replaced with ${ENV} references and the
+// environment variables that must be set instead are returned.
+func WriteConfig(configFile string, config map[string]interface{}) ([]string, error) {
+	config, envs := withoutSecrets(config)
+	v := viper.New()
 	for key, value := range config {
-		viper.Set(key, value)
-	}
-
-	if err := viper.WriteConfigAs("config.yaml"); err != nil {
-		fmt.Println("Failed to save configuration:", err)
-	} else {
-		fmt.Println("Configuration saved to config.yaml")
+		v.Set(key, value)
 	}
+	return envs, v.WriteConfigAs(configFile)
 }
 
 // Helper function to retrieve registered input methods
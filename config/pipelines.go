@@ -0,0 +1,260 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/SkySingh04/fractal/verify"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPipeline names the pipeline described by inputmethod and outputmethod
+const DefaultPipeline = "default"
+
+// fileConfig is the part of a config file that describes pipelines
+type fileConfig struct {
+	InputMethod  string                 `yaml:"inputmethod"`
+	OutputMethod string                 `yaml:"outputmethod"`
+	InputConfig  map[string]interface{} `yaml:"inputconfig"`
+	OutputConfig map[string]interface{} `yaml:"outputconfig"`
+	Cronjob      map[string]interface{} `yaml:"cronjob"`
+	Pipelines    []pipelineConfig       `yaml:"pipelines"`
+}
+
+// pipelineConfig is an entry of the pipelines list:
+//
+//	pipelines:
+//	  - name: users
+//	    source:
+//	      integration: PostgreSQL
+//	      config:
+//	        connstring: ${DATABASE_URL}
+//	    destinations:
+//	      - integration: CSV
+//	        config:
+//	          csvdestinationfilename: users.csv
+//	    schedule: "0 2 * * *"
+//	    error_strategy: RETRY
+//	    verify:
+//	      key_fields: [id]
+//
+// The schedule keys are those of the cronjob section, except repetition_interval.
+type pipelineConfig struct {
+	Name            string           `yaml:"name"`
+	Description     string           `yaml:"description"`
+	Source          endpointConfig   `yaml:"source"`
+	Destinations    []endpointConfig `yaml:"destinations"`
+	Validations     []string         `yaml:"validations"`
+	Transformations []string         `yaml:"transformations"`
+	Schedule        string           `yaml:"schedule"`
+	Timezone        string           `yaml:"timezone"`
+	Overlap         string           `yaml:"overlap"`
+	CatchUp         string           `yaml:"catch_up"`
+	Jitter          string           `yaml:"jitter"`
+	ErrorStrategy   string           `yaml:"error_strategy"`
+	Verify          *verify.Options  `yaml:"verify"`
+}
+
+type endpointConfig struct {
+	Integration string                 `yaml:"integration"`
+	Config      map[string]interface{} `yaml:"config"`
+}
+
+// LoadPipelines returns every pipeline of a config file: the entries of its pipelines
+// list, preceded by a pipeline named "default" when the file also sets inputmethod and
+// outputmethod. The file is checked with Validate first. Secret references in the
+// integration configs are kept, so they can be resolved for every run.
+func LoadPipelines(configFile string) ([]*pipeline.Definition, error) {
+	if err := Validate(configFile); err != nil {
+		return nil, err
+	}
+	file, err := readFileConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var definitions []*pipeline.Definition
+	if file.InputMethod != "" {
+		definition, err := PipelineFromConfig(map[string]interface{}{
+			"inputMethod":  file.InputMethod,
+			"outputMethod": file.OutputMethod,
+			"inputconfig":  file.InputConfig,
+			"outputconfig": file.OutputConfig,
+			"cronjob":      file.Cronjob,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", configFile, err)
+		}
+		definitions = append(definitions, definition)
+	}
+
+	for _, entry := range file.Pipelines {
+		definition := &pipeline.Definition{
+			Name:            entry.Name,
+			Description:     entry.Description,
+			Source:          pipeline.Endpoint{Integration: entry.Source.Integration, Config: ToRequest(entry.Source.Config)},
+			Validations:     entry.Validations,
+			Transformations: entry.Transformations,
+			Schedule:        entry.Schedule,
+			Timezone:        entry.Timezone,
+			Overlap:         entry.Overlap,
+			CatchUp:         entry.CatchUp,
+			Jitter:          entry.Jitter,
+			ErrorStrategy:   entry.ErrorStrategy,
+			Verify:          entry.Verify,
+		}
+		for _, destination := range entry.Destinations {
+			definition.Destinations = append(definition.Destinations, pipeline.Endpoint{Integration: destination.Integration, Config: ToRequest(destination.Config)})
+		}
+		if err := definition.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: pipeline %s: %w", configFile, entry.Name, err)
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions, nil
+}
+
+// Source is the source of a pipeline of a config file
+type Source struct {
+	Pipeline string
+	pipeline.Endpoint
+}
+
+// LoadSources returns the source of every pipeline of a config file, in the order of
+// LoadPipelines. Unlike LoadPipelines, it accepts a file without destinations. Secret
+// references in the integration configs are kept.
+func LoadSources(configFile string) ([]Source, error) {
+	if err := ValidateSources(configFile); err != nil {
+		return nil, err
+	}
+	file, err := readFileConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	var sources []Source
+	if file.InputMethod != "" {
+		sources = append(sources, Source{DefaultPipeline, pipeline.Endpoint{Integration: file.InputMethod, Config: ToRequest(file.InputConfig)}})
+	}
+	for _, entry := range file.Pipelines {
+		sources = append(sources, Source{entry.Name, pipeline.Endpoint{Integration: entry.Source.Integration, Config: ToRequest(entry.Source.Config)}})
+	}
+	return sources, nil
+}
+
+// readFileConfig decodes a config file that was checked with Validate
+func readFileConfig(configFile string) (fileConfig, error) {
+	var file fileConfig
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return file, err
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return file, fmt.Errorf("%s: %w", configFile, err)
+	}
+	lowercaseKeys(&document)
+	if err := document.Decode(&file); err != nil {
+		return file, fmt.Errorf("%s: %w", configFile, err)
+	}
+	return file, nil
+}
+
+// PipelineFromConfig returns the pipeline named "default" described by a configuration
+// loaded with LoadConfig or set up interactively
+func PipelineFromConfig(configuration map[string]interface{}) (*pipeline.Definition, error) {
+	inputMethod, _ := configuration["inputMethod"].(string)
+	outputMethod, _ := configuration["outputMethod"].(string)
+	inputconfig, _ := configuration["inputconfig"].(map[string]interface{})
+	outputconfig, _ := configuration["outputconfig"].(map[string]interface{})
+
+	definition := &pipeline.Definition{
+		Name:         DefaultPipeline,
+		Source:       pipeline.Endpoint{Integration: inputMethod, Config: ToRequest(inputconfig)},
+		Destinations: []pipeline.Endpoint{{Integration: outputMethod, Config: ToRequest(outputconfig)}},
+	}
+	spec, scheduled, err := ScheduleFromConfig(configuration)
+	if err != nil {
+		return nil, err
+	}
+	if scheduled {
+		definition.Schedule = spec.Expression
+		definition.Timezone = spec.Timezone
+		definition.Overlap = string(spec.Overlap)
+		definition.CatchUp = string(spec.CatchUp)
+		if spec.Jitter > 0 {
+			definition.Jitter = spec.Jitter.String()
+		}
+	}
+	return definition, nil
+}
+
+// lowercaseKeys lowercases every mapping key below node, so keys are matched without
+// regard to case as they are by viper and Validate
+func lowercaseKeys(node *yaml.Node) {
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && i%2 == 0 {
+			child.Value = strings.ToLower(child.Value)
+		}
+		lowercaseKeys(child)
+	}
+}
+
+// getStringField returns a config value as a string; YAML numbers and booleans are formatted
+func getStringField(config map[string]interface{}, field string, defaultValue string) string {
+	if value, ok := config[field]; ok && value != nil {
+		return fmt.Sprint(value)
+	}
+	return defaultValue
+}
+
+// ToRequest maps the fields of an integration config section, keyed by their config
+// file names, to a request
+func ToRequest(config map[string]interface{}) interfaces.Request {
+	return interfaces.Request{
+		Input:                   getStringField(config, "inputmethod", ""),
+		Output:                  getStringField(config, "outputmethod", ""),
+		RabbitMQInputURL:        getStringField(config, "url", ""),
+		RabbitMQInputQueueName:  getStringField(config, "queuename", ""),
+		RabbitMQOutputURL:       getStringField(config, "url", ""),
+		RabbitMQOutputQueueName: getStringField(config, "queuename", ""),
+		ConsumerURL:             getStringField(config, "url", ""),
+		ConsumerTopic:           getStringField(config, "topic", ""), // Default is empty if "topic" is missing
+		ProducerURL:             getStringField(config, "url", ""),
+		ProducerTopic:           getStringField(config, "topic", ""),
+		SQLSourceConnString:     getStringField(config, "connstring", ""),
+		SQLTargetConnString:     getStringField(config, "connstring", ""),
+		SourceMongoDBConnString: getStringField(config, "connstring", ""),
+		SourceMongoDBDatabase:   getStringField(config, "database", ""),
+		SourceMongoDBCollection: getStringField(config, "collection", ""),
+		TargetMongoDBConnString: getStringField(config, "connstring", ""),
+		TargetMongoDBDatabase:   getStringField(config, "database", ""),
+		TargetMongoDBCollection: getStringField(config, "collection", ""),
+		OutputFileName:          getStringField(config, "filename", ""),
+		CSVSourceFileName:       getStringField(config, "csvsourcefilename", ""),
+		CSVDestinationFileName:  getStringField(config, "csvdestinationfilename", ""),
+		JSONSourceData:          getStringField(config, "data", ""),
+		JSONOutputFilename:      getStringField(config, "filename", ""),
+		YAMLSourceFilePath:      getStringField(config, "filepath", ""),
+		YAMLDestinationFilePath: getStringField(config, "filepath", ""),
+		DynamoDBSourceTable:     getStringField(config, "tablename", ""),
+		DynamoDBTargetTable:     getStringField(config, "tablename", ""),
+		DynamoDBSourceRegion:    getStringField(config, "region", ""),
+		DynamoDBTargetRegion:    getStringField(config, "region", ""),
+		FTPFILEPATH:             getStringField(config, "filepath", ""),
+		FTPURL:                  getStringField(config, "url", ""),
+		FTPUser:                 getStringField(config, "user", ""),
+		FTPPassword:             getStringField(config, "password", ""),
+		SFTPFILEPATH:            getStringField(config, "filepath", ""),
+		SFTPURL:                 getStringField(config, "url", ""),
+		SFTPUser:                getStringField(config, "user", ""),
+		SFTPPassword:            getStringField(config, "password", ""),
+		WebSocketSourceURL:      getStringField(config, "url", ""),
+		WebSocketDestURL:        getStringField(config, "url", ""),
+		CredentialFileAddr:      getStringField(config, "credentialfileaddr", ""),
+		Collection:              getStringField(config, "collection", ""),
+		Document:                getStringField(config, "document", ""),
+	}
+}
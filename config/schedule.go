@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SkySingh04/fractal/schedule"
+	"github.com/manifoldco/promptui"
+)
+
+// ScheduleFromConfig reads the cronjob section of a configuration loaded with LoadConfig:
+//
+//	cronjob:
+//	  schedule: "0 2 * * *"      # cron expression or @hourly-style shortcut
+//	  repetition_interval: "1h"  # used when schedule is not set
+//	  timezone: "Europe/Berlin"
+//	  overlap: skip              # skip, queue or concurrent
+//	  catch_up: once             # none, once or all
+//	  jitter: "30s"
+//
+// ok is false when the section sets neither schedule nor repetition_interval.
+func ScheduleFromConfig(configuration map[string]interface{}) (spec schedule.Spec, ok bool, err error) {
+	cronjob, _ := configuration["cronjob"].(map[string]interface{})
+	value := func(key string) string {
+		if v, found := cronjob[key]; found && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+
+	spec = schedule.Spec{
+		Expression: value("schedule"),
+		Timezone:   value("timezone"),
+		Overlap:    schedule.Overlap(value("overlap")),
+		CatchUp:    schedule.CatchUp(value("catch_up")),
+	}
+	if spec.Expression == "" {
+		spec.Expression = value("repetition_interval")
+	}
+	if spec.Expression == "" {
+		return spec, false, nil
+	}
+	if jitter := value("jitter"); jitter != "" {
+		if spec.Jitter, err = time.ParseDuration(jitter); err != nil {
+			return spec, true, fmt.Errorf("cronjob.jitter %q is not a duration such as 30s", jitter)
+		}
+	}
+	if err := spec.Validate(); err != nil {
+		return spec, true, fmt.Errorf("invalid cronjob section: %w", err)
+	}
+	return spec, true, nil
+}
+
+// AskForSchedule prompts the user for how often the CLI should run the pipeline
+func AskForSchedule() (schedule.Spec, error) {
+	prompt := promptui.Prompt{
+		Label:   "Schedule (cron expression, @hourly-style shortcut, or interval such as 30s)",
+		Default: "1h",
+		Validate: func(input string) error {
+			return schedule.Spec{Expression: input}.Validate()
+		},
+	}
+	expression, err := prompt.Run()
+	if err != nil {
+		return schedule.Spec{}, fmt.Errorf("failed to read schedule: %w", err)
+	}
+	return schedule.Spec{Expression: expression}, nil
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SkySingh04/fractal/schema"
+	"github.com/SkySingh04/fractal/secrets"
+)
+
+// sections maps each integration config section to the key naming its integration
+var sections = []struct {
+	section, method string
+	kind            schema.Kind
+}{
+	{"inputconfig", "inputMethod", schema.KindSource},
+	{"outputconfig", "outputMethod", schema.KindDestination},
+}
+
+// SecretEnv returns the environment variable that holds a secret field of a section
+// when it is not stored in the config file, such as FRACTAL_INPUT_PASSWORD
+func SecretEnv(section, key string) string {
+	prefix := strings.ToUpper(strings.TrimSuffix(section, "config"))
+	return "FRACTAL_" + prefix + "_" + strings.ToUpper(key)
+}
+
+// withoutSecrets returns a copy of a configuration to be written to disk. Secret
+// fields that hold a plain value, in inputconfig and outputconfig as in the sources
+// and destinations of the pipelines list, are replaced with a reference to their
+// environment variable, and the variables that must be set are returned.
+func withoutSecrets(configuration map[string]interface{}) (map[string]interface{}, []string) {
+	stripped := make(map[string]interface{}, len(configuration))
+	for key, value := range configuration {
+		stripped[key] = value
+	}
+
+	var envs []string
+	for _, s := range sections {
+		fields, ok := configuration[s.section].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		method, _ := configuration[s.method].(string)
+		section := s.section
+		copied, added := stripSecrets(fields, secretFields(method, s.kind), func(key string) string { return SecretEnv(section, key) })
+		stripped[s.section] = copied
+		envs = append(envs, added...)
+	}
+
+	list, ok := configuration["pipelines"].([]interface{})
+	if !ok {
+		return stripped, envs
+	}
+	pipelines := make([]interface{}, len(list))
+	for i, item := range list {
+		pipelines[i] = item
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := entry["name"].(string)
+		copied := make(map[string]interface{}, len(entry))
+		for key, value := range entry {
+			copied[key] = value
+		}
+		if source, ok := entry["source"].(map[string]interface{}); ok {
+			var added []string
+			copied["source"], added = stripEndpoint(source, schema.KindSource, name, "source")
+			envs = append(envs, added...)
+		}
+		if destinations, ok := entry["destinations"].([]interface{}); ok {
+			endpoints := make([]interface{}, len(destinations))
+			for j, destination := range destinations {
+				endpoints[j] = destination
+				if endpoint, ok := destination.(map[string]interface{}); ok {
+					var added []string
+					endpoints[j], added = stripEndpoint(endpoint, schema.KindDestination, name, fmt.Sprintf("destinations_%d", j))
+					envs = append(envs, added...)
+				}
+			}
+			copied["destinations"] = endpoints
+		}
+		pipelines[i] = copied
+	}
+	stripped["pipelines"] = pipelines
+	return stripped, envs
+}
+
+// stripEndpoint strips the secrets of the config of an endpoint of the pipelines
+// list. Their variables are named after the pipeline and the endpoint, such as
+// FRACTAL_USERS_SOURCE_PASSWORD.
+func stripEndpoint(endpoint map[string]interface{}, kind schema.Kind, pipelineName, role string) (map[string]interface{}, []string) {
+	fields, ok := endpoint["config"].(map[string]interface{})
+	if !ok {
+		return endpoint, nil
+	}
+	integration, _ := endpoint["integration"].(string)
+	prefix := envName(pipelineName) + "_" + envName(role)
+	copied, envs := stripSecrets(fields, secretFields(integration, kind), func(key string) string {
+		return "FRACTAL_" + prefix + "_" + envName(key)
+	})
+	stripped := make(map[string]interface{}, len(endpoint))
+	for key, value := range endpoint {
+		stripped[key] = value
+	}
+	stripped["config"] = copied
+	return stripped, envs
+}
+
+// stripSecrets replaces the plain values of the secret fields of a config section
+// with a reference to the environment variable that env names
+func stripSecrets(fields map[string]interface{}, secret map[string]bool, env func(key string) string) (map[string]interface{}, []string) {
+	var envs []string
+	copied := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		copied[key] = value
+		if text, _ := value.(string); secret[strings.ToLower(key)] && text != "" && !secrets.IsReference(text) {
+			name := env(key)
+			copied[key] = fmt.Sprintf("${%s}", name)
+			envs = append(envs, name)
+		}
+	}
+	return copied, envs
+}
+
+// envName turns a name into the part of an environment variable name
+func envName(name string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name))
+}
+
+// secretFields returns the config keys of the secret fields of an integration
+func secretFields(method string, kind schema.Kind) map[string]bool {
+	integration, err := schema.For(method, kind)
+	if err != nil {
+		return nil
+	}
+	keys := map[string]bool{}
+	for _, field := range integration.Fields {
+		if field.Secret {
+			keys[field.ConfigKey] = true
+		}
+	}
+	return keys
+}
@@ -0,0 +1,40 @@
+package config
+
+import "github.com/SkySingh04/fractal/schema"
+
+// Template returns a configuration for the given source and destination with every
+// field of their schemas set to its default, or left empty for the user to fill in.
+// Secret fields reference an environment variable instead. A non-empty schedule is
+// written to the cronjob section.
+func Template(source, destination, schedule string) (map[string]interface{}, error) {
+	input, err := schema.ForSource(source)
+	if err != nil {
+		return nil, err
+	}
+	output, err := schema.ForDestination(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]interface{}{
+		"inputMethod":  source,
+		"outputMethod": destination,
+		"inputconfig":  templateFields("inputconfig", input),
+		"outputconfig": templateFields("outputconfig", output),
+	}
+	if schedule != "" {
+		config["cronjob"] = map[string]interface{}{"schedule": schedule}
+	}
+	return config, nil
+}
+
+func templateFields(section string, integration schema.Integration) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for _, field := range integration.Fields {
+		fields[field.ConfigKey] = field.Default
+		if field.Secret {
+			fields[field.ConfigKey] = "${" + SecretEnv(section, field.ConfigKey) + "}"
+		}
+	}
+	return fields
+}
@@ -0,0 +1,567 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SkySingh04/fractal/language"
+	"github.com/SkySingh04/fractal/notify"
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/schedule"
+	"github.com/SkySingh04/fractal/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// topLevelKeys are the sections a config file may contain. Keys are matched without
+// regard to case, like viper does.
+var topLevelKeys = []string{
+	"inputmethod", "outputmethod", "inputconfig", "outputconfig", "cronjob",
+	"error-handling", "monitoring", "transformations", "validations", "auth", "rbac",
+	"pipelines", "notifications", "lineage",
+}
+
+// legacyKeys describe the single pipeline of a config file without a pipelines list
+var legacyKeys = []string{"inputmethod", "outputmethod", "inputconfig", "outputconfig"}
+
+// pipelineKeys are the keys of an entry of the pipelines list, see LoadPipelines
+var pipelineKeys = []string{
+	"name", "description", "source", "destinations", "validations", "transformations",
+	"schedule", "timezone", "overlap", "catch_up", "jitter", "error_strategy", "verify",
+}
+
+// endpointKeys are the keys of the source and of each destination of a pipeline
+var endpointKeys = []string{"integration", "config"}
+
+// verifyKeys are the keys of the verify section of a pipeline, see verify.Options
+var verifyKeys = []string{"key_fields", "sample"}
+
+// Keys of the notifications section, see notify.Config
+var (
+	notificationKeys = []string{"summary_file", "thresholds", "notifiers"}
+	thresholdKeys    = []string{"max_duration", "max_errors", "max_quarantined", "min_records"}
+	notifierKeys     = []string{"type", "on", "url", "headers", "body", "host", "port", "username", "password", "from", "to", "subject"}
+)
+
+// lineageKeys are the keys of the lineage section, see lineage.Config
+var lineageKeys = []string{"namespace", "url", "endpoint", "api_key", "headers", "file"}
+
+// cronjobKeys are the keys of the cronjob section, see ScheduleFromConfig
+var cronjobKeys = []string{"schedule", "repetition_interval", "timezone", "overlap", "catch_up", "jitter"}
+
+// Problem is a single mistake in a config file
+type Problem struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (p Problem) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+}
+
+// ValidationError lists every problem found in a config file
+type ValidationError struct {
+	File     string
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		messages[i] = problem.Error()
+	}
+	return fmt.Sprintf("%s has %d problem(s):\n  %s", e.File, len(e.Problems), strings.Join(messages, "\n  "))
+}
+
+// Validate checks a config file against the schemas of the integrations it names.
+// It reports unknown keys with the closest known key, integrations that are not
+// registered, required fields that are missing and invalid cronjob settings, each
+// with its line in the file. Problems are returned as a *ValidationError.
+func Validate(configFile string) error {
+	return validate(configFile, false)
+}
+
+// ValidateSources checks a config file like Validate, except that destinations are
+// optional: the file only has to describe the sources of its pipelines.
+func ValidateSources(configFile string) error {
+	return validate(configFile, true)
+}
+
+func validate(configFile string, sourcesOnly bool) error {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("%s: %w", configFile, err)
+	}
+
+	v := &validator{file: configFile, sourcesOnly: sourcesOnly}
+	v.document(&document)
+	if len(v.problems) == 0 {
+		return nil
+	}
+	sort.SliceStable(v.problems, func(i, j int) bool { return v.problems[i].Line < v.problems[j].Line })
+	return &ValidationError{File: configFile, Problems: v.problems}
+}
+
+type validator struct {
+	file        string
+	sourcesOnly bool // Destinations are optional
+	problems    []Problem
+}
+
+func (v *validator) report(node *yaml.Node, format string, args ...any) {
+	line, column := 1, 1
+	if node != nil {
+		line, column = node.Line, node.Column
+	}
+	v.problems = append(v.problems, Problem{File: v.file, Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) document(document *yaml.Node) {
+	if len(document.Content) == 0 {
+		v.report(nil, "the file is empty")
+		return
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		v.report(root, "expected a mapping of sections such as inputmethod and inputconfig")
+		return
+	}
+
+	sections := v.mapping(root, "", topLevelKeys)
+	pipelines, hasPipelines := sections["pipelines"]
+	legacy := false
+	for _, key := range legacyKeys {
+		_, found := sections[key]
+		legacy = legacy || found
+	}
+	if !legacy && !hasPipelines {
+		v.report(root, "no pipeline is defined: add a pipelines list, or inputmethod and outputmethod")
+		return
+	}
+
+	if legacy {
+		v.integration(root, sections, "inputmethod", "inputconfig", schema.KindSource)
+		if _, ok := sections["outputmethod"]; ok || !v.sourcesOnly {
+			v.integration(root, sections, "outputmethod", "outputconfig", schema.KindDestination)
+		}
+	}
+	if cronjob, ok := sections["cronjob"]; ok {
+		v.cronjob(cronjob.value)
+	}
+	if notifications, ok := sections["notifications"]; ok {
+		v.notifications(notifications.value)
+	}
+	if lineage, ok := sections["lineage"]; ok {
+		v.lineage(lineage.value)
+	}
+	if hasPipelines {
+		v.pipelines(pipelines.value, legacy)
+	}
+}
+
+// entry is a key of a mapping node together with its value
+type entry struct {
+	key, value *yaml.Node
+}
+
+// mapping reports unknown and duplicate keys of a mapping node and returns its entries
+// by lowercased key. prefix is the path of the mapping used in messages.
+func (v *validator) mapping(node *yaml.Node, prefix string, known []string) map[string]entry {
+	entries := map[string]entry{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		name := strings.ToLower(key.Value)
+		if !contains(known, name) {
+			v.report(key, "unknown key %q%s", prefix+key.Value, didYouMean(key.Value, known))
+			continue
+		}
+		if previous, ok := entries[name]; ok {
+			v.report(key, "%q is already set on line %d", prefix+key.Value, previous.key.Line)
+			continue
+		}
+		entries[name] = entry{key, value}
+	}
+	return entries
+}
+
+// integration checks the method key naming an integration and the section holding its fields
+func (v *validator) integration(root *yaml.Node, sections map[string]entry, methodKey, sectionKey string, kind schema.Kind) {
+	methodEntry, ok := sections[methodKey]
+	if !ok {
+		v.report(root, "%s is required", methodKey)
+		return
+	}
+	sectionEntry, hasSection := sections[sectionKey]
+	// The interactive setup also stores the method inside the section
+	v.endpoint(methodEntry.value, sectionEntry, hasSection, methodKey, sectionKey, kind, methodKey)
+}
+
+// endpoint checks the node naming an integration and the section holding its fields.
+// methodKey and sectionKey are the paths of both used in messages, and extra lists
+// keys the section may hold besides the fields of the integration.
+func (v *validator) endpoint(method *yaml.Node, sectionEntry entry, hasSection bool, methodKey, sectionKey string, kind schema.Kind, extra ...string) {
+	if method.Kind != yaml.ScalarNode || method.Value == "" {
+		v.report(method, "%s must name a %s integration", methodKey, kind)
+		return
+	}
+
+	integration, err := schema.For(method.Value, kind)
+	if err != nil {
+		v.report(method, "unknown %s %q%s", kind, method.Value, didYouMean(method.Value, integrationNames(kind)))
+		return
+	}
+
+	section := sectionEntry.value
+	if !hasSection || section.Kind == yaml.ScalarNode && section.Tag == "!!null" {
+		v.report(method, "%s is required for %s", sectionKey, method.Value)
+		return
+	}
+	if section.Kind != yaml.MappingNode {
+		v.report(section, "%s must be a mapping of %s fields", sectionKey, method.Value)
+		return
+	}
+
+	known := append([]string(nil), extra...)
+	for _, field := range integration.Fields {
+		known = append(known, field.ConfigKey)
+	}
+	entries := v.mapping(section, sectionKey+".", known)
+	for key, e := range entries {
+		if e.value.Kind != yaml.ScalarNode {
+			v.report(e.value, "%s.%s must be a single value", sectionKey, key)
+		}
+	}
+
+	for _, field := range integration.Fields {
+		if e, ok := entries[field.ConfigKey]; ok && e.value.Kind == yaml.ScalarNode && strings.TrimSpace(e.value.Value) != "" {
+			if err := schema.Check(field, e.value.Value); err != nil {
+				v.report(e.value, "%s.%s %v", sectionKey, field.ConfigKey, err)
+			}
+		}
+		if !field.Required || field.Default != "" {
+			continue
+		}
+		if e, ok := entries[field.ConfigKey]; !ok || e.value.Kind == yaml.ScalarNode && strings.TrimSpace(e.value.Value) == "" {
+			at := sectionEntry.key
+			if ok {
+				at = e.value
+			}
+			message := fmt.Sprintf("%s.%s is required by %s", sectionKey, field.ConfigKey, method.Value)
+			if field.Description != "" {
+				message += " (" + field.Description + ")"
+			}
+			v.report(at, "%s", message)
+		}
+	}
+}
+
+func (v *validator) cronjob(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		v.report(node, "cronjob must be a mapping with schedule or repetition_interval")
+		return
+	}
+	entries := v.mapping(node, "cronjob.", cronjobKeys)
+	values := map[string]*yaml.Node{}
+	for key, e := range entries {
+		values[key] = e.value
+	}
+	v.schedule(values)
+}
+
+// rules reports the validation or transformation rules of node that do not compile
+func (v *validator) rules(node *yaml.Node, path string, validations bool) {
+	for i, rule := range node.Content {
+		if rule.Kind != yaml.ScalarNode {
+			v.report(rule, "%s[%d] must be a rule", path, i)
+			continue
+		}
+		var err error
+		if validations {
+			_, err = language.Compile([]string{rule.Value}, nil)
+		} else {
+			_, err = language.Compile(nil, []string{rule.Value})
+		}
+		var invalid *language.RuleError
+		if errors.As(err, &invalid) {
+			v.report(rule, "%s[%d] %v", path, i, invalid.Err)
+		}
+	}
+}
+
+// pipelines checks every entry of the pipelines list. Names must be unique, and may
+// not be "default" when the file also describes a pipeline with inputmethod.
+func (v *validator) pipelines(node *yaml.Node, legacy bool) {
+	if node.Kind != yaml.SequenceNode {
+		v.report(node, "pipelines must be a list of pipelines")
+		return
+	}
+	names := map[string]int{}
+	if legacy {
+		names[DefaultPipeline] = 0
+	}
+	for i, item := range node.Content {
+		prefix := fmt.Sprintf("pipelines[%d].", i)
+		if item.Kind != yaml.MappingNode {
+			v.report(item, "%s must be a mapping with name, source and destinations", strings.TrimSuffix(prefix, "."))
+			continue
+		}
+		entries := v.mapping(item, prefix, pipelineKeys)
+
+		if name, ok := entries["name"]; !ok || strings.TrimSpace(name.value.Value) == "" {
+			v.report(item, "%sname is required", prefix)
+		} else if err := pipeline.ValidateName(name.value.Value); err != nil {
+			v.report(name.value, "%sname %q %v", prefix, name.value.Value, err)
+		} else if line, taken := names[name.value.Value]; taken && line == 0 {
+			v.report(name.value, "pipeline name %q is taken by the pipeline of inputmethod", name.value.Value)
+		} else if taken {
+			v.report(name.value, "pipeline %q is already defined on line %d", name.value.Value, line)
+		} else {
+			names[name.value.Value] = name.value.Line
+		}
+
+		if source, ok := entries["source"]; !ok {
+			v.report(item, "%ssource is required", prefix)
+		} else {
+			v.pipelineEndpoint(source, prefix+"source", schema.KindSource)
+		}
+		if destinations, ok := entries["destinations"]; !ok {
+			if !v.sourcesOnly {
+				v.report(item, "%sdestinations is required", prefix)
+			}
+		} else if destinations.value.Kind != yaml.SequenceNode || len(destinations.value.Content) == 0 {
+			v.report(destinations.value, "%sdestinations must be a list of at least one destination", prefix)
+		} else {
+			for j, destination := range destinations.value.Content {
+				v.pipelineEndpoint(entry{destinations.key, destination}, fmt.Sprintf("%sdestinations[%d]", prefix, j), schema.KindDestination)
+			}
+		}
+
+		for _, key := range []string{"validations", "transformations"} {
+			if rules, ok := entries[key]; ok && rules.value.Kind != yaml.SequenceNode {
+				v.report(rules.value, "%s%s must be a list of rules", prefix, key)
+			} else if ok {
+				v.rules(rules.value, prefix+key, key == "validations")
+			}
+		}
+		if strategy, ok := entries["error_strategy"]; ok && !contains(runner.ErrorStrategies, strategy.value.Value) {
+			v.report(strategy.value, "unknown error_strategy %q%s", strategy.value.Value, didYouMean(strategy.value.Value, runner.ErrorStrategies))
+		}
+
+		if section, ok := entries["verify"]; ok {
+			v.verify(section.value, prefix+"verify")
+		}
+
+		values := map[string]*yaml.Node{}
+		for key, e := range entries {
+			values[key] = e.value
+		}
+		v.schedule(values)
+	}
+}
+
+// notifications checks the notifications section
+func (v *validator) notifications(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		v.report(node, "notifications must be a mapping with summary_file, thresholds and notifiers")
+		return
+	}
+	entries := v.mapping(node, "notifications.", notificationKeys)
+	if thresholds, ok := entries["thresholds"]; ok {
+		if thresholds.value.Kind != yaml.MappingNode {
+			v.report(thresholds.value, "notifications.thresholds must be a mapping of limits")
+		} else {
+			for key, e := range v.mapping(thresholds.value, "notifications.thresholds.", thresholdKeys) {
+				if key == "max_duration" {
+					if duration, err := time.ParseDuration(e.value.Value); err != nil || duration <= 0 {
+						v.report(e.value, "notifications.thresholds.max_duration %q is not a duration such as 10m", e.value.Value)
+					}
+				} else if n, err := strconv.Atoi(e.value.Value); err != nil || n < 0 {
+					v.report(e.value, "notifications.thresholds.%s must be a number", key)
+				}
+			}
+		}
+	}
+	notifiers, ok := entries["notifiers"]
+	if !ok {
+		return
+	}
+	if notifiers.value.Kind != yaml.SequenceNode {
+		v.report(notifiers.value, "notifications.notifiers must be a list of notifiers")
+		return
+	}
+	for i, item := range notifiers.value.Content {
+		path := fmt.Sprintf("notifications.notifiers[%d]", i)
+		if item.Kind != yaml.MappingNode {
+			v.report(item, "%s must be a mapping with a type", path)
+			continue
+		}
+		entries := v.mapping(item, path+".", notifierKeys)
+		if typ, ok := entries["type"]; !ok {
+			v.report(item, "%s.type is required, one of %s", path, strings.Join(notify.Types(), ", "))
+		} else if !contains(notify.Types(), typ.value.Value) {
+			v.report(typ.value, "unknown notifier type %q%s", typ.value.Value, didYouMean(typ.value.Value, notify.Types()))
+		}
+		if on, ok := entries["on"]; ok {
+			events := []*yaml.Node{on.value}
+			if on.value.Kind == yaml.SequenceNode {
+				events = on.value.Content
+			}
+			for _, event := range events {
+				if !contains(notify.Events, event.Value) {
+					v.report(event, "unknown event %q%s, use %s", event.Value, didYouMean(event.Value, notify.Events), strings.Join(notify.Events, ", "))
+				}
+			}
+		}
+	}
+}
+
+// lineage checks the lineage section
+func (v *validator) lineage(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		v.report(node, "lineage must be a mapping with a url or a file")
+		return
+	}
+	entries := v.mapping(node, "lineage.", lineageKeys)
+	if u, ok := entries["url"]; ok && !strings.Contains(u.value.Value, "${") && !strings.HasPrefix(u.value.Value, "secret://") {
+		if parsed, err := url.Parse(u.value.Value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			v.report(u.value, "lineage.url %q is not an http or https URL", u.value.Value)
+		}
+	}
+	if headers, ok := entries["headers"]; ok && headers.value.Kind != yaml.MappingNode {
+		v.report(headers.value, "lineage.headers must be a mapping of header names to values")
+	}
+}
+
+// verify checks the verify section of an entry of the pipelines list
+func (v *validator) verify(node *yaml.Node, path string) {
+	if node.Kind != yaml.MappingNode {
+		v.report(node, "%s must be a mapping with key_fields and sample", path)
+		return
+	}
+	entries := v.mapping(node, path+".", verifyKeys)
+	if keys, ok := entries["key_fields"]; ok && keys.value.Kind != yaml.SequenceNode {
+		v.report(keys.value, "%s.key_fields must be a list of field names", path)
+	}
+	if sample, ok := entries["sample"]; ok {
+		if n, err := strconv.Atoi(sample.value.Value); err != nil || n < 0 {
+			v.report(sample.value, "%s.sample must be a number of records", path)
+		}
+	}
+}
+
+// pipelineEndpoint checks the source or a destination of an entry of the pipelines list
+func (v *validator) pipelineEndpoint(e entry, path string, kind schema.Kind) {
+	if e.value.Kind != yaml.MappingNode {
+		v.report(e.value, "%s must be a mapping with integration and config", path)
+		return
+	}
+	entries := v.mapping(e.value, path+".", endpointKeys)
+	integration, ok := entries["integration"]
+	if !ok {
+		v.report(e.value, "%s.integration is required", path)
+		return
+	}
+	section, hasSection := entries["config"]
+	v.endpoint(integration.value, section, hasSection, path+".integration", path+".config", kind)
+}
+
+// schedule checks the schedule settings of a cronjob section or of a pipeline
+func (v *validator) schedule(values map[string]*yaml.Node) {
+	expression, ok := values["schedule"]
+	if !ok {
+		expression, ok = values["repetition_interval"]
+	}
+	if ok {
+		if _, err := (schedule.Spec{Expression: expression.Value}).Parse(); err != nil {
+			v.report(expression, "%v", err)
+		}
+	}
+	if timezone, ok := values["timezone"]; ok {
+		if _, err := time.LoadLocation(timezone.Value); err != nil {
+			v.report(timezone, "unknown time zone %q", timezone.Value)
+		}
+	}
+	if overlap, ok := values["overlap"]; ok {
+		if err := (schedule.Spec{Expression: "1h", Overlap: schedule.Overlap(overlap.Value)}).Validate(); err != nil {
+			v.report(overlap, "%v", err)
+		}
+	}
+	if catchUp, ok := values["catch_up"]; ok {
+		if err := (schedule.Spec{Expression: "1h", CatchUp: schedule.CatchUp(catchUp.Value)}).Validate(); err != nil {
+			v.report(catchUp, "%v", err)
+		}
+	}
+	if jitter, ok := values["jitter"]; ok {
+		if duration, err := time.ParseDuration(jitter.Value); err != nil || duration < 0 {
+			v.report(jitter, "jitter %q is not a duration such as 30s", jitter.Value)
+		}
+	}
+}
+
+func integrationNames(kind schema.Kind) []string {
+	var names []string
+	if kind == schema.KindSource {
+		names = getRegisteredDataSources()
+	} else {
+		names = getRegisteredDataDestinations()
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// didYouMean returns a hint naming the candidate closest to word, or "" if none is close
+func didYouMean(word string, candidates []string) string {
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		distance := levenshtein(strings.ToLower(word), strings.ToLower(candidate))
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if bestDistance < 0 || bestDistance > 2 && bestDistance > len(word)/3 {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
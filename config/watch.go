@@ -0,0 +1,81 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/fsnotify/fsnotify"
+)
+
+// settle is how long Watch waits for writes to a config file to stop before reloading it
+const settle = 200 * time.Millisecond
+
+// Watch calls reload with the pipelines of configFile every time the file is saved or
+// the process receives SIGHUP, until ctx is done. Writes in quick succession cause a
+// single reload. A file that no longer loads is passed to rejected instead, so the
+// caller can keep running the pipelines it has.
+func Watch(ctx context.Context, configFile string, reload func([]*pipeline.Definition), rejected func(error)) error {
+	path, err := filepath.Abs(configFile)
+	if err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Editors often save by writing a new file and renaming it over the old one, which
+	// a watch on the file itself would not survive
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		defer watcher.Close()
+		defer signal.Stop(hangup)
+
+		load := func() {
+			definitions, err := LoadPipelines(configFile)
+			if err != nil {
+				rejected(err)
+				return
+			}
+			reload(definitions)
+		}
+
+		timer := time.NewTimer(settle)
+		timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == path && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					timer.Reset(settle)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				rejected(err)
+			case <-hangup:
+				load()
+			case <-timer.C:
+				load()
+			}
+		}
+	}()
+	return nil
+}
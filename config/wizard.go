@@ -0,0 +1,147 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/SkySingh04/fractal/schema"
+	"github.com/manifoldco/promptui"
+)
+
+// readIntegrationFields prompts for every field declared in the selected integration's
+// config schema and returns them keyed by their config.yaml name. Values in current
+// are offered as defaults, so a section can be corrected without typing it again.
+func readIntegrationFields(method string, kind schema.Kind, current map[string]interface{}) (map[string]interface{}, error) {
+	integration, err := schema.For(method, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	config := make(map[string]interface{})
+	for _, field := range integration.Fields {
+		previous, _ := current[field.ConfigKey].(string)
+		value, err := askField(field, previous)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get value for field %s: %w", field.ConfigKey, err)
+		}
+		// Leave out optional fields that were skipped, so the file only holds settings
+		if value != "" {
+			config[field.ConfigKey] = value
+		}
+	}
+
+	return config, nil
+}
+
+// askField prompts for a single field. Fields with a list of values are picked from
+// it, secrets are typed without being echoed and every value is checked against the
+// schema before it is accepted.
+func askField(field schema.Field, current string) (string, error) {
+	label := fmt.Sprintf("%s (%s)", field.ConfigKey, field.Type)
+	if field.Description != "" {
+		label = fmt.Sprintf("%s - %s", label, field.Description)
+	}
+
+	if len(field.Enum) > 0 {
+		selected := current
+		if selected == "" {
+			selected = field.Default
+		}
+		prompt := promptui.Select{
+			Label:     label,
+			Items:     field.Enum,
+			CursorPos: max(slices.Index(field.Enum, selected), 0),
+		}
+		_, value, err := prompt.Run()
+		return value, err
+	}
+
+	prompt := promptui.Prompt{
+		Label:   label,
+		Default: current,
+		Validate: func(input string) error {
+			return schema.Check(field, input)
+		},
+	}
+	if current == "" {
+		prompt.Default = field.Default
+	}
+	if field.Secret {
+		// A secret is never shown, not even as the default
+		prompt.Mask = '*'
+		prompt.Default = ""
+		prompt.Label = label + ", or a ${ENV} or secret:// reference"
+		if current != "" {
+			prompt.Label = prompt.Label.(string) + " (empty keeps the value entered before)"
+			prompt.Validate = func(input string) error {
+				if input == "" {
+					return nil
+				}
+				return schema.Check(field, input)
+			}
+		}
+	}
+
+	value, err := prompt.Run()
+	if err != nil {
+		return "", err
+	}
+	value = strings.TrimSpace(value)
+	if value == "" && field.Secret {
+		value = current
+	}
+	return value, nil
+}
+
+// testConnections offers to check the source and destination settings before they are
+// saved. When a check fails the settings can be entered again, kept as they are, or
+// the setup cancelled.
+func testConnections(configuration map[string]interface{}) error {
+	confirm := promptui.Prompt{Label: "Test the connections before saving", IsConfirm: true, Default: "y"}
+	if _, err := confirm.Run(); errors.Is(err, promptui.ErrAbort) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, s := range sections {
+		method, _ := configuration[s.method].(string)
+		for {
+			fields, _ := configuration[s.section].(map[string]interface{})
+			endpoint := pipeline.Endpoint{Integration: method, Config: ToRequest(fields)}
+			err := endpoint.TestConnection(context.Background(), s.kind, true)
+			if errors.Is(err, pipeline.ErrNotTestable) {
+				fmt.Printf("- %s %s cannot be tested without moving data, skipped\n", s.kind, method)
+				break
+			}
+			if err == nil {
+				fmt.Printf("\033[32m✔\033[0m %s %s is reachable\n", s.kind, method)
+				break
+			}
+
+			fmt.Printf("\033[31m✘\033[0m %s %s: %v\n", s.kind, method, err)
+			choice := promptui.Select{
+				Label: "What next",
+				Items: []string{"Change the " + method + " settings", "Save anyway", "Cancel the setup"},
+			}
+			index, _, err := choice.Run()
+			if err != nil {
+				return err
+			}
+			if index == 1 {
+				break
+			}
+			if index == 2 {
+				return errors.New("setup cancelled")
+			}
+			if configuration[s.section], err = readIntegrationFields(method, s.kind, fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
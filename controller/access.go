@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/SkySingh04/fractal/audit"
+	"github.com/SkySingh04/fractal/auth"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/SkySingh04/fractal/rbac"
+	"github.com/SkySingh04/fractal/runner"
+	gofrHTTP "gofr.dev/pkg/gofr/http"
+)
+
+// caller names the caller of a request in run records and the audit log: the
+// authenticated principal, or anonymous when authentication is disabled
+func caller(ctx context.Context) string {
+	principal := auth.FromContext(ctx)
+	if principal == nil {
+		return "anonymous"
+	}
+	return principal.Method + ":" + principal.Name
+}
+
+// authorizeRun checks that the caller may run pipeline with the integrations and
+// endpoints of req. It runs before the source and destination are created, so a
+// denied request never opens a connection.
+func authorizeRun(ctx context.Context, policy *rbac.Policy, pipelineName string, req interfaces.Request) error {
+	return authorize(ctx, policy, &pipeline.Definition{
+		Name:         pipelineName,
+		Source:       pipeline.Endpoint{Integration: req.Input, Config: req},
+		Destinations: []pipeline.Endpoint{{Integration: req.Output, Config: req}},
+	}, auth.OperationRun)
+}
+
+// authorizeSource checks that the caller may read from the input integration of req
+// without writing anywhere
+func authorizeSource(ctx context.Context, policy *rbac.Policy, pipelineName string, req interfaces.Request) error {
+	return authorize(ctx, policy, &pipeline.Definition{
+		Name:   pipelineName,
+		Source: pipeline.Endpoint{Integration: req.Input, Config: req},
+	}, auth.OperationRun)
+}
+
+// authorize checks that the caller may perform every op on a pipeline, and that its
+// roles allow the pipeline's source, every destination and every endpoint they connect to
+func authorize(ctx context.Context, policy *rbac.Policy, definition *pipeline.Definition, ops ...auth.Operation) error {
+	principal := auth.FromContext(ctx)
+	integrations := []string{definition.Source.Integration}
+	for _, destination := range definition.Destinations {
+		integrations = append(integrations, destination.Integration)
+	}
+	for _, op := range ops {
+		if err := principal.Authorize(op, integrations...); err != nil {
+			return err
+		}
+	}
+	if principal == nil {
+		return nil // Authentication is disabled, so there is nobody to check roles for
+	}
+
+	destinations := definition.Destinations
+	if len(destinations) == 0 {
+		// Only the source is read
+		destinations = []pipeline.Endpoint{{}}
+	}
+	for _, destination := range destinations {
+		action := rbac.ActionFor(definition.Name, definition.Source.Integration, destination.Integration, definition.Source.Config, destination.Config)
+		if err := policy.Check(caller(ctx), principal.Roles(), action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readable reports whether the caller may read a pipeline: its scopes allow reading
+// every integration of the pipeline and one of its roles allows every endpoint.
+// Unlike authorize it records no denial, as it only filters listings.
+func readable(ctx context.Context, policy *rbac.Policy, definition *pipeline.Definition) bool {
+	principal := auth.FromContext(ctx)
+	if principal == nil {
+		return true
+	}
+	integrations := []string{definition.Source.Integration}
+	for _, destination := range definition.Destinations {
+		integrations = append(integrations, destination.Integration)
+	}
+	if principal.Authorize(auth.OperationRead, integrations...) != nil {
+		return false
+	}
+	for _, destination := range definition.Destinations {
+		action := rbac.ActionFor(definition.Name, definition.Source.Integration, destination.Integration, definition.Source.Config, destination.Config)
+		if !policy.Allows(principal.Roles(), action) {
+			return false
+		}
+	}
+	return true
+}
+
+// runActions describes a run to the policy, one action per destination. Run records
+// name their integrations but keep no endpoints, so hosts and databases are not checked.
+func runActions(run runner.Run) (integrations []string, actions []rbac.Action) {
+	integrations = append([]string{run.Source}, run.Destinations()...)
+	destinations := run.Destinations()
+	if len(destinations) == 0 {
+		destinations = []string{""}
+	}
+	for _, destination := range destinations {
+		actions = append(actions, rbac.Action{Pipeline: run.Pipeline, Source: run.Source, Destination: destination})
+	}
+	return integrations, actions
+}
+
+// entryActions describes an audit entry to the policy, together with the hosts and
+// databases it records
+func entryActions(entry audit.Entry) (integrations []string, actions []rbac.Action) {
+	integrations = []string{entry.Source.Integration}
+	destinations := entry.Destinations
+	if len(destinations) == 0 {
+		destinations = []audit.Endpoint{{}}
+	}
+	for _, destination := range destinations {
+		if destination.Integration != "" {
+			integrations = append(integrations, destination.Integration)
+		}
+		action := rbac.Action{Pipeline: entry.Pipeline, Source: entry.Source.Integration, Destination: destination.Integration}
+		action.Hosts = append(append(append(action.Hosts, entry.Hosts...), entry.Source.Hosts...), destination.Hosts...)
+		action.Databases = append(append(append(action.Databases, entry.Databases...), entry.Source.Databases...), destination.Databases...)
+		actions = append(actions, action)
+	}
+	return integrations, actions
+}
+
+// authorizeRecord checks that the caller may perform op on a run or audit entry
+// described by integrations and actions, and records the denial if it may not
+func authorizeRecord(ctx context.Context, policy *rbac.Policy, op auth.Operation, integrations []string, actions []rbac.Action) error {
+	principal := auth.FromContext(ctx)
+	if err := principal.Authorize(op, integrations...); err != nil {
+		return err
+	}
+	if principal == nil {
+		return nil
+	}
+	for _, action := range actions {
+		if err := policy.Check(caller(ctx), principal.Roles(), action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visibleRecord reports whether the caller may read a run or audit entry, like
+// readable does for pipelines
+func visibleRecord(ctx context.Context, policy *rbac.Policy, integrations []string, actions []rbac.Action) bool {
+	principal := auth.FromContext(ctx)
+	if principal == nil {
+		return true
+	}
+	if principal.Authorize(auth.OperationRead, integrations...) != nil {
+		return false
+	}
+	for _, action := range actions {
+		if !policy.Allows(principal.Roles(), action) {
+			return false
+		}
+	}
+	return true
+}
+
+// jobRun returns the run of a job, from the runs in progress or else the store
+func jobRun(deps Dependencies, id string) (runner.Run, bool) {
+	if run, ok := runner.Running(id); ok {
+		return run, true
+	}
+	if deps.Runs == nil {
+		return runner.Run{}, false
+	}
+	run, err := deps.Runs.GetRun(id)
+	if err != nil {
+		return runner.Run{}, false
+	}
+	return *run, true
+}
+
+// authorizeJob checks that the caller may perform op on the job called id. A job
+// whose run cannot be found is reported as not found rather than shown to anyone.
+func authorizeJob(ctx context.Context, deps Dependencies, op auth.Operation, id string) error {
+	if auth.FromContext(ctx) == nil {
+		return nil
+	}
+	run, ok := jobRun(deps, id)
+	if !ok {
+		return gofrHTTP.ErrorEntityNotFound{Name: "id", Value: id}
+	}
+	integrations, actions := runActions(run)
+	return authorizeRecord(ctx, deps.Policy, op, integrations, actions)
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/SkySingh04/fractal/audit"
+	"github.com/SkySingh04/fractal/rbac"
+	"gofr.dev/pkg/gofr"
+	gofrHTTP "gofr.dev/pkg/gofr/http"
+)
+
+// defaultAuditLimit is the number of audit entries returned when no limit is asked for
+const defaultAuditLimit = 100
+
+// ListAuditHandler returns the entries of the audit log that the caller may read,
+// newest first. It accepts the optional query parameters pipeline, caller, run_id,
+// since and limit.
+func ListAuditHandler(log *audit.Log, policy *rbac.Policy) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		filter := audit.Filter{
+			Pipeline: ctx.Param("pipeline"),
+			Caller:   ctx.Param("caller"),
+			RunID:    ctx.Param("run_id"),
+			Limit:    defaultAuditLimit,
+			Visible: func(entry audit.Entry) bool {
+				integrations, actions := entryActions(entry)
+				return visibleRecord(ctx, policy, integrations, actions)
+			},
+		}
+		if since := ctx.Param("since"); since != "" {
+			t, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				return nil, gofrHTTP.ErrorInvalidParam{Params: []string{"since"}}
+			}
+			filter.Since = t
+		}
+		if limit := ctx.Param("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n < 0 {
+				return nil, gofrHTTP.ErrorInvalidParam{Params: []string{"limit"}}
+			}
+			filter.Limit = n
+		}
+		return log.List(filter)
+	}
+}
+
+// VerifyAuditHandler checks the hash chain of the audit log
+func VerifyAuditHandler(log *audit.Log) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		return log.Verify()
+	}
+}
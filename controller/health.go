@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/SkySingh04/fractal/health"
+	"github.com/SkySingh04/fractal/pipeline"
+	"gofr.dev/pkg/gofr"
+	gofrHTTP "gofr.dev/pkg/gofr/http"
+)
+
+// readyPath and readyDetailsPath are served by HealthMiddleware
+const (
+	readyPath        = "/health/ready"
+	readyDetailsPath = "/health/ready/details"
+)
+
+// maxProbeTimeout caps the timeout a readiness request may ask for
+const maxProbeTimeout = time.Minute
+
+// Liveness is the answer of the liveness probe
+type Liveness struct {
+	Status health.Status `json:"status"`
+}
+
+// LiveHandler reports that the process is up and answering requests. It does not
+// connect to any integration, so a database that is down does not get it restarted.
+func LiveHandler(ctx *gofr.Context) (interface{}, error) {
+	return Liveness{Status: health.StatusOK}, nil
+}
+
+// Readiness is the answer of the readiness probe, which anyone may call: whether
+// every check passed, without the checks themselves
+type Readiness struct {
+	Status    health.Status `json:"status"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+// ReadyHandler probes the source and destinations of every pipeline that is not
+// paused and reports whether all of them answered. Served through gofr it always
+// answers 200; HealthMiddleware answers 503 when a check fails.
+func ReadyHandler(deps Dependencies) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		return ready(ctx.Context, deps)
+	}
+}
+
+// ReadyDetailsHandler probes like ReadyHandler and returns every check, of the
+// pipelines the caller may read. It accepts the optional query parameters pipeline
+// and timeout.
+func ReadyDetailsHandler(deps Dependencies) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		return readiness(ctx.Context, deps, ctx.Param("pipeline"), ctx.Param("timeout"))
+	}
+}
+
+// HealthMiddleware serves /health/ready and /health/ready/details, because gofr
+// answers 206 rather than 503 when a handler returns a report together with an error
+func HealthMiddleware(deps Dependencies) gofrHTTP.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || (r.URL.Path != readyPath && r.URL.Path != readyDetailsPath) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var data interface{}
+			var status health.Status
+			var err error
+			if r.URL.Path == readyPath {
+				var summary Readiness
+				summary, err = ready(r.Context(), deps)
+				data, status = summary, summary.Status
+			} else {
+				query := r.URL.Query()
+				var report health.Report
+				report, err = readiness(r.Context(), deps, query.Get("pipeline"), query.Get("timeout"))
+				data, status = report, report.Status
+			}
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if status != health.StatusOK {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+		})
+	}
+}
+
+// ready probes every pipeline of the scheduler that is not paused, with the default
+// timeout, and keeps only the outcome
+func ready(ctx context.Context, deps Dependencies) (Readiness, error) {
+	definitions, err := deps.Scheduler.Pipelines()
+	if err != nil {
+		return Readiness{}, err
+	}
+	var probed []*pipeline.Definition
+	for _, definition := range definitions {
+		if !definition.Paused {
+			probed = append(probed, definition)
+		}
+	}
+	report := deps.Health.Probe(ctx, probed, health.DefaultTimeout, deps.Scheduler.Static)
+	return Readiness{Status: report.Status, CheckedAt: report.CheckedAt}, nil
+}
+
+// readiness probes the pipelines of the scheduler that the caller may read, or only
+// the one called name
+func readiness(ctx context.Context, deps Dependencies, name, timeout string) (health.Report, error) {
+	limit := health.DefaultTimeout
+	if timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 || d > maxProbeTimeout {
+			return health.Report{}, gofrHTTP.ErrorInvalidParam{Params: []string{"timeout"}}
+		}
+		limit = d
+	}
+
+	definitions, err := deps.Scheduler.Pipelines()
+	if err != nil {
+		return health.Report{}, err
+	}
+	var probed []*pipeline.Definition
+	for _, definition := range definitions {
+		if !readable(ctx, deps.Policy, definition) {
+			continue
+		}
+		if name != "" && definition.Name == name {
+			probed = []*pipeline.Definition{definition}
+			break
+		}
+		if name == "" && !definition.Paused {
+			probed = append(probed, definition)
+		}
+	}
+	if name != "" && len(probed) == 0 {
+		return health.Report{}, gofrHTTP.ErrorEntityNotFound{Name: "pipeline", Value: name}
+	}
+	return deps.Health.Probe(ctx, probed, limit, deps.Scheduler.Static), nil
+}
+
+// writeError answers a request served by a middleware with err the way gofr answers
+// handlers that return it: a JSON error message and the status code of err, if it has one
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var coded interface{ StatusCode() int }
+	if errors.As(err, &coded) {
+		status = coded.StatusCode()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"message": err.Error()}})
+}
@@ -0,0 +1,12 @@
+package controller
+
+import (
+	"github.com/SkySingh04/fractal/schema"
+	"gofr.dev/pkg/gofr"
+)
+
+// IntegrationsHandler lists every registered source and destination together with
+// the request fields each of them needs
+func IntegrationsHandler(ctx *gofr.Context) (interface{}, error) {
+	return schema.All()
+}
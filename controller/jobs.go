@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/SkySingh04/fractal/auth"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/progress"
+	"github.com/SkySingh04/fractal/rbac"
+	"github.com/SkySingh04/fractal/runner"
+	"gofr.dev/pkg/gofr"
+	gofrHTTP "gofr.dev/pkg/gofr/http"
+)
+
+// MethodWebSocket marks routes that are registered with app.WebSocket
+const MethodWebSocket = "WEBSOCKET"
+
+const heartbeatInterval = 15 * time.Second
+
+var eventsPath = regexp.MustCompile(`^/jobs/([^/]+)/events$`)
+
+// JobResponse is returned when a migration is started in the background
+type JobResponse struct {
+	JobID  string         `json:"job_id"`
+	Status progress.Phase `json:"status"`
+}
+
+// StartJobHandler starts a migration in the background and returns its id immediately.
+// Progress can then be followed on /jobs/{id}, /jobs/{id}/events and /jobs/{id}/ws.
+func StartJobHandler(policy *rbac.Policy) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		var req interfaces.Request
+		if err := ctx.Bind(&req); err != nil {
+			return nil, fmt.Errorf("failed to bind request: %v", err)
+		}
+		if err := authorizeRun(ctx, policy, apiPipeline, req); err != nil {
+			return nil, err
+		}
+
+		id := runner.Start(runner.Spec{
+			Pipeline:           apiPipeline,
+			Source:             req.Input,
+			Destination:        req.Output,
+			SourceRequest:      req,
+			DestinationRequest: req,
+			Trigger:            "http",
+			Caller:             caller(ctx),
+		}, logFailure)
+
+		return JobResponse{JobID: id, Status: progress.PhaseStarting}, nil
+	}
+}
+
+// CancelJobHandler cancels a running job and returns its latest progress. The caller
+// must be allowed to cancel runs with every integration of the job.
+func CancelJobHandler(deps Dependencies) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		id := ctx.PathParam("id")
+		if _, ok := runner.Running(id); !ok {
+			return nil, gofrHTTP.ErrorEntityNotFound{Name: "id", Value: id}
+		}
+		if err := authorizeJob(ctx, deps, auth.OperationCancel, id); err != nil {
+			return nil, err
+		}
+		if !runner.Cancel(id) {
+			return nil, gofrHTTP.ErrorEntityNotFound{Name: "id", Value: id}
+		}
+		logger.Logf("Job %s cancelled", id)
+
+		tracker, _ := progress.Get(id)
+		return tracker.Snapshot(), nil
+	}
+}
+
+// logFailure logs runs that were started in the background and failed
+func logFailure(run *runner.Run, err error) {
+	if err != nil {
+		logger.Errorf("Job %s failed: %v", run.ID, err)
+	}
+}
+
+// ListJobsHandler returns the progress of every job that is still running and that
+// the caller may read
+func ListJobsHandler(deps Dependencies) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		jobs := []progress.Event{}
+		for _, event := range progress.Active() {
+			if jobReadable(ctx, deps, event.JobID) {
+				jobs = append(jobs, event)
+			}
+		}
+		return jobs, nil
+	}
+}
+
+// jobReadable reports whether the caller may read the job called id
+func jobReadable(ctx context.Context, deps Dependencies, id string) bool {
+	if auth.FromContext(ctx) == nil {
+		return true
+	}
+	run, ok := jobRun(deps, id)
+	if !ok {
+		return false
+	}
+	integrations, actions := runActions(run)
+	return visibleRecord(ctx, deps.Policy, integrations, actions)
+}
+
+// readableTracker returns the progress of the job called id, if the caller may read it
+func readableTracker(ctx context.Context, deps Dependencies, id string) (*progress.Tracker, error) {
+	tracker, ok := progress.Get(id)
+	if !ok {
+		return nil, gofrHTTP.ErrorEntityNotFound{Name: "id", Value: id}
+	}
+	if err := authorizeJob(ctx, deps, auth.OperationRead, id); err != nil {
+		return nil, err
+	}
+	return tracker, nil
+}
+
+// GetJobHandler returns the latest progress of a single job
+func GetJobHandler(deps Dependencies) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		tracker, err := readableTracker(ctx, deps, ctx.PathParam("id"))
+		if err != nil {
+			return nil, err
+		}
+		return tracker.Snapshot(), nil
+	}
+}
+
+// JobEventsHandler is the registered handler of /jobs/{id}/events. The stream itself is
+// written by EventStreamMiddleware, because gofr handlers can only return one response;
+// this handler only runs if the middleware is not installed.
+func JobEventsHandler(deps Dependencies) gofr.Handler {
+	return GetJobHandler(deps)
+}
+
+// JobSocketHandler streams the progress of a job over a WebSocket until it finishes
+func JobSocketHandler(deps Dependencies) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		tracker, err := readableTracker(ctx, deps, ctx.PathParam("id"))
+		if err != nil {
+			return nil, err
+		}
+
+		events, cancel := tracker.Subscribe()
+		defer cancel()
+		for event := range events {
+			if err := ctx.WriteMessageToSocket(event); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+}
+
+// EventStreamMiddleware serves GET /jobs/{id}/events as a Server-Sent Events stream.
+// Each update is sent as a "progress" event and the stream ends with an "end" event.
+func EventStreamMiddleware(deps Dependencies) gofrHTTP.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			match := eventsPath.FindStringSubmatch(r.URL.Path)
+			if r.Method != http.MethodGet || match == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			tracker, err := readableTracker(r.Context(), deps, match[1])
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("Connection", "keep-alive")
+			w.WriteHeader(http.StatusOK)
+			flusher := http.NewResponseController(w)
+
+			events, cancel := tracker.Subscribe()
+			defer cancel()
+			heartbeat := time.NewTicker(heartbeatInterval)
+			defer heartbeat.Stop()
+
+			for {
+				select {
+				case <-r.Context().Done():
+					return
+				case <-heartbeat.C:
+					fmt.Fprint(w, ": keep-alive\n\n")
+				case event, ok := <-events:
+					if !ok {
+						fmt.Fprint(w, "event: end\ndata: {}\n\n")
+						_ = flusher.Flush()
+						return
+					}
+					data, err := json.Marshal(event)
+					if err != nil {
+						return
+					}
+					fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+				}
+				_ = flusher.Flush()
+			}
+		})
+	}
+}
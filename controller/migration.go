@@ -1,55 +1,66 @@
 package controller
 
 import (
+	"context"
 	"fmt"
-	"log"
 
-	"github.com/SkySingh04/fractal/factory"
 	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/SkySingh04/fractal/rbac"
+	"github.com/SkySingh04/fractal/runner"
 	"gofr.dev/pkg/gofr"
 )
 
-func RegisterRoutes(app *gofr.App) {
-	app.POST("/migrate", MigrationHandler)
-}
+// apiPipeline is the pipeline name of migrations requested over HTTP
+const apiPipeline = "api"
 
-func MigrationHandler(ctx *gofr.Context) (interface{}, error) {
-	var req interfaces.Request
-	if err := ctx.Bind(&req); err != nil {
-		// Log detailed error to understand the bind issue
-		return nil, fmt.Errorf("failed to bind request: %v", err)
-	}
-	return runMigration(req)
+// MigrationResponse is returned by a successful migration
+type MigrationResponse struct {
+	Status string `json:"status"`
+	RunID  string `json:"run_id"`
 }
 
-func runMigration(req interfaces.Request) (interface{}, error) {
-	// Create source
-	input, err := factory.CreateSource(req.Input)
-	if err != nil {
-		log.Printf("Error creating source for input method %s: %v", req.Input, err)
-		return nil, fmt.Errorf("failed to create source for input method %s: %v", req.Input, err)
-	}
+// GreetHandler answers with a fixed greeting so clients can check the API is up
+func GreetHandler(ctx *gofr.Context) (interface{}, error) {
+	// Start a span for this route
+	_, span := opentele.CreateSpan(ctx.Context, "HTTP GET /greet")
+	defer span.End()
 
-	// Create destination
-	output, err := factory.CreateDestination(req.Output)
-	if err != nil {
-		log.Printf("Error creating destination for output method %s: %v", req.Output, err)
-		return nil, fmt.Errorf("failed to create destination for output method %s: %v", req.Output, err)
-	}
+	return "Hello Fractal!", nil
+}
 
-	// Fetch data from the source
-	data, err := input.FetchData(req)
-	if err != nil {
-		log.Printf("Error fetching data from source: %v", err)
-		return nil, fmt.Errorf("failed to fetch data from source: %v", err)
+// MigrationHandler runs a migration and waits for it to finish. The caller must be
+// allowed to run it by policy.
+func MigrationHandler(policy *rbac.Policy) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		var req interfaces.Request
+		if err := ctx.Bind(&req); err != nil {
+			// Log detailed error to understand the bind issue
+			return nil, fmt.Errorf("failed to bind request: %v", err)
+		}
+		if err := authorizeRun(ctx, policy, apiPipeline, req); err != nil {
+			return nil, err
+		}
+		return runMigration(ctx.Context, req)
 	}
+}
 
-	// Send data to the destination
-	if err := output.SendData(data, req); err != nil {
-		log.Printf("Error sending data to destination: %v", err)
-		return nil, fmt.Errorf("failed to send data to destination: %v", err)
+func runMigration(ctx context.Context, req interfaces.Request) (interface{}, error) {
+	run, err := runner.Execute(ctx, runner.Spec{
+		Pipeline:           apiPipeline,
+		Source:             req.Input,
+		Destination:        req.Output,
+		SourceRequest:      req,
+		DestinationRequest: req,
+		Trigger:            "http",
+		Caller:             caller(ctx),
+	})
+	if err != nil {
+		logger.FromContext(ctx).Errorf("Migration %s failed: %v", run.ID, err)
+		return nil, err
 	}
 
-	log.Println("Migration successful!")
-	return map[string]string{"status": "success"}, nil
+	logger.FromContext(ctx).Infof("Migration %s successful", run.ID)
+	return MigrationResponse{Status: "success", RunID: run.ID}, nil
 }
@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SkySingh04/fractal/auth"
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/SkySingh04/fractal/progress"
+	"github.com/SkySingh04/fractal/scheduler"
+	"github.com/SkySingh04/fractal/store"
+	"gofr.dev/pkg/gofr"
+	gofrHTTP "gofr.dev/pkg/gofr/http"
+)
+
+// PipelineResponse is a saved pipeline together with its scheduling state
+type PipelineResponse struct {
+	Pipeline pipeline.Definition `json:"pipeline"`
+	Status   scheduler.Status    `json:"status"`
+}
+
+// conflictError is returned when a request clashes with the current state
+type conflictError struct {
+	message string
+}
+
+func (e conflictError) Error() string {
+	return e.message
+}
+
+// StatusCode implements gofr's status code responder
+func (e conflictError) StatusCode() int {
+	return http.StatusConflict
+}
+
+// CreatePipelineHandler saves a new pipeline and schedules it
+func CreatePipelineHandler(deps Dependencies) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		var definition pipeline.Definition
+		if err := ctx.Bind(&definition); err != nil {
+			return nil, fmt.Errorf("failed to bind pipeline: %v", err)
+		}
+		if err := definition.Validate(); err != nil {
+			return nil, err
+		}
+		// A saved pipeline runs on its own later, so its author must be allowed to run it
+		if err := authorize(ctx, deps.Policy, &definition, auth.OperationManage, auth.OperationRun); err != nil {
+			return nil, err
+		}
+
+		definition.CreatedAt = time.Now().UTC()
+		definition.UpdatedAt = definition.CreatedAt
+		if err := deps.Runs.CreatePipeline(&definition); errors.Is(err, store.ErrExists) {
+			return nil, conflictError{fmt.Sprintf("pipeline %s already exists", definition.Name)}
+		} else if err != nil {
+			return nil, err
+		}
+
+		deps.Scheduler.Schedule(&definition)
+		return pipelineResponse(deps, &definition), nil
+	}
+}
+
+// ListPipelinesHandler returns the saved pipelines that the caller may read
+func ListPipelinesHandler(deps Dependencies) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		definitions, err := deps.Runs.ListPipelines()
+		if err != nil {
+			return nil, err
+		}
+		responses := []PipelineResponse{}
+		for i := range definitions {
+			if readable(ctx, deps.Policy, &definitions[i]) {
+				responses = append(responses, pipelineResponse(deps, &definitions[i]))
+			}
+		}
+		return responses, nil
+	}
+}
+
+// GetPipelineHandler returns a single saved pipeline
+func GetPipelineHandler(deps Dependencies) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		definition, err := getPipeline(deps, ctx.PathParam("name"))
+		if err != nil {
+			return nil, err
+		}
+		if err := authorize(ctx, deps.Policy, definition, auth.OperationRead); err != nil {
+			return nil, err
+		}
+		return pipelineResponse(deps, definition), nil
+	}
+}
+
+// UpdatePipelineHandler replaces a saved pipeline. The creation time and the paused
+// state are kept; use the pause and resume endpoints to change the latter. Secret
+// fields sent back as returned, redacted, keep their saved value.
+func UpdatePipelineHandler(deps Dependencies) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		existing, err := getPipeline(deps, ctx.PathParam("name"))
+		if err != nil {
+			return nil, err
+		}
+
+		var definition pipeline.Definition
+		if err := ctx.Bind(&definition); err != nil {
+			return nil, fmt.Errorf("failed to bind pipeline: %v", err)
+		}
+		if definition.Name != "" && definition.Name != existing.Name {
+			return nil, pipeline.ValidationError{Field: "name", Reason: "cannot be changed"}
+		}
+		definition.Name = existing.Name
+		definition.KeepRedacted(existing)
+		if err := definition.Validate(); err != nil {
+			return nil, err
+		}
+		if err := authorize(ctx, deps.Policy, existing, auth.OperationManage); err != nil {
+			return nil, err
+		}
+		if err := authorize(ctx, deps.Policy, &definition, auth.OperationManage, auth.OperationRun); err != nil {
+			return nil, err
+		}
+
+		definition.CreatedAt = existing.CreatedAt
+		definition.UpdatedAt = time.Now().UTC()
+		definition.Paused = existing.Paused
+		if err := deps.Runs.SavePipeline(&definition); err != nil {
+			return nil, err
+		}
+
+		deps.Scheduler.Schedule(&definition)
+		return pipelineResponse(deps, &definition), nil
+	}
+}
+
+// DeletePipelineHandler removes a saved pipeline. Its run history is kept and a run
+// in progress is left to finish.
+func DeletePipelineHandler(deps Dependencies) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		definition, err := getPipeline(deps, ctx.PathParam("name"))
+		if err != nil {
+			return nil, err
+		}
+		if err := authorize(ctx, deps.Policy, definition, auth.OperationManage); err != nil {
+			return nil, err
+		}
+
+		deps.Scheduler.Unschedule(definition.Name)
+		if err := deps.Runs.DeletePipeline(definition.Name); err != nil {
+			return nil, err
+		}
+		return pipelineResponse(deps, definition), nil
+	}
+}
+
+// PausePipelineHandler stops scheduled runs of a pipeline until it is resumed
+func PausePipelineHandler(deps Dependencies) gofr.Handler {
+	return setPaused(deps, true)
+}
+
+// ResumePipelineHandler re-enables scheduled runs of a paused pipeline
+func ResumePipelineHandler(deps Dependencies) gofr.Handler {
+	return setPaused(deps, false)
+}
+
+func setPaused(deps Dependencies, paused bool) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		definition, err := getPipeline(deps, ctx.PathParam("name"))
+		if err != nil {
+			return nil, err
+		}
+		if err := authorize(ctx, deps.Policy, definition, auth.OperationManage); err != nil {
+			return nil, err
+		}
+
+		definition.Paused = paused
+		definition.UpdatedAt = time.Now().UTC()
+		if err := deps.Runs.SavePipeline(definition); err != nil {
+			return nil, err
+		}
+
+		deps.Scheduler.Schedule(definition)
+		return pipelineResponse(deps, definition), nil
+	}
+}
+
+// RunPipelineHandler starts a run of a saved pipeline straight away, even if it is
+// paused, and returns the job id to follow its progress
+func RunPipelineHandler(deps Dependencies) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		definition, err := getPipeline(deps, ctx.PathParam("name"))
+		if err != nil {
+			return nil, err
+		}
+		if err := authorize(ctx, deps.Policy, definition, auth.OperationRun); err != nil {
+			return nil, err
+		}
+
+		id, err := deps.Scheduler.RunNow(definition, caller(ctx))
+		if errors.Is(err, scheduler.ErrAlreadyRunning) {
+			return nil, conflictError{fmt.Sprintf("pipeline %s is already running", definition.Name)}
+		} else if err != nil {
+			return nil, err
+		}
+		return JobResponse{JobID: id, Status: progress.PhaseStarting}, nil
+	}
+}
+
+func getPipeline(deps Dependencies, name string) (*pipeline.Definition, error) {
+	definition, err := deps.Runs.GetPipeline(name)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, gofrHTTP.ErrorEntityNotFound{Name: "name", Value: name}
+	}
+	return definition, err
+}
+
+// pipelineResponse shows a pipeline with its secrets redacted, since the read scope
+// is enough to see it
+func pipelineResponse(deps Dependencies, definition *pipeline.Definition) PipelineResponse {
+	return PipelineResponse{Pipeline: definition.Redacted(), Status: deps.Scheduler.Status(definition.Name)}
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/profile"
+	"github.com/SkySingh04/fractal/rbac"
+	"gofr.dev/pkg/gofr"
+	gofrHTTP "gofr.dev/pkg/gofr/http"
+)
+
+// ProfileHandler profiles the data of the input integration of the request, reading
+// the query parameters sample and top. The caller must be allowed to read from the
+// source by policy.
+func ProfileHandler(policy *rbac.Policy) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		var req interfaces.Request
+		if err := ctx.Bind(&req); err != nil {
+			return nil, fmt.Errorf("failed to bind request: %v", err)
+		}
+		var options profile.Options
+		for name, value := range map[string]*int{"sample": &options.Sample, "top": &options.Top} {
+			if param := ctx.Param(name); param != "" {
+				n, err := strconv.Atoi(param)
+				if err != nil || n < 1 {
+					return nil, gofrHTTP.ErrorInvalidParam{Params: []string{name}}
+				}
+				*value = n
+			}
+		}
+		if err := authorizeSource(ctx, policy, apiPipeline, req); err != nil {
+			return nil, err
+		}
+		return profile.Source(ctx.Context, req.Input, req, options)
+	}
+}
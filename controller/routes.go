@@ -0,0 +1,414 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/SkySingh04/fractal/audit"
+	"github.com/SkySingh04/fractal/auth"
+	"github.com/SkySingh04/fractal/health"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/openapi"
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/SkySingh04/fractal/profile"
+	"github.com/SkySingh04/fractal/progress"
+	"github.com/SkySingh04/fractal/rbac"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/scheduler"
+	"github.com/SkySingh04/fractal/schema"
+	"github.com/SkySingh04/fractal/store"
+	"github.com/gorilla/mux"
+	"gofr.dev/pkg/gofr"
+	gofrHTTP "gofr.dev/pkg/gofr/http"
+	"gofr.dev/pkg/gofr/http/response"
+)
+
+// APIVersion is the version reported in the generated OpenAPI document
+const APIVersion = "1.0.0"
+
+// OpenAPIFile is where gofr looks for the document it renders at /.well-known/swagger.
+// It is written by go generate, never by the server, see RegisterOpenAPI.
+const OpenAPIFile = "static/openapi.json"
+
+// OpenAPIPath is where the OpenAPI document is served
+const OpenAPIPath = "/.well-known/openapi.json"
+
+// Route is an HTTP endpoint together with the metadata used to document it
+type Route struct {
+	openapi.Operation
+	Handler gofr.Handler
+}
+
+// Scopes required by the routes
+const (
+	scopeRun    = string(auth.OperationRun)
+	scopeRead   = string(auth.OperationRead)
+	scopeCancel = string(auth.OperationCancel)
+	scopeManage = string(auth.OperationManage)
+)
+
+// Dependencies holds the shared state the handlers need
+type Dependencies struct {
+	Runs      *store.Store
+	Policy    *rbac.Policy
+	Scheduler *scheduler.Scheduler
+	Audit     *audit.Log
+	Health    *health.Cache // Reuses readiness reports; nil probes on every request
+}
+
+// Routes returns every endpoint served by Fractal. Both the router and the OpenAPI
+// document are built from this table, so a handler cannot exist without documentation.
+func Routes(deps Dependencies) []Route {
+	return []Route{
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: "/greet", Tag: "meta",
+				Summary:  "Check that the API is up",
+				Response: "",
+			},
+			Handler: GreetHandler,
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodPost, Path: "/api/migration", Tag: "migrations", Scope: scopeRun,
+				Summary:     "Perform data migration",
+				Description: "Fetches data from the input integration and sends it to the output integration. Only the fields of the selected integrations are read.",
+				Body:        interfaces.Request{},
+				Response:    MigrationResponse{},
+			},
+			Handler: MigrationHandler(deps.Policy),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodPost, Path: "/migrate", Tag: "migrations", Scope: scopeRun,
+				Summary:  "Perform data migration (alias of /api/migration)",
+				Body:     interfaces.Request{},
+				Response: MigrationResponse{},
+			},
+			Handler: MigrationHandler(deps.Policy),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: "/integrations", Tag: "integrations", Scope: scopeRead,
+				Summary:  "List registered integrations and their config schemas",
+				Response: schema.Catalog{},
+			},
+			Handler: IntegrationsHandler,
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: "/runs", Tag: "runs", Scope: scopeRead,
+				Summary: "List past runs, newest first",
+				Query: []openapi.Param{
+					{Name: "pipeline", Type: "string", Description: "Only return runs of this pipeline"},
+					{Name: "status", Type: "string", Description: "Only return runs with this status (running, succeeded, failed, cancelled)"},
+					{Name: "limit", Type: "integer", Description: "Maximum number of runs to return"},
+				},
+				Response: []runner.Run{},
+			},
+			Handler: ListRunsHandler(deps.Runs, deps.Policy),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: "/runs/{id}", Tag: "runs", Scope: scopeRead,
+				Summary:  "Get a single run",
+				Response: runner.Run{},
+			},
+			Handler: GetRunHandler(deps.Runs, deps.Policy),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodPost, Path: "/jobs", Tag: "jobs", Scope: scopeRun,
+				Summary:     "Start a migration in the background",
+				Description: "Returns the job id immediately; the job id is also the id of the run record.",
+				Body:        interfaces.Request{},
+				Response:    JobResponse{},
+			},
+			Handler: StartJobHandler(deps.Policy),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: "/jobs", Tag: "jobs", Scope: scopeRead,
+				Summary:  "List the progress of running jobs",
+				Response: []progress.Event{},
+			},
+			Handler: ListJobsHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: "/jobs/{id}", Tag: "jobs", Scope: scopeRead,
+				Summary:  "Get the latest progress of a job",
+				Response: progress.Event{},
+			},
+			Handler: GetJobHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: "/jobs/{id}/events", Tag: "jobs", Scope: scopeRead,
+				Summary:     "Stream the progress of a job as Server-Sent Events",
+				Description: "Responds with text/event-stream. Every update is a `progress` event whose data is the JSON encoded progress; the stream ends with an `end` event.",
+				Response:    progress.Event{},
+			},
+			Handler: JobEventsHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: MethodWebSocket, Path: "/jobs/{id}/ws", Tag: "jobs", Scope: scopeRead,
+				Summary:     "Stream the progress of a job over a WebSocket",
+				Description: "Upgrades to a WebSocket and sends one JSON encoded progress message per update until the job finishes.",
+				Response:    progress.Event{},
+			},
+			Handler: JobSocketHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodDelete, Path: "/jobs/{id}", Tag: "jobs", Scope: scopeCancel,
+				Summary:     "Cancel a running job",
+				Description: "The job stops before it starts writing to its next destination; a destination that is already being written is finished first.",
+				Response:    progress.Event{},
+			},
+			Handler: CancelJobHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodPost, Path: "/pipelines", Tag: "pipelines", Scope: scopeManage,
+				Summary:     "Save a new pipeline",
+				Description: "The pipeline runs every `schedule` interval until it is paused or deleted. Saving a pipeline also requires the `run` scope.",
+				Body:        pipeline.Definition{},
+				Response:    PipelineResponse{},
+			},
+			Handler: CreatePipelineHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: "/pipelines", Tag: "pipelines", Scope: scopeRead,
+				Summary:  "List saved pipelines",
+				Response: []PipelineResponse{},
+			},
+			Handler: ListPipelinesHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: "/pipelines/{name}", Tag: "pipelines", Scope: scopeRead,
+				Summary:  "Get a saved pipeline",
+				Response: PipelineResponse{},
+			},
+			Handler: GetPipelineHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodPut, Path: "/pipelines/{name}", Tag: "pipelines", Scope: scopeManage,
+				Summary:     "Replace a saved pipeline",
+				Description: "The creation time and paused state are kept. Saving a pipeline also requires the `run` scope.",
+				Body:        pipeline.Definition{},
+				Response:    PipelineResponse{},
+			},
+			Handler: UpdatePipelineHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodDelete, Path: "/pipelines/{name}", Tag: "pipelines", Scope: scopeManage,
+				Summary:     "Delete a saved pipeline",
+				Description: "Its run history is kept and a run in progress is left to finish.",
+				Response:    PipelineResponse{},
+			},
+			Handler: DeletePipelineHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodPost, Path: "/pipelines/{name}/pause", Tag: "pipelines", Scope: scopeManage,
+				Summary:  "Stop scheduled runs of a pipeline",
+				Response: PipelineResponse{},
+			},
+			Handler: PausePipelineHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodPost, Path: "/pipelines/{name}/resume", Tag: "pipelines", Scope: scopeManage,
+				Summary:  "Resume scheduled runs of a paused pipeline",
+				Response: PipelineResponse{},
+			},
+			Handler: ResumePipelineHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodPost, Path: "/pipelines/{name}/run", Tag: "pipelines", Scope: scopeRun,
+				Summary:     "Run a saved pipeline now",
+				Description: "Works for paused pipelines too. Returns the job id; progress is available under /jobs/{id}.",
+				Response:    JobResponse{},
+			},
+			Handler: RunPipelineHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodPost, Path: "/profile", Tag: "migrations", Scope: scopeRun,
+				Summary:     "Profile the data of a source",
+				Description: "Fetches the data of the input integration and reports, per field, the inferred type, null and empty ratios, distinct values, minimum and maximum, most frequent values, lengths and nested shape, with suggested validation rules. Sources are read as by a migration, so queues are consumed.",
+				Query: []openapi.Param{
+					{Name: "sample", Type: "integer", Description: "Maximum number of records profiled per table, collection or file (default 10000)"},
+					{Name: "top", Type: "integer", Description: "Number of most frequent values reported per field (default 5)"},
+				},
+				Body:     interfaces.Request{},
+				Response: profile.Report{},
+			},
+			Handler: ProfileHandler(deps.Policy),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: "/audit", Tag: "audit", Scope: scopeRead,
+				Summary:     "List the entries of the audit log, newest first",
+				Description: "Every run is recorded when it starts and when it finishes, with its caller, source and destinations, the hosts and databases they connect to, the hash of its rules, its outcome and record counts.",
+				Query: []openapi.Param{
+					{Name: "pipeline", Type: "string", Description: "Only return entries of this pipeline"},
+					{Name: "caller", Type: "string", Description: "Only return entries of runs started by this caller, such as jwt:alice"},
+					{Name: "run_id", Type: "string", Description: "Only return entries of this run"},
+					{Name: "since", Type: "string", Description: "Only return entries written at or after this RFC 3339 time"},
+					{Name: "limit", Type: "integer", Description: "Maximum number of entries to return (default 100, 0 for all)"},
+				},
+				Response: []audit.Entry{},
+			},
+			Handler: ListAuditHandler(deps.Audit, deps.Policy),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: "/audit/verify", Tag: "audit", Scope: scopeRead,
+				Summary:     "Verify the hash chain of the audit log",
+				Description: "Reports the first line that was changed, removed or reordered since it was written.",
+				Response:    audit.Verification{},
+			},
+			Handler: VerifyAuditHandler(deps.Audit),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: "/health/live", Tag: "health",
+				Summary:     "Check that the server is up",
+				Description: "Does not connect to any integration. Meant for liveness probes.",
+				Response:    Liveness{},
+			},
+			Handler: LiveHandler,
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: readyPath, Tag: "health",
+				Summary:     "Check the connections of every pipeline",
+				Description: "Tests the connection to the source and destinations of every pipeline that is not paused, saved or loaded from the config file, all at once: a PostgreSQL ping, a MongoDB ping, a Kafka metadata request, an AMQP or FTP login and so on. Responds with 503 when a check fails. Integrations that cannot test their connection are skipped. Only the outcome is returned, and it is reused for 10s. Meant for readiness probes.",
+				Response:    Readiness{},
+			},
+			Handler: ReadyHandler(deps),
+		},
+		{
+			Operation: openapi.Operation{
+				Method: http.MethodGet, Path: readyDetailsPath, Tag: "health", Scope: scopeRead,
+				Summary:     "List the connection checks of the pipelines",
+				Description: "Probes like /health/ready and returns every check of the pipelines the caller may read, with its error and duration. Responds with 503 when a check fails.",
+				Query: []openapi.Param{
+					{Name: "pipeline", Type: "string", Description: "Only check this pipeline, even if it is paused"},
+					{Name: "timeout", Type: "string", Description: "How long every check may take, such as 10s (default 5s, at most 1m)"},
+				},
+				Response: health.Report{},
+			},
+			Handler: ReadyDetailsHandler(deps),
+		},
+	}
+}
+
+// RegisterRoutes registers every route of the Routes table on app
+func RegisterRoutes(app *gofr.App, deps Dependencies) {
+	for _, route := range Routes(deps) {
+		switch route.Method {
+		case http.MethodGet:
+			app.GET(route.Path, route.Handler)
+		case http.MethodPost:
+			app.POST(route.Path, route.Handler)
+		case http.MethodPut:
+			app.PUT(route.Path, route.Handler)
+		case http.MethodDelete:
+			app.DELETE(route.Path, route.Handler)
+		case MethodWebSocket:
+			app.WebSocket(route.Path, route.Handler)
+		default:
+			panic(fmt.Sprintf("unsupported method %s for route %s", route.Method, route.Path))
+		}
+	}
+}
+
+// AuthMiddleware authenticates requests to every route that requires a scope and
+// rejects callers that do not hold it. Routes without a scope stay public.
+func AuthMiddleware(authenticator auth.Authenticator) gofrHTTP.Middleware {
+	scopes := map[string]auth.Operation{}
+	for _, route := range Routes(Dependencies{}) {
+		method := route.Method
+		if method == MethodWebSocket {
+			method = http.MethodGet
+		}
+		if route.Scope != "" {
+			scopes[method+" "+route.Path] = auth.Operation(route.Scope)
+		}
+	}
+
+	return auth.Middleware(authenticator, func(r *http.Request) (auth.Operation, bool) {
+		current := mux.CurrentRoute(r)
+		if current == nil {
+			return "", false
+		}
+		template, err := current.GetPathTemplate()
+		if err != nil {
+			return "", false
+		}
+		op, ok := scopes[r.Method+" "+template]
+		return op, ok
+	})
+}
+
+// OpenAPI generates the OpenAPI document for the Routes table
+func OpenAPI() ([]byte, error) {
+	catalog, err := schema.All()
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []openapi.Operation
+	for _, route := range Routes(Dependencies{}) {
+		operation := route.Operation
+		if operation.Method == MethodWebSocket {
+			operation.Method = http.MethodGet // The upgrade request is a plain GET
+		}
+		operations = append(operations, operation)
+	}
+
+	info := openapi.Info{
+		Title:       "Fractal API",
+		Version:     APIVersion,
+		Description: "API documentation for the Fractal migration service",
+	}
+	return openapi.Generate(info, operations, catalog)
+}
+
+// RegisterOpenAPI serves doc, the document generated by OpenAPI, at
+// /.well-known/openapi.json. gofr only serves that path when OpenAPIFile exists,
+// and the route registered here takes precedence over the copy in the file, which
+// may be stale.
+func RegisterOpenAPI(app *gofr.App, doc []byte) {
+	app.GET(OpenAPIPath, OpenAPIHandler(doc))
+}
+
+// OpenAPIHandler returns doc as it is, without the data envelope of other responses
+func OpenAPIHandler(doc []byte) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		return response.File{Content: doc, ContentType: "application/json"}, nil
+	}
+}
+
+// WriteOpenAPI regenerates the OpenAPI document at path
+func WriteOpenAPI(path string) error {
+	doc, err := OpenAPI()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(doc, '\n'), 0644)
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/SkySingh04/fractal/auth"
+	"github.com/SkySingh04/fractal/rbac"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/store"
+	"gofr.dev/pkg/gofr"
+	gofrHTTP "gofr.dev/pkg/gofr/http"
+)
+
+// ListRunsHandler returns the past runs the caller may read, newest first. It accepts
+// the optional query parameters pipeline, status and limit.
+func ListRunsHandler(runs *store.Store, policy *rbac.Policy) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		filter := store.RunFilter{
+			Pipeline: ctx.Param("pipeline"),
+			Status:   runner.Status(ctx.Param("status")),
+			Visible: func(run runner.Run) bool {
+				integrations, actions := runActions(run)
+				return visibleRecord(ctx, policy, integrations, actions)
+			},
+		}
+		if limit := ctx.Param("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n < 0 {
+				return nil, gofrHTTP.ErrorInvalidParam{Params: []string{"limit"}}
+			}
+			filter.Limit = n
+		}
+		return runs.ListRuns(filter)
+	}
+}
+
+// GetRunHandler returns a single run by id
+func GetRunHandler(runs *store.Store, policy *rbac.Policy) gofr.Handler {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		id := ctx.PathParam("id")
+		run, err := runs.GetRun(id)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, gofrHTTP.ErrorEntityNotFound{Name: "id", Value: id}
+		}
+		if err != nil {
+			return nil, err
+		}
+		integrations, actions := runActions(*run)
+		if err := authorizeRecord(ctx, policy, auth.OperationRead, integrations, actions); err != nil {
+			return nil, err
+		}
+		return run, nil
+	}
+}
@@ -0,0 +1,323 @@
+// Package dsn reads the connection strings of integrations: URLs, key=value strings
+// such as "host=db dbname=app" and comma separated host:port lists. Access control,
+// the audit log and lineage all use it, so they agree on which hosts and databases a
+// pipeline connects to.
+package dsn
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultHost is the host drivers connect to when a connection string names none
+const DefaultHost = "localhost"
+
+// Address is a host and its port, if the connection string gives one
+type Address struct {
+	Host string
+	Port string
+}
+
+// Endpoint is what a connection string points to
+type Endpoint struct {
+	// Addresses in the order drivers use them. Hosts set in the query string of a URL,
+	// which override those before the path with lib/pq, come first; the hosts they
+	// override are kept, so that every host a driver may connect to is listed.
+	Addresses []Address
+	// Databases named, in the same order: query string first, then the path
+	Databases []string
+}
+
+// Hosts returns the host names of the addresses, without duplicates
+func (e Endpoint) Hosts() []string {
+	var hosts []string
+	seen := map[string]bool{}
+	for _, address := range e.Addresses {
+		if !seen[address.Host] {
+			seen[address.Host] = true
+			hosts = append(hosts, address.Host)
+		}
+	}
+	return hosts
+}
+
+// Database returns the database a driver uses, or "" if none is named
+func (e Endpoint) Database() string {
+	if len(e.Databases) == 0 {
+		return ""
+	}
+	return e.Databases[0]
+}
+
+// hostKeys, portKeys and databaseKeys are the settings of URL query strings and
+// key=value strings that say where to connect
+var (
+	hostKeys     = []string{"host", "hostaddr", "server"}
+	portKeys     = []string{"port"}
+	databaseKeys = []string{"dbname", "database"}
+)
+
+// Parse reads the addresses and databases of a URL, a key=value connection string
+// or a comma separated host:port list. A connection string that names no host points
+// to DefaultHost, as it does for the drivers.
+func Parse(value string) Endpoint {
+	value = strings.TrimSpace(value)
+	var e Endpoint
+	switch {
+	case strings.Contains(value, "://"):
+		e = parseURL(value)
+	case strings.Contains(value, "="):
+		var ports []string
+		e, ports = fromSettings(Pairs(value))
+		if len(e.Addresses) == 0 && len(ports) > 0 {
+			e.Addresses = []Address{{Host: DefaultHost}}
+		}
+		setPorts(e.Addresses, ports)
+	default:
+		for _, hostport := range strings.Split(value, ",") {
+			if hostport = strings.TrimSpace(hostport); hostport != "" {
+				e.Addresses = append(e.Addresses, splitAddress(withoutUser(hostport)))
+			}
+		}
+	}
+	if len(e.Addresses) == 0 {
+		e.Addresses = []Address{{Host: DefaultHost}}
+	}
+	return e
+}
+
+func parseURL(value string) Endpoint {
+	u, err := parseLenient(value)
+	if err != nil {
+		return Endpoint{}
+	}
+	query := u.Query()
+	var keys []string
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var settings []Pair
+	for _, key := range keys {
+		for _, v := range query[key] {
+			settings = append(settings, Pair{Key: key, Value: v})
+		}
+	}
+	e, ports := fromSettings(settings)
+	for _, hostport := range strings.Split(u.Host, ",") {
+		if hostport != "" {
+			e.Addresses = append(e.Addresses, splitAddress(hostport))
+		}
+	}
+	setPorts(e.Addresses, ports)
+	if database := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")[0]; database != "" {
+		if unescaped, err := url.PathUnescape(database); err == nil {
+			database = unescaped
+		}
+		e.Databases = append(e.Databases, database)
+	}
+	return e
+}
+
+// parseLenient parses a URL. One that fails, as when its password has characters
+// that are not escaped, is parsed again without everything up to its last @.
+func parseLenient(value string) (*url.URL, error) {
+	u, err := url.Parse(value)
+	if err == nil {
+		return u, nil
+	}
+	return url.Parse(afterUserinfo(value))
+}
+
+// afterUserinfo drops everything between the scheme of a URL and its last @
+func afterUserinfo(value string) string {
+	scheme, rest, _ := strings.Cut(value, "://")
+	if at := strings.LastIndex(rest, "@"); at >= 0 {
+		rest = rest[at+1:]
+	}
+	return scheme + "://" + rest
+}
+
+// fromSettings reads hosts and databases from key=value settings, and the ports
+// to pair with the hosts
+func fromSettings(settings []Pair) (e Endpoint, ports []string) {
+	for _, setting := range settings {
+		switch {
+		case is(setting.Key, hostKeys):
+			for _, hostport := range strings.Split(setting.Value, ",") {
+				if hostport = strings.TrimSpace(hostport); hostport != "" {
+					e.Addresses = append(e.Addresses, splitAddress(hostport))
+				}
+			}
+		case is(setting.Key, portKeys):
+			ports = strings.Split(setting.Value, ",")
+		case is(setting.Key, databaseKeys):
+			if setting.Value != "" {
+				e.Databases = append(e.Databases, setting.Value)
+			}
+		}
+	}
+	return e, ports
+}
+
+// setPorts gives the addresses without a port their port of ports. A single port
+// applies to every host; several are paired with the hosts in order.
+func setPorts(addresses []Address, ports []string) {
+	for i := range addresses {
+		if addresses[i].Port != "" || len(ports) == 0 {
+			continue
+		}
+		port := ports[0]
+		if len(ports) > 1 && i < len(ports) {
+			port = ports[i]
+		}
+		addresses[i].Port = strings.TrimSpace(port)
+	}
+}
+
+func is(key string, keys []string) bool {
+	for _, k := range keys {
+		if strings.EqualFold(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitAddress(hostport string) Address {
+	if host, port, err := net.SplitHostPort(hostport); err == nil {
+		return Address{Host: host, Port: port}
+	}
+	return Address{Host: strings.Trim(hostport, "[]")}
+}
+
+// withoutUser drops the user and password of a user:password@host:port address
+func withoutUser(hostport string) string {
+	if at := strings.LastIndex(hostport, "@"); at >= 0 {
+		return hostport[at+1:]
+	}
+	return hostport
+}
+
+// Pair is a setting of a key=value connection string
+type Pair struct {
+	Key   string
+	Value string
+}
+
+// Pairs splits a key=value connection string into its settings. Values may be
+// quoted with single or double quotes, in which a backslash escapes the next
+// character, and spaces are allowed around the equal signs, as libpq allows.
+func Pairs(value string) []Pair {
+	var pairs []Pair
+	s := strings.TrimSpace(value)
+	for s != "" {
+		eq := strings.IndexAny(s, "= \t\n")
+		if eq < 0 {
+			pairs = append(pairs, Pair{Key: s})
+			break
+		}
+		key := s[:eq]
+		s = strings.TrimLeft(s[eq:], " \t\n")
+		if !strings.HasPrefix(s, "=") {
+			// A key without a value
+			pairs = append(pairs, Pair{Key: key})
+			continue
+		}
+		s = strings.TrimLeft(s[1:], " \t\n")
+
+		var val strings.Builder
+		if s != "" && (s[0] == '\'' || s[0] == '"') {
+			quote := s[0]
+			i := 1
+			for ; i < len(s) && s[i] != quote; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				val.WriteByte(s[i])
+			}
+			s = s[min(i+1, len(s)):]
+		} else {
+			i := 0
+			for ; i < len(s) && !strings.ContainsRune(" \t\n", rune(s[i])); i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				val.WriteByte(s[i])
+			}
+			s = s[i:]
+		}
+		pairs = append(pairs, Pair{Key: key, Value: val.String()})
+		s = strings.TrimLeft(s, " \t\n")
+	}
+	return pairs
+}
+
+// Join writes settings back as a key=value connection string, quoting the values
+// that need it
+func Join(pairs []Pair) string {
+	var parts []string
+	for _, pair := range pairs {
+		value := pair.Value
+		if value == "" || strings.ContainsAny(value, " \t\n'\"\\=") {
+			value = "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+		}
+		parts = append(parts, pair.Key+"="+value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// credentialKeys are the settings of URLs and key=value connection strings that
+// hold credentials
+var credentialKeys = []string{"user", "password", "passwd", "pwd", "secret", "token", "key", "auth"}
+
+// IsCredential reports whether a setting of a connection string holds a credential
+func IsCredential(name string) bool {
+	name = strings.ToLower(name)
+	for _, key := range credentialKeys {
+		if strings.Contains(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// StripCredentials removes the user, password and other credentials from a URL, a
+// key=value connection string or a host list. A URL that cannot be parsed loses its
+// user information and query string rather than being kept as it is.
+func StripCredentials(value string) string {
+	value = strings.TrimSpace(value)
+	switch {
+	case strings.Contains(value, "://"):
+		u, err := parseLenient(value)
+		if err != nil {
+			stripped, _, _ := strings.Cut(afterUserinfo(value), "?")
+			return stripped
+		}
+		u.User = nil
+		query := u.Query()
+		for name := range query {
+			if IsCredential(name) {
+				query.Del(name)
+			}
+		}
+		u.RawQuery = query.Encode()
+		return u.String()
+	case strings.Contains(value, "="):
+		var kept []Pair
+		for _, pair := range Pairs(value) {
+			if !IsCredential(pair.Key) {
+				kept = append(kept, pair)
+			}
+		}
+		return Join(kept)
+	default:
+		hosts := strings.Split(value, ",")
+		for i := range hosts {
+			hosts[i] = withoutUser(strings.TrimSpace(hosts[i]))
+		}
+		return strings.Join(hosts, ",")
+	}
+}
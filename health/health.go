@@ -0,0 +1,216 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/SkySingh04/fractal/schema"
+)
+
+// DefaultTimeout bounds every probe unless another timeout is asked for
+const DefaultTimeout = 5 * time.Second
+
+// DefaultCacheTTL is how long the server reuses a report before probing again
+const DefaultCacheTTL = 10 * time.Second
+
+// Status is the outcome of a check, or of a whole report
+type Status string
+
+const (
+	StatusOK     Status = "ok"
+	StatusFailed Status = "failed"
+	// StatusSkipped is reported for integrations that cannot test their connection
+	// without moving data. They do not fail the report.
+	StatusSkipped Status = "skipped"
+)
+
+// Check is the result of probing the source or a destination of a pipeline
+type Check struct {
+	Pipeline        string  `json:"pipeline"`
+	Role            string  `json:"role"` // source or destination
+	Integration     string  `json:"integration"`
+	Status          Status  `json:"status"`
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// Report gathers the checks of every pipeline. Its status is failed if any of them failed.
+type Report struct {
+	Status    Status    `json:"status"`
+	CheckedAt time.Time `json:"checked_at"`
+	Checks    []Check   `json:"checks"`
+}
+
+// Ready reports whether no check failed
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// probe is a single connection test, shared by the checks of pipelines that use the
+// same endpoint
+type probe struct {
+	endpoint pipeline.Endpoint
+	kind     schema.Kind
+	resolve  bool
+	checks   []int // Indexes of the checks it answers
+}
+
+// ResolveAll resolves the secret references of every pipeline, as runs of the
+// pipelines of a config file do
+func ResolveAll(string) bool { return true }
+
+// Probe tests the connection to the source and every destination of each pipeline,
+// all at once, through the ConnectionTester of their integration. Every probe gets
+// timeout; one that has not answered by then fails. An endpoint used by several
+// pipelines is probed once.
+//
+// resolve reports whether the secret references of the pipeline called name are
+// resolved before probing it. It must only hold for pipelines of the config file:
+// those saved through the API are probed with the references as they are, like their
+// runs, so that saving a pipeline cannot send secrets to a host of the caller's
+// choosing. A nil resolve resolves none.
+func Probe(ctx context.Context, definitions []*pipeline.Definition, timeout time.Duration, resolve func(name string) bool) Report {
+	report := Report{Status: StatusOK, CheckedAt: time.Now().UTC(), Checks: []Check{}}
+	probes := map[string]*probe{}
+	var order []string
+	add := func(definition *pipeline.Definition, endpoint pipeline.Endpoint, kind schema.Kind) {
+		report.Checks = append(report.Checks, Check{Pipeline: definition.Name, Role: string(kind), Integration: endpoint.Integration})
+		resolved := resolve != nil && resolve(definition.Name)
+		key := fmt.Sprintf("%t %s", resolved, endpointKey(endpoint, kind))
+		p, ok := probes[key]
+		if !ok {
+			p = &probe{endpoint: endpoint, kind: kind, resolve: resolved}
+			probes[key] = p
+			order = append(order, key)
+		}
+		p.checks = append(p.checks, len(report.Checks)-1)
+	}
+	for _, definition := range definitions {
+		add(definition, definition.Source, schema.KindSource)
+		for _, destination := range definition.Destinations {
+			add(definition, destination, schema.KindDestination)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, key := range order {
+		wg.Add(1)
+		go func(p *probe) {
+			defer wg.Done()
+			start := time.Now()
+			status, err := test(ctx, p, timeout)
+			for _, i := range p.checks {
+				report.Checks[i].Status = status
+				report.Checks[i].DurationSeconds = time.Since(start).Seconds()
+				if err != nil {
+					report.Checks[i].Error = err.Error()
+				}
+			}
+		}(probes[key])
+	}
+	wg.Wait()
+
+	for _, check := range report.Checks {
+		if check.Status == StatusFailed {
+			report.Status = StatusFailed
+		}
+	}
+	return report
+}
+
+// test runs the connection test of a probe and gives up after timeout, even if
+// the integration does not stop when its context is done
+func test(ctx context.Context, p *probe, timeout time.Duration) (Status, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() { result <- p.endpoint.TestConnection(ctx, p.kind, p.resolve) }()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("no answer within %s", timeout)
+	}
+	switch {
+	case errors.Is(err, pipeline.ErrNotTestable):
+		return StatusSkipped, err
+	case err != nil:
+		return StatusFailed, err
+	}
+	return StatusOK, nil
+}
+
+// endpointKey identifies the integration, role and settings of an endpoint
+func endpointKey(endpoint pipeline.Endpoint, kind schema.Kind) string {
+	config, _ := json.Marshal(endpoint.Config)
+	return string(kind) + " " + endpoint.Integration + " " + string(config)
+}
+
+// Cache reuses the report of a probe for a while, so that frequent readiness requests
+// do not open connections to every integration each time. Requests that arrive while
+// the same probe runs wait for it. A nil Cache probes every time.
+type Cache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cached
+}
+
+type cached struct {
+	done   chan struct{} // Closed once report is set
+	report Report
+	at     time.Time
+}
+
+// NewCache returns a cache that keeps reports for ttl
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{ttl: ttl, entries: map[string]*cached{}}
+}
+
+// Probe returns the report of a probe of the same pipelines with the same timeout
+// made within the ttl, or probes them as Probe does. The probe is not cancelled
+// when ctx is, since other requests may wait for it.
+func (c *Cache) Probe(ctx context.Context, definitions []*pipeline.Definition, timeout time.Duration, resolve func(name string) bool) Report {
+	if c == nil {
+		return Probe(ctx, definitions, timeout, resolve)
+	}
+	names := make([]string, len(definitions))
+	for i, definition := range definitions {
+		names[i] = definition.Name
+	}
+	key := timeout.String() + " " + strings.Join(names, ",")
+
+	c.mu.Lock()
+	now := time.Now()
+	for k, entry := range c.entries {
+		if !entry.at.IsZero() && now.Sub(entry.at) >= c.ttl {
+			delete(c.entries, k)
+		}
+	}
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cached{done: make(chan struct{})}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		report := Probe(context.WithoutCancel(ctx), definitions, timeout, resolve)
+		c.mu.Lock()
+		entry.report, entry.at = report, time.Now()
+		c.mu.Unlock()
+		close(entry.done)
+	}
+	<-entry.done
+	return entry.report
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/SkySingh04/fractal/config"
+	"github.com/spf13/cobra"
+)
+
+func initCommand() *cobra.Command {
+	var (
+		configFile, source, destination, schedule string
+		interactive, force                        bool
+	)
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create a config file",
+		Long: `Create a config file for a source and a destination. Every field of the two
+integrations is written with its default value, ready to be filled in. With
+--interactive the wizard asks for each field instead.`,
+		Example: `  fractal init --source CSV --destination MongoDB -c pipeline.yaml
+  fractal init --interactive`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := os.Stat(configFile); err == nil && !force {
+				return fmt.Errorf("%s already exists, use --force to overwrite it", configFile)
+			}
+
+			if interactive {
+				_, err := config.SetupConfigInteractively(configFile)
+				return err
+			}
+
+			if source == "" || destination == "" {
+				return errors.New("--source and --destination are required unless --interactive is set")
+			}
+			configuration, err := config.Template(source, destination, schedule)
+			if err != nil {
+				return err
+			}
+			if _, err := config.WriteConfig(configFile, configuration); err != nil {
+				return fmt.Errorf("failed to write %s: %w", configFile, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s, fill in its empty fields and check it with `fractal validate -c %s`\n", configFile, configFile)
+			return nil
+		},
+	}
+	configFlag(cmd, &configFile)
+	cmd.Flags().StringVar(&source, "source", "", "source integration, see `fractal integrations list`")
+	cmd.Flags().StringVar(&destination, "destination", "", "destination integration")
+	cmd.Flags().StringVar(&schedule, "schedule", "", "cron expression, shortcut or interval for the cronjob section")
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "ask for every field with the wizard")
+	cmd.Flags().BoolVar(&force, "force", false, "overwrite an existing config file")
+	cmd.MarkFlagsMutuallyExclusive("interactive", "source")
+	cmd.MarkFlagsMutuallyExclusive("interactive", "destination")
+	return cmd
+}
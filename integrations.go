@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/SkySingh04/fractal/schema"
+	"github.com/spf13/cobra"
+)
+
+func integrationsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "integrations",
+		Short: "Inspect the registered integrations",
+	}
+
+	var asJSON bool
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List every source and destination with its required config fields",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			catalog, err := schema.All()
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				encoded, err := json.MarshalIndent(catalog, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(encoded))
+				return nil
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tKIND\tREQUIRED FIELDS")
+			for _, integration := range append(catalog.Sources, catalog.Destinations...) {
+				var required []string
+				for _, field := range integration.Fields {
+					if field.Required {
+						required = append(required, field.ConfigKey)
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", integration.Name, integration.Kind, strings.Join(required, ", "))
+			}
+			return w.Flush()
+		},
+	}
+	list.Flags().BoolVar(&asJSON, "json", false, "print the full config schemas as JSON")
+
+	cmd.AddCommand(list)
+	return cmd
+}
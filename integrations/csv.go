@@ -10,7 +10,10 @@ import (
 
 	"github.com/SkySingh04/fractal/interfaces"
 	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/SkySingh04/fractal/progress"
 	"github.com/SkySingh04/fractal/registry"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 // ReadCSV reads the content of a CSV file and returns it as a byte slice.
@@ -58,21 +61,26 @@ func WriteCSV(fileName string, data []byte) error {
 
 // CSVSource struct represents the configuration for consuming messages from CSV.
 type CSVSource struct {
-	CSVSourceFileName string `json:"csv_source_file_name"`
+	CSVSourceFileName string `json:"csv_source_file_name" config:"csvsourcefilename" required:"true" description:"Path of the CSV file to read"`
 }
 
 // CSVDestination struct represents the configuration for publishing messages to CSV.
 type CSVDestination struct {
-	CSVDestinationFileName string `json:"csv_destination_file_name"`
+	CSVDestinationFileName string `json:"csv_destination_file_name" config:"csvdestinationfilename" required:"true" description:"Path of the CSV file to write"`
 }
 
 // FetchData connects to CSV, retrieves data, and processes it concurrently.
-func (r CSVSource) FetchData(req interfaces.Request) (interface{}, error) {
-	logger.Infof("Reading data from CSV Source: %s", req.CSVSourceFileName)
+func (r CSVSource) FetchData(req interfaces.Request) (result interface{}, err error) {
+	log := logger.FromContext(req.Context())
+	log.Infof("Reading data from CSV Source: %s", req.CSVSourceFileName)
 
 	if req.CSVSourceFileName == "" {
 		return nil, errors.New("missing CSV source file name")
 	}
+	tracker := progress.FromContext(req.Context())
+	tracker.SetCurrent(req.CSVSourceFileName)
+	ctx, span := opentele.CreateSpan(req.Context(), "csv-read", semconv.FilePath(req.CSVSourceFileName))
+	defer func() { opentele.EndSpan(span, err) }()
 
 	// Create channels for processing pipeline
 	dataChan := make(chan string, bufferSize)
@@ -96,13 +104,19 @@ func (r CSVSource) FetchData(req interfaces.Request) (interface{}, error) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		_, stage := opentele.CreateSpan(ctx, "csv-validate")
+		valid := 0
 		for data := range dataChan {
 			if validData, err := validateCSVData(data); err != nil {
+				stage.RecordError(err)
 				errChan <- err
 			} else {
+				valid++
 				validChan <- validData
 			}
 		}
+		stage.SetAttributes(opentele.Records(valid))
+		stage.End()
 		close(validChan)
 	}()
 
@@ -110,9 +124,14 @@ func (r CSVSource) FetchData(req interfaces.Request) (interface{}, error) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		_, stage := opentele.CreateSpan(ctx, "csv-transform")
+		transformed := 0
 		for validData := range validChan {
+			transformed++
 			transformedChan <- transformCSVData(validData)
 		}
+		stage.SetAttributes(opentele.Records(transformed))
+		stage.End()
 		close(transformedChan)
 	}()
 
@@ -126,6 +145,7 @@ func (r CSVSource) FetchData(req interfaces.Request) (interface{}, error) {
 	var results []string
 	for transformedData := range transformedChan {
 		results = append(results, transformedData)
+		tracker.AddRead(1)
 	}
 
 	// Check for errors
@@ -133,16 +153,21 @@ func (r CSVSource) FetchData(req interfaces.Request) (interface{}, error) {
 		return nil, err
 	}
 
+	span.SetAttributes(opentele.Records(len(results)))
 	return strings.Join(results, "\n"), nil
 }
 
 // SendData writes data to a CSV file concurrently.
-func (r CSVDestination) SendData(data interface{}, req interfaces.Request) error {
-	logger.Infof("Writing data to CSV Destination: %s", req.CSVDestinationFileName)
+func (r CSVDestination) SendData(data interface{}, req interfaces.Request) (err error) {
+	log := logger.FromContext(req.Context())
+	log.Infof("Writing data to CSV Destination: %s", req.CSVDestinationFileName)
 
 	if req.CSVDestinationFileName == "" {
 		return errors.New("missing CSV destination file name")
 	}
+	progress.FromContext(req.Context()).SetCurrent(req.CSVDestinationFileName)
+	_, span := opentele.CreateSpan(req.Context(), "csv-write", semconv.FilePath(req.CSVDestinationFileName))
+	defer func() { opentele.EndSpan(span, err) }()
 
 	// Convert data to a slice of strings for writing
 	lines, ok := data.(string)
@@ -150,6 +175,7 @@ func (r CSVDestination) SendData(data interface{}, req interfaces.Request) error
 		return errors.New("invalid data format for CSV destination")
 	}
 	records := strings.Split(lines, "\n")
+	span.SetAttributes(opentele.Records(len(records)))
 
 	// Write concurrently
 	errChan := make(chan error, 1)
@@ -165,6 +191,15 @@ func (r CSVDestination) SendData(data interface{}, req interfaces.Request) error
 	return nil
 }
 
+// ReadBack reads the CSV file written by SendData
+func (r CSVDestination) ReadBack(req interfaces.Request) (interface{}, error) {
+	data, err := os.ReadFile(req.CSVDestinationFileName)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
 // readCSVConcurrently reads the content of a CSV file and sends records to a channel.
 func readCSVConcurrently(fileName string, out chan<- string, errChan chan<- error) error {
 	file, err := os.Open(fileName)
@@ -208,7 +243,7 @@ func writeCSVConcurrently(fileName string, records []string) error {
 
 // validateCSVData ensures the input data meets the required criteria.
 func validateCSVData(data string) (string, error) {
-	logger.Infof("Validating data: %s", data)
+	logger.Debugf("Validating data: %s", data)
 
 	// Example: Check if data is non-empty
 	if strings.TrimSpace(data) == "" {
@@ -221,7 +256,7 @@ func validateCSVData(data string) (string, error) {
 
 // transformCSVData modifies the input data as per business logic.
 func transformCSVData(data string) string {
-	logger.Infof("Transforming data: %s", data)
+	logger.Debugf("Transforming data: %s", data)
 
 	// Example: Convert data to uppercase (modify as needed)
 	return strings.ToUpper(data)
@@ -232,3 +267,23 @@ func init() {
 	registry.RegisterSource("CSV", CSVSource{})
 	registry.RegisterDestination("CSV", CSVDestination{})
 }
+
+// TestConnection checks that the CSV file can be read
+func (r CSVSource) TestConnection(req interfaces.Request) error {
+	return checkReadable(req.CSVSourceFileName)
+}
+
+// TestConnection checks that the CSV file can be written
+func (r CSVDestination) TestConnection(req interfaces.Request) error {
+	return checkWritable(req.CSVDestinationFileName)
+}
+
+// Datasets names the CSV file read
+func (r CSVSource) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	return fileDataset(req.CSVSourceFileName)
+}
+
+// Datasets names the CSV file written
+func (r CSVDestination) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	return fileDataset(req.CSVDestinationFileName)
+}
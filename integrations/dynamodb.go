@@ -9,10 +9,13 @@ import (
 
 	"github.com/SkySingh04/fractal/interfaces"
 	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/SkySingh04/fractal/progress"
 	"github.com/SkySingh04/fractal/registry"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 // MockDynamoDB is a mock struct for simulating DynamoDB operations.
@@ -46,25 +49,32 @@ func (m *MockDynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemO
 
 // DynamoDBSource represents the configuration for reading data from DynamoDB.
 type DynamoDBSource struct {
-	TableName string `json:"table_name"`
-	Region    string `json:"region"`
+	TableName string `json:"dynamodb_source_table" config:"tablename" required:"true" description:"DynamoDB table to scan"`
+	Region    string `json:"dynamodb_source_region" config:"region" required:"true" default:"us-east-1" description:"AWS region of the table"`
 }
 
 // DynamoDBDestination represents the configuration for writing data to DynamoDB.
 type DynamoDBDestination struct {
-	TableName string `json:"table_name"`
-	Region    string `json:"region"`
+	TableName string `json:"dynamodb_target_table" config:"tablename" required:"true" description:"DynamoDB table to write to"`
+	Region    string `json:"dynamodb_target_region" config:"region" required:"true" default:"us-east-1" description:"AWS region of the table"`
 }
 
 // FetchData retrieves data from the source DynamoDB table in the specified region.
-func (d DynamoDBSource) FetchData(req interfaces.Request) (interface{}, error) {
-	logger.Infof("Connecting to DynamoDB Source: Table=%s, Region=%s", req.DynamoDBSourceTable, req.DynamoDBSourceRegion)
+func (d DynamoDBSource) FetchData(req interfaces.Request) (records interface{}, err error) {
+	log := logger.FromContext(req.Context())
+	log.Infof("Connecting to DynamoDB Source: Table=%s, Region=%s", req.DynamoDBSourceTable, req.DynamoDBSourceRegion)
 
 	// Validate the request
 	if err := validateDynamoDBRequest(req, true); err != nil {
 		return nil, err
 	}
 
+	tracker := progress.FromContext(req.Context())
+	tracker.SetCurrent(req.DynamoDBSourceTable)
+	_, span := opentele.CreateSpan(req.Context(), "dynamodb-scan",
+		semconv.DBSystemDynamoDB, semconv.AWSDynamoDBTableNames(req.DynamoDBSourceTable))
+	defer func() { opentele.EndSpan(span, err) }()
+
 	// Mock DynamoDB client
 	mockDynamoDB := &MockDynamoDB{}
 
@@ -80,7 +90,7 @@ func (d DynamoDBSource) FetchData(req interfaces.Request) (interface{}, error) {
 
 	// Handle empty result
 	if len(result.Items) == 0 {
-		logger.Logf("No data retrieved from DynamoDB table: %s", req.DynamoDBSourceTable)
+		log.Logf("No data retrieved from DynamoDB table: %s", req.DynamoDBSourceTable)
 		return nil, errors.New("no data retrieved from DynamoDB")
 	}
 
@@ -105,20 +115,9 @@ func (d DynamoDBSource) FetchData(req interfaces.Request) (interface{}, error) {
 			// Transform data
 			transformedData := transformDynamoDBData(validatedData)
 
-			// Convert transformed data (map[string]*dynamodb.AttributeValue) to map[string]interface{}
-			interfaceData := make(map[string]interface{})
-			for key, value := range transformedData {
-				if value.S != nil {
-					interfaceData[key] = *value.S
-				} else if value.N != nil {
-					interfaceData[key] = *value.N
-				} else if value.BOOL != nil {
-					interfaceData[key] = *value.BOOL
-				}
-			}
-
 			// Send processed data to the channel
-			dataChannel <- interfaceData
+			dataChannel <- dynamoDBItemToMap(transformedData)
+			tracker.AddRead(1)
 		}(item)
 	}
 
@@ -143,13 +142,15 @@ func (d DynamoDBSource) FetchData(req interfaces.Request) (interface{}, error) {
 	if len(processedData) == 0 {
 		return nil, errors.New("no valid data processed from DynamoDB")
 	}
+	span.SetAttributes(opentele.Records(len(processedData)))
 
 	return processedData, nil
 }
 
 // SendData writes data to the target DynamoDB table in the specified region.
-func (d DynamoDBDestination) SendData(data interface{}, req interfaces.Request) error {
-	logger.Infof("Connecting to DynamoDB Destination: Table=%s, Region=%s", req.DynamoDBTargetTable, req.DynamoDBTargetRegion)
+func (d DynamoDBDestination) SendData(data interface{}, req interfaces.Request) (err error) {
+	log := logger.FromContext(req.Context())
+	log.Infof("Connecting to DynamoDB Destination: Table=%s, Region=%s", req.DynamoDBTargetTable, req.DynamoDBTargetRegion)
 
 	// Validate the request
 	if err := validateDynamoDBRequest(req, false); err != nil {
@@ -171,6 +172,10 @@ func (d DynamoDBDestination) SendData(data interface{}, req interfaces.Request)
 		}
 	}
 
+	_, span := opentele.CreateSpan(req.Context(), "dynamodb-write",
+		semconv.DBSystemDynamoDB, semconv.AWSDynamoDBTableNames(req.DynamoDBTargetTable), opentele.Records(1))
+	defer func() { opentele.EndSpan(span, err) }()
+
 	// Mock DynamoDB client
 	mockDynamoDB := &MockDynamoDB{}
 
@@ -191,10 +196,59 @@ func (d DynamoDBDestination) SendData(data interface{}, req interfaces.Request)
 		return err
 	}
 
-	logger.Infof("Data successfully written to DynamoDB table %s: %v", req.DynamoDBTargetTable, data)
+	log.Infof("Data successfully written to DynamoDB table %s", req.DynamoDBTargetTable)
+	log.Debugf("Data written to DynamoDB: %v", data)
 	return nil
 }
 
+// ReadBack scans the target table, so a run can be verified
+func (d DynamoDBDestination) ReadBack(req interfaces.Request) (items interface{}, err error) {
+	if err := validateDynamoDBRequest(req, false); err != nil {
+		return nil, err
+	}
+	_, span := opentele.CreateSpan(req.Context(), "dynamodb-scan",
+		semconv.DBSystemDynamoDB, semconv.AWSDynamoDBTableNames(req.DynamoDBTargetTable))
+	defer func() { opentele.EndSpan(span, err) }()
+
+	// Mock DynamoDB client
+	mockDynamoDB := &MockDynamoDB{}
+	result, err := mockDynamoDB.Scan(&dynamodb.ScanInput{TableName: aws.String(req.DynamoDBTargetTable)})
+	if err != nil {
+		return nil, err
+	}
+	records := make([]map[string]interface{}, 0, len(result.Items))
+	for _, item := range result.Items {
+		records = append(records, dynamoDBItemToMap(item))
+	}
+	span.SetAttributes(opentele.Records(len(records)))
+	return records, nil
+}
+
+// Datasets names the DynamoDB table read, in the namespace of its region
+func (d DynamoDBSource) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	return []interfaces.Dataset{{Namespace: "dynamodb://" + req.DynamoDBSourceRegion, Name: req.DynamoDBSourceTable}}
+}
+
+// Datasets names the DynamoDB table written, in the namespace of its region
+func (d DynamoDBDestination) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	return []interfaces.Dataset{{Namespace: "dynamodb://" + req.DynamoDBTargetRegion, Name: req.DynamoDBTargetTable}}
+}
+
+// dynamoDBItemToMap converts a map[string]*dynamodb.AttributeValue to a map[string]interface{}
+func dynamoDBItemToMap(item map[string]*dynamodb.AttributeValue) map[string]interface{} {
+	data := make(map[string]interface{})
+	for key, value := range item {
+		if value.S != nil {
+			data[key] = *value.S
+		} else if value.N != nil {
+			data[key] = *value.N
+		} else if value.BOOL != nil {
+			data[key] = *value.BOOL
+		}
+	}
+	return data
+}
+
 // prepareDynamoDBItem converts a map[string]interface{} to a map[string]*dynamodb.AttributeValue
 func prepareDynamoDBItem(data map[string]interface{}) (map[string]*dynamodb.AttributeValue, error) {
 	// Convert the map to a DynamoDB-compatible item
@@ -217,7 +271,7 @@ func prepareDynamoDBItem(data map[string]interface{}) (map[string]*dynamodb.Attr
 
 // validateDynamoDBData ensures the input DynamoDB data meets required criteria.
 func validateDynamoDBData(data map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
-	logger.Infof("Validating DynamoDB data: %v", data)
+	logger.Debugf("Validating DynamoDB data: %v", data)
 
 	// Example: Ensure a specific attribute exists and is not empty
 	if val, ok := data["KeyAttribute"]; !ok || val.S == nil || *val.S == "" {
@@ -229,7 +283,7 @@ func validateDynamoDBData(data map[string]*dynamodb.AttributeValue) (map[string]
 
 // transformDynamoDBData modifies the input DynamoDB data as per business logic.
 func transformDynamoDBData(data map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
-	logger.Infof("Transforming DynamoDB data: %v", data)
+	logger.Debugf("Transforming DynamoDB data: %v", data)
 
 	// Example: Convert a string attribute to uppercase
 	if val, ok := data["KeyAttribute"]; ok && val.S != nil {
@@ -0,0 +1,39 @@
+package integrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkReadable reports whether a local file can be opened for reading
+func checkReadable(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// checkWritable reports whether a local file can be created or overwritten, without
+// changing it
+func checkWritable(path string) error {
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", path)
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		return file.Close()
+	}
+
+	dir := filepath.Dir(path)
+	probe, err := os.CreateTemp(dir, ".fractal-check-*")
+	if err != nil {
+		return fmt.Errorf("cannot create files in %s: %w", dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
@@ -10,27 +10,30 @@ import (
 	"time"
 
 	firebase "firebase.google.com/go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"google.golang.org/api/option"
 
 	"github.com/SkySingh04/fractal/interfaces"
 	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
 	"github.com/SkySingh04/fractal/registry"
 )
 
 type FirebaseSource struct {
-	CredentialFileAddr string `json:"firebase_credential_file"`
-	Collection         string `json:"firebase_collection"`
-	Document           string `json:"firebase_document"`
+	CredentialFileAddr string `json:"firebase_credential_file" config:"credentialfileaddr" required:"true" description:"Path of the service account credentials file"`
+	Collection         string `json:"firebase_collection" config:"collection" required:"true" description:"Firestore collection to read from"`
+	Document           string `json:"firebase_document" config:"document" required:"true" description:"Firestore document to read"`
 }
 
 type FirebaseDestination struct {
-	CredentialFileAddr string `json:"firebase_credential_file"`
-	Collection         string `json:"firebase_collection"`
-	Document           string `json:"firebase_document"`
+	CredentialFileAddr string `json:"firebase_credential_file" config:"credentialfileaddr" required:"true" description:"Path of the service account credentials file"`
+	Collection         string `json:"firebase_collection" config:"collection" required:"true" description:"Firestore collection to write to"`
+	Document           string `json:"firebase_document" config:"document" description:"Name of the document, for logging only"`
 }
 
-func (f FirebaseSource) FetchData(req interfaces.Request) (interface{}, error) {
-	logger.Infof("Connecting to Firebase Source: Collection=%s, Document=%s, using Service Account=%s",
+func (f FirebaseSource) FetchData(req interfaces.Request) (result interface{}, err error) {
+	log := logger.FromContext(req.Context())
+	log.Infof("Connecting to Firebase Source: Collection=%s, Document=%s, using Service Account=%s",
 		req.Collection, req.Document, req.CredentialFileAddr)
 
 	opt := option.WithCredentialsFile(req.CredentialFileAddr)
@@ -44,6 +47,9 @@ func (f FirebaseSource) FetchData(req interfaces.Request) (interface{}, error) {
 		return nil, fmt.Errorf("failed to initialize Firestore client: %w", err)
 	}
 	defer client.Close()
+	ctx, span := opentele.CreateSpan(req.Context(), "firestore-read",
+		semconv.DBSystemKey.String("firestore"), semconv.DBCollectionName(req.Collection), opentele.Records(1))
+	defer func() { opentele.EndSpan(span, err) }()
 
 	dataChan := make(chan map[string]interface{}, 1)
 	errChan := make(chan error, 1)
@@ -52,7 +58,7 @@ func (f FirebaseSource) FetchData(req interfaces.Request) (interface{}, error) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		dsnap, err := client.Collection(req.Collection).Doc(req.Document).Get(context.Background())
+		dsnap, err := client.Collection(req.Collection).Doc(req.Document).Get(ctx)
 		if err != nil {
 			errChan <- fmt.Errorf("failed to fetch document from Firestore: %w", err)
 			return
@@ -72,18 +78,23 @@ func (f FirebaseSource) FetchData(req interfaces.Request) (interface{}, error) {
 
 	select {
 	case data := <-dataChan:
+		_, stage := opentele.CreateSpan(ctx, "firestore-validate")
 		validatedData, err := validateFirebaseData(data)
+		opentele.EndSpan(stage, err)
 		if err != nil {
 			return nil, err
 		}
+		_, stage = opentele.CreateSpan(ctx, "firestore-transform")
+		defer stage.End()
 		return transformFirebaseData(validatedData), nil
 	case err := <-errChan:
 		return nil, err
 	}
 }
 
-func (f FirebaseDestination) SendData(data interface{}, req interfaces.Request) error {
-	logger.Infof("Writing data to Firebase database: Collection=%s, Document=%s", req.Collection, req.Document)
+func (f FirebaseDestination) SendData(data interface{}, req interfaces.Request) (err error) {
+	log := logger.FromContext(req.Context())
+	log.Infof("Writing data to Firebase database: Collection=%s, Document=%s", req.Collection, req.Document)
 
 	opt := option.WithCredentialsFile(req.CredentialFileAddr)
 	app, err := firebase.NewApp(context.Background(), nil, opt)
@@ -96,6 +107,9 @@ func (f FirebaseDestination) SendData(data interface{}, req interfaces.Request)
 		return fmt.Errorf("failed to initialize Firestore client: %w", err)
 	}
 	defer client.Close()
+	ctx, span := opentele.CreateSpan(req.Context(), "firestore-write",
+		semconv.DBSystemKey.String("firestore"), semconv.DBCollectionName(req.Collection), opentele.Records(1))
+	defer func() { opentele.EndSpan(span, err) }()
 
 	var post map[string]interface{}
 	if err := convertToMap(data, &post); err != nil {
@@ -108,7 +122,7 @@ func (f FirebaseDestination) SendData(data interface{}, req interfaces.Request)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		_, err := client.Collection(req.Collection).NewDoc().Create(context.Background(), post)
+		_, err := client.Collection(req.Collection).NewDoc().Create(ctx, post)
 		if err != nil {
 			errChan <- fmt.Errorf("error writing to Firestore: %w", err)
 		}
@@ -121,7 +135,7 @@ func (f FirebaseDestination) SendData(data interface{}, req interfaces.Request)
 	case err := <-errChan:
 		return err
 	default:
-		logger.Infof("Successfully written data to Firestore: Collection=%s, Document=%s", req.Collection, req.Document)
+		log.Infof("Successfully written data to Firestore: Collection=%s, Document=%s", req.Collection, req.Document)
 		return nil
 	}
 }
@@ -139,7 +153,7 @@ func convertToMap(data interface{}, result *map[string]interface{}) error {
 }
 
 func validateFirebaseData(data map[string]interface{}) (map[string]interface{}, error) {
-	logger.Infof("Validating Firebase data: %v", data)
+	logger.Debugf("Validating Firebase data: %v", data)
 	message, ok := data["data"].(string)
 	if !ok || strings.TrimSpace(message) == "" {
 		return nil, errors.New("invalid or missing 'data' field")
@@ -148,7 +162,7 @@ func validateFirebaseData(data map[string]interface{}) (map[string]interface{},
 }
 
 func transformFirebaseData(data map[string]interface{}) map[string]interface{} {
-	logger.Infof("Transforming Firebase data: %v", data)
+	logger.Debugf("Transforming Firebase data: %v", data)
 	if message, ok := data["data"].(string); ok {
 		data["data"] = strings.ToUpper(message)
 	}
@@ -160,3 +174,23 @@ func init() {
 	registry.RegisterSource("Firebase", FirebaseSource{})
 	registry.RegisterDestination("Firebase", FirebaseDestination{})
 }
+
+// TestConnection checks that the service account credentials can be read
+func (f FirebaseSource) TestConnection(req interfaces.Request) error {
+	return checkReadable(req.CredentialFileAddr)
+}
+
+// TestConnection checks that the service account credentials can be read
+func (f FirebaseDestination) TestConnection(req interfaces.Request) error {
+	return checkReadable(req.CredentialFileAddr)
+}
+
+// Datasets names the Firestore document read, as collection/document
+func (f FirebaseSource) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	return []interfaces.Dataset{{Namespace: "firestore", Name: req.Collection + "/" + req.Document}}
+}
+
+// Datasets names the Firestore collection written
+func (f FirebaseDestination) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	return []interfaces.Dataset{{Namespace: "firestore", Name: req.Collection}}
+}
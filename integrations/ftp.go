@@ -2,6 +2,7 @@ package integrations
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -10,40 +11,45 @@ import (
 
 	"github.com/SkySingh04/fractal/interfaces"
 	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
 	"github.com/SkySingh04/fractal/registry"
 	"github.com/jlaffaye/ftp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 // FTPSource implements the DataSource interface
 type FTPSource struct {
-	URL         string `json:"url"`
-	User        string `json:"user"`
-	Password    string `json:"password"`
-	FTPFILEPATH string `json:"file_path"`
+	URL         string `json:"ftp_url" config:"url" required:"true" endpoint:"url" description:"FTP server URL, for example ftp://host:port"`
+	User        string `json:"ftp_user" config:"user" required:"true" description:"FTP user name"`
+	Password    string `json:"ftp_password" config:"password" required:"true" secret:"true" description:"FTP password"`
+	FTPFILEPATH string `json:"ftp_file_path" config:"filepath" required:"true" description:"Remote path of the file to read"`
 }
 
 // FTPDestination implements the DataDestination interface
 type FTPDestination struct {
-	URL         string `json:"url"`
-	User        string `json:"user"`
-	Password    string `json:"password"`
-	FTPFILEPATH string `json:"file_path"`
+	URL         string `json:"ftp_url" config:"url" required:"true" endpoint:"url" description:"FTP server URL, for example ftp://host:port"`
+	User        string `json:"ftp_user" config:"user" required:"true" description:"FTP user name"`
+	Password    string `json:"ftp_password" config:"password" required:"true" secret:"true" description:"FTP password"`
+	FTPFILEPATH string `json:"ftp_file_path" config:"filepath" required:"true" description:"Remote path of the file to write"`
 }
 
 // FetchData fetches data from an FTP server
-func (f FTPSource) FetchData(req interfaces.Request) (interface{}, error) {
+func (f FTPSource) FetchData(req interfaces.Request) (result interface{}, err error) {
+	log := logger.FromContext(req.Context())
 	if err := validateFTPRequest(req, true); err != nil {
 		return nil, err
 	}
-	logger.Infof("Connecting to FTP server at %s...", req.FTPURL)
+	log.Infof("Connecting to FTP server at %s...", req.FTPURL)
 
-	conn, err := dialFTP(req.FTPURL, req.FTPUser, req.FTPPassword)
+	conn, err := dialFTP(req.Context(), req.FTPURL, req.FTPUser, req.FTPPassword)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Quit()
+	_, span := opentele.CreateSpan(req.Context(), "ftp-read", semconv.FilePath(req.FTPFILEPATH))
+	defer func() { opentele.EndSpan(span, err) }()
 
-	logger.Infof("Downloading file from FTP: %s", req.FTPFILEPATH)
+	log.Infof("Downloading file from FTP: %s", req.FTPFILEPATH)
 	resp, err := conn.Retr(req.FTPFILEPATH)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve file from FTP: %w", err)
@@ -55,24 +61,27 @@ func (f FTPSource) FetchData(req interfaces.Request) (interface{}, error) {
 		return nil, fmt.Errorf("failed to read data from FTP response: %w", err)
 	}
 
-	logger.Infof("Successfully fetched data from FTP.")
+	log.Infof("Successfully fetched data from FTP.")
 	return data, nil
 }
 
 // SendData sends data to an FTP server
-func (f FTPDestination) SendData(data interface{}, req interfaces.Request) error {
+func (f FTPDestination) SendData(data interface{}, req interfaces.Request) (err error) {
+	log := logger.FromContext(req.Context())
 	if err := validateFTPRequest(req, false); err != nil {
 		return err
 	}
-	logger.Infof("Connecting to FTP server at %s...", req.FTPURL)
+	log.Infof("Connecting to FTP server at %s...", req.FTPURL)
 
-	conn, err := dialFTP(req.FTPURL, req.FTPUser, req.FTPPassword)
+	conn, err := dialFTP(req.Context(), req.FTPURL, req.FTPUser, req.FTPPassword)
 	if err != nil {
 		return err
 	}
 	defer conn.Quit()
+	_, span := opentele.CreateSpan(req.Context(), "ftp-write", semconv.FilePath(req.FTPFILEPATH))
+	defer func() { opentele.EndSpan(span, err) }()
 
-	logger.Infof("Uploading file to FTP: %s", req.FTPFILEPATH)
+	log.Infof("Uploading file to FTP: %s", req.FTPFILEPATH)
 	dataBytes, ok := data.([]byte)
 	if !ok {
 		return fmt.Errorf("invalid data format; expected []byte, got %T", data)
@@ -83,16 +92,19 @@ func (f FTPDestination) SendData(data interface{}, req interfaces.Request) error
 		return fmt.Errorf("failed to store file to FTP: %w", err)
 	}
 
-	logger.Infof("Successfully sent data to FTP.")
+	log.Infof("Successfully sent data to FTP.")
 	return nil
 }
 
 // dialFTP creates and authenticates an FTP connection
-func dialFTP(url, user, password string) (*ftp.ServerConn, error) {
+func dialFTP(ctx context.Context, url, user, password string) (conn *ftp.ServerConn, err error) {
+	_, span := opentele.CreateSpan(ctx, "ftp-connect")
+	defer func() { opentele.EndSpan(span, err) }()
+
 	// Remove "ftp://" prefix if present
 	url = strings.TrimPrefix(url, "ftp://")
 
-	conn, err := ftp.Dial(url, ftp.DialWithTimeout(10*time.Second))
+	conn, err = ftp.Dial(url, ftp.DialWithTimeout(10*time.Second))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to FTP server: %w", err)
 	}
@@ -136,3 +148,33 @@ func validateFTPRequest(req interfaces.Request, isSource bool) error {
 	}
 	return nil
 }
+
+// TestConnection checks that the FTP server accepts the user and password
+func (f FTPSource) TestConnection(req interfaces.Request) error {
+	return loginFTP(req)
+}
+
+// TestConnection checks that the FTP server accepts the user and password
+func (f FTPDestination) TestConnection(req interfaces.Request) error {
+	return loginFTP(req)
+}
+
+// Datasets names the remote file read, by its path on the FTP server
+func (f FTPSource) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	namespace, _ := endpointNamespace("ftp", req.FTPURL, "21")
+	return []interfaces.Dataset{{Namespace: namespace, Name: req.FTPFILEPATH}}
+}
+
+// Datasets names the remote file written, by its path on the FTP server
+func (f FTPDestination) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	namespace, _ := endpointNamespace("ftp", req.FTPURL, "21")
+	return []interfaces.Dataset{{Namespace: namespace, Name: req.FTPFILEPATH}}
+}
+
+func loginFTP(req interfaces.Request) error {
+	conn, err := dialFTP(req.Context(), req.FTPURL, req.FTPUser, req.FTPPassword)
+	if err != nil {
+		return err
+	}
+	return conn.Quit()
+}
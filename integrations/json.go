@@ -8,34 +8,44 @@ import (
 
 	"github.com/SkySingh04/fractal/interfaces"
 	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
 	"github.com/SkySingh04/fractal/registry"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 type JSONSource struct {
-	Data string `json:"json_source_data"`
+	Data string `json:"json_source_data" config:"data" required:"true" description:"Raw JSON document to migrate"`
 }
 
 type JSONDestination struct {
-	Filename string `json:"json_output_filename"`
+	Filename string `json:"json_output_filename" config:"filename" required:"true" description:"Path of the JSON file to write"`
 }
 
 // FetchData retrieves and processes JSON source data
-func (j JSONSource) FetchData(req interfaces.Request) (interface{}, error) {
+func (j JSONSource) FetchData(req interfaces.Request) (result interface{}, err error) {
+	log := logger.FromContext(req.Context())
 	if req.JSONSourceData == "" {
 		return nil, errors.New("missing JSON source data")
 	}
 
+	ctx, span := opentele.CreateSpan(req.Context(), "json-read")
+	defer func() { opentele.EndSpan(span, err) }()
+
 	// Validate and sanitize JSON data
+	_, stage := opentele.CreateSpan(ctx, "json-validate")
 	validatedData, err := ValidateJSONData(req.JSONSourceData)
+	opentele.EndSpan(stage, err)
 	if err != nil {
-		logger.Fatalf("Validation error: %v", err)
+		log.Errorf("Validation error: %v", err)
 		return nil, err
 	}
 
 	// Transform JSON data
+	_, stage = opentele.CreateSpan(ctx, "json-transform")
 	transformedData, err := transformJSONData(validatedData)
+	opentele.EndSpan(stage, err)
 	if err != nil {
-		logger.Fatalf("Transformation error: %v", err)
+		log.Errorf("Transformation error: %v", err)
 		return nil, err
 	}
 
@@ -43,22 +53,26 @@ func (j JSONSource) FetchData(req interfaces.Request) (interface{}, error) {
 }
 
 // SendData writes JSON data to a destination file
-func (j JSONDestination) SendData(data interface{}, req interfaces.Request) error {
+func (j JSONDestination) SendData(data interface{}, req interfaces.Request) (err error) {
+	log := logger.FromContext(req.Context())
 	if req.JSONOutputFilename == "" {
 		return errors.New("missing JSON destination filename")
 	}
 
-	logger.Infof("Sending data to JSON destination...")
-	logger.Infof("Data: %v", data)
+	log.Infof("Sending data to JSON destination...")
+	log.Debugf("Data: %v", data)
+
+	_, span := opentele.CreateSpan(req.Context(), "json-write", semconv.FilePath(req.JSONOutputFilename))
+	defer func() { opentele.EndSpan(span, err) }()
 
 	// Write data to a JSON file
-	err := writeJSONFile(req.JSONOutputFilename, data)
+	err = writeJSONFile(req.JSONOutputFilename, data)
 	if err != nil {
-		logger.Fatalf("Error writing data to JSON file: %v", err)
+		log.Errorf("Error writing data to JSON file: %v", err)
 		return err
 	}
 
-	logger.Infof("Data successfully written to %s", req.JSONOutputFilename)
+	log.Infof("Data successfully written to %s", req.JSONOutputFilename)
 	return nil
 }
 
@@ -76,7 +90,7 @@ func ValidateJSONData(data string) (interface{}, error) {
 
 	// Sanitize the JSON data
 	sanitizedData := sanitizeJSONData(jsonData)
-	logger.Infof("Validation and sanitization successful for JSON data")
+	logger.Debugf("Validation and sanitization successful for JSON data")
 	return sanitizedData, nil
 }
 
@@ -108,6 +122,19 @@ func sanitizeJSONData(data interface{}) interface{} {
 	}
 }
 
+// ReadBack reads the JSON file written by SendData
+func (j JSONDestination) ReadBack(req interfaces.Request) (interface{}, error) {
+	data, err := os.ReadFile(req.JSONOutputFilename)
+	if err != nil {
+		return nil, err
+	}
+	var written interface{}
+	if err := json.Unmarshal(data, &written); err != nil {
+		return nil, err
+	}
+	return written, nil
+}
+
 // writeJSONFile writes the provided data to a JSON file with proper formatting
 func writeJSONFile(filename string, data interface{}) error {
 	file, err := os.Create(filename)
@@ -134,6 +161,16 @@ func transformJSONData(data interface{}) (interface{}, error) {
 	}
 
 	// If no transformation is required, return data as is
-	logger.Infof("No transformation applied to JSON data")
+	logger.Debugf("No transformation applied to JSON data")
 	return data, nil
 }
+
+// TestConnection checks that the JSON file can be written
+func (j JSONDestination) TestConnection(req interfaces.Request) error {
+	return checkWritable(req.JSONOutputFilename)
+}
+
+// Datasets names the JSON file written. The source has none, its data is inline.
+func (j JSONDestination) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	return fileDataset(req.JSONOutputFilename)
+}
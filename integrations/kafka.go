@@ -9,29 +9,35 @@ import (
 
 	"github.com/SkySingh04/fractal/interfaces"
 	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/SkySingh04/fractal/progress"
 	"github.com/SkySingh04/fractal/registry"
 	"github.com/segmentio/kafka-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 // KafkaSource struct represents the configuration for consuming messages from Kafka.
 type KafkaSource struct {
-	URL   string `json:"consumer_url"`
-	Topic string `json:"consumer_topic"`
+	URL   string `json:"consumer_url" config:"url" required:"true" endpoint:"url" description:"Comma separated list of Kafka brokers"`
+	Topic string `json:"consumer_topic" config:"topic" required:"true" description:"Topic to consume from"`
 }
 
 // KafkaDestination struct represents the configuration for publishing messages to Kafka.
 type KafkaDestination struct {
-	URL   string `json:"producer_url"`
-	Topic string `json:"producer_topic"`
+	URL   string `json:"producer_url" config:"url" required:"true" endpoint:"url" description:"Comma separated list of Kafka brokers"`
+	Topic string `json:"producer_topic" config:"topic" required:"true" description:"Topic to publish to"`
 }
 
 // FetchData connects to Kafka, retrieves data, and processes it concurrently.
 func (k KafkaSource) FetchData(req interfaces.Request) (interface{}, error) {
-	logger.Infof("Connecting to Kafka Source: URL=%s, Topic=%s", req.ConsumerURL, req.ConsumerTopic)
+	log := logger.FromContext(req.Context())
+	log.Infof("Connecting to Kafka Source: URL=%s, Topic=%s", req.ConsumerURL, req.ConsumerTopic)
 
 	if req.ConsumerURL == "" || req.ConsumerTopic == "" {
 		return nil, errors.New("missing Kafka source details")
 	}
+	tracker := progress.FromContext(req.Context())
+	tracker.SetCurrent(req.ConsumerTopic)
 
 	// Create Kafka reader
 	reader := kafka.NewReader(kafka.ReaderConfig{
@@ -51,21 +57,38 @@ func (k KafkaSource) FetchData(req interfaces.Request) (interface{}, error) {
 		for {
 			message, err := reader.ReadMessage(context.Background())
 			if err != nil {
-				logger.Errorf("Error reading message from Kafka: %v", err)
+				tracker.Fail(progress.ErrorRead, err)
+				log.Errorf("Error reading message from Kafka: %v", err)
 				continue
 			}
 
-			logger.Infof("Message received from Kafka: %s", message.Value)
+			log.Debugf("Message received from Kafka: %s", message.Value)
+			tracker.SetLag(reader.Stats().Lag)
+
+			// Continue the trace of the producer when the message carries one
+			ctx, span := opentele.CreateSpan(opentele.Extract(req.Context(), kafkaHeaders{&message.Headers}), "kafka-process",
+				semconv.MessagingSystemKafka,
+				semconv.MessagingDestinationName(message.Topic),
+				semconv.MessagingKafkaMessageOffset(int(message.Offset)),
+			)
 
 			// Validation
+			_, stage := opentele.CreateSpan(ctx, "kafka-validate")
 			validatedData, err := validateKafkaData(message.Value)
+			opentele.EndSpan(stage, err)
 			if err != nil {
-				logger.Errorf("Validation failed for message: %s, Error: %s", message.Value, err)
+				tracker.Quarantine(progress.ErrorValidation, err)
+				opentele.EndSpan(span, err)
+				log.Errorf("Validation failed for message: %s, Error: %s", message.Value, err)
 				continue // Skip invalid message
 			}
 
 			// Transformation
+			_, stage = opentele.CreateSpan(ctx, "kafka-transform")
 			transformedData := transformKafkaData(validatedData)
+			stage.End()
+			span.End()
+			tracker.AddRead(1)
 
 			// Send processed data to channel for further handling
 			wg.Add(1)
@@ -92,12 +115,20 @@ func (k KafkaSource) FetchData(req interfaces.Request) (interface{}, error) {
 }
 
 // SendData connects to Kafka and publishes data to the specified topic concurrently.
-func (k KafkaDestination) SendData(data interface{}, req interfaces.Request) error {
-	logger.Infof("Connecting to Kafka Destination: URL=%s, Topic=%s", req.ProducerURL, req.ProducerTopic)
+func (k KafkaDestination) SendData(data interface{}, req interfaces.Request) (err error) {
+	log := logger.FromContext(req.Context())
+	log.Infof("Connecting to Kafka Destination: URL=%s, Topic=%s", req.ProducerURL, req.ProducerTopic)
 
 	if req.ProducerURL == "" || req.ProducerTopic == "" {
 		return errors.New("missing Kafka target details")
 	}
+	progress.FromContext(req.Context()).SetCurrent(req.ProducerTopic)
+	ctx, span := opentele.CreateSpan(req.Context(), "kafka-publish",
+		semconv.MessagingSystemKafka,
+		semconv.MessagingDestinationName(req.ProducerTopic),
+		opentele.Records(1),
+	)
+	defer func() { opentele.EndSpan(span, err) }()
 
 	// Create Kafka writer
 	writer := kafka.NewWriter(kafka.WriterConfig{
@@ -125,12 +156,12 @@ func (k KafkaDestination) SendData(data interface{}, req interfaces.Request) err
 	go func() {
 		defer wg.Done()
 
-		// Publish message
-		err := writer.WriteMessages(context.Background(),
-			kafka.Message{
-				Value: []byte(message),
-			},
-		)
+		// Publish message, with the trace context for consumers to continue
+		msg := kafka.Message{
+			Value: []byte(message),
+		}
+		opentele.Inject(ctx, kafkaHeaders{&msg.Headers})
+		err := writer.WriteMessages(ctx, msg)
 		if err != nil {
 			errCh <- err
 		}
@@ -145,7 +176,7 @@ func (k KafkaDestination) SendData(data interface{}, req interfaces.Request) err
 		return err
 	}
 
-	logger.Infof("Message sent to Kafka topic %s: %s", req.ProducerTopic, message)
+	log.Debugf("Message sent to Kafka topic %s: %s", req.ProducerTopic, message)
 	return nil
 }
 
@@ -157,7 +188,7 @@ func init() {
 
 // validateKafkaData ensures the input data meets the required criteria.
 func validateKafkaData(data []byte) ([]byte, error) {
-	logger.Infof("Validating data: %s", data)
+	logger.Debugf("Validating data: %s", data)
 
 	// Example: Check if data is non-empty
 	if len(data) == 0 {
@@ -170,9 +201,52 @@ func validateKafkaData(data []byte) ([]byte, error) {
 
 // transformKafkaData modifies the input data as per business logic.
 func transformKafkaData(data []byte) []byte {
-	logger.Infof("Transforming data: %s", data)
+	logger.Debugf("Transforming data: %s", data)
 
 	// Example: Convert data to uppercase (modify as needed)
 	transformed := []byte(strings.ToUpper(string(data)))
 	return transformed
 }
+
+// TestConnection checks that a broker of the list answers a metadata request
+func (k KafkaSource) TestConnection(req interfaces.Request) error {
+	return dialKafka(req.Context(), req.ConsumerURL)
+}
+
+// TestConnection checks that a broker of the list answers a metadata request
+func (k KafkaDestination) TestConnection(req interfaces.Request) error {
+	return dialKafka(req.Context(), req.ProducerURL)
+}
+
+// Datasets names the topic consumed, in the namespace of the first broker
+func (k KafkaSource) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	namespace, _ := endpointNamespace("kafka", req.ConsumerURL, "9092")
+	return []interfaces.Dataset{{Namespace: namespace, Name: req.ConsumerTopic}}
+}
+
+// Datasets names the topic published to, in the namespace of the first broker
+func (k KafkaDestination) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	namespace, _ := endpointNamespace("kafka", req.ProducerURL, "9092")
+	return []interfaces.Dataset{{Namespace: namespace, Name: req.ProducerTopic}}
+}
+
+// dialKafka connects to the brokers of a comma separated list until one answers, and
+// fetches the brokers of the cluster from it
+func dialKafka(ctx context.Context, brokers string) error {
+	var err error
+	for _, broker := range strings.Split(brokers, ",") {
+		var conn *kafka.Conn
+		if conn, err = kafka.DialContext(ctx, "tcp", strings.TrimSpace(broker)); err != nil {
+			continue
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		_, err = conn.Brokers()
+		conn.Close()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package integrations
+
+import (
+	"net"
+	"path/filepath"
+	"sort"
+
+	"github.com/SkySingh04/fractal/dsn"
+	"github.com/SkySingh04/fractal/interfaces"
+)
+
+// endpointNamespace returns the lineage namespace of the server that a URL, a key=value
+// connection string ("host=db port=5432 dbname=app") or a comma separated host:port
+// list points to, as scheme://host:port without credentials, and the database it names.
+// The first of several hosts stands for the cluster.
+func endpointNamespace(scheme, value, defaultPort string) (namespace, database string) {
+	endpoint := dsn.Parse(value)
+	address := endpoint.Addresses[0]
+	host, port := address.Host, address.Port
+	if port == "" {
+		port = defaultPort
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	return scheme + "://" + host, endpoint.Database()
+}
+
+// fileDataset names a local file by its absolute path
+func fileDataset(path string) []interfaces.Dataset {
+	if path == "" {
+		return nil
+	}
+	if absolute, err := filepath.Abs(path); err == nil {
+		path = absolute
+	}
+	return []interfaces.Dataset{{Namespace: "file", Name: path}}
+}
+
+// tableDatasets names the tables of data holding rows by table name, as prefix.table
+func tableDatasets(namespace, prefix string, data interface{}) []interfaces.Dataset {
+	tables, ok := data.(map[string][]map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var names []string
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var datasets []interfaces.Dataset
+	for _, name := range names {
+		datasets = append(datasets, interfaces.Dataset{Namespace: namespace, Name: prefix + "." + name, Table: name})
+	}
+	return datasets
+}
@@ -4,54 +4,61 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"sync"
 
 	"github.com/SkySingh04/fractal/interfaces"
 	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/SkySingh04/fractal/progress"
 	"github.com/SkySingh04/fractal/registry"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 const bufferSize = 10 // Buffer size for channels
 
 // MongoDBSource struct represents the configuration for consuming messages from MongoDB.
 type MongoDBSource struct {
-	ConnString string `json:"source_mongodb_conn_string"`
-	Database   string `json:"source_mongodb_database"`
-	Collection string `json:"source_mongodb_collection"`
+	ConnString string `json:"source_mongodb_conn_string" config:"connstring" required:"true" endpoint:"url" secret:"true" description:"MongoDB connection string of the source cluster"`
+	Database   string `json:"source_mongodb_database" config:"database" required:"true" endpoint:"database" description:"Database to read from"`
+	Collection string `json:"source_mongodb_collection" config:"collection" required:"true" description:"Collection to read from"`
 }
 
 // MongoDBDestination struct represents the configuration for publishing messages to MongoDB.
 type MongoDBDestination struct {
-	ConnString string `json:"target_mongodb_conn_string"`
-	Database   string `json:"target_mongodb_database"`
-	Collection string `json:"target_mongodb_collection"`
+	ConnString string `json:"target_mongodb_conn_string" config:"connstring" required:"true" endpoint:"url" secret:"true" description:"MongoDB connection string of the target cluster"`
+	Database   string `json:"target_mongodb_database" config:"database" required:"true" endpoint:"database" description:"Database to write to"`
+	Collection string `json:"target_mongodb_collection" config:"collection" required:"true" description:"Collection to write to"`
 }
 
 // FetchData connects to MongoDB, retrieves data, and returns it.
-func (m MongoDBSource) FetchData(req interfaces.Request) (interface{}, error) {
+func (m MongoDBSource) FetchData(req interfaces.Request) (result interface{}, err error) {
+	log := logger.FromContext(req.Context())
 	if req.SourceMongoDBConnString == "" || req.SourceMongoDBDatabase == "" || req.SourceMongoDBCollection == "" {
 		return nil, errors.New("missing MongoDB source connection details")
 	}
-	logger.Infof("Connecting to MongoDB source...")
+	log.Infof("Connecting to MongoDB source...")
+	tracker := progress.FromContext(req.Context())
+	tracker.SetCurrent(req.SourceMongoDBDatabase + "." + req.SourceMongoDBCollection)
 
-	clientOptions := options.Client().ApplyURI(req.SourceMongoDBConnString)
-	client, err := mongo.Connect(context.TODO(), clientOptions)
+	client, err := connectMongoDB(req.Context(), req.SourceMongoDBConnString)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
-		if err = client.Disconnect(context.TODO()); err != nil {
-			log.Fatal(err)
+		if err := client.Disconnect(context.TODO()); err != nil {
+			log.Errorf("Error disconnecting MongoDB client: %v", err)
 		}
 	}()
 
+	ctx, span := opentele.CreateSpan(req.Context(), "mongodb-read", mongoDBAttributes(req.SourceMongoDBDatabase, req.SourceMongoDBCollection)...)
+	defer func() { opentele.EndSpan(span, err) }()
 	collection := client.Database(req.SourceMongoDBDatabase).Collection(req.SourceMongoDBCollection)
 
-	cursor, err := collection.Find(context.TODO(), bson.D{})
+	cursor, err := collection.Find(ctx, bson.D{})
 	if err != nil {
 		return nil, err
 	}
@@ -80,6 +87,7 @@ func (m MongoDBSource) FetchData(req interfaces.Request) (interface{}, error) {
 			fetchError = err
 			break
 		}
+		tracker.AddRead(1)
 
 		wg.Add(1)
 		go func(d bson.M) {
@@ -98,45 +106,84 @@ func (m MongoDBSource) FetchData(req interfaces.Request) (interface{}, error) {
 		return nil, err
 	}
 
-	logger.Infof("Data fetched from MongoDB: %d documents", len(allResults))
+	span.SetAttributes(opentele.Records(len(allResults)))
+	log.Infof("Data fetched from MongoDB: %d documents", len(allResults))
 	return allResults, nil
 }
 
+// ReadBack reads the documents of the target collection, so a run can be verified
+func (m MongoDBDestination) ReadBack(req interfaces.Request) (documents interface{}, err error) {
+	if req.TargetMongoDBConnString == "" || req.TargetMongoDBDatabase == "" || req.TargetMongoDBCollection == "" {
+		return nil, errors.New("missing MongoDB target connection details")
+	}
+	client, err := connectMongoDB(req.Context(), req.TargetMongoDBConnString)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := client.Disconnect(context.TODO()); err != nil {
+			logger.FromContext(req.Context()).Errorf("Error disconnecting MongoDB client: %v", err)
+		}
+	}()
+
+	ctx, span := opentele.CreateSpan(req.Context(), "mongodb-read", mongoDBAttributes(req.TargetMongoDBDatabase, req.TargetMongoDBCollection)...)
+	defer func() { opentele.EndSpan(span, err) }()
+	cursor, err := client.Database(req.TargetMongoDBDatabase).Collection(req.TargetMongoDBCollection).Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	var results []bson.M
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	span.SetAttributes(opentele.Records(len(results)))
+	return results, nil
+}
+
 // SendData connects to MongoDB and publishes data to the specified collection.
-func (m MongoDBDestination) SendData(data interface{}, req interfaces.Request) error {
+func (m MongoDBDestination) SendData(data interface{}, req interfaces.Request) (err error) {
+	log := logger.FromContext(req.Context())
 	if req.TargetMongoDBConnString == "" || req.TargetMongoDBDatabase == "" || req.TargetMongoDBCollection == "" {
 		return errors.New("missing MongoDB target connection details")
 	}
-	logger.Infof("Connecting to MongoDB destination...")
+	log.Infof("Connecting to MongoDB destination...")
+	tracker := progress.FromContext(req.Context())
+	tracker.SetCurrent(req.TargetMongoDBDatabase + "." + req.TargetMongoDBCollection)
 
 	// Initialize MongoDB client
-	clientOptions := options.Client().ApplyURI(req.TargetMongoDBConnString)
-	client, err := mongo.Connect(context.TODO(), clientOptions)
+	client, err := connectMongoDB(req.Context(), req.TargetMongoDBConnString)
 	if err != nil {
 		return fmt.Errorf("failed to connect to MongoDB: %w", err)
 	}
 	defer func() {
-		if err = client.Disconnect(context.TODO()); err != nil {
-			logger.Errorf("Error disconnecting MongoDB client: %v", err)
+		if err := client.Disconnect(context.TODO()); err != nil {
+			log.Errorf("Error disconnecting MongoDB client: %v", err)
 		}
 	}()
 
 	// Transform data to BSON
+	_, stage := opentele.CreateSpan(req.Context(), "mongodb-transform")
 	bsonData, err := TransformDataToBSON(data)
+	stage.SetAttributes(opentele.Records(len(bsonData)))
+	opentele.EndSpan(stage, err)
 	if err != nil {
 		return fmt.Errorf("data transformation failed: %w", err)
 	}
 
+	ctx, span := opentele.CreateSpan(req.Context(), "mongodb-write", append(mongoDBAttributes(req.TargetMongoDBDatabase, req.TargetMongoDBCollection), opentele.Records(len(bsonData)))...)
+	defer func() { opentele.EndSpan(span, err) }()
+
 	// Access database and collection
 	collection := client.Database(req.TargetMongoDBDatabase).Collection(req.TargetMongoDBCollection)
 
 	// Insert data into MongoDB
 	if len(bsonData) == 1 {
 		// Insert a single document
-		_, err = collection.InsertOne(context.TODO(), bsonData[0])
+		_, err = collection.InsertOne(ctx, bsonData[0])
 		if err != nil {
 			return fmt.Errorf("failed to insert document: %w", err)
 		}
+		tracker.AddWritten(1)
 
 	} else {
 		// Insert multiple documents
@@ -144,11 +191,12 @@ func (m MongoDBDestination) SendData(data interface{}, req interfaces.Request) e
 		for i, doc := range bsonData {
 			docs[i] = doc
 		}
-		_, err = collection.InsertMany(context.TODO(), docs)
+		_, err = collection.InsertMany(ctx, docs)
 		if err != nil {
 			return fmt.Errorf("failed to insert documents: %w", err)
 		}
-		logger.Infof("Successfully inserted %d documents into MongoDB collection %s", len(bsonData), req.TargetMongoDBCollection)
+		tracker.AddWritten(len(docs))
+		log.Infof("Successfully inserted %d documents into MongoDB collection %s", len(bsonData), req.TargetMongoDBCollection)
 	}
 
 	return nil
@@ -176,3 +224,55 @@ func TransformDataToBSON(data interface{}) ([]bson.M, error) {
 		return nil, fmt.Errorf("unsupported data format: %T", v)
 	}
 }
+
+// TestConnection checks that the source cluster accepts connections
+func (m MongoDBSource) TestConnection(req interfaces.Request) error {
+	return pingMongoDB(req.Context(), req.SourceMongoDBConnString)
+}
+
+// TestConnection checks that the target cluster accepts connections
+func (m MongoDBDestination) TestConnection(req interfaces.Request) error {
+	return pingMongoDB(req.Context(), req.TargetMongoDBConnString)
+}
+
+// Datasets names the collection read as database.collection
+func (m MongoDBSource) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	namespace, _ := endpointNamespace("mongodb", req.SourceMongoDBConnString, "27017")
+	return []interfaces.Dataset{{Namespace: namespace, Name: req.SourceMongoDBDatabase + "." + req.SourceMongoDBCollection}}
+}
+
+// Datasets names the collection written as database.collection
+func (m MongoDBDestination) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	namespace, _ := endpointNamespace("mongodb", req.TargetMongoDBConnString, "27017")
+	return []interfaces.Dataset{{Namespace: namespace, Name: req.TargetMongoDBDatabase + "." + req.TargetMongoDBCollection}}
+}
+
+// pingMongoDB connects to a MongoDB cluster and pings it
+func pingMongoDB(ctx context.Context, connString string) error {
+	client, err := connectMongoDB(ctx, connString)
+	if err != nil {
+		return err
+	}
+	return client.Disconnect(context.Background())
+}
+
+// connectMongoDB connects to a MongoDB cluster and checks that it answers
+func connectMongoDB(ctx context.Context, connString string) (client *mongo.Client, err error) {
+	ctx, span := opentele.CreateSpan(ctx, "mongodb-connect", semconv.DBSystemMongoDB)
+	defer func() { opentele.EndSpan(span, err) }()
+
+	client, err = mongo.Connect(ctx, options.Client().ApplyURI(connString))
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	return client, nil
+}
+
+// mongoDBAttributes describe the collection a span reads or writes
+func mongoDBAttributes(database, collection string) []attribute.KeyValue {
+	return []attribute.KeyValue{semconv.DBSystemMongoDB, semconv.DBNamespace(database), semconv.DBCollectionName(collection)}
+}
@@ -1,48 +1,50 @@
 package integrations
 
 import (
+	"context"
 	"errors"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/SkySingh04/fractal/interfaces"
 	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/SkySingh04/fractal/progress"
 	"github.com/SkySingh04/fractal/registry"
 	"github.com/streadway/amqp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 // RabbitMQSource struct represents the configuration for consuming messages from RabbitMQ.
 type RabbitMQSource struct {
-	URL       string `json:"rabbitmq_input_url"`
-	QueueName string `json:"rabbitmq_input_queue_name"`
+	URL       string `json:"rabbitmq_input_url" config:"url" required:"true" endpoint:"url" secret:"true" description:"AMQP URL of the RabbitMQ server, including credentials"`
+	QueueName string `json:"rabbitmq_input_queue_name" config:"queuename" required:"true" description:"Queue to consume from"`
 }
 
 // RabbitMQDestination struct represents the configuration for publishing messages to RabbitMQ.
 type RabbitMQDestination struct {
-	URL       string `json:"rabbitmq_output_url"`
-	QueueName string `json:"rabbitmq_output_queue_name"`
+	URL       string `json:"rabbitmq_output_url" config:"url" required:"true" endpoint:"url" secret:"true" description:"AMQP URL of the RabbitMQ server, including credentials"`
+	QueueName string `json:"rabbitmq_output_queue_name" config:"queuename" required:"true" description:"Queue to publish to"`
 }
 
 // FetchData connects to RabbitMQ, retrieves data, and processes it concurrently.
 func (r RabbitMQSource) FetchData(req interfaces.Request) (interface{}, error) {
-	logger.Infof("Connecting to RabbitMQ Source: URL=%s, Queue=%s", req.RabbitMQInputURL, req.RabbitMQInputQueueName)
+	log := logger.FromContext(req.Context())
+	log.Infof("Connecting to RabbitMQ Source: URL=%s, Queue=%s", req.RabbitMQInputURL, req.RabbitMQInputQueueName)
 
 	if req.RabbitMQInputURL == "" || req.RabbitMQInputQueueName == "" {
 		return nil, errors.New("missing RabbitMQ source details")
 	}
+	tracker := progress.FromContext(req.Context())
+	tracker.SetCurrent(req.RabbitMQInputQueueName)
 
-	// Connect to RabbitMQ
-	conn, err := amqp.Dial(req.RabbitMQInputURL)
+	// Connect to RabbitMQ and open a channel
+	conn, ch, err := connectRabbitMQ(req.Context(), req.RabbitMQInputURL)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
-
-	// Open a channel
-	ch, err := conn.Channel()
-	if err != nil {
-		return nil, err
-	}
 	defer ch.Close()
 
 	// Consume messages
@@ -60,7 +62,7 @@ func (r RabbitMQSource) FetchData(req interfaces.Request) (interface{}, error) {
 	}
 
 	// Use a buffered channel for processing messages
-	messageChannel := make(chan []byte, 10)
+	messageChannel := make(chan amqp.Delivery, 10)
 	var wg sync.WaitGroup
 
 	// Start multiple goroutines for concurrent processing
@@ -69,7 +71,8 @@ func (r RabbitMQSource) FetchData(req interfaces.Request) (interface{}, error) {
 		go func() {
 			defer wg.Done()
 			for message := range messageChannel {
-				processRabbitMQMessage(message)
+				processRabbitMQMessage(req.Context(), message)
+				tracker.AddRead(1)
 			}
 		}()
 	}
@@ -77,36 +80,43 @@ func (r RabbitMQSource) FetchData(req interfaces.Request) (interface{}, error) {
 	// Read messages from RabbitMQ and send to the channel
 	go func() {
 		for msg := range msgs {
-			messageChannel <- msg.Body
+			messageChannel <- msg
 		}
 		close(messageChannel)
 	}()
 
+	// Report the messages still waiting in the queue as the lag of the job
+	done := make(chan struct{})
+	defer close(done)
+	go reportQueueLag(ch, req.RabbitMQInputQueueName, tracker, done)
+
 	wg.Wait()
 	return nil, nil // Return nil as we process messages asynchronously
 }
 
 // SendData connects to RabbitMQ and publishes data to the specified queue.
-func (r RabbitMQDestination) SendData(data interface{}, req interfaces.Request) error {
-	logger.Infof("Connecting to RabbitMQ Destination: URL=%s, Queue=%s", req.RabbitMQOutputURL, req.RabbitMQOutputQueueName)
+func (r RabbitMQDestination) SendData(data interface{}, req interfaces.Request) (err error) {
+	log := logger.FromContext(req.Context())
+	log.Infof("Connecting to RabbitMQ Destination: URL=%s, Queue=%s", req.RabbitMQOutputURL, req.RabbitMQOutputQueueName)
 
 	if req.RabbitMQOutputURL == "" || req.RabbitMQOutputQueueName == "" {
 		return errors.New("missing RabbitMQ target details")
 	}
+	progress.FromContext(req.Context()).SetCurrent(req.RabbitMQOutputQueueName)
 
-	// Connect to RabbitMQ
-	conn, err := amqp.Dial(req.RabbitMQOutputURL)
+	// Connect to RabbitMQ and open a channel
+	conn, ch, err := connectRabbitMQ(req.Context(), req.RabbitMQOutputURL)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
-
-	// Open a channel
-	ch, err := conn.Channel()
-	if err != nil {
-		return err
-	}
 	defer ch.Close()
+	ctx, span := opentele.CreateSpan(req.Context(), "rabbitmq-publish",
+		semconv.MessagingSystemRabbitmq,
+		semconv.MessagingDestinationName(req.RabbitMQOutputQueueName),
+		opentele.Records(1),
+	)
+	defer func() { opentele.EndSpan(span, err) }()
 
 	// Declare the queue to ensure it exists
 	_, err = ch.QueueDeclare(
@@ -127,13 +137,16 @@ func (r RabbitMQDestination) SendData(data interface{}, req interfaces.Request)
 		return errors.New("unsupported data type for RabbitMQ message")
 	}
 
-	// Publish the message
+	// Publish the message, with the trace context for consumers to continue
+	headers := amqp.Table{}
+	opentele.Inject(ctx, amqpHeaders(headers))
 	err = ch.Publish(
 		"",                          // exchange
 		req.RabbitMQOutputQueueName, // routing key
 		false,                       // mandatory
 		false,                       // immediate
 		amqp.Publishing{
+			Headers:     headers,
 			ContentType: "text/plain",
 			Body:        messageBody,
 		},
@@ -142,30 +155,63 @@ func (r RabbitMQDestination) SendData(data interface{}, req interfaces.Request)
 		return err
 	}
 
-	logger.Infof("Message sent to RabbitMQ queue %s: %s", req.RabbitMQOutputQueueName, string(messageBody))
+	log.Debugf("Message sent to RabbitMQ queue %s: %s", req.RabbitMQOutputQueueName, string(messageBody))
 	return nil
 }
 
-// processRabbitMQMessage handles individual RabbitMQ messages.
-func processRabbitMQMessage(message []byte) {
-	logger.Infof("Processing RabbitMQ message: %s", message)
+// lagInterval is how often the depth of a consumed queue is reported
+const lagInterval = 10 * time.Second
+
+// reportQueueLag reports the number of messages ready in a queue until done is closed
+func reportQueueLag(ch *amqp.Channel, queue string, tracker *progress.Tracker, done <-chan struct{}) {
+	ticker := time.NewTicker(lagInterval)
+	defer ticker.Stop()
+	for {
+		if state, err := ch.QueueInspect(queue); err == nil {
+			tracker.SetLag(int64(state.Messages))
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processRabbitMQMessage handles individual RabbitMQ messages, continuing the trace
+// of their producer when they carry one.
+func processRabbitMQMessage(ctx context.Context, delivery amqp.Delivery) {
+	log := logger.FromContext(ctx)
+	message := delivery.Body
+	log.Debugf("Processing RabbitMQ message: %s", message)
+	ctx, span := opentele.CreateSpan(opentele.Extract(ctx, amqpHeaders(delivery.Headers)), "rabbitmq-process",
+		semconv.MessagingSystemRabbitmq,
+		semconv.MessagingDestinationName(delivery.RoutingKey),
+	)
+	defer span.End()
 
 	// Validation
+	_, stage := opentele.CreateSpan(ctx, "rabbitmq-validate")
 	validatedData, err := validateRabbitMQData(message)
+	opentele.EndSpan(stage, err)
 	if err != nil {
-		logger.Errorf("Validation failed: %s", err)
+		progress.FromContext(ctx).Quarantine(progress.ErrorValidation, err)
+		opentele.EndSpan(span, err)
+		log.Errorf("Validation failed: %s", err)
 		return
 	}
 
 	// Transformation
+	_, stage = opentele.CreateSpan(ctx, "rabbitmq-transform")
 	transformedData := transformRabbitMQData(validatedData)
+	stage.End()
 
-	logger.Infof("Message processed successfully: %s", transformedData)
+	log.Debugf("Message processed successfully: %s", transformedData)
 }
 
 // validateRabbitMQData ensures the input data meets the required criteria.
 func validateRabbitMQData(data []byte) ([]byte, error) {
-	logger.Infof("Validating data: %s", data)
+	logger.Debugf("Validating data: %s", data)
 
 	// Example: Check if data is non-empty
 	if len(data) == 0 {
@@ -177,7 +223,7 @@ func validateRabbitMQData(data []byte) ([]byte, error) {
 
 // transformRabbitMQData modifies the input data as per business logic.
 func transformRabbitMQData(data []byte) []byte {
-	logger.Infof("Transforming data: %s", data)
+	logger.Debugf("Transforming data: %s", data)
 
 	// Example: Convert data to uppercase
 	return []byte(strings.ToUpper(string(data)))
@@ -188,3 +234,51 @@ func init() {
 	registry.RegisterSource("RabbitMQ", RabbitMQSource{})
 	registry.RegisterDestination("RabbitMQ", RabbitMQDestination{})
 }
+
+// TestConnection checks that the RabbitMQ server accepts the credentials of the URL
+func (r RabbitMQSource) TestConnection(req interfaces.Request) error {
+	return dialRabbitMQ(req.RabbitMQInputURL)
+}
+
+// TestConnection checks that the RabbitMQ server accepts the credentials of the URL
+func (r RabbitMQDestination) TestConnection(req interfaces.Request) error {
+	return dialRabbitMQ(req.RabbitMQOutputURL)
+}
+
+// Datasets names the queue consumed
+func (r RabbitMQSource) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	namespace, _ := endpointNamespace("amqp", req.RabbitMQInputURL, "5672")
+	return []interfaces.Dataset{{Namespace: namespace, Name: req.RabbitMQInputQueueName}}
+}
+
+// Datasets names the queue published to
+func (r RabbitMQDestination) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	namespace, _ := endpointNamespace("amqp", req.RabbitMQOutputURL, "5672")
+	return []interfaces.Dataset{{Namespace: namespace, Name: req.RabbitMQOutputQueueName}}
+}
+
+// connectRabbitMQ connects to a RabbitMQ server and opens a channel
+func connectRabbitMQ(ctx context.Context, url string) (conn *amqp.Connection, ch *amqp.Channel, err error) {
+	_, span := opentele.CreateSpan(ctx, "rabbitmq-connect", semconv.MessagingSystemRabbitmq)
+	defer func() { opentele.EndSpan(span, err) }()
+
+	conn, err = amqp.Dial(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, err = conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, ch, nil
+}
+
+// dialRabbitMQ opens and closes a connection to a RabbitMQ server
+func dialRabbitMQ(url string) error {
+	conn, err := amqp.DialConfig(url, amqp.Config{Dial: amqp.DefaultDial(10 * time.Second)})
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -1,6 +1,7 @@
 package integrations
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -10,39 +11,44 @@ import (
 
 	"github.com/SkySingh04/fractal/interfaces"
 	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
 	"github.com/SkySingh04/fractal/registry"
 	"github.com/pkg/sftp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"golang.org/x/crypto/ssh"
 )
 
 // SFTPSource implements the DataSource interface
 type SFTPSource struct {
-	URL          string `json:"url"`
-	User         string `json:"user"`
-	Password     string `json:"password"`
-	SFTPFILEPATH string `json:"file_path"`
+	URL          string `json:"sftp_url" config:"url" required:"true" endpoint:"url" description:"SFTP server URL, for example sftp://host:port"`
+	User         string `json:"sftp_user" config:"user" required:"true" description:"SFTP user name"`
+	Password     string `json:"sftp_password" config:"password" required:"true" secret:"true" description:"SFTP password"`
+	SFTPFILEPATH string `json:"sftp_file_path" config:"filepath" required:"true" description:"Remote path of the file to read"`
 }
 
 // SFTPDestination implements the DataDestination interface
 type SFTPDestination struct {
-	URL          string `json:"url"`
-	User         string `json:"user"`
-	Password     string `json:"password"`
-	SFTPFILEPATH string `json:"file_path"`
+	URL          string `json:"sftp_url" config:"url" required:"true" endpoint:"url" description:"SFTP server URL, for example sftp://host:port"`
+	User         string `json:"sftp_user" config:"user" required:"true" description:"SFTP user name"`
+	Password     string `json:"sftp_password" config:"password" required:"true" secret:"true" description:"SFTP password"`
+	SFTPFILEPATH string `json:"sftp_file_path" config:"filepath" required:"true" description:"Remote path of the file to write"`
 }
 
 // FetchData fetches data from an SFTP server concurrently
-func (s SFTPSource) FetchData(req interfaces.Request) (interface{}, error) {
+func (s SFTPSource) FetchData(req interfaces.Request) (result interface{}, err error) {
+	log := logger.FromContext(req.Context())
 	if err := validateSFTPRequest(req, true); err != nil {
 		return nil, err
 	}
-	logger.Infof("Connecting to SFTP server at %s...", req.SFTPURL)
+	log.Infof("Connecting to SFTP server at %s...", req.SFTPURL)
 
-	client, err := dialSFTP(req.SFTPURL, req.SFTPUser, req.SFTPPassword)
+	client, err := dialSFTP(req.Context(), req.SFTPURL, req.SFTPUser, req.SFTPPassword)
 	if err != nil {
 		return nil, err
 	}
 	defer client.Close()
+	_, span := opentele.CreateSpan(req.Context(), "sftp-read", semconv.FilePath(req.SFTPFILEPATH))
+	defer func() { opentele.EndSpan(span, err) }()
 
 	// Use WaitGroup to ensure all operations finish
 	var wg sync.WaitGroup
@@ -53,7 +59,7 @@ func (s SFTPSource) FetchData(req interfaces.Request) (interface{}, error) {
 	go func() {
 		defer wg.Done()
 
-		logger.Infof("Downloading file from SFTP: %s", req.SFTPFILEPATH)
+		log.Infof("Downloading file from SFTP: %s", req.SFTPFILEPATH)
 		file, err := client.Open(req.SFTPFILEPATH)
 		if err != nil {
 			errorChan <- fmt.Errorf("failed to retrieve file from SFTP: %w", err)
@@ -84,17 +90,20 @@ func (s SFTPSource) FetchData(req interfaces.Request) (interface{}, error) {
 }
 
 // SendData sends data to an SFTP server concurrently
-func (s SFTPDestination) SendData(data interface{}, req interfaces.Request) error {
+func (s SFTPDestination) SendData(data interface{}, req interfaces.Request) (err error) {
+	log := logger.FromContext(req.Context())
 	if err := validateSFTPRequest(req, false); err != nil {
 		return err
 	}
-	logger.Infof("Connecting to SFTP server at %s...", req.SFTPURL)
+	log.Infof("Connecting to SFTP server at %s...", req.SFTPURL)
 
-	client, err := dialSFTP(req.SFTPURL, req.SFTPUser, req.SFTPPassword)
+	client, err := dialSFTP(req.Context(), req.SFTPURL, req.SFTPUser, req.SFTPPassword)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
+	_, span := opentele.CreateSpan(req.Context(), "sftp-write", semconv.FilePath(req.SFTPFILEPATH))
+	defer func() { opentele.EndSpan(span, err) }()
 
 	// Use WaitGroup to ensure all operations finish
 	var wg sync.WaitGroup
@@ -109,7 +118,7 @@ func (s SFTPDestination) SendData(data interface{}, req interfaces.Request) erro
 	go func() {
 		defer wg.Done()
 
-		logger.Infof("Uploading file to SFTP: %s", req.SFTPFILEPATH)
+		log.Infof("Uploading file to SFTP: %s", req.SFTPFILEPATH)
 		file, err := client.Create(req.SFTPFILEPATH)
 		if err != nil {
 			errorChan <- fmt.Errorf("failed to create file on SFTP server: %w", err)
@@ -132,12 +141,15 @@ func (s SFTPDestination) SendData(data interface{}, req interfaces.Request) erro
 		return <-errorChan
 	}
 
-	logger.Infof("Successfully sent data to SFTP.")
+	log.Infof("Successfully sent data to SFTP.")
 	return nil
 }
 
 // dialSFTP creates and authenticates an SFTP connection
-func dialSFTP(url, user, password string) (*sftp.Client, error) {
+func dialSFTP(ctx context.Context, url, user, password string) (client *sftp.Client, err error) {
+	_, span := opentele.CreateSpan(ctx, "sftp-connect")
+	defer func() { opentele.EndSpan(span, err) }()
+
 	// Remove "sftp://" prefix if present
 	url = strings.TrimPrefix(url, "sftp://")
 
@@ -155,7 +167,7 @@ func dialSFTP(url, user, password string) (*sftp.Client, error) {
 		return nil, fmt.Errorf("failed to connect to SFTP server: %w", err)
 	}
 
-	client, err := sftp.NewClient(conn)
+	client, err = sftp.NewClient(conn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create SFTP client: %w", err)
 	}
@@ -187,3 +199,33 @@ func init() {
 	registry.RegisterSource("SFTP", SFTPSource{})
 	registry.RegisterDestination("SFTP", SFTPDestination{})
 }
+
+// TestConnection checks that the SFTP server accepts the user and password
+func (s SFTPSource) TestConnection(req interfaces.Request) error {
+	return loginSFTP(req)
+}
+
+// TestConnection checks that the SFTP server accepts the user and password
+func (s SFTPDestination) TestConnection(req interfaces.Request) error {
+	return loginSFTP(req)
+}
+
+// Datasets names the remote file read, by its path on the SFTP server
+func (s SFTPSource) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	namespace, _ := endpointNamespace("sftp", req.SFTPURL, "22")
+	return []interfaces.Dataset{{Namespace: namespace, Name: req.SFTPFILEPATH}}
+}
+
+// Datasets names the remote file written, by its path on the SFTP server
+func (s SFTPDestination) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	namespace, _ := endpointNamespace("sftp", req.SFTPURL, "22")
+	return []interfaces.Dataset{{Namespace: namespace, Name: req.SFTPFILEPATH}}
+}
+
+func loginSFTP(req interfaces.Request) error {
+	client, err := dialSFTP(req.Context(), req.SFTPURL, req.SFTPUser, req.SFTPPassword)
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
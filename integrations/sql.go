@@ -1,6 +1,7 @@
 package integrations
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -9,28 +10,52 @@ import (
 
 	"github.com/SkySingh04/fractal/interfaces"
 	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/SkySingh04/fractal/progress"
 	"github.com/SkySingh04/fractal/registry"
 	_ "github.com/lib/pq" // PostgreSQL driver
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 // PostgreSQLSource struct represents the configuration for consuming messages from PostgreSQL.
 type PostgreSQLSource struct {
-	ConnString string `json:"postgresql_source_conn_string"`
+	ConnString string `json:"sql_source_conn_string" config:"connstring" required:"true" endpoint:"url" secret:"true" description:"PostgreSQL connection string of the source database"`
 }
 
 // PostgreSQLDestination struct represents the configuration for publishing messages to PostgreSQL.
 type PostgreSQLDestination struct {
-	ConnString string `json:"postgresql_target_conn_string"`
+	ConnString string `json:"sql_target_conn_string" config:"connstring" required:"true" endpoint:"url" secret:"true" description:"PostgreSQL connection string of the target database"`
 }
 
 // FetchData connects to PostgreSQL, retrieves data, and returns it.
 func (p PostgreSQLSource) FetchData(req interfaces.Request) (interface{}, error) {
+	log := logger.FromContext(req.Context())
 	if req.SQLSourceConnString == "" {
 		return nil, errors.New("missing PostgreSQL source connection string")
 	}
-	logger.Infof("Connecting to PostgreSQL source...")
+	log.Infof("Connecting to PostgreSQL source...")
 
-	db, err := sql.Open("postgres", req.SQLSourceConnString)
+	allResults, err := readPostgreSQL(req.Context(), req.SQLSourceConnString)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Data fetched from PostgreSQL: %d tables", len(allResults))
+	log.Debugf("Data fetched from PostgreSQL: %v", allResults)
+	return allResults, nil
+}
+
+// ReadBack reads every table of the target database, so a run can be verified
+func (p PostgreSQLDestination) ReadBack(req interfaces.Request) (interface{}, error) {
+	if req.SQLTargetConnString == "" {
+		return nil, errors.New("missing PostgreSQL target connection string")
+	}
+	return readPostgreSQL(req.Context(), req.SQLTargetConnString)
+}
+
+// readPostgreSQL returns the rows of every table in the public schema, by table name
+func readPostgreSQL(ctx context.Context, connString string) (map[string][]map[string]interface{}, error) {
+	db, err := openPostgreSQL(ctx, connString)
 	if err != nil {
 		return nil, err
 	}
@@ -38,7 +63,7 @@ func (p PostgreSQLSource) FetchData(req interfaces.Request) (interface{}, error)
 
 	// Retrieve the list of all tables in the public schema
 	tablesQuery := "SELECT table_name FROM information_schema.tables WHERE table_schema = 'public'"
-	rows, err := db.Query(tablesQuery)
+	rows, err := db.QueryContext(ctx, tablesQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -54,54 +79,75 @@ func (p PostgreSQLSource) FetchData(req interfaces.Request) (interface{}, error)
 		}
 
 		// For each table, fetch its data
-		dataQuery := "SELECT * FROM " + tableName // Fetch all columns from the table
-		dataRows, err := db.Query(dataQuery)
-		if err != nil {
-			logger.Errorf("Error querying table %s: %s", tableName, err)
-			continue // Skip this table on error
-		}
-		defer dataRows.Close()
-
-		// Get column names for later use
-		columns, err := dataRows.Columns()
-		if err != nil {
+		if err := readPostgreSQLTable(ctx, db, tableName, allResults); err != nil {
 			return nil, err
 		}
+	}
 
-		for dataRows.Next() {
-			values := make([]interface{}, len(columns))
-			valuePtrs := make([]interface{}, len(columns))
-			for i := range values {
-				valuePtrs[i] = &values[i]
-			}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return allResults, nil
+}
 
-			if err := dataRows.Scan(valuePtrs...); err != nil {
-				return nil, err
-			}
+// readPostgreSQLTable adds every row of a table to results. A table that cannot be
+// queried is skipped.
+func readPostgreSQLTable(ctx context.Context, db *sql.DB, tableName string, results map[string][]map[string]interface{}) (err error) {
+	tracker := progress.FromContext(ctx)
+	tracker.SetCurrent(tableName)
+	ctx, span := opentele.CreateSpan(ctx, "postgresql-read", semconv.DBSystemPostgreSQL, semconv.DBCollectionName(tableName))
+	defer func() {
+		span.SetAttributes(opentele.Records(len(results[tableName])))
+		opentele.EndSpan(span, err)
+	}()
+
+	dataQuery := "SELECT * FROM " + tableName // Fetch all columns from the table
+	dataRows, err := db.QueryContext(ctx, dataQuery)
+	if err != nil {
+		tracker.Fail(progress.ErrorRead, err)
+		span.RecordError(err)
+		logger.FromContext(ctx).Warnf("Skipping table %s, which could not be queried: %s", tableName, err)
+		return nil // Skip this table on error
+	}
+	defer dataRows.Close()
 
-			rowData := make(map[string]interface{})
-			for i, colName := range columns {
-				val := values[i]
-				rowData[colName] = val
-			}
-			allResults[tableName] = append(allResults[tableName], rowData) // Append row data to the appropriate table key
+	// Get column names for later use
+	columns, err := dataRows.Columns()
+	if err != nil {
+		return err
+	}
+
+	for dataRows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
 		}
 
-		if err := dataRows.Err(); err != nil {
-			return nil, err
+		if err := dataRows.Scan(valuePtrs...); err != nil {
+			return err
 		}
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+		rowData := make(map[string]interface{})
+		for i, colName := range columns {
+			val := values[i]
+			rowData[colName] = val
+		}
+		results[tableName] = append(results[tableName], rowData) // Append row data to the appropriate table key
+		tracker.AddRead(1)
 	}
 
-	logger.Infof("Data fetched from PostgreSQL: %v", allResults)
-	return allResults, nil
+	return dataRows.Err()
+}
+
+// SQLExecutor runs statements on a database, or in a transaction
+type SQLExecutor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
 }
 
 // EnsureTableExistsWorker processes table creation tasks.
-func EnsureTableExistsWorker(db *sql.DB, tasks chan map[string]interface{}, errorsChan chan error, done chan bool) {
+func EnsureTableExistsWorker(db SQLExecutor, tasks chan map[string]interface{}, errorsChan chan error, done chan bool) {
 	for task := range tasks {
 		tableName := task["tableName"].(string)
 		row := task["row"].(map[string]interface{})
@@ -142,7 +188,7 @@ func EnsureTableExistsWorker(db *sql.DB, tasks chan map[string]interface{}, erro
 }
 
 // EnsureTableExists enqueues table creation tasks and processes them concurrently.
-func EnsureTableExists(db *sql.DB, tableName string, row map[string]interface{}) error {
+func EnsureTableExists(db SQLExecutor, tableName string, row map[string]interface{}) error {
 	// Buffered channels to queue tasks and capture errors
 	tasks := make(chan map[string]interface{}, 1)
 	errorsChan := make(chan error, 1)
@@ -173,12 +219,13 @@ func EnsureTableExists(db *sql.DB, tableName string, row map[string]interface{})
 
 // SendData connects to PostgreSQL and publishes data to the specified table.
 func (p PostgreSQLDestination) SendData(data interface{}, req interfaces.Request) error {
+	log := logger.FromContext(req.Context())
 	if req.SQLTargetConnString == "" {
 		return errors.New("missing PostgreSQL target connection string")
 	}
-	logger.Infof("Connecting to PostgreSQL destination...")
+	log.Infof("Connecting to PostgreSQL destination...")
 
-	db, err := sql.Open("postgres", req.SQLTargetConnString)
+	db, err := openPostgreSQL(req.Context(), req.SQLTargetConnString)
 	if err != nil {
 		return err
 	}
@@ -191,34 +238,63 @@ func (p PostgreSQLDestination) SendData(data interface{}, req interfaces.Request
 	}
 
 	for tableName, rows := range dataMap {
-		for _, row := range rows {
-			// Ensure the table exists
-			if err := EnsureTableExists(db, tableName, row); err != nil {
-				return err
-			}
+		if err := writePostgreSQLTable(req.Context(), db, tableName, rows); err != nil {
+			return err
+		}
+	}
 
-			// Prepare column names and values for the insert query
-			var columns []string
-			var placeholders []string
-			var values []interface{}
+	return nil
+}
 
-			for colName, value := range row {
-				columns = append(columns, colName)
-				placeholders = append(placeholders, "$"+strconv.Itoa(len(values)+1))
-				values = append(values, value)
-			}
+// writePostgreSQLTable inserts rows into a table, creating the table first if needed.
+// The rows are inserted in a single transaction, so a write that fails or is cancelled
+// part way through leaves the table as it was.
+func writePostgreSQLTable(ctx context.Context, db *sql.DB, tableName string, rows []map[string]interface{}) (err error) {
+	tracker := progress.FromContext(ctx)
+	tracker.SetCurrent(tableName)
+	ctx, span := opentele.CreateSpan(ctx, "postgresql-write", semconv.DBSystemPostgreSQL, semconv.DBCollectionName(tableName), opentele.Records(len(rows)))
+	defer func() { opentele.EndSpan(span, err) }()
 
-			// Construct the INSERT query
-			query := "INSERT INTO " + tableName + " (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
-			if _, err := db.Exec(query, values...); err != nil {
-				logger.Errorf("Error inserting into table %s: %s", tableName, err)
-				return err // Return on error
-			}
+	// Create the table from the first row, in the transaction so that it is only
+	// kept when the rows are
+	if len(rows) > 0 {
+		if err := EnsureTableExists(tx, tableName, rows[0]); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	for _, row := range rows {
+		// Prepare column names and values for the insert query
+		var columns []string
+		var placeholders []string
+		var values []interface{}
+
+		for colName, value := range row {
+			columns = append(columns, colName)
+			placeholders = append(placeholders, "$"+strconv.Itoa(len(values)+1))
+			values = append(values, value)
+		}
+
+		// Construct the INSERT query
+		query := "INSERT INTO " + tableName + " (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+
+		if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+			logger.FromContext(ctx).Errorf("Error inserting into table %s: %s", tableName, err)
+			return err // Return on error
+		}
+		tracker.AddWritten(1)
+	}
+	return tx.Commit()
 }
 
 // Initialize the PostgreSQL integrations by registering them with the registry.
@@ -226,3 +302,50 @@ func init() {
 	registry.RegisterSource("PostgreSQL", PostgreSQLSource{})
 	registry.RegisterDestination("PostgreSQL", PostgreSQLDestination{})
 }
+
+// TestConnection checks that the source database accepts connections
+func (p PostgreSQLSource) TestConnection(req interfaces.Request) error {
+	return pingPostgreSQL(req.Context(), req.SQLSourceConnString)
+}
+
+// TestConnection checks that the target database accepts connections
+func (p PostgreSQLDestination) TestConnection(req interfaces.Request) error {
+	return pingPostgreSQL(req.Context(), req.SQLTargetConnString)
+}
+
+// Datasets names the tables read as database.public.table once the data has been read
+func (p PostgreSQLSource) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	namespace, database := endpointNamespace("postgres", req.SQLSourceConnString, "5432")
+	return tableDatasets(namespace, database+".public", data)
+}
+
+// Datasets names the tables written as database.public.table, one per table of the data
+func (p PostgreSQLDestination) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	namespace, database := endpointNamespace("postgres", req.SQLTargetConnString, "5432")
+	return tableDatasets(namespace, database+".public", data)
+}
+
+// pingPostgreSQL connects to a PostgreSQL database and pings it
+func pingPostgreSQL(ctx context.Context, connString string) error {
+	db, err := openPostgreSQL(ctx, connString)
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+// openPostgreSQL connects to a PostgreSQL database and checks that it answers
+func openPostgreSQL(ctx context.Context, connString string) (db *sql.DB, err error) {
+	ctx, span := opentele.CreateSpan(ctx, "postgresql-connect", semconv.DBSystemPostgreSQL)
+	defer func() { opentele.EndSpan(span, err) }()
+
+	db, err = sql.Open("postgres", connString)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
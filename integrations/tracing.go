@@ -0,0 +1,58 @@
+package integrations
+
+import (
+	"github.com/segmentio/kafka-go"
+	"github.com/streadway/amqp"
+)
+
+// kafkaHeaders carries trace context in the headers of a Kafka message
+type kafkaHeaders struct {
+	headers *[]kafka.Header
+}
+
+func (c kafkaHeaders) Get(key string) string {
+	for _, header := range *c.headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c kafkaHeaders) Set(key, value string) {
+	for i, header := range *c.headers {
+		if header.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c kafkaHeaders) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, header := range *c.headers {
+		keys = append(keys, header.Key)
+	}
+	return keys
+}
+
+// amqpHeaders carries trace context in the headers of an AMQP message
+type amqpHeaders amqp.Table
+
+func (c amqpHeaders) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+func (c amqpHeaders) Set(key, value string) {
+	c[key] = value
+}
+
+func (c amqpHeaders) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
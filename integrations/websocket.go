@@ -1,76 +1,93 @@
 package integrations
 
 import (
+	"context"
 	"errors"
+	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/SkySingh04/fractal/interfaces"
 	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
 	"github.com/SkySingh04/fractal/registry"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 // WebSocketSource struct represents the configuration for consuming messages from WebSocket.
 type WebSocketSource struct {
-	URL string `json:"websocket_source_url"`
+	URL string `json:"websocket_source_url" config:"url" required:"true" endpoint:"url" description:"WebSocket URL to read a message from"`
 }
 
 // WebSocketDestination struct represents the configuration for publishing messages to WebSocket.
 type WebSocketDestination struct {
-	URL string `json:"websocket_dest_url"`
+	URL string `json:"websocket_dest_url" config:"url" required:"true" endpoint:"url" description:"WebSocket URL to send the message to"`
 }
 
 // FetchData connects to WebSocket, retrieves data, and passes it through validation and transformation pipelines.
-func (ws WebSocketSource) FetchData(req interfaces.Request) (interface{}, error) {
-	logger.Infof("Connecting to WebSocket Source: URL=%s", req.WebSocketSourceURL)
+func (ws WebSocketSource) FetchData(req interfaces.Request) (result interface{}, err error) {
+	log := logger.FromContext(req.Context())
+	log.Infof("Connecting to WebSocket Source: URL=%s", req.WebSocketSourceURL)
 
 	if req.WebSocketSourceURL == "" {
 		return nil, errors.New("missing WebSocket source details")
 	}
 
 	// Connect to WebSocket server
-	conn, _, err := websocket.DefaultDialer.Dial(req.WebSocketSourceURL, nil)
+	conn, err := connectWebSocket(req.Context(), req.WebSocketSourceURL)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
+	ctx, span := opentele.CreateSpan(req.Context(), "websocket-read", semconv.URLFull(req.WebSocketSourceURL))
+	defer func() { opentele.EndSpan(span, err) }()
 
 	// Read message from WebSocket
 	_, msg, err := conn.ReadMessage()
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(opentele.Records(1))
 
-	logger.Infof("Message received from WebSocket: %s", msg)
+	log.Debugf("Message received from WebSocket: %s", msg)
 
 	// Validation
+	_, stage := opentele.CreateSpan(ctx, "websocket-validate")
 	validatedData, err := validateWebSocketData(msg)
+	opentele.EndSpan(stage, err)
 	if err != nil {
-		logger.Fatalf("Validation failed for message: %s, Error: %s", msg, err)
+		log.Errorf("Validation failed for message: %s, Error: %s", msg, err)
 		return nil, err
 	}
 
 	// Transformation
+	_, stage = opentele.CreateSpan(ctx, "websocket-transform")
 	transformedData := transformWebSocketData(validatedData)
+	stage.End()
 
-	logger.Infof("Message successfully processed and routed: %s", transformedData)
+	log.Debugf("Message successfully processed and routed: %s", transformedData)
 	return transformedData, nil
 }
 
 // SendData connects to WebSocket and publishes data to the specified WebSocket server.
-func (ws WebSocketDestination) SendData(data interface{}, req interfaces.Request) error {
-	logger.Infof("Connecting to WebSocket Destination: URL=%s", req.WebSocketDestURL)
+func (ws WebSocketDestination) SendData(data interface{}, req interfaces.Request) (err error) {
+	log := logger.FromContext(req.Context())
+	log.Infof("Connecting to WebSocket Destination: URL=%s", req.WebSocketDestURL)
 
 	if req.WebSocketDestURL == "" {
 		return errors.New("missing WebSocket destination details")
 	}
 
 	// Connect to WebSocket server
-	conn, _, err := websocket.DefaultDialer.Dial(req.WebSocketDestURL, nil)
+	conn, err := connectWebSocket(req.Context(), req.WebSocketDestURL)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
+	_, span := opentele.CreateSpan(req.Context(), "websocket-write", semconv.URLFull(req.WebSocketDestURL), opentele.Records(1))
+	defer func() { opentele.EndSpan(span, err) }()
 
 	// Convert data to string if necessary
 	var msg string
@@ -89,7 +106,7 @@ func (ws WebSocketDestination) SendData(data interface{}, req interfaces.Request
 		return err
 	}
 
-	logger.Infof("Message sent to WebSocket server: %s", msg)
+	log.Debugf("Message sent to WebSocket server: %s", msg)
 	return nil
 }
 
@@ -101,7 +118,7 @@ func init() {
 
 // validateWebSocketData ensures the input data meets the required criteria.
 func validateWebSocketData(data []byte) ([]byte, error) {
-	logger.Infof("Validating data: %s", data)
+	logger.Debugf("Validating data: %s", data)
 
 	// Example: Check if data is non-empty
 	if len(data) == 0 {
@@ -114,9 +131,61 @@ func validateWebSocketData(data []byte) ([]byte, error) {
 
 // transformWebSocketData modifies the input data as per business logic.
 func transformWebSocketData(data []byte) []byte {
-	logger.Infof("Transforming data: %s", data)
+	logger.Debugf("Transforming data: %s", data)
 
 	// Example: Convert data to uppercase (modify as needed)
 	transformed := []byte(strings.ToUpper(string(data)))
 	return transformed
 }
+
+// TestConnection checks that the WebSocket server accepts connections
+func (ws WebSocketSource) TestConnection(req interfaces.Request) error {
+	return dialWebSocket(req.Context(), req.WebSocketSourceURL)
+}
+
+// TestConnection checks that the WebSocket server accepts connections
+func (ws WebSocketDestination) TestConnection(req interfaces.Request) error {
+	return dialWebSocket(req.Context(), req.WebSocketDestURL)
+}
+
+// Datasets names the WebSocket endpoint read, by its path on the server
+func (ws WebSocketSource) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	return webSocketDataset(req.WebSocketSourceURL)
+}
+
+// Datasets names the WebSocket endpoint written, by its path on the server
+func (ws WebSocketDestination) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	return webSocketDataset(req.WebSocketDestURL)
+}
+
+func webSocketDataset(rawURL string) []interfaces.Dataset {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	name := u.Path
+	if name == "" {
+		name = "/"
+	}
+	return []interfaces.Dataset{{Namespace: u.Scheme + "://" + u.Host, Name: name}}
+}
+
+func dialWebSocket(ctx context.Context, url string) error {
+	conn, err := connectWebSocket(ctx, url)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// connectWebSocket opens a connection to a WebSocket server, passing the trace context
+// of ctx in the headers of the handshake
+func connectWebSocket(ctx context.Context, url string) (conn *websocket.Conn, err error) {
+	ctx, span := opentele.CreateSpan(ctx, "websocket-connect", semconv.URLFull(url))
+	defer func() { opentele.EndSpan(span, err) }()
+
+	header := http.Header{}
+	opentele.Inject(ctx, propagation.HeaderCarrier(header))
+	conn, _, err = websocket.DefaultDialer.DialContext(ctx, url, header)
+	return conn, err
+}
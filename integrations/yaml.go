@@ -6,28 +6,34 @@ import (
 
 	"github.com/SkySingh04/fractal/interfaces"
 	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
 	"github.com/SkySingh04/fractal/registry"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"gopkg.in/yaml.v3"
 )
 
 // YAMLSource struct represents the configuration for reading data from a YAML file.
 type YAMLSource struct {
-	FilePath string `json:"yaml_source_file_path"`
+	FilePath string `json:"yaml_source_file_path" config:"filepath" required:"true" description:"Path of the YAML file to read"`
 }
 
 // YAMLDestination struct represents the configuration for writing data to a YAML file.
 type YAMLDestination struct {
-	FilePath string `json:"yaml_output_file_path"`
+	FilePath string `json:"yaml_destination_file_path" config:"filepath" required:"true" description:"Path of the YAML file to write"`
 }
 
 // FetchData reads and processes data from a YAML source file.
-func (y YAMLSource) FetchData(req interfaces.Request) (interface{}, error) {
-	logger.Infof("Fetching data from YAML source: %s", req.YAMLSourceFilePath)
+func (y YAMLSource) FetchData(req interfaces.Request) (result interface{}, err error) {
+	log := logger.FromContext(req.Context())
+	log.Infof("Fetching data from YAML source: %s", req.YAMLSourceFilePath)
 
 	if req.YAMLSourceFilePath == "" {
 		return nil, errors.New("missing YAML source file path")
 	}
 
+	ctx, span := opentele.CreateSpan(req.Context(), "yaml-read", semconv.FilePath(req.YAMLSourceFilePath))
+	defer func() { opentele.EndSpan(span, err) }()
+
 	// Read the YAML file
 	data, err := ioutil.ReadFile(req.YAMLSourceFilePath)
 	if err != nil {
@@ -35,16 +41,20 @@ func (y YAMLSource) FetchData(req interfaces.Request) (interface{}, error) {
 	}
 
 	// Validate and sanitize the YAML data
+	_, stage := opentele.CreateSpan(ctx, "yaml-validate")
 	validatedData, err := ValidateYAMLData(data)
+	opentele.EndSpan(stage, err)
 	if err != nil {
-		logger.Fatalf("Validation error: %v", err)
+		log.Errorf("Validation error: %v", err)
 		return nil, err
 	}
 
 	// Transform the YAML data if necessary
+	_, stage = opentele.CreateSpan(ctx, "yaml-transform")
 	transformedData, err := transformYAMLData(validatedData)
+	opentele.EndSpan(stage, err)
 	if err != nil {
-		logger.Fatalf("Transformation error: %v", err)
+		log.Errorf("Transformation error: %v", err)
 		return nil, err
 	}
 
@@ -52,21 +62,25 @@ func (y YAMLSource) FetchData(req interfaces.Request) (interface{}, error) {
 }
 
 // SendData writes the provided data to a YAML destination file.
-func (y YAMLDestination) SendData(data interface{}, req interfaces.Request) error {
-	logger.Infof("Sending data to YAML destination: %s", req.YAMLDestinationFilePath)
+func (y YAMLDestination) SendData(data interface{}, req interfaces.Request) (err error) {
+	log := logger.FromContext(req.Context())
+	log.Infof("Sending data to YAML destination: %s", req.YAMLDestinationFilePath)
 
 	if req.YAMLDestinationFilePath == "" {
 		return errors.New("missing YAML destination file path")
 	}
 
+	_, span := opentele.CreateSpan(req.Context(), "yaml-write", semconv.FilePath(req.YAMLDestinationFilePath))
+	defer func() { opentele.EndSpan(span, err) }()
+
 	// Write the data to the YAML file
-	err := writeYAMLFile(req.YAMLDestinationFilePath, data)
+	err = writeYAMLFile(req.YAMLDestinationFilePath, data)
 	if err != nil {
-		logger.Fatalf("Error writing data to YAML file: %v", err)
+		log.Errorf("Error writing data to YAML file: %v", err)
 		return err
 	}
 
-	logger.Infof("Data successfully written to %s", req.YAMLDestinationFilePath)
+	log.Infof("Data successfully written to %s", req.YAMLDestinationFilePath)
 	return nil
 }
 
@@ -79,7 +93,7 @@ func ValidateYAMLData(data []byte) (interface{}, error) {
 
 	// Sanitize the data
 	sanitizedData := sanitizeYAMLData(yamlData)
-	logger.Infof("Validation and sanitization successful for YAML data")
+	logger.Debugf("Validation and sanitization successful for YAML data")
 	return sanitizedData, nil
 }
 
@@ -109,6 +123,19 @@ func sanitizeYAMLData(data interface{}) interface{} {
 	}
 }
 
+// ReadBack reads the YAML file written by SendData
+func (y YAMLDestination) ReadBack(req interfaces.Request) (interface{}, error) {
+	data, err := ioutil.ReadFile(req.YAMLDestinationFilePath)
+	if err != nil {
+		return nil, err
+	}
+	var written interface{}
+	if err := yaml.Unmarshal(data, &written); err != nil {
+		return nil, err
+	}
+	return written, nil
+}
+
 // writeYAMLFile writes the provided data to a YAML file.
 func writeYAMLFile(filename string, data interface{}) error {
 	outputData, err := yaml.Marshal(data)
@@ -133,7 +160,7 @@ func transformYAMLData(data interface{}) (interface{}, error) {
 	}
 
 	// If no transformation is required, return data as is
-	logger.Infof("No transformation applied to YAML data")
+	logger.Debugf("No transformation applied to YAML data")
 	return data, nil
 }
 
@@ -142,3 +169,23 @@ func init() {
 	registry.RegisterSource("YAML", YAMLSource{})
 	registry.RegisterDestination("YAML", YAMLDestination{})
 }
+
+// TestConnection checks that the YAML file can be read
+func (y YAMLSource) TestConnection(req interfaces.Request) error {
+	return checkReadable(req.YAMLSourceFilePath)
+}
+
+// TestConnection checks that the YAML file can be written
+func (y YAMLDestination) TestConnection(req interfaces.Request) error {
+	return checkWritable(req.YAMLDestinationFilePath)
+}
+
+// Datasets names the YAML file read
+func (y YAMLSource) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	return fileDataset(req.YAMLSourceFilePath)
+}
+
+// Datasets names the YAML file written
+func (y YAMLDestination) Datasets(req interfaces.Request, data interface{}) []interfaces.Dataset {
+	return fileDataset(req.YAMLDestinationFilePath)
+}
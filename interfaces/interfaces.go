@@ -1,5 +1,7 @@
 package interfaces
 
+import "context"
+
 type DataSource interface {
 	FetchData(req Request) (interface{}, error)
 }
@@ -8,6 +10,35 @@ type DataDestination interface {
 	SendData(data interface{}, req Request) error
 }
 
+// ConnectionTester is implemented by sources and destinations that can check their
+// configuration without moving any data, for example by connecting and logging in.
+// The context of req carries the deadline of the check.
+type ConnectionTester interface {
+	TestConnection(req Request) error
+}
+
+// ReadBacker is implemented by destinations that can read back what they hold, so that
+// a run can be verified. The data has the shape the matching source returns, without
+// validation or transformation rules applied.
+type ReadBacker interface {
+	ReadBack(req Request) (interface{}, error)
+}
+
+// DatasetNamer is implemented by sources and destinations that can name the datasets
+// they read or write, for lineage. data is what the run read from its source, nil
+// when it has not been read yet; datasets named after the data, such as the tables
+// of a database, are only known once it has been.
+type DatasetNamer interface {
+	Datasets(req Request, data interface{}) []Dataset
+}
+
+// Dataset names a table, collection, topic, queue or file the way OpenLineage does
+type Dataset struct {
+	Namespace string // The server or store holding the dataset, such as postgres://db:5432, without credentials
+	Name      string // The dataset within the namespace, such as shop.public.orders
+	Table     string // The key of the dataset in data holding several, such as the rows of a table
+}
+
 // Request struct to hold migration request data
 type Request struct {
 	Input                   string `json:"input"`          // List of input types (Kafka, SQL, MongoDB, etc.)
@@ -59,4 +90,20 @@ type Request struct {
 	CredentialFileAddr string `json:"firebase_credential_file"`
 	Collection         string `json:"firebase_collection"`
 	Document           string `json:"firebase_document"`
+
+	ctx context.Context // Set by the runner; carries tracing spans and progress reporting
+}
+
+// Context returns the context of the request, never nil
+func (r Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// WithContext returns a copy of the request that carries ctx
+func (r Request) WithContext(ctx context.Context) Request {
+	r.ctx = ctx
+	return r
 }
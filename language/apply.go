@@ -0,0 +1,337 @@
+package language
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Rejection is a record that failed a validation or a transformation and was left
+// out of the data
+type Rejection struct {
+	Kind string // "validation" or "transformation"
+	Err  error
+}
+
+const (
+	RejectedValidation     = "validation"
+	RejectedTransformation = "transformation"
+)
+
+// Apply validates and transforms every record of data, keeping its shape: tables of
+// rows stay tables, lists stay lists and CSV text stays CSV text. Records that fail
+// are left out and returned as rejections. It returns an error when data holds
+// something that is not a record.
+func (rules *Rules) Apply(data interface{}) (interface{}, []Rejection, error) {
+	if rules == nil || data == nil {
+		return data, nil, nil
+	}
+	var rejections []Rejection
+	out, err := rules.walk(reflect.ValueOf(data), &rejections)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out.Interface(), rejections, nil
+}
+
+// walk applies the rules to the records held by v
+func (rules *Rules) walk(v reflect.Value, rejections *[]Rejection) (reflect.Value, error) {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch {
+	case v.Kind() == reflect.String:
+		out, err := rules.applyCSV(v.String(), rejections)
+		if err != nil {
+			return v, err
+		}
+		return reflect.ValueOf(out).Convert(v.Type()), nil
+
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		out, err := rules.applyBytes(v.Bytes(), rejections)
+		if err != nil {
+			return v, err
+		}
+		return reflect.ValueOf(out).Convert(v.Type()), nil
+
+	case v.Kind() == reflect.Slice:
+		out := reflect.MakeSlice(v.Type(), 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			r, ok := asRecord(v.Index(i))
+			if !ok {
+				return v, fmt.Errorf("item %d is a %T, not a record rules can apply to", i, v.Index(i).Interface())
+			}
+			if rules.keep(r, rejections) {
+				out = reflect.Append(out, v.Index(i))
+			}
+		}
+		return out, nil
+
+	case v.Kind() == reflect.Map && isTables(v):
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, key := range v.MapKeys() {
+			table, err := rules.walk(v.MapIndex(key), rejections)
+			if err != nil {
+				return v, fmt.Errorf("%v: %w", key.Interface(), err)
+			}
+			out.SetMapIndex(key, table.Convert(v.Type().Elem()))
+		}
+		return out, nil
+
+	case v.Kind() == reflect.Map:
+		r, ok := asRecord(v)
+		if !ok {
+			return v, fmt.Errorf("a %s is not a record rules can apply to", v.Type())
+		}
+		if !rules.keep(r, rejections) {
+			return reflect.MakeMap(v.Type()), nil
+		}
+		return v, nil
+	}
+	return v, fmt.Errorf("a %s is not a record rules can apply to", v.Type())
+}
+
+// keep validates and transforms r, and tells whether it stays in the data
+func (rules *Rules) keep(r record, rejections *[]Rejection) bool {
+	if err := rules.validate(r); err != nil {
+		*rejections = append(*rejections, Rejection{Kind: RejectedValidation, Err: err})
+		return false
+	}
+	if err := rules.transform(r); err != nil {
+		*rejections = append(*rejections, Rejection{Kind: RejectedTransformation, Err: err})
+		return false
+	}
+	return true
+}
+
+// applyCSV applies the rules to CSV text with a header row
+func (rules *Rules) applyCSV(data string, rejections *[]Rejection) (string, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("reading CSV: %w", err)
+	}
+	if len(rows) == 0 {
+		return data, nil
+	}
+	header := rows[0]
+	columns := rules.columns(header)
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(columns); err != nil {
+		return "", err
+	}
+	for _, row := range rows[1:] {
+		fields := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			if i < len(row) {
+				fields[name] = row[i]
+			}
+		}
+		if !rules.keep(mapRecord{reflect.ValueOf(fields)}, rejections) {
+			continue
+		}
+		line := make([]string, len(columns))
+		for i, name := range columns {
+			if value, ok := (mapRecord{reflect.ValueOf(fields)}).lookup(name); ok && value != nil {
+				line[i] = text(value)
+			}
+		}
+		if err := writer.Write(line); err != nil {
+			return "", err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// applyBytes applies the rules to a JSON document, or to CSV text otherwise
+func (rules *Rules) applyBytes(data []byte, rejections *[]Rejection) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		out, err := rules.applyCSV(string(data), rejections)
+		return []byte(out), err
+	}
+	out, err := rules.walk(reflect.ValueOf(document), rejections)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(out.Interface())
+}
+
+// isTables tells whether every value of the map v is a list of records, as SQL
+// sources read
+func isTables(v reflect.Value) bool {
+	if v.Len() == 0 || v.Type().Key().Kind() != reflect.String {
+		return false
+	}
+	for _, key := range v.MapKeys() {
+		value := v.MapIndex(key)
+		for value.Kind() == reflect.Interface && !value.IsNil() {
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.Slice || value.Type().Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+		for i := 0; i < value.Len(); i++ {
+			if _, ok := asRecord(value.Index(i)); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// record is a row or document the rules read and change
+type record interface {
+	lookup(path string) (interface{}, bool)
+	set(path string, value interface{}) error
+	remove(path string)
+}
+
+// mapRecord is a record held in a map with string keys
+type mapRecord struct {
+	m reflect.Value
+}
+
+func asRecord(v reflect.Value) (record, bool) {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String || v.IsNil() {
+		return nil, false
+	}
+	return mapRecord{v}, true
+}
+
+// key returns the key of the map holding name, matching it exactly first and then
+// regardless of case, as CSV headers are upper-cased when read
+func (r mapRecord) key(name string) (reflect.Value, bool) {
+	exact := reflect.ValueOf(name).Convert(r.m.Type().Key())
+	if r.m.MapIndex(exact).IsValid() {
+		return exact, true
+	}
+	for _, key := range r.m.MapKeys() {
+		if strings.EqualFold(key.String(), name) {
+			return key, true
+		}
+	}
+	return exact, false
+}
+
+// resolve returns the record and the name a field path points to. "$.user.age" and
+// "user.age" are the field "age" of the nested record "user", unless the record has
+// a field named "user.age" itself.
+func (r mapRecord) resolve(path string) (mapRecord, string, bool) {
+	path = strings.TrimPrefix(path, "$.")
+	if _, ok := r.key(path); ok || !strings.Contains(path, ".") {
+		return r, path, true
+	}
+	parts := strings.Split(path, ".")
+	current := r
+	for _, part := range parts[:len(parts)-1] {
+		key, ok := current.key(part)
+		if !ok {
+			return r, path, false
+		}
+		nested, ok := asRecord(current.m.MapIndex(key))
+		if !ok {
+			return r, path, false
+		}
+		current = nested.(mapRecord)
+	}
+	return current, parts[len(parts)-1], true
+}
+
+func (r mapRecord) lookup(path string) (interface{}, bool) {
+	target, name, ok := r.resolve(path)
+	if !ok {
+		return nil, false
+	}
+	key, ok := target.key(name)
+	if !ok {
+		return nil, false
+	}
+	return target.m.MapIndex(key).Interface(), true
+}
+
+func (r mapRecord) set(path string, value interface{}) error {
+	target, name, ok := r.resolve(path)
+	if !ok {
+		target, name = r, strings.TrimPrefix(path, "$.")
+	}
+	key, _ := target.key(name)
+	elem := target.m.Type().Elem()
+	v := reflect.ValueOf(value)
+	switch {
+	case !v.IsValid():
+		v = reflect.Zero(elem)
+	case elem.Kind() == reflect.String:
+		v = reflect.ValueOf(text(value)).Convert(elem)
+	case v.Type().AssignableTo(elem):
+	case v.Type().ConvertibleTo(elem):
+		v = v.Convert(elem)
+	default:
+		return fmt.Errorf("cannot set %q to a %T", path, value)
+	}
+	target.m.SetMapIndex(key, v)
+	return nil
+}
+
+func (r mapRecord) remove(path string) {
+	target, name, ok := r.resolve(path)
+	if !ok {
+		return
+	}
+	if key, ok := target.key(name); ok {
+		target.m.SetMapIndex(key, reflect.Value{})
+	}
+}
+
+// text returns the text form of a value
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return fmt.Sprint(value)
+}
+
+// number returns the numeric value of a number, or of text spelling one
+func number(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.String()), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
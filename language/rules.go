@@ -0,0 +1,738 @@
+package language
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Rules are the compiled validation and transformation rules of a pipeline, see
+// "Validation Rules" and "Transformation Rules". Every record must pass every
+// validation; the transformations are then applied to it in order.
+type Rules struct {
+	validations     []validation
+	transformations []transformation
+}
+
+// validation is a rule such as FIELD("age") TYPE(INT) RANGE(18, 65)
+type validation struct {
+	rule   string
+	field  string
+	checks []check
+}
+
+// check is a condition on the value of a field
+type check struct {
+	text string // The condition as written, for error messages
+	test func(value interface{}, present bool) bool
+}
+
+// transformation is a rule such as RENAME("old_field", "new_field")
+type transformation struct {
+	rule   string
+	apply  func(r record) error
+	fields func(columns []string) []string // The columns of text data after the rule
+}
+
+// namedPatterns are the patterns MATCHES accepts by name
+var namedPatterns = map[string]string{
+	"EMAIL_REGEX": `^[^@\s]+@[^@\s]+\.[^@\s]+$`,
+}
+
+// dateLayouts are the layouts text is tried against to be a DATE
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// Compile parses validation and transformation rules. It returns nil, which applies
+// no rules, when there are none.
+func Compile(validations, transformations []string) (*Rules, error) {
+	if len(validations) == 0 && len(transformations) == 0 {
+		return nil, nil
+	}
+	rules := &Rules{}
+	for i, rule := range validations {
+		p, err := newRuleParser(rule)
+		if err != nil {
+			return nil, &RuleError{"validations", i, err}
+		}
+		v, err := p.validation()
+		if err != nil {
+			return nil, &RuleError{"validations", i, err}
+		}
+		rules.validations = append(rules.validations, v)
+	}
+	for i, rule := range transformations {
+		p, err := newRuleParser(rule)
+		if err != nil {
+			return nil, &RuleError{"transformations", i, err}
+		}
+		t, err := p.transformation()
+		if err == nil && !p.done() {
+			err = fmt.Errorf("unexpected %s after the operation", p.peek().text)
+		}
+		if err != nil {
+			return nil, &RuleError{"transformations", i, err}
+		}
+		rules.transformations = append(rules.transformations, t)
+	}
+	return rules, nil
+}
+
+// RuleError is a rule that does not compile
+type RuleError struct {
+	List  string // "validations" or "transformations"
+	Index int
+	Err   error
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("%s[%d]: %v", e.List, e.Index, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// validate returns why r fails a validation, or nil if it passes them all
+func (rules *Rules) validate(r record) error {
+	for _, v := range rules.validations {
+		value, present := r.lookup(v.field)
+		for _, c := range v.checks {
+			if !present && c.text != "REQUIRED" {
+				continue // Only REQUIRED applies to missing fields
+			}
+			if !c.test(value, present) {
+				if !present {
+					return fmt.Errorf("field %q is missing, %s", v.field, v.rule)
+				}
+				return fmt.Errorf("field %q: %s does not pass %s", v.field, truncate(text(value)), c.text)
+			}
+		}
+	}
+	return nil
+}
+
+// transform applies every transformation to r
+func (rules *Rules) transform(r record) error {
+	for _, t := range rules.transformations {
+		if err := t.apply(r); err != nil {
+			return fmt.Errorf("%s: %w", t.rule, err)
+		}
+	}
+	return nil
+}
+
+// columns returns the columns of text data once the transformations are applied
+func (rules *Rules) columns(header []string) []string {
+	columns := append([]string(nil), header...)
+	for _, t := range rules.transformations {
+		columns = t.fields(columns)
+	}
+	return columns
+}
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenPunct    // ( ) , { } :
+	tokenOperator // > >= < <= == !=
+	tokenEnd
+)
+
+type ruleToken struct {
+	kind tokenKind
+	text string
+}
+
+// ruleParser reads a single rule
+type ruleParser struct {
+	rule   string
+	tokens []ruleToken
+	pos    int
+}
+
+func newRuleParser(rule string) (*ruleParser, error) {
+	tokens, err := scanRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	return &ruleParser{rule: strings.TrimSpace(rule), tokens: tokens}, nil
+}
+
+// scanRule splits a rule into tokens
+func scanRule(rule string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	runes := []rune(rule)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, ruleToken{tokenIdent, string(runes[start:i])})
+		case unicode.IsDigit(c) || (c == '-' || c == '.') && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E') {
+				i++
+			}
+			tokens = append(tokens, ruleToken{tokenNumber, string(runes[start:i])})
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != c {
+				if runes[i] == '\\' && c == '"' {
+					i++
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string %s", string(runes[start:]))
+			}
+			i++
+			value := string(runes[start+1 : i-1])
+			if c == '"' {
+				unquoted, err := strconv.Unquote(string(runes[start:i]))
+				if err != nil {
+					return nil, fmt.Errorf("invalid string %s", string(runes[start:i]))
+				}
+				value = unquoted
+			}
+			tokens = append(tokens, ruleToken{tokenString, value})
+		case strings.ContainsRune("(),{}:", c):
+			tokens = append(tokens, ruleToken{tokenPunct, string(c)})
+			i++
+		case strings.ContainsRune("<>=!", c):
+			op := string(c)
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				op += "="
+			}
+			if op == "=" || op == "!" {
+				return nil, fmt.Errorf("unknown operator %s, use == or !=", op)
+			}
+			tokens = append(tokens, ruleToken{tokenOperator, op})
+			i += len(op)
+		default:
+			return nil, fmt.Errorf("unexpected %q", c)
+		}
+	}
+	return append(tokens, ruleToken{kind: tokenEnd, text: "end of rule"}), nil
+}
+
+func (p *ruleParser) peek() ruleToken {
+	return p.tokens[p.pos]
+}
+
+func (p *ruleParser) next() ruleToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *ruleParser) done() bool {
+	return p.peek().kind == tokenEnd
+}
+
+// expect reads a token of kind, and of text unless text is empty
+func (p *ruleParser) expect(kind tokenKind, text string) (ruleToken, error) {
+	t := p.next()
+	if t.kind != kind || text != "" && t.text != text {
+		want := text
+		if want == "" {
+			want = map[tokenKind]string{tokenIdent: "a name", tokenString: "a quoted string", tokenNumber: "a number"}[kind]
+		}
+		return t, fmt.Errorf("expected %s, found %s", want, t.text)
+	}
+	return t, nil
+}
+
+// field reads FIELD("name")
+func (p *ruleParser) field() (string, error) {
+	if _, err := p.expect(tokenIdent, "FIELD"); err != nil {
+		return "", err
+	}
+	if _, err := p.expect(tokenPunct, "("); err != nil {
+		return "", err
+	}
+	name, err := p.expect(tokenString, "")
+	if err != nil {
+		return "", err
+	}
+	_, err = p.expect(tokenPunct, ")")
+	return name.text, err
+}
+
+// validation reads FIELD("name") followed by its conditions
+func (p *ruleParser) validation() (validation, error) {
+	v := validation{rule: p.rule}
+	var err error
+	if v.field, err = p.field(); err != nil {
+		return v, err
+	}
+	if v.checks, err = p.checks("THEN"); err != nil {
+		return v, err
+	}
+	if !p.done() {
+		return v, fmt.Errorf("unexpected %s", p.peek().text)
+	}
+	return v, nil
+}
+
+// checks reads conditions, optionally joined by AND, up to the end of the rule or stop
+func (p *ruleParser) checks(stop string) ([]check, error) {
+	var checks []check
+	for !p.done() && p.peek().text != stop {
+		if p.peek().kind == tokenIdent && p.peek().text == "AND" && len(checks) > 0 {
+			p.next()
+		}
+		c, err := p.check()
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+	}
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("expected a condition such as REQUIRED or TYPE(INT), found %s", p.peek().text)
+	}
+	return checks, nil
+}
+
+// check reads a single condition
+func (p *ruleParser) check() (check, error) {
+	t := p.next()
+	if t.kind == tokenOperator {
+		literal, err := p.literal()
+		if err != nil {
+			return check{}, err
+		}
+		return compare(t.text, literal), nil
+	}
+	if t.kind != tokenIdent {
+		return check{}, fmt.Errorf("expected a condition, found %s", t.text)
+	}
+
+	switch t.text {
+	case "REQUIRED":
+		return check{text: "REQUIRED", test: func(value interface{}, present bool) bool {
+			return present && value != nil && text(value) != ""
+		}}, nil
+
+	case "TYPE":
+		if _, err := p.expect(tokenPunct, "("); err != nil {
+			return check{}, err
+		}
+		typ, err := p.expect(tokenIdent, "")
+		if err != nil {
+			return check{}, err
+		}
+		if _, err := p.expect(tokenPunct, ")"); err != nil {
+			return check{}, err
+		}
+		is, ok := types[typ.text]
+		if !ok {
+			return check{}, fmt.Errorf("unknown type %s, expected STRING, INT, FLOAT, BOOL or DATE", typ.text)
+		}
+		return check{text: "TYPE(" + typ.text + ")", test: func(value interface{}, _ bool) bool { return is(value) }}, nil
+
+	case "RANGE":
+		if _, err := p.expect(tokenPunct, "("); err != nil {
+			return check{}, err
+		}
+		low, err := p.number()
+		if err != nil {
+			return check{}, err
+		}
+		if _, err := p.expect(tokenPunct, ","); err != nil {
+			return check{}, err
+		}
+		high, err := p.number()
+		if err != nil {
+			return check{}, err
+		}
+		if _, err := p.expect(tokenPunct, ")"); err != nil {
+			return check{}, err
+		}
+		return check{text: fmt.Sprintf("RANGE(%s, %s)", formatNumber(low), formatNumber(high)), test: func(value interface{}, _ bool) bool {
+			n, ok := number(value)
+			return ok && n >= low && n <= high
+		}}, nil
+
+	case "MATCHES":
+		if _, err := p.expect(tokenPunct, "("); err != nil {
+			return check{}, err
+		}
+		pattern := p.next()
+		source := pattern.text
+		switch {
+		case pattern.kind == tokenIdent && namedPatterns[source] != "":
+			source = namedPatterns[source]
+		case pattern.kind != tokenString:
+			return check{}, fmt.Errorf("expected a quoted pattern or EMAIL_REGEX, found %s", pattern.text)
+		}
+		re, err := regexp.Compile(source)
+		if err != nil {
+			return check{}, fmt.Errorf("invalid pattern %s: %w", pattern.text, err)
+		}
+		if _, err := p.expect(tokenPunct, ")"); err != nil {
+			return check{}, err
+		}
+		return check{text: "MATCHES(" + pattern.text + ")", test: func(value interface{}, _ bool) bool {
+			return re.MatchString(text(value))
+		}}, nil
+
+	case "IN":
+		if _, err := p.expect(tokenPunct, "("); err != nil {
+			return check{}, err
+		}
+		allowed := map[string]bool{}
+		var listed []string
+		for {
+			literal, err := p.literal()
+			if err != nil {
+				return check{}, err
+			}
+			allowed[text(literal)] = true
+			listed = append(listed, strconv.Quote(text(literal)))
+			if p.peek().text != "," {
+				break
+			}
+			p.next()
+		}
+		if _, err := p.expect(tokenPunct, ")"); err != nil {
+			return check{}, err
+		}
+		return check{text: "IN (" + strings.Join(listed, ", ") + ")", test: func(value interface{}, _ bool) bool {
+			return allowed[text(value)]
+		}}, nil
+	}
+	return check{}, fmt.Errorf("unknown condition %s, expected REQUIRED, TYPE, RANGE, MATCHES, IN or a comparison", t.text)
+}
+
+// transformation reads one operation
+func (p *ruleParser) transformation() (transformation, error) {
+	t := transformation{rule: p.rule, fields: func(columns []string) []string { return columns }}
+	op, err := p.expect(tokenIdent, "")
+	if err != nil {
+		return t, err
+	}
+
+	switch op.text {
+	case "RENAME":
+		names, err := p.arguments(tokenString, tokenString)
+		if err != nil {
+			return t, err
+		}
+		from, to := names[0].text, names[1].text
+		t.apply = func(r record) error {
+			value, ok := r.lookup(from)
+			if !ok {
+				return nil
+			}
+			r.remove(from)
+			return r.set(to, value)
+		}
+		t.fields = func(columns []string) []string {
+			for i, column := range columns {
+				if strings.EqualFold(column, from) {
+					columns[i] = to
+				}
+			}
+			return columns
+		}
+		return t, nil
+
+	case "MAP":
+		if _, err := p.expect(tokenPunct, "("); err != nil {
+			return t, err
+		}
+		name, err := p.expect(tokenString, "")
+		if err != nil {
+			return t, err
+		}
+		if _, err := p.expect(tokenPunct, ","); err != nil {
+			return t, err
+		}
+		if _, err := p.expect(tokenPunct, "{"); err != nil {
+			return t, err
+		}
+		mapping := map[string]interface{}{}
+		for p.peek().text != "}" {
+			key, err := p.literal()
+			if err != nil {
+				return t, err
+			}
+			if _, err := p.expect(tokenPunct, ":"); err != nil {
+				return t, err
+			}
+			value, err := p.literal()
+			if err != nil {
+				return t, err
+			}
+			mapping[text(key)] = value
+			if p.peek().text != "," {
+				break
+			}
+			p.next()
+		}
+		if _, err := p.expect(tokenPunct, "}"); err != nil {
+			return t, err
+		}
+		if _, err := p.expect(tokenPunct, ")"); err != nil {
+			return t, err
+		}
+		t.apply = func(r record) error {
+			value, ok := r.lookup(name.text)
+			if !ok {
+				return nil
+			}
+			if mapped, ok := mapping[text(value)]; ok {
+				return r.set(name.text, mapped)
+			}
+			return nil
+		}
+		return t, nil
+
+	case "ADD_FIELD":
+		if _, err := p.expect(tokenPunct, "("); err != nil {
+			return t, err
+		}
+		name, err := p.expect(tokenString, "")
+		if err != nil {
+			return t, err
+		}
+		if _, err := p.expect(tokenPunct, ","); err != nil {
+			return t, err
+		}
+		value := func() interface{} { return nil }
+		if p.peek().text == "CURRENT_TIME" {
+			p.next()
+			if _, err := p.expect(tokenPunct, "("); err != nil {
+				return t, err
+			}
+			if _, err := p.expect(tokenPunct, ")"); err != nil {
+				return t, err
+			}
+			value = func() interface{} { return time.Now().UTC().Format(time.RFC3339) }
+		} else {
+			literal, err := p.literal()
+			if err != nil {
+				return t, err
+			}
+			value = func() interface{} { return literal }
+		}
+		if _, err := p.expect(tokenPunct, ")"); err != nil {
+			return t, err
+		}
+		t.apply = func(r record) error { return r.set(name.text, value()) }
+		t.fields = func(columns []string) []string {
+			for _, column := range columns {
+				if strings.EqualFold(column, name.text) {
+					return columns
+				}
+			}
+			return append(columns, name.text)
+		}
+		return t, nil
+
+	case "IF":
+		field, err := p.field()
+		if err != nil {
+			return t, err
+		}
+		checks, err := p.checks("THEN")
+		if err != nil {
+			return t, err
+		}
+		if _, err := p.expect(tokenIdent, "THEN"); err != nil {
+			return t, err
+		}
+		then, err := p.transformation()
+		if err != nil {
+			return t, err
+		}
+		t.apply = func(r record) error {
+			value, present := r.lookup(field)
+			if !present {
+				return nil
+			}
+			for _, c := range checks {
+				if !c.test(value, present) {
+					return nil
+				}
+			}
+			return then.apply(r)
+		}
+		t.fields = then.fields
+		return t, nil
+	}
+	return t, fmt.Errorf("unknown operation %s, expected RENAME, MAP, ADD_FIELD or IF", op.text)
+}
+
+// arguments reads a parenthesized list of tokens of the given kinds
+func (p *ruleParser) arguments(kinds ...tokenKind) ([]ruleToken, error) {
+	if _, err := p.expect(tokenPunct, "("); err != nil {
+		return nil, err
+	}
+	var tokens []ruleToken
+	for i, kind := range kinds {
+		if i > 0 {
+			if _, err := p.expect(tokenPunct, ","); err != nil {
+				return nil, err
+			}
+		}
+		t, err := p.expect(kind, "")
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	_, err := p.expect(tokenPunct, ")")
+	return tokens, err
+}
+
+// literal reads a quoted string, a number, TRUE, FALSE or NULL
+func (p *ruleParser) literal() (interface{}, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenString:
+		return t.text, nil
+	case t.kind == tokenNumber:
+		if n, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return n, nil
+		}
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", t.text)
+		}
+		return n, nil
+	case t.kind == tokenIdent && t.text == "TRUE":
+		return true, nil
+	case t.kind == tokenIdent && t.text == "FALSE":
+		return false, nil
+	case t.kind == tokenIdent && t.text == "NULL":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("expected a value, found %s", t.text)
+}
+
+func (p *ruleParser) number() (float64, error) {
+	t, err := p.expect(tokenNumber, "")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s", t.text)
+	}
+	return n, nil
+}
+
+// compare is a comparison with a literal. Numbers are compared as numbers, anything
+// else as text; text only supports == and !=.
+func compare(op string, literal interface{}) check {
+	c := check{text: op + " " + text(literal)}
+	if s, ok := literal.(string); ok {
+		c.text = op + " " + strconv.Quote(s)
+	}
+	want, numeric := number(literal)
+	if _, isText := literal.(string); isText {
+		numeric = false
+	}
+	c.test = func(value interface{}, _ bool) bool {
+		if numeric {
+			if got, ok := number(value); ok {
+				switch op {
+				case ">":
+					return got > want
+				case ">=":
+					return got >= want
+				case "<":
+					return got < want
+				case "<=":
+					return got <= want
+				case "==":
+					return got == want
+				case "!=":
+					return got != want
+				}
+			}
+		}
+		switch op {
+		case "==":
+			return text(value) == text(literal)
+		case "!=":
+			return text(value) != text(literal)
+		}
+		return false
+	}
+	return c
+}
+
+// types test whether a value is of a type of TYPE(). Text, as read from CSV, is of
+// the type it spells.
+var types = map[string]func(interface{}) bool{
+	"STRING": func(value interface{}) bool {
+		switch value.(type) {
+		case string, []byte:
+			return true
+		}
+		return false
+	},
+	"INT": func(value interface{}) bool {
+		if s, ok := value.(string); ok {
+			_, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			return err == nil
+		}
+		n, ok := number(value)
+		return ok && n == float64(int64(n))
+	},
+	"FLOAT": func(value interface{}) bool {
+		_, ok := number(value)
+		return ok
+	},
+	"BOOL": func(value interface{}) bool {
+		switch v := value.(type) {
+		case bool:
+			return true
+		case string:
+			_, err := strconv.ParseBool(v)
+			return err == nil && len(v) > 1
+		}
+		return false
+	},
+	"DATE": func(value interface{}) bool {
+		switch v := value.(type) {
+		case time.Time:
+			return true
+		case string:
+			for _, layout := range dateLayouts {
+				if _, err := time.Parse(layout, v); err == nil {
+					return true
+				}
+			}
+		}
+		return false
+	},
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func truncate(value string) string {
+	if len(value) > 40 {
+		return strconv.Quote(value[:40] + "...")
+	}
+	return strconv.Quote(value)
+}
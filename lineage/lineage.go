@@ -0,0 +1,412 @@
+package lineage
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SkySingh04/fractal/factory"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/profile"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/schema"
+	"github.com/spf13/viper"
+)
+
+// Types of run events
+const (
+	EventStart    = "START"
+	EventComplete = "COMPLETE"
+	EventFail     = "FAIL"
+	EventAbort    = "ABORT" // The run was cancelled
+)
+
+const (
+	// Producer identifies Fractal as the producer of events and facets
+	Producer = "https://github.com/SkySingh04/fractal"
+
+	DefaultNamespace = "fractal"
+	DefaultEndpoint  = "api/v1/lineage"
+
+	runEventSchema        = "https://openlineage.io/spec/2-0-2/OpenLineage.json#/$defs/RunEvent"
+	schemaFacetSchema     = "https://openlineage.io/spec/facets/1-1-1/SchemaDatasetFacet.json#/$defs/SchemaDatasetFacet"
+	statisticsFacetSchema = "https://openlineage.io/spec/facets/1-0-2/OutputStatisticsOutputDatasetFacet.json#/$defs/OutputStatisticsOutputDatasetFacet"
+	errorFacetSchema      = "https://openlineage.io/spec/facets/1-0-1/ErrorMessageRunFacet.json#/$defs/ErrorMessageRunFacet"
+	jobTypeFacetSchema    = "https://openlineage.io/spec/facets/2-0-3/JobTypeJobFacet.json#/$defs/JobTypeJobFacet"
+)
+
+// emitTimeout bounds the time an event may take to be delivered
+const emitTimeout = 5 * time.Second
+
+// schemaSample is the number of records of each dataset read to describe its schema
+const schemaSample = 1000
+
+// Config is the lineage section of a config file:
+//
+//	lineage:
+//	  namespace: analytics
+//	  url: http://marquez:5000
+//	  api_key: ${OPENLINEAGE_API_KEY}
+//	  file: lineage.jsonl
+//
+// Unset fields are taken from the OPENLINEAGE_NAMESPACE, OPENLINEAGE_URL,
+// OPENLINEAGE_ENDPOINT and OPENLINEAGE_API_KEY environment variables, see FromEnv.
+type Config struct {
+	Namespace string            `mapstructure:"namespace"` // Namespace of the jobs, "fractal" by default
+	URL       string            `mapstructure:"url"`       // Base URL of the lineage server
+	Endpoint  string            `mapstructure:"endpoint"`  // Path of the events on the server, "api/v1/lineage" by default
+	APIKey    string            `mapstructure:"api_key"`   // Sent as a bearer token
+	Headers   map[string]string `mapstructure:"headers"`
+	File      string            `mapstructure:"file"` // Every event is appended to this file as a JSON line
+}
+
+// LoadConfig reads the lineage section of a YAML configuration file
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return config, fmt.Errorf("failed to read lineage config %s: %w", path, err)
+	}
+	if err := v.UnmarshalKey("lineage", &config); err != nil {
+		return config, fmt.Errorf("invalid lineage config in %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// FromEnv fills the fields config leaves unset from the environment variables of
+// OpenLineage clients
+func FromEnv(config Config) Config {
+	fill := func(field *string, name string) {
+		if *field == "" {
+			*field = os.Getenv(name)
+		}
+	}
+	fill(&config.Namespace, "OPENLINEAGE_NAMESPACE")
+	fill(&config.URL, "OPENLINEAGE_URL")
+	fill(&config.Endpoint, "OPENLINEAGE_ENDPOINT")
+	fill(&config.APIKey, "OPENLINEAGE_API_KEY")
+	return config
+}
+
+// Enabled reports whether config sends events anywhere
+func (c Config) Enabled() bool {
+	return c.URL != "" || c.File != ""
+}
+
+// RunEvent is an OpenLineage run event
+type RunEvent struct {
+	EventType string    `json:"eventType"`
+	EventTime time.Time `json:"eventTime"`
+	Run       Run       `json:"run"`
+	Job       Job       `json:"job"`
+	Inputs    []Dataset `json:"inputs"`
+	Outputs   []Dataset `json:"outputs"`
+	Producer  string    `json:"producer"`
+	SchemaURL string    `json:"schemaURL"`
+}
+
+// Run identifies the run of an event
+type Run struct {
+	RunID  string                 `json:"runId"`
+	Facets map[string]interface{} `json:"facets,omitempty"`
+}
+
+// Job identifies the job of an event, a pipeline
+type Job struct {
+	Namespace string                 `json:"namespace"`
+	Name      string                 `json:"name"`
+	Facets    map[string]interface{} `json:"facets,omitempty"`
+}
+
+// Dataset is a dataset read or written by a run
+type Dataset struct {
+	Namespace    string                 `json:"namespace"`
+	Name         string                 `json:"name"`
+	Facets       map[string]interface{} `json:"facets,omitempty"`
+	OutputFacets map[string]interface{} `json:"outputFacets,omitempty"`
+}
+
+// Facet holds the fields every facet carries
+type Facet struct {
+	Producer  string `json:"_producer"`
+	SchemaURL string `json:"_schemaURL"`
+}
+
+// SchemaFacet describes the fields of a dataset
+type SchemaFacet struct {
+	Facet
+	Fields []SchemaField `json:"fields"`
+}
+
+// SchemaField is a field of a dataset, with the fields nested in it
+type SchemaField struct {
+	Name   string        `json:"name"`
+	Type   string        `json:"type,omitempty"`
+	Fields []SchemaField `json:"fields,omitempty"`
+}
+
+// OutputStatisticsFacet counts the records written to a dataset
+type OutputStatisticsFacet struct {
+	Facet
+	RowCount int `json:"rowCount"`
+}
+
+// ErrorFacet describes why a run failed
+type ErrorFacet struct {
+	Facet
+	Message             string `json:"message"`
+	ProgrammingLanguage string `json:"programmingLanguage"`
+}
+
+// JobTypeFacet describes the kind of job
+type JobTypeFacet struct {
+	Facet
+	ProcessingType string `json:"processingType"`
+	Integration    string `json:"integration"`
+	JobType        string `json:"jobType"`
+}
+
+// RunUUID returns the run ID of the events of a Fractal run. Lineage run IDs are
+// UUIDs, so one is derived from the name of the run; the same run always gets the same.
+func RunUUID(id string) string {
+	sum := sha1.Sum([]byte("fractal/" + id))
+	sum[6] = sum[6]&0x0f | 0x50 // Version 5, name based
+	sum[8] = sum[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// Hook is a runner.DataHook that emits a START event when a run starts and a COMPLETE,
+// FAIL or ABORT event when it finishes, naming the datasets it reads and writes
+type Hook struct {
+	namespace  string
+	transports []Transport
+
+	mu   sync.Mutex
+	runs map[string]*runState // By run ID, until the run finishes
+}
+
+// runState is what a hook knows of a run that has not finished
+type runState struct {
+	spec runner.Spec
+	data interface{}
+	read bool
+}
+
+// New builds the hook described by config
+func New(config Config) (*Hook, error) {
+	h := &Hook{namespace: config.Namespace, runs: map[string]*runState{}}
+	if h.namespace == "" {
+		h.namespace = DefaultNamespace
+	}
+	if config.URL != "" {
+		transport, err := newHTTPTransport(config)
+		if err != nil {
+			return nil, err
+		}
+		h.transports = append(h.transports, transport)
+	}
+	if config.File != "" {
+		h.transports = append(h.transports, &fileTransport{path: config.File})
+	}
+	if len(h.transports) == 0 {
+		return nil, errors.New("lineage needs a url or a file to send events to")
+	}
+	return h, nil
+}
+
+// Planned implements runner.DataHook
+func (h *Hook) Planned(run *runner.Run, spec runner.Spec) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.runs[run.ID] = &runState{spec: spec}
+}
+
+// Read implements runner.DataHook
+func (h *Hook) Read(run *runner.Run, data interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if state, ok := h.runs[run.ID]; ok {
+		state.data, state.read = data, true
+	}
+}
+
+// RunStarted emits the START event of a run
+func (h *Hook) RunStarted(run *runner.Run) {
+	h.mu.Lock()
+	state, ok := h.runs[run.ID]
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+	event := h.event(EventStart, run, run.StartedAt)
+	event.Inputs = describe(datasets(state.spec.Source, schema.KindSource, state.spec.SourceRequest, nil), nil, nil)
+	for _, target := range targets(state.spec) {
+		event.Outputs = append(event.Outputs, describe(datasets(target.Destination, schema.KindDestination, target.Request, nil), nil, nil)...)
+	}
+	h.emit(run, event)
+}
+
+// RunFinished emits the COMPLETE, FAIL or ABORT event of a run. The datasets carry
+// their schema when the data was read, and outputs the number of records written
+// when the run succeeded.
+func (h *Hook) RunFinished(run *runner.Run) {
+	h.mu.Lock()
+	state, ok := h.runs[run.ID]
+	delete(h.runs, run.ID)
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	eventType := EventComplete
+	switch run.Status {
+	case runner.StatusFailed:
+		eventType = EventFail
+	case runner.StatusCancelled:
+		eventType = EventAbort
+	}
+	event := h.event(eventType, run, run.FinishedAt)
+	if run.Error != "" {
+		event.Run.Facets = map[string]interface{}{
+			"errorMessage": ErrorFacet{Facet: facet(errorFacetSchema), Message: run.Error, ProgrammingLanguage: "go"},
+		}
+	}
+
+	var schemas map[string]SchemaFacet
+	var records map[string]int
+	if state.read {
+		schemas, records = profiled(state.data)
+	}
+	var rows func(table string) int
+	if run.Status == runner.StatusSucceeded {
+		rows = func(table string) int { return records[table] }
+	}
+	event.Inputs = describe(datasets(state.spec.Source, schema.KindSource, state.spec.SourceRequest, state.data), schemas, nil)
+	for _, target := range targets(state.spec) {
+		event.Outputs = append(event.Outputs, describe(datasets(target.Destination, schema.KindDestination, target.Request, state.data), schemas, rows)...)
+	}
+	h.emit(run, event)
+}
+
+// event returns an event of a run without datasets
+func (h *Hook) event(eventType string, run *runner.Run, at time.Time) RunEvent {
+	return RunEvent{
+		EventType: eventType,
+		EventTime: at,
+		Run:       Run{RunID: RunUUID(run.ID)},
+		Job: Job{Namespace: h.namespace, Name: run.Pipeline, Facets: map[string]interface{}{
+			"jobType": JobTypeFacet{Facet: facet(jobTypeFacetSchema), ProcessingType: "BATCH", Integration: "FRACTAL", JobType: "JOB"},
+		}},
+		Inputs:    []Dataset{},
+		Outputs:   []Dataset{},
+		Producer:  Producer,
+		SchemaURL: runEventSchema,
+	}
+}
+
+// emit sends an event with every transport. Failures are logged.
+func (h *Hook) emit(run *runner.Run, event RunEvent) {
+	for _, transport := range h.transports {
+		ctx, cancel := context.WithTimeout(context.Background(), emitTimeout)
+		if err := transport.Emit(ctx, event); err != nil {
+			logger.Warnf("Failed to emit the %s lineage event of run %s: %v", event.EventType, run.ID, err)
+		}
+		cancel()
+	}
+}
+
+func facet(schemaURL string) Facet {
+	return Facet{Producer: Producer, SchemaURL: schemaURL}
+}
+
+// targets returns every destination of a spec
+func targets(spec runner.Spec) []runner.Target {
+	all := []runner.Target{{Destination: spec.Destination, Request: spec.DestinationRequest}}
+	return append(all, spec.Destinations...)
+}
+
+// datasets names the datasets an integration reads or writes, none when it cannot
+// tell. Requests get the defaults of the integration, as they do in a run.
+func datasets(name string, kind schema.Kind, req interfaces.Request, data interface{}) []interfaces.Dataset {
+	var integration interface{}
+	var err error
+	var described schema.Integration
+	if kind == schema.KindSource {
+		integration, err = factory.CreateSource(name)
+		if err == nil {
+			described, err = schema.ForSource(name)
+		}
+	} else {
+		integration, err = factory.CreateDestination(name)
+		if err == nil {
+			described, err = schema.ForDestination(name)
+		}
+	}
+	if err != nil {
+		return nil
+	}
+	namer, ok := integration.(interfaces.DatasetNamer)
+	if !ok {
+		return nil
+	}
+	schema.ApplyDefaults(described, &req)
+	return namer.Datasets(req, data)
+}
+
+// describe turns named datasets into the datasets of an event, with the schema of
+// their table when schemas has it and, when rows is set, the number of records written
+func describe(named []interfaces.Dataset, schemas map[string]SchemaFacet, rows func(table string) int) []Dataset {
+	described := []Dataset{}
+	for _, dataset := range named {
+		d := Dataset{Namespace: dataset.Namespace, Name: dataset.Name}
+		if facet, ok := schemas[dataset.Table]; ok {
+			d.Facets = map[string]interface{}{"schema": facet}
+		}
+		if rows != nil {
+			d.OutputFacets = map[string]interface{}{
+				"outputStatistics": OutputStatisticsFacet{Facet: facet(statisticsFacetSchema), RowCount: rows(dataset.Table)},
+			}
+		}
+		described = append(described, d)
+	}
+	return described
+}
+
+// profiled describes the fields of the data read by a run and counts its records, by
+// table, with "" for data holding a single dataset
+func profiled(data interface{}) (map[string]SchemaFacet, map[string]int) {
+	report, err := profile.Data(data, profile.Options{Sample: schemaSample, Top: 1})
+	if err != nil {
+		return nil, map[string]int{"": runner.CountRecords(data)}
+	}
+	schemas, records := map[string]SchemaFacet{}, map[string]int{}
+	for _, dataset := range report.Datasets {
+		records[dataset.Name] = dataset.Records
+		if fields := schemaFields(dataset.Fields, ""); len(fields) > 0 {
+			schemas[dataset.Name] = SchemaFacet{Facet: facet(schemaFacetSchema), Fields: fields}
+		}
+	}
+	return schemas, records
+}
+
+// schemaFields returns the fields directly under prefix, with the fields of the
+// objects they hold, or of the objects in the arrays they hold, nested
+func schemaFields(fields []profile.Field, prefix string) []SchemaField {
+	var described []SchemaField
+	for _, field := range fields {
+		name, ok := strings.CutPrefix(field.Path, prefix)
+		if !ok || name == "" || strings.ContainsAny(name, ".[") {
+			continue
+		}
+		nested := append(schemaFields(fields, field.Path+"."), schemaFields(fields, field.Path+"[].")...)
+		described = append(described, SchemaField{Name: name, Type: field.Type, Fields: nested})
+	}
+	return described
+}
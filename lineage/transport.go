@@ -0,0 +1,105 @@
+package lineage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/SkySingh04/fractal/secrets"
+)
+
+// Transport delivers lineage events
+type Transport interface {
+	Emit(ctx context.Context, event RunEvent) error
+}
+
+// httpTransport posts events to the lineage API of a server such as Marquez
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHTTPTransport(config Config) (*httpTransport, error) {
+	base, err := secrets.Resolve(context.Background(), config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("lineage url: %w", err)
+	}
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	t := &httpTransport{
+		url:     strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(endpoint, "/"),
+		headers: map[string]string{},
+		client:  &http.Client{Timeout: emitTimeout},
+	}
+	if config.APIKey != "" {
+		key, err := secrets.Resolve(context.Background(), config.APIKey)
+		if err != nil {
+			return nil, fmt.Errorf("lineage api_key: %w", err)
+		}
+		t.headers["Authorization"] = "Bearer " + key
+	}
+	for name, value := range config.Headers {
+		if t.headers[name], err = secrets.Resolve(context.Background(), value); err != nil {
+			return nil, fmt.Errorf("lineage header %s: %w", name, err)
+		}
+	}
+	return t, nil
+}
+
+func (t *httpTransport) Emit(ctx context.Context, event RunEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s answered %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// fileTransport appends events to a file, one JSON object per line
+type fileTransport struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (t *fileTransport) Emit(ctx context.Context, event RunEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	file, err := os.OpenFile(t.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
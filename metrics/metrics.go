@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/SkySingh04/fractal/progress"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	gofrMetrics "gofr.dev/pkg/gofr/metrics"
+)
+
+// Names of the metrics recorded for every run. Record counts are up-down counters,
+// because gofr counters can only be incremented by one.
+const (
+	RunsTotal      = "fractal_runs_total"
+	RunDuration    = "fractal_run_duration_seconds"
+	JobsInFlight   = "fractal_jobs_in_flight"
+	LastSuccess    = "fractal_last_success_timestamp_seconds"
+	RecordsRead    = "fractal_records_read"
+	RecordsWritten = "fractal_records_written"
+	RecordsFailed  = "fractal_records_failed"
+	FetchDuration  = "fractal_fetch_duration_seconds"
+	SendDuration   = "fractal_send_duration_seconds"
+	RetriesTotal   = "fractal_retries_total"
+	SourceLag      = "fractal_source_lag"
+)
+
+// durationBuckets are the histogram buckets, in seconds, of every duration metric
+var durationBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600}
+
+// Hook records the metrics of every run in a gofr metrics manager
+type Hook struct {
+	manager gofrMetrics.Manager
+}
+
+// Register declares the run metrics in manager and records every run from now on
+func Register(manager gofrMetrics.Manager) *Hook {
+	manager.NewCounter(RunsTotal, "Number of finished runs, by pipeline and status")
+	manager.NewHistogram(RunDuration, "Time a run took from start to finish", durationBuckets...)
+	manager.NewUpDownCounter(JobsInFlight, "Number of runs in progress")
+	manager.NewGauge(LastSuccess, "Unix time at which the last successful run of a pipeline finished")
+	manager.NewUpDownCounter(RecordsRead, "Number of records read from sources")
+	manager.NewUpDownCounter(RecordsWritten, "Number of records written to destinations")
+	manager.NewUpDownCounter(RecordsFailed, "Number of records a destination failed to receive")
+	manager.NewHistogram(FetchDuration, "Time taken to fetch the data of a run from its source", durationBuckets...)
+	manager.NewHistogram(SendDuration, "Time taken to send the data of a run to a destination, retries included", durationBuckets...)
+	manager.NewCounter(RetriesTotal, "Number of times sending to a destination was retried")
+	manager.NewGauge(SourceLag, "Messages a streaming source still holds for a pipeline to read")
+
+	hook := &Hook{manager: manager}
+	runner.RegisterHook(hook)
+	return hook
+}
+
+// Handler serves the metrics at /metrics in the Prometheus text format, for processes
+// that do not run the gofr metrics server. Every gofr app registers a collector of its
+// own, so series that several of them export are skipped rather than failing the scrape.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}))
+	return mux
+}
+
+// RunStarted counts the run as in flight and follows the lag its source reports
+func (h *Hook) RunStarted(run *runner.Run) {
+	h.manager.DeltaUpDownCounter(context.Background(), JobsInFlight, 1, "pipeline", run.Pipeline)
+
+	events, _ := progress.Start(run.ID).Subscribe()
+	go func() {
+		var lag int64
+		for event := range events {
+			if event.Lag != lag {
+				lag = event.Lag
+				h.manager.SetGauge(SourceLag, float64(lag), "pipeline", run.Pipeline, "source", run.Source)
+			}
+		}
+	}()
+}
+
+// RunFinished records the outcome and duration of the run
+func (h *Hook) RunFinished(run *runner.Run) {
+	ctx := context.Background()
+	h.manager.DeltaUpDownCounter(ctx, JobsInFlight, -1, "pipeline", run.Pipeline)
+	h.manager.IncrementCounter(ctx, RunsTotal, "pipeline", run.Pipeline, "status", string(run.Status))
+	h.manager.RecordHistogram(ctx, RunDuration, run.Duration().Seconds(), "pipeline", run.Pipeline, "status", string(run.Status))
+	if run.Status == runner.StatusSucceeded {
+		h.manager.SetGauge(LastSuccess, float64(run.FinishedAt.Unix()), "pipeline", run.Pipeline)
+	}
+}
+
+// Fetched records how long the source took and how many records it returned
+func (h *Hook) Fetched(run *runner.Run, took time.Duration, err error) {
+	ctx := context.Background()
+	h.manager.RecordHistogram(ctx, FetchDuration, took.Seconds(), "pipeline", run.Pipeline, "source", run.Source, "status", status(err))
+	if err == nil {
+		h.manager.DeltaUpDownCounter(ctx, RecordsRead, float64(run.RecordsRead), "pipeline", run.Pipeline, "source", run.Source)
+	}
+}
+
+// Sent records how long a destination took, its retries and the records it received
+func (h *Hook) Sent(run *runner.Run, destination string, attempts int, took time.Duration, err error) {
+	ctx := context.Background()
+	labels := []string{"pipeline", run.Pipeline, "source", run.Source, "destination", destination}
+	h.manager.RecordHistogram(ctx, SendDuration, took.Seconds(), append(labels, "status", status(err))...)
+	for retry := 1; retry < attempts; retry++ {
+		h.manager.IncrementCounter(ctx, RetriesTotal, labels...)
+	}
+	if err != nil {
+		h.manager.DeltaUpDownCounter(ctx, RecordsFailed, float64(run.RecordsRead), labels...)
+		return
+	}
+	h.manager.DeltaUpDownCounter(ctx, RecordsWritten, float64(run.RecordsRead), labels...)
+}
+
+func status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,274 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/SkySingh04/fractal/secrets"
+)
+
+const (
+	defaultSMTPPort = 587
+	defaultSubject  = "[fractal] {{.Pipeline}} {{.Event}}"
+)
+
+func init() {
+	Register("webhook", newWebhook)
+	Register("slack", newSlack)
+	Register("email", newEmail)
+}
+
+// templateFuncs are available in the templates of notifiers
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// resolve expands the ${ENV} and secret:// references of a notifier setting
+func resolve(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	return secrets.Resolve(context.Background(), value)
+}
+
+// webhook posts notifications to a URL, as JSON or as the body rendered by a template
+type webhook struct {
+	url     string
+	headers map[string]string
+	body    *template.Template
+	client  *http.Client
+}
+
+func newWebhook(config NotifierConfig) (Notifier, error) {
+	url, err := resolve(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, errors.New("url is required")
+	}
+	w := &webhook{url: url, headers: map[string]string{}, client: &http.Client{Timeout: sendTimeout}}
+	for name, value := range config.Headers {
+		if w.headers[name], err = resolve(value); err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+	}
+	if config.Body != "" {
+		if w.body, err = template.New("body").Funcs(templateFuncs).Parse(config.Body); err != nil {
+			return nil, fmt.Errorf("invalid body template: %w", err)
+		}
+	}
+	return w, nil
+}
+
+func (w *webhook) Notify(ctx context.Context, notification Notification) error {
+	var body bytes.Buffer
+	if w.body != nil {
+		if err := w.body.Execute(&body, notification); err != nil {
+			return fmt.Errorf("failed to render the body: %w", err)
+		}
+	} else if err := json.NewEncoder(&body).Encode(notification); err != nil {
+		return err
+	}
+	return post(ctx, w.client, w.url, w.headers, body.Bytes())
+}
+
+// post sends body to url with a JSON content type unless headers set another one
+func post(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s answered %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// slack posts notifications to a Slack-compatible incoming webhook
+type slack struct {
+	url    string
+	client *http.Client
+}
+
+func newSlack(config NotifierConfig) (Notifier, error) {
+	url, err := resolve(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, errors.New("url is required")
+	}
+	return &slack{url: url, client: &http.Client{Timeout: sendTimeout}}, nil
+}
+
+// slackIcons mark the messages of each event
+var slackIcons = map[string]string{
+	EventFailure:   ":red_circle:",
+	EventRecovery:  ":large_green_circle:",
+	EventThreshold: ":warning:",
+}
+
+func (s *slack) Notify(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(map[string]string{"text": slackIcons[notification.Event] + " " + message(notification)})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.url, nil, body)
+}
+
+// message describes a notification in a few lines of text
+func message(notification Notification) string {
+	var b strings.Builder
+	switch notification.Event {
+	case EventFailure:
+		fmt.Fprintf(&b, "Pipeline %s failed\n", notification.Pipeline)
+	case EventRecovery:
+		fmt.Fprintf(&b, "Pipeline %s recovered\n", notification.Pipeline)
+	case EventThreshold:
+		fmt.Fprintf(&b, "Pipeline %s breached its thresholds: %s\n", notification.Pipeline, strings.Join(notification.Breaches, "; "))
+	}
+	b.WriteString(notification.Summary.String())
+	return b.String()
+}
+
+// email sends notifications over SMTP, upgrading to TLS when the server offers it
+type email struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+	subject  *template.Template
+}
+
+func newEmail(config NotifierConfig) (Notifier, error) {
+	if config.Host == "" || config.From == "" || len(config.To) == 0 {
+		return nil, errors.New("host, from and to are required")
+	}
+	for _, address := range append([]string{config.From}, config.To...) {
+		if err := headerValue(address); err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", address, err)
+		}
+	}
+	password, err := resolve(config.Password)
+	if err != nil {
+		return nil, err
+	}
+	port := config.Port
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	subject := config.Subject
+	if subject == "" {
+		subject = defaultSubject
+	}
+	e := &email{
+		addr:     net.JoinHostPort(config.Host, strconv.Itoa(port)),
+		host:     config.Host,
+		username: config.Username,
+		password: password,
+		from:     config.From,
+		to:       config.To,
+	}
+	if e.subject, err = template.New("subject").Funcs(templateFuncs).Parse(subject); err != nil {
+		return nil, fmt.Errorf("invalid subject template: %w", err)
+	}
+	return e, nil
+}
+
+// headerValue rejects a value that would end its mail header and start another
+func headerValue(value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return errors.New("line breaks are not allowed in mail headers")
+	}
+	return nil
+}
+
+func (e *email) Notify(ctx context.Context, notification Notification) error {
+	var subject strings.Builder
+	if err := e.subject.Execute(&subject, notification); err != nil {
+		return fmt.Errorf("failed to render the subject: %w", err)
+	}
+	if err := headerValue(subject.String()); err != nil {
+		return fmt.Errorf("invalid subject %q: %w", subject.String(), err)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject.String())
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(message(notification), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+			return err
+		}
+	}
+	if e.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(e.from); err != nil {
+		return err
+	}
+	for _, to := range e.to {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,394 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/store"
+	"github.com/spf13/viper"
+)
+
+// Events a notifier can be told about
+const (
+	EventFailure   = "failure"   // A run failed
+	EventRecovery  = "recovery"  // A run succeeded after the previous run of its pipeline failed
+	EventThreshold = "threshold" // A run breached a threshold
+)
+
+// Events lists every event
+var Events = []string{EventFailure, EventRecovery, EventThreshold}
+
+// sendTimeout bounds the time a notifier may take to deliver a notification
+const sendTimeout = 10 * time.Second
+
+// Config is the notifications section of a config file:
+//
+//	notifications:
+//	  summary_file: runs.jsonl
+//	  thresholds:
+//	    max_duration: 10m
+//	    max_errors: 0
+//	  notifiers:
+//	    - type: slack
+//	      url: ${SLACK_WEBHOOK_URL}
+//	      on: [failure, recovery]
+//	    - type: email
+//	      host: smtp.example.com
+//	      from: fractal@example.com
+//	      to: [oncall@example.com]
+type Config struct {
+	SummaryFile string           `mapstructure:"summary_file"` // Every run summary is appended to this file as a JSON line
+	Thresholds  Thresholds       `mapstructure:"thresholds"`
+	Notifiers   []NotifierConfig `mapstructure:"notifiers"`
+}
+
+// Thresholds are the limits whose breach by a run is a threshold event. Unset limits
+// are not checked.
+type Thresholds struct {
+	MaxDuration    time.Duration `mapstructure:"max_duration"`
+	MaxErrors      *int          `mapstructure:"max_errors"`
+	MaxQuarantined *int          `mapstructure:"max_quarantined"`
+	MinRecords     *int          `mapstructure:"min_records"` // Fewest records a successful run should read
+}
+
+// NotifierConfig configures a notifier. Which fields apply depends on its type.
+type NotifierConfig struct {
+	Type string   `mapstructure:"type"` // webhook, slack or email
+	On   []string `mapstructure:"on"`   // Events to send, all of them when empty
+
+	// webhook and slack
+	URL string `mapstructure:"url"`
+	// webhook: Headers of the request, and Body a text/template of its body, the
+	// notification as JSON by default
+	Headers map[string]string `mapstructure:"headers"`
+	Body    string            `mapstructure:"body"`
+
+	// email
+	Host     string   `mapstructure:"host"`
+	Port     int      `mapstructure:"port"` // 587 by default
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+	Subject  string   `mapstructure:"subject"` // A text/template, "[fractal] {{.Pipeline}} {{.Event}}" by default
+}
+
+// LoadConfig reads the notifications section of a YAML configuration file
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return config, fmt.Errorf("failed to read notifications config %s: %w", path, err)
+	}
+	if err := v.UnmarshalKey("notifications", &config); err != nil {
+		return config, fmt.Errorf("invalid notifications config in %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Summary sums up a finished run
+type Summary struct {
+	RunID           string         `json:"run_id"`
+	Pipeline        string         `json:"pipeline"`
+	Source          string         `json:"source"`
+	Destination     string         `json:"destination"`
+	Trigger         string         `json:"trigger"`
+	Status          runner.Status  `json:"status"`
+	StartedAt       time.Time      `json:"started_at"`
+	FinishedAt      time.Time      `json:"finished_at"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	RecordsRead     int            `json:"records_read"`
+	RecordsWritten  int            `json:"records_written"`
+	Errors          map[string]int `json:"errors,omitempty"` // By kind, such as read or validation
+	Quarantined     int            `json:"quarantined"`
+	Error           string         `json:"error,omitempty"`
+}
+
+// SummaryOf sums up a finished run
+func SummaryOf(run *runner.Run) Summary {
+	return Summary{
+		RunID:           run.ID,
+		Pipeline:        run.Pipeline,
+		Source:          run.Source,
+		Destination:     run.Destination,
+		Trigger:         run.Trigger,
+		Status:          run.Status,
+		StartedAt:       run.StartedAt,
+		FinishedAt:      run.FinishedAt,
+		DurationSeconds: run.Duration().Seconds(),
+		RecordsRead:     run.RecordsRead,
+		RecordsWritten:  run.RecordsWritten,
+		Errors:          run.Errors,
+		Quarantined:     run.Quarantined,
+		Error:           run.Error,
+	}
+}
+
+// Duration returns how long the run took
+func (s Summary) Duration() time.Duration {
+	return time.Duration(s.DurationSeconds * float64(time.Second)).Round(time.Millisecond)
+}
+
+// ErrorCount returns the number of errors of every kind
+func (s Summary) ErrorCount() int {
+	count := 0
+	for _, n := range s.Errors {
+		count += n
+	}
+	return count
+}
+
+// String renders the summary on one line, for logs and messages
+func (s Summary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pipeline %s run %s %s in %s: %d records read, %d written, %d errors",
+		s.Pipeline, s.RunID, s.Status, s.Duration(), s.RecordsRead, s.RecordsWritten, s.ErrorCount())
+	if len(s.Errors) > 0 {
+		var kinds []string
+		for kind, n := range s.Errors {
+			kinds = append(kinds, fmt.Sprintf("%s: %d", kind, n))
+		}
+		sort.Strings(kinds)
+		fmt.Fprintf(&b, " (%s)", strings.Join(kinds, ", "))
+	}
+	fmt.Fprintf(&b, ", %d quarantined", s.Quarantined)
+	if s.Error != "" {
+		fmt.Fprintf(&b, "; %s", s.Error)
+	}
+	return b.String()
+}
+
+// Notification is what a notifier is told. Its fields, those of the summary included,
+// can be used in templates, e.g. {{.Event}} or {{.Pipeline}}.
+type Notification struct {
+	Event    string   `json:"event"`
+	Breaches []string `json:"breaches,omitempty"` // The thresholds breached, for threshold events
+	Summary
+}
+
+// Notifier delivers notifications
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// Builder creates a notifier from its configuration
+type Builder func(config NotifierConfig) (Notifier, error)
+
+var (
+	buildersMu sync.RWMutex
+	builders   = map[string]Builder{}
+)
+
+// Register makes a type of notifier available to configs
+func Register(typ string, build Builder) {
+	buildersMu.Lock()
+	defer buildersMu.Unlock()
+	builders[typ] = build
+}
+
+// Types lists the registered types of notifiers
+func Types() []string {
+	buildersMu.RLock()
+	defer buildersMu.RUnlock()
+	var types []string
+	for typ := range builders {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// History returns past runs, to tell whether a run recovers from a failure
+type History interface {
+	ListRuns(filter store.RunFilter) ([]runner.Run, error)
+}
+
+// Hook is a runner.Hook that writes the summary of every run and notifies of
+// failures, recoveries and threshold breaches
+type Hook struct {
+	summaryFile string
+	thresholds  Thresholds
+	notifiers   []subscription
+	history     History
+
+	mu         sync.Mutex
+	lastFailed map[string]bool // Whether the last run of each pipeline failed
+
+	sending sync.WaitGroup // Notifications being sent, see Wait
+}
+
+// subscription is a notifier together with the events it is sent
+type subscription struct {
+	typ      string
+	events   map[string]bool
+	notifier Notifier
+}
+
+// New builds the hook described by config. history, which may be nil, tells whether
+// the first run of a pipeline after a restart recovers from a failure.
+func New(config Config, history History) (*Hook, error) {
+	h := &Hook{summaryFile: config.SummaryFile, thresholds: config.Thresholds, history: history, lastFailed: map[string]bool{}}
+	for i, notifierConfig := range config.Notifiers {
+		buildersMu.RLock()
+		build, ok := builders[notifierConfig.Type]
+		buildersMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("notifiers[%d]: unknown type %q, use one of %s", i, notifierConfig.Type, strings.Join(Types(), ", "))
+		}
+		notifier, err := build(notifierConfig)
+		if err != nil {
+			return nil, fmt.Errorf("notifiers[%d] (%s): %w", i, notifierConfig.Type, err)
+		}
+		events := map[string]bool{}
+		for _, event := range notifierConfig.On {
+			if !contains(Events, event) {
+				return nil, fmt.Errorf("notifiers[%d] (%s): unknown event %q, use one of %s", i, notifierConfig.Type, event, strings.Join(Events, ", "))
+			}
+			events[event] = true
+		}
+		if len(events) == 0 {
+			for _, event := range Events {
+				events[event] = true
+			}
+		}
+		h.notifiers = append(h.notifiers, subscription{notifierConfig.Type, events, notifier})
+	}
+	return h, nil
+}
+
+// RunStarted implements runner.Hook
+func (h *Hook) RunStarted(*runner.Run) {}
+
+// RunFinished writes the summary of the run and sends its notifications in the
+// background, so that a slow notifier does not hold up the run. Failures to do so
+// are logged.
+func (h *Hook) RunFinished(run *runner.Run) {
+	summary := SummaryOf(run)
+	if h.summaryFile != "" {
+		if err := h.writeSummary(summary); err != nil {
+			logger.Warnf("Failed to write the summary of run %s to %s: %v", run.ID, h.summaryFile, err)
+		}
+	}
+
+	for _, notification := range h.notifications(run, summary) {
+		for _, subscription := range h.notifiers {
+			if !subscription.events[notification.Event] {
+				continue
+			}
+			h.sending.Add(1)
+			go func() {
+				defer h.sending.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+				defer cancel()
+				if err := subscription.notifier.Notify(ctx, notification); err != nil {
+					logger.Warnf("Failed to send the %s notification of run %s with %s: %v", notification.Event, run.ID, subscription.typ, err)
+				}
+			}()
+		}
+	}
+}
+
+// Wait blocks until the notifications being sent are delivered or given up on,
+// which takes at most the send timeout of 10s
+func (h *Hook) Wait() {
+	h.sending.Wait()
+}
+
+// notifications returns the events of a finished run. Cancelled runs have none.
+func (h *Hook) notifications(run *runner.Run, summary Summary) []Notification {
+	if run.Status != runner.StatusFailed && run.Status != runner.StatusSucceeded {
+		return nil
+	}
+	var notifications []Notification
+	failed := run.Status == runner.StatusFailed
+	if failed {
+		notifications = append(notifications, Notification{Event: EventFailure, Summary: summary})
+	} else if h.previousFailed(run) {
+		notifications = append(notifications, Notification{Event: EventRecovery, Summary: summary})
+	}
+	h.mu.Lock()
+	h.lastFailed[run.Pipeline] = failed
+	h.mu.Unlock()
+
+	if breaches := h.thresholds.Breaches(summary); len(breaches) > 0 {
+		notifications = append(notifications, Notification{Event: EventThreshold, Breaches: breaches, Summary: summary})
+	}
+	return notifications
+}
+
+// previousFailed reports whether the run before run of the same pipeline failed
+func (h *Hook) previousFailed(run *runner.Run) bool {
+	h.mu.Lock()
+	failed, known := h.lastFailed[run.Pipeline]
+	h.mu.Unlock()
+	if known || h.history == nil {
+		return failed
+	}
+	runs, err := h.history.ListRuns(store.RunFilter{Pipeline: run.Pipeline, Limit: 10})
+	if err != nil {
+		logger.Warnf("Failed to read the previous runs of pipeline %s: %v", run.Pipeline, err)
+		return false
+	}
+	for _, previous := range runs {
+		if previous.ID == run.ID || previous.Status == runner.StatusRunning || previous.Status == runner.StatusCancelled {
+			continue
+		}
+		return previous.Status == runner.StatusFailed
+	}
+	return false
+}
+
+// Breaches lists the thresholds a run breached
+func (t Thresholds) Breaches(summary Summary) []string {
+	var breaches []string
+	if t.MaxDuration > 0 && summary.Duration() > t.MaxDuration {
+		breaches = append(breaches, fmt.Sprintf("took %s, more than %s", summary.Duration(), t.MaxDuration))
+	}
+	if t.MaxErrors != nil && summary.ErrorCount() > *t.MaxErrors {
+		breaches = append(breaches, fmt.Sprintf("%d errors, more than %d", summary.ErrorCount(), *t.MaxErrors))
+	}
+	if t.MaxQuarantined != nil && summary.Quarantined > *t.MaxQuarantined {
+		breaches = append(breaches, fmt.Sprintf("%d records quarantined, more than %d", summary.Quarantined, *t.MaxQuarantined))
+	}
+	if t.MinRecords != nil && summary.Status == runner.StatusSucceeded && summary.RecordsRead < *t.MinRecords {
+		breaches = append(breaches, fmt.Sprintf("%d records read, fewer than %d", summary.RecordsRead, *t.MinRecords))
+	}
+	return breaches
+}
+
+// writeSummary appends the summary to the summary file as a JSON line
+func (h *Hook) writeSummary(summary Summary) error {
+	line, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	file, err := os.OpenFile(h.summaryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
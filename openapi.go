@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/SkySingh04/fractal/controller"
+	"github.com/spf13/cobra"
+)
+
+// openAPICommand implements `fractal openapi` which prints or writes the generated OpenAPI document
+func openAPICommand() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "openapi",
+		Short: "Print or write the OpenAPI document of the HTTP API",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "" {
+				if err := controller.WriteOpenAPI(output); err != nil {
+					return fmt.Errorf("failed to write OpenAPI document: %w", err)
+				}
+				return nil
+			}
+
+			doc, err := controller.OpenAPI()
+			if err != nil {
+				return fmt.Errorf("failed to generate OpenAPI document: %w", err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(doc))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write the document to this file instead of stdout")
+	return cmd
+}
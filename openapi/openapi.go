@@ -0,0 +1,341 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SkySingh04/fractal/schema"
+)
+
+// Info is the metadata shown at the top of the generated document
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+// Param describes a query parameter accepted by an operation
+type Param struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// Operation describes an HTTP endpoint. Body and Response hold zero values of the
+// request body and response data types; their schemas are derived by reflection.
+// Operations with a Scope require an authenticated caller that holds it.
+type Operation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Tag         string
+	Scope       string
+	Query       []Param
+	Body        interface{}
+	Response    interface{}
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// securitySchemes are the ways a caller can authenticate
+var securitySchemes = map[string]interface{}{
+	"apiKey": map[string]interface{}{
+		"type": "apiKey", "in": "header", "name": "X-API-Key",
+	},
+	"bearer": map[string]interface{}{
+		"type": "http", "scheme": "bearer", "bearerFormat": "JWT",
+	},
+	"hmac": map[string]interface{}{
+		"type": "apiKey", "in": "header", "name": "X-Fractal-Signature",
+		"description": "Hex HMAC-SHA256 of METHOD, request URI, X-Fractal-Timestamp and the hex SHA-256 of the body, joined by newlines. The key is named in X-Fractal-Key-Id.",
+	},
+}
+
+// Generate builds an OpenAPI 3 document for operations. Request body fields that
+// belong to an integration are described with the metadata from the catalog.
+func Generate(info Info, operations []Operation, catalog schema.Catalog) ([]byte, error) {
+	g := &generator{
+		components:   map[string]interface{}{},
+		types:        map[string]reflect.Type{},
+		descriptions: fieldDescriptions(catalog),
+		catalog:      catalog,
+	}
+
+	paths := map[string]map[string]interface{}{}
+	for _, op := range operations {
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]interface{}{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = g.operation(op)
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.0.0",
+		"info":    info,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas":         g.components,
+			"securitySchemes": securitySchemes,
+		},
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+type generator struct {
+	components   map[string]interface{}
+	types        map[string]reflect.Type // Struct type of each component
+	descriptions map[string]string
+	catalog      schema.Catalog
+}
+
+func (g *generator) operation(op Operation) map[string]interface{} {
+	operation := map[string]interface{}{
+		"summary":     op.Summary,
+		"operationId": operationID(op),
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "Successful response",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"data": g.schemaOf(reflect.TypeOf(op.Response)),
+							},
+						},
+					},
+				},
+			},
+			"default": map[string]interface{}{
+				"description": "Error response",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"},
+					},
+				},
+			},
+		},
+	}
+	g.components["Error"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"message": map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+	}
+	description := op.Description
+	if op.Scope != "" {
+		description = strings.TrimSpace(description + " Requires the `" + op.Scope + "` scope.")
+		operation["security"] = []map[string][]string{{"apiKey": {}}, {"bearer": {}}, {"hmac": {}}}
+	}
+	if description != "" {
+		operation["description"] = description
+	}
+	if op.Tag != "" {
+		operation["tags"] = []string{op.Tag}
+	}
+
+	var params []interface{}
+	for _, match := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		params = append(params, map[string]interface{}{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, param := range op.Query {
+		params = append(params, map[string]interface{}{
+			"name":        param.Name,
+			"in":          "query",
+			"description": param.Description,
+			"schema":      map[string]interface{}{"type": param.Type},
+		})
+	}
+	if params != nil {
+		operation["parameters"] = params
+	}
+
+	if op.Body != nil {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": g.schemaOf(reflect.TypeOf(op.Body)),
+				},
+			},
+		}
+	}
+	return operation
+}
+
+// schemaOf returns the schema of typ, registering named structs as components
+func (g *generator) schemaOf(typ reflect.Type) map[string]interface{} {
+	if typ == nil {
+		return map[string]interface{}{}
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schemaOf(typ.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaOf(typ.Elem())}
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return g.structSchema(typ)
+		}
+		name := g.componentName(typ)
+		if _, done := g.components[name]; !done {
+			g.components[name] = map[string]interface{}{} // Guard against recursive types
+			g.components[name] = g.structSchema(typ)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// componentName names the component of a named struct after its type, prefixed with
+// its package when a type of another package already took the name
+func (g *generator) componentName(typ reflect.Type) string {
+	name := typ.Name()
+	if taken, ok := g.types[name]; ok && taken != typ {
+		pkg := path.Base(typ.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	g.types[name] = typ
+	return name
+}
+
+func (g *generator) structSchema(typ reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schemaOf(field.Type)
+		if description, ok := g.descriptions[name]; ok {
+			property = withDescription(property, description)
+		}
+		if enum := g.enumFor(typ, name); enum != nil {
+			property["enum"] = enum
+		}
+		properties[name] = property
+		if field.Tag.Get("required") == "true" || (!strings.Contains(options, "omitempty") && g.requiredByDefault(typ, name)) {
+			required = append(required, name)
+		}
+	}
+
+	result := map[string]interface{}{"type": "object", "properties": properties}
+	if required != nil {
+		sort.Strings(required)
+		result["required"] = required
+	}
+	return result
+}
+
+// enumFor restricts the integration name fields of a migration request to registered integrations
+func (g *generator) enumFor(typ reflect.Type, name string) []string {
+	if typ.Name() != "Request" {
+		return nil
+	}
+	var integrations []schema.Integration
+	switch name {
+	case "input":
+		integrations = g.catalog.Sources
+	case "output":
+		integrations = g.catalog.Destinations
+	default:
+		return nil
+	}
+	names := []string{}
+	for _, integration := range integrations {
+		names = append(names, integration.Name)
+	}
+	return names
+}
+
+func (g *generator) requiredByDefault(typ reflect.Type, name string) bool {
+	return typ.Name() == "Request" && (name == "input" || name == "output")
+}
+
+func withDescription(property map[string]interface{}, description string) map[string]interface{} {
+	if _, isRef := property["$ref"]; isRef {
+		return map[string]interface{}{"allOf": []interface{}{property}, "description": description}
+	}
+	property["description"] = description
+	return property
+}
+
+// fieldDescriptions merges the catalog into one description per request field,
+// naming the integrations that use it
+func fieldDescriptions(catalog schema.Catalog) map[string]string {
+	users := map[string][]string{}
+	texts := map[string]string{}
+	for _, integration := range append(append([]schema.Integration{}, catalog.Sources...), catalog.Destinations...) {
+		for _, field := range integration.Fields {
+			users[field.Name] = append(users[field.Name], integration.Name+" "+string(integration.Kind))
+			if texts[field.Name] == "" {
+				texts[field.Name] = field.Description
+			}
+		}
+	}
+
+	descriptions := map[string]string{
+		"input":  "Name of the source integration",
+		"output": "Name of the destination integration",
+	}
+	for name, text := range texts {
+		descriptions[name] = text + ". Used by " + strings.Join(users[name], ", ") + "."
+	}
+	return descriptions
+}
+
+func operationID(op Operation) string {
+	parts := []string{strings.ToLower(op.Method)}
+	for _, segment := range strings.Split(op.Path, "/") {
+		segment = strings.Trim(segment, "{}.")
+		if segment == "" {
+			continue
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			parts = append(parts, strings.ToUpper(word[:1])+word[1:])
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -3,63 +3,267 @@ package opentele
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace" // Alias for the SDK trace package
-	"go.opentelemetry.io/otel/trace"              // OpenTelemetry API trace
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace" // OpenTelemetry API trace
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
-// InitTracing initializes OpenTelemetry with Jaeger exporter
+// tracerName is the instrumentation scope of the spans fractal creates
+const tracerName = "github.com/SkySingh04/fractal"
+
+// tracerProvider creates the spans of fractal. It is kept apart from the global
+// provider, which every gofr app replaces with one of its own; until InitTracing
+// sets it, the global provider is used.
+var tracerProvider trace.TracerProvider
+
+// propagator carries W3C trace context and baggage across the systems fractal
+// connects, even when the spans of fractal itself are not exported
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// InitTracing sets up the tracer provider of fractal from the standard OTEL_* environment
+// variables, which can also be set in a .env file:
+//
+//   - OTEL_TRACES_EXPORTER picks the exporter: otlp, console (or stdout) or none.
+//     Without it, traces are exported over OTLP when an OTLP endpoint is set and
+//     dropped otherwise.
+//   - OTEL_EXPORTER_OTLP_PROTOCOL (or OTEL_EXPORTER_OTLP_TRACES_PROTOCOL) is grpc, the
+//     default, or http/protobuf. The endpoint, headers and TLS settings are read by
+//     the exporters from the other OTEL_EXPORTER_OTLP_* variables.
+//   - FRACTAL_TRACES_FILE makes the console exporter append to a file instead of stdout.
+//   - OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG choose the sampler,
+//     parentbased_always_on by default.
+//   - OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the resource, which
+//     otherwise names the service fractal (or $APP_NAME), sets its version to
+//     $APP_VERSION or the version of the build, and its environment to $APP_ENV.
+//
+// JAEGER_URL is still accepted as the host:port of an OTLP gRPC endpoint. The returned
+// function flushes pending spans.
 func InitTracing() (func(), error) {
 	// Load environment variables from .env file
 	if err := godotenv.Load(); err != nil {
 		log.Println("Error loading .env file")
 	}
+	ctx := context.Background()
+	otel.SetTextMapPropagator(propagator)
 
-	// Jaeger endpoint (this will be the Jaeger service in the docker-compose network)
-	jaegerURL := os.Getenv("JAEGER_URL")
-	if jaegerURL == "" {
-		jaegerURL = "http://jaeger:14268/api/traces" // Default to the Docker service name 'jaeger'
+	exporter, closer, err := newExporter(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
 	}
-
-	// Create a Jaeger exporter to send traces
-	traceExporter, err := otlptracegrpc.New(
-		context.Background(),
-		otlptracegrpc.WithEndpoint(jaegerURL),
-		otlptracegrpc.WithInsecure(),
-	)
+	if exporter == nil {
+		// Spans are not recorded, but trace context is still passed on
+		tracerProvider = noop.NewTracerProvider()
+		return func() {}, nil
+	}
+	sampler, err := newSampler()
+	if err != nil {
+		return nil, err
+	}
+	res, err := newResource(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Jaeger exporter: %v", err)
+		return nil, fmt.Errorf("failed to describe the tracing resource: %w", err)
 	}
 
-	// Set up the tracer provider with the exporter and a sampler
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(traceExporter),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()), // You can use a custom sampler here
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(res),
 	)
+	tracerProvider = provider
 
 	// Register the TracerProvider globally
-	otel.SetTracerProvider(tracerProvider)
+	otel.SetTracerProvider(provider)
 
 	// Return a cleanup function to flush traces when done
 	return func() {
-		tracerProvider.Shutdown(context.Background())
+		provider.Shutdown(context.Background())
+		if closer != nil {
+			closer.Close()
+		}
 	}, nil
 }
 
-// CreateSpan starts a new span with the provided operation name and adds some attributes
-func CreateSpan(ctx context.Context, operationName string) (context.Context, trace.Span) {
-	tracer := otel.Tracer("my-service")
-	ctx, span := tracer.Start(ctx, operationName)
-	span.SetAttributes(
-		// Custom attributes (optional)
-		// Example: Set environment as "development"
-		attribute.String("env", "development"),
+// newExporter returns the exporter selected by OTEL_TRACES_EXPORTER, or nil when
+// traces are not exported. The closer, if any, releases the file written to.
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, io.Closer, error) {
+	name := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER"))
+	if name == "" {
+		name = "none"
+		if firstEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT", "JAEGER_URL") != "" {
+			name = "otlp"
+		}
+	}
+
+	switch name {
+	case "none":
+		return nil, nil, nil
+	case "console", "stdout":
+		path := os.Getenv("FRACTAL_TRACES_FILE")
+		if path == "" {
+			exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+			return exporter, nil, err
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	case "otlp":
+		protocol := firstEnv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL")
+		switch protocol {
+		case "", "grpc":
+			var options []otlptracegrpc.Option
+			if jaegerURL := os.Getenv("JAEGER_URL"); jaegerURL != "" && firstEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+				options = append(options, otlptracegrpc.WithEndpoint(jaegerURL), otlptracegrpc.WithInsecure())
+			}
+			exporter, err := otlptracegrpc.New(ctx, options...)
+			return exporter, nil, err
+		case "http/protobuf":
+			exporter, err := otlptracehttp.New(ctx)
+			return exporter, nil, err
+		default:
+			return nil, nil, fmt.Errorf("unsupported OTLP protocol %q: use grpc or http/protobuf", protocol)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q: use otlp, console or none", name)
+	}
+}
+
+// newSampler returns the sampler selected by OTEL_TRACES_SAMPLER
+func newSampler() (sdktrace.Sampler, error) {
+	name := strings.ToLower(os.Getenv("OTEL_TRACES_SAMPLER"))
+	ratio := func() (float64, error) {
+		arg := os.Getenv("OTEL_TRACES_SAMPLER_ARG")
+		if arg == "" {
+			return 1, nil
+		}
+		value, err := strconv.ParseFloat(arg, 64)
+		if err != nil || value < 0 || value > 1 {
+			return 0, fmt.Errorf("invalid OTEL_TRACES_SAMPLER_ARG %q: use a ratio between 0 and 1", arg)
+		}
+		return value, nil
+	}
+
+	switch name {
+	case "", "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case "always_on":
+		return sdktrace.AlwaysSample(), nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio", "parentbased_traceidratio":
+		value, err := ratio()
+		if err != nil {
+			return nil, err
+		}
+		if name == "traceidratio" {
+			return sdktrace.TraceIDRatioBased(value), nil
+		}
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(value)), nil
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_SAMPLER %q", name)
+	}
+}
+
+// newResource describes the process that creates the spans. OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES take precedence over the defaults.
+func newResource(ctx context.Context) (*resource.Resource, error) {
+	name := os.Getenv("APP_NAME")
+	if name == "" {
+		name = "fractal"
+	}
+	version := os.Getenv("APP_VERSION")
+	if version == "" {
+		version = buildVersion()
+	}
+	attributes := []attribute.KeyValue{semconv.ServiceName(name), semconv.ServiceVersion(version)}
+	if environment := os.Getenv("APP_ENV"); environment != "" {
+		attributes = append(attributes, semconv.DeploymentEnvironment(environment))
+	}
+
+	return resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(attributes...),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithFromEnv(),
 	)
-	return ctx, span
+}
+
+// buildVersion returns the module version fractal was built from, "(devel)" for a
+// build of a working copy
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// firstEnv returns the first of the environment variables that is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// CreateSpan starts a new span with the provided operation name and attributes, as a
+// child of the span carried by ctx
+func CreateSpan(ctx context.Context, operationName string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	provider := tracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName).Start(ctx, operationName, trace.WithAttributes(attributes...))
+}
+
+// EndSpan marks span as failed when err is not nil, then ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Records is the attribute holding how many records a span read, wrote or processed
+func Records(count int) attribute.KeyValue {
+	return attribute.Int("fractal.records", count)
+}
+
+// Inject writes the trace context of ctx to the headers of an outgoing message or request
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	propagator.Inject(ctx, carrier)
+}
+
+// Extract returns ctx with the trace context read from the headers of an incoming
+// message, so the spans that handle it continue the trace of its producer
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return propagator.Extract(ctx, carrier)
 }
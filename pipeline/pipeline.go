@@ -0,0 +1,388 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SkySingh04/fractal/dsn"
+	"github.com/SkySingh04/fractal/factory"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/language"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/schedule"
+	"github.com/SkySingh04/fractal/schema"
+	"github.com/SkySingh04/fractal/secrets"
+	"github.com/SkySingh04/fractal/verify"
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Endpoint is an integration together with its configuration. Config uses the same
+// keys as the body of POST /api/migration.
+type Endpoint struct {
+	Integration string             `json:"integration"`
+	Config      interfaces.Request `json:"config"`
+}
+
+// Definition is a named, saved pipeline
+type Definition struct {
+	Name            string          `json:"name"`
+	Description     string          `json:"description,omitempty"`
+	Source          Endpoint        `json:"source"`
+	Destinations    []Endpoint      `json:"destinations"`
+	Validations     []string        `json:"validations,omitempty"`     // Validation rules, see "Validation Rules"
+	Transformations []string        `json:"transformations,omitempty"` // Transformation rules, see "Transformation Rules"
+	Schedule        string          `json:"schedule,omitempty"`        // Cron expression, "@hourly"-style shortcut or interval such as "15m"; empty means manual runs only
+	Timezone        string          `json:"timezone,omitempty"`        // IANA time zone of a cron schedule; server local time when empty
+	Overlap         string          `json:"overlap,omitempty"`         // skip (default), queue or concurrent
+	CatchUp         string          `json:"catch_up,omitempty"`        // Runs missed while the server was down: none (default), once or all
+	Jitter          string          `json:"jitter,omitempty"`          // Random delay of up to this duration added to each scheduled run
+	ErrorStrategy   string          `json:"error_strategy,omitempty"`  // STOP (default), LOG_AND_CONTINUE or RETRY when a destination fails
+	Verify          *verify.Options `json:"verify,omitempty"`          // Read the destinations back after each run and reconcile them with the source
+	Paused          bool            `json:"paused"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// ValidationError is returned for an invalid definition. Its StatusCode is picked up
+// by gofr when a handler returns it.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("invalid pipeline %s: %s", e.Field, e.Reason)
+}
+
+// StatusCode implements gofr's status code responder
+func (e ValidationError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ValidateName checks that name can name a pipeline
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return errors.New("must be 1-64 letters, digits, '.', '_' or '-' and start with a letter or digit")
+	}
+	return nil
+}
+
+// Validate checks that the definition names registered integrations, sets their
+// required fields and has a valid schedule
+func (d *Definition) Validate() error {
+	if err := ValidateName(d.Name); err != nil {
+		return ValidationError{"name", err.Error()}
+	}
+
+	source, err := schema.ForSource(d.Source.Integration)
+	if err != nil {
+		return ValidationError{"source.integration", err.Error()}
+	}
+	if missing := schema.Missing(source, d.Source.Config); len(missing) > 0 {
+		return ValidationError{"source.config", "missing " + fieldNames(missing)}
+	}
+
+	if len(d.Destinations) == 0 {
+		return ValidationError{"destinations", "at least one destination is required"}
+	}
+	for i, endpoint := range d.Destinations {
+		destination, err := schema.ForDestination(endpoint.Integration)
+		if err != nil {
+			return ValidationError{fmt.Sprintf("destinations[%d].integration", i), err.Error()}
+		}
+		if missing := schema.Missing(destination, endpoint.Config); len(missing) > 0 {
+			return ValidationError{fmt.Sprintf("destinations[%d].config", i), "missing " + fieldNames(missing)}
+		}
+	}
+
+	if _, err := language.Compile(d.Validations, d.Transformations); err != nil {
+		var rule *language.RuleError
+		if errors.As(err, &rule) {
+			return ValidationError{fmt.Sprintf("%s[%d]", rule.List, rule.Index), rule.Err.Error()}
+		}
+		return ValidationError{"validations", err.Error()}
+	}
+
+	if d.ErrorStrategy != "" && !slices.Contains(runner.ErrorStrategies, d.ErrorStrategy) {
+		return ValidationError{"error_strategy", "must be one of " + strings.Join(runner.ErrorStrategies, ", ")}
+	}
+
+	if d.Schedule == "" {
+		return nil
+	}
+	spec, err := d.ScheduleSpec()
+	if err != nil {
+		return ValidationError{"jitter", err.Error()}
+	}
+	if err := spec.Validate(); err != nil {
+		return ValidationError{"schedule", err.Error()}
+	}
+	return nil
+}
+
+// Scheduled reports whether the pipeline runs on a schedule rather than only on demand
+func (d *Definition) Scheduled() bool {
+	return d.Schedule != ""
+}
+
+// ScheduleSpec returns the schedule settings of the pipeline
+func (d *Definition) ScheduleSpec() (schedule.Spec, error) {
+	spec := schedule.Spec{
+		Expression: d.Schedule,
+		Timezone:   d.Timezone,
+		Overlap:    schedule.Overlap(d.Overlap),
+		CatchUp:    schedule.CatchUp(d.CatchUp),
+	}
+	if d.Jitter != "" {
+		jitter, err := time.ParseDuration(d.Jitter)
+		if err != nil {
+			return spec, fmt.Errorf("%q is not a duration such as 30s", d.Jitter)
+		}
+		spec.Jitter = jitter
+	}
+	return spec, nil
+}
+
+// Spec returns the runner spec for one run of the pipeline
+func (d *Definition) Spec(trigger string) runner.Spec {
+	spec := runner.Spec{
+		Pipeline:           d.Name,
+		Source:             d.Source.Integration,
+		SourceRequest:      d.Source.Config,
+		Destination:        d.Destinations[0].Integration,
+		DestinationRequest: d.Destinations[0].Config,
+		Trigger:            trigger,
+		OnError:            d.ErrorStrategy,
+		Verify:             d.Verify,
+		Validations:        d.Validations,
+		Transformations:    d.Transformations,
+	}
+	for _, endpoint := range d.Destinations[1:] {
+		spec.Destinations = append(spec.Destinations, runner.Target{Destination: endpoint.Integration, Request: endpoint.Config})
+	}
+	return spec
+}
+
+// ResolvedSpec returns the runner spec for one run of a pipeline read from a config
+// file, with the ${ENV} and secret:// references of its integration configs resolved.
+// Resolving for every run picks up rotated secrets.
+func (d *Definition) ResolvedSpec(ctx context.Context, trigger string) (runner.Spec, error) {
+	spec := d.Spec(trigger)
+	if err := secrets.ResolveRequest(ctx, &spec.SourceRequest); err != nil {
+		return spec, fmt.Errorf("source %s: %w", spec.Source, err)
+	}
+	if err := secrets.ResolveRequest(ctx, &spec.DestinationRequest); err != nil {
+		return spec, fmt.Errorf("destination %s: %w", spec.Destination, err)
+	}
+	for i := range spec.Destinations {
+		if err := secrets.ResolveRequest(ctx, &spec.Destinations[i].Request); err != nil {
+			return spec, fmt.Errorf("destination %s: %w", spec.Destinations[i].Destination, err)
+		}
+	}
+	return spec, nil
+}
+
+func fieldNames(fields []schema.Field) string {
+	var names []string
+	for _, field := range fields {
+		names = append(names, field.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// MapSecrets replaces every secret field set in the source and destination configs
+// with what fn returns for its value, as the store does to encrypt them
+func (d *Definition) MapSecrets(fn func(value string) (string, error)) error {
+	return d.mapFields(func(field schema.Field, value string) (string, error) {
+		if !field.Secret {
+			return value, nil
+		}
+		return fn(value)
+	})
+}
+
+// Redacted returns a copy of the definition that may be shown to anyone allowed to
+// read it. Secret fields that hold more than a reference are replaced with
+// secrets.Redacted, and credentials are removed from the other connection strings.
+func (d Definition) Redacted() Definition {
+	d.Destinations = slices.Clone(d.Destinations)
+	_ = d.mapFields(func(field schema.Field, value string) (string, error) {
+		switch {
+		case field.Secret && !secrets.IsOnlyReference(value):
+			return secrets.Redacted, nil
+		case field.Endpoint == "url":
+			return dsn.StripCredentials(value), nil
+		}
+		return value, nil
+	})
+	return d
+}
+
+// KeepRedacted gives the secret fields that were sent back as secrets.Redacted the
+// value they have in previous, so that a definition read through the API can be
+// changed and saved again. Only endpoints at the same place with the same
+// integration are matched.
+func (d *Definition) KeepRedacted(previous *Definition) {
+	keep := func(endpoint *Endpoint, old Endpoint, kind schema.Kind) {
+		if endpoint.Integration != old.Integration {
+			return
+		}
+		integration, err := schema.For(endpoint.Integration, kind)
+		if err != nil {
+			return
+		}
+		for _, field := range integration.Fields {
+			if field.Secret && schema.Value(endpoint.Config, field) == secrets.Redacted {
+				schema.SetValue(&endpoint.Config, field, schema.Value(old.Config, field))
+			}
+		}
+	}
+	keep(&d.Source, previous.Source, schema.KindSource)
+	for i := range d.Destinations {
+		if i < len(previous.Destinations) {
+			keep(&d.Destinations[i], previous.Destinations[i], schema.KindDestination)
+		}
+	}
+}
+
+// mapFields replaces every field set in the source and destination configs with
+// what fn returns for it. Endpoints of unknown integrations are left as they are.
+func (d *Definition) mapFields(fn func(field schema.Field, value string) (string, error)) error {
+	endpoints := []*Endpoint{&d.Source}
+	for i := range d.Destinations {
+		endpoints = append(endpoints, &d.Destinations[i])
+	}
+	for i, endpoint := range endpoints {
+		kind := schema.KindDestination
+		if i == 0 {
+			kind = schema.KindSource
+		}
+		integration, err := schema.For(endpoint.Integration, kind)
+		if err != nil {
+			continue
+		}
+		for _, field := range integration.Fields {
+			value := schema.Value(endpoint.Config, field)
+			if value == "" {
+				continue
+			}
+			mapped, err := fn(field, value)
+			if err != nil {
+				return fmt.Errorf("%s %s field %s: %w", kind, endpoint.Integration, field.Name, err)
+			}
+			schema.SetValue(&endpoint.Config, field, mapped)
+		}
+	}
+	return nil
+}
+
+// Changes describes how a set of pipelines differs from a previous set, by name
+type Changes struct {
+	Added   []*Definition
+	Changed []*Definition
+	Removed []string
+}
+
+// Empty reports whether both sets hold the same pipelines
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Changed) == 0 && len(c.Removed) == 0
+}
+
+func (c Changes) String() string {
+	if c.Empty() {
+		return "no changes"
+	}
+	var parts []string
+	for _, change := range []struct {
+		verb  string
+		names []string
+	}{{"added", definitionNames(c.Added)}, {"changed", definitionNames(c.Changed)}, {"removed", c.Removed}} {
+		if len(change.names) > 0 {
+			parts = append(parts, change.verb+" "+strings.Join(change.names, ", "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Diff compares two sets of pipelines. Added and Changed follow the order of current.
+func Diff(previous, current []*Definition) Changes {
+	before := map[string]*Definition{}
+	for _, definition := range previous {
+		before[definition.Name] = definition
+	}
+
+	var changes Changes
+	for _, definition := range current {
+		old, ok := before[definition.Name]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, definition)
+		case !reflect.DeepEqual(old, definition):
+			changes.Changed = append(changes.Changed, definition)
+		}
+		delete(before, definition.Name)
+	}
+	for _, definition := range previous {
+		if _, removed := before[definition.Name]; removed {
+			changes.Removed = append(changes.Removed, definition.Name)
+		}
+	}
+	return changes
+}
+
+func definitionNames(definitions []*Definition) []string {
+	var names []string
+	for _, definition := range definitions {
+		names = append(names, definition.Name)
+	}
+	return names
+}
+
+// ErrNotTestable is returned by TestConnection for integrations that cannot check
+// their settings without moving data
+var ErrNotTestable = errors.New("the integration cannot test its connection")
+
+// connectionTimeout bounds TestConnection
+const connectionTimeout = 15 * time.Second
+
+// TestConnection checks the settings of an endpoint by connecting with them, without
+// moving any data. With resolve set, secret references in the config are resolved
+// first. Endpoints of pipelines saved through the API must leave them as they are,
+// as their runs do.
+func (e Endpoint) TestConnection(ctx context.Context, kind schema.Kind, resolve bool) error {
+	var integration interface{}
+	var err error
+	if kind == schema.KindSource {
+		integration, err = factory.CreateSource(e.Integration)
+	} else {
+		integration, err = factory.CreateDestination(e.Integration)
+	}
+	if err != nil {
+		return err
+	}
+	tester, ok := integration.(interfaces.ConnectionTester)
+	if !ok {
+		return ErrNotTestable
+	}
+
+	req := e.Config
+	if resolve {
+		if err := secrets.ResolveRequest(ctx, &req); err != nil {
+			return err
+		}
+	}
+	if described, err := schema.For(e.Integration, kind); err == nil {
+		schema.ApplyDefaults(described, &req)
+	}
+	ctx, cancel := context.WithTimeout(ctx, connectionTimeout)
+	defer cancel()
+	return tester.TestConnection(req.WithContext(ctx))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/SkySingh04/fractal/config"
+	"github.com/SkySingh04/fractal/profile"
+	"github.com/SkySingh04/fractal/secrets"
+	"github.com/spf13/cobra"
+)
+
+func profileCommand() *cobra.Command {
+	var configFile, pipelineName string
+	var options profile.Options
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Profile the data of the sources of a config file",
+		Long: `Profile the data of the sources of a config file before migrating it.
+
+For every field of every table, collection or file, the profile reports the inferred
+type, the ratio of null and empty values, the number of distinct values, the minimum
+and maximum, the most frequent values, the distribution of lengths and the shape of
+nested objects and arrays. Validation rules that every profiled record passes are
+suggested as a starting point for the validations of the pipeline.
+
+The config file only needs to describe sources: inputmethod and inputconfig, or
+pipelines with a source. Sources are read as by a run, so queues are consumed.
+Large inputs are sampled, --sample records spread evenly over each dataset.`,
+		Example: `  fractal profile -c source.yaml
+  fractal profile -c pipelines.yaml --pipeline users --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sources, err := config.LoadSources(configFile)
+			if err != nil {
+				return err
+			}
+			ctx := context.Background()
+			var reports []profile.Report
+			for _, source := range sources {
+				if pipelineName != "" && source.Pipeline != pipelineName {
+					continue
+				}
+				req := source.Config
+				if err := secrets.ResolveRequest(ctx, &req); err != nil {
+					return fmt.Errorf("pipeline %s: failed to resolve secrets: %w", source.Pipeline, err)
+				}
+				report, err := profile.Source(ctx, source.Integration, req, options)
+				if err != nil {
+					return fmt.Errorf("pipeline %s: %w", source.Pipeline, err)
+				}
+				reports = append(reports, report)
+			}
+			if len(reports) == 0 {
+				return fmt.Errorf("%s has no pipeline named %q", configFile, pipelineName)
+			}
+
+			if asJSON {
+				encoded, err := json.MarshalIndent(reports, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(encoded))
+				return nil
+			}
+			fmt.Fprint(cmd.OutOrStdout(), profile.Text(reports))
+			return nil
+		},
+	}
+	configFlag(cmd, &configFile)
+	cmd.Flags().StringVarP(&pipelineName, "pipeline", "p", "", "profile only the source of the pipeline with this name")
+	cmd.Flags().IntVar(&options.Sample, "sample", profile.DefaultSample, "maximum number of records profiled per table, collection or file")
+	cmd.Flags().IntVar(&options.Top, "top", profile.DefaultTop, "number of most frequent values shown per field")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the profile as JSON")
+	return cmd
+}
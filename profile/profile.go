@@ -0,0 +1,712 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SkySingh04/fractal/factory"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/SkySingh04/fractal/schema"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Defaults of Options
+const (
+	DefaultSample = 10000
+	DefaultTop    = 5
+)
+
+// Fields with at most maxEnum distinct values, each seen at least twice, get an IN rule
+const maxEnum = 10
+
+// Distinct values are counted exactly up to sketchSize, and estimated beyond
+const sketchSize = 1024
+
+// Top values are counted for at most maxTracked values of a field
+const maxTracked = 10000
+
+// Inferred types. The scalar ones are those of the TYPE validation condition.
+const (
+	TypeString = "STRING"
+	TypeInt    = "INT"
+	TypeFloat  = "FLOAT"
+	TypeBool   = "BOOL"
+	TypeDate   = "DATE"
+	TypeObject = "OBJECT"
+	TypeArray  = "ARRAY"
+	TypeNull   = "NULL"
+)
+
+// dateLayouts are the layouts text is tried against to be inferred as a DATE
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// Options configure a profile
+type Options struct {
+	// Sample is the number of records profiled per dataset, spread evenly over larger
+	// inputs. DefaultSample when 0.
+	Sample int `json:"sample,omitempty"`
+	// Top is the number of most frequent values reported per field. DefaultTop when 0.
+	Top int `json:"top,omitempty"`
+}
+
+// Report profiles the data returned by a source
+type Report struct {
+	Source   string    `json:"source"`
+	Datasets []Dataset `json:"datasets"`
+}
+
+// Dataset profiles one table, collection or file
+type Dataset struct {
+	Name    string  `json:"name"`
+	Records int     `json:"records"`
+	Sampled int     `json:"sampled"`
+	Fields  []Field `json:"fields"`
+	// Rules are validation rules that every sampled record passes, a starting point
+	// for the validations of a pipeline
+	Rules []string `json:"rules,omitempty"`
+}
+
+// Field profiles the values of a field. Nested fields are named by their path: a.b for
+// field b of object a, a[] for the elements of array a and a[].b for their field b.
+type Field struct {
+	Path string `json:"path"`
+	// Type is the most frequent type of the non-null values, FLOAT when integers and
+	// floats are mixed
+	Type string `json:"type"`
+	// Types counts the values of each type, nulls included
+	Types map[string]int `json:"types"`
+	// Count is the number of records, objects or array elements the field could appear in
+	Count      int     `json:"count"`
+	Nulls      int     `json:"nulls"` // Missing or null
+	Empty      int     `json:"empty"` // Empty text, arrays and objects
+	NullRatio  float64 `json:"null_ratio"`
+	EmptyRatio float64 `json:"empty_ratio"`
+	// Distinct is the number of distinct non-null values, estimated when not Exact
+	Distinct      int     `json:"distinct"`
+	DistinctExact bool    `json:"distinct_exact"`
+	Min           string  `json:"min,omitempty"`
+	Max           string  `json:"max,omitempty"`
+	Top           []Value `json:"top,omitempty"`
+	// Lengths are those of text values, or the number of elements of arrays
+	Lengths *Lengths `json:"lengths,omitempty"`
+	// Shape lists the fields of objects or the element types of arrays
+	Shape string `json:"shape,omitempty"`
+}
+
+// Value is a value together with the number of times it was seen
+type Value struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Lengths is a distribution of lengths
+type Lengths struct {
+	Min  int     `json:"min"`
+	Max  int     `json:"max"`
+	Mean float64 `json:"mean"`
+	P50  int     `json:"p50"`
+	P90  int     `json:"p90"`
+	P99  int     `json:"p99"`
+}
+
+// Source fetches the data of a registered source and profiles it. Sources that
+// consume what they read, such as queues, are consumed as by a run.
+func Source(ctx context.Context, name string, req interfaces.Request, options Options) (report Report, err error) {
+	ctx, span := opentele.CreateSpan(ctx, "profile", attribute.String("fractal.source", name))
+	defer func() { opentele.EndSpan(span, err) }()
+
+	source, err := factory.CreateSource(name)
+	if err != nil {
+		return Report{}, err
+	}
+	if integration, err := schema.ForSource(name); err == nil {
+		schema.ApplyDefaults(integration, &req)
+	}
+	data, err := source.FetchData(req.WithContext(ctx))
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to fetch data from %s: %w", name, err)
+	}
+	report, err = Data(data, options)
+	report.Source = name
+	return report, err
+}
+
+// Data profiles data as returned by a source. Text is read as CSV with a header line,
+// with types inferred from the text; maps of lists, such as rows by table name, hold
+// one dataset per key; anything else is a single dataset named "".
+func Data(data interface{}, options Options) (Report, error) {
+	if options.Sample <= 0 {
+		options.Sample = DefaultSample
+	}
+	if options.Top <= 0 {
+		options.Top = DefaultTop
+	}
+	sets, err := datasets(data)
+	if err != nil {
+		return Report{}, err
+	}
+	var names []string
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var report Report
+	for _, name := range names {
+		report.Datasets = append(report.Datasets, profileDataset(name, sets[name], options))
+	}
+	return report, nil
+}
+
+// dataset holds the records of a table, collection or file. Values of untyped
+// records are text whose type is inferred.
+type dataset struct {
+	records []interface{}
+	untyped bool
+}
+
+func datasets(data interface{}) (map[string]dataset, error) {
+	switch v := data.(type) {
+	case nil:
+		return map[string]dataset{"": {}}, nil
+	case string:
+		return csvDataset(v)
+	case []byte:
+		var decoded interface{}
+		if err := json.Unmarshal(v, &decoded); err != nil {
+			return csvDataset(string(v))
+		}
+		data = decoded
+	}
+
+	// Encoding to JSON and back turns documents, rows and structs into plain values
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	if tables, ok := value.(map[string]interface{}); ok && len(tables) > 0 {
+		sets := map[string]dataset{}
+		for name, rows := range tables {
+			list, ok := rows.([]interface{})
+			if !ok {
+				return map[string]dataset{"": {records: []interface{}{value}}}, nil
+			}
+			sets[name] = dataset{records: list}
+		}
+		return sets, nil
+	}
+	if rows, ok := value.([]interface{}); ok {
+		return map[string]dataset{"": {records: rows}}, nil
+	}
+	return map[string]dataset{"": {records: []interface{}{value}}}, nil
+}
+
+func csvDataset(text string) (map[string]dataset, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	set := dataset{untyped: true}
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		record := map[string]interface{}{}
+		for j, value := range row {
+			column := fmt.Sprintf("column_%d", j+1)
+			if j < len(rows[0]) {
+				column = rows[0][j]
+			}
+			record[column] = value
+		}
+		set.records = append(set.records, record)
+	}
+	return map[string]dataset{"": set}, nil
+}
+
+// sample returns n records spread evenly over records
+func sample(records []interface{}, n int) []interface{} {
+	if len(records) <= n {
+		return records
+	}
+	sampled := make([]interface{}, n)
+	for i := range sampled {
+		sampled[i] = records[i*len(records)/n]
+	}
+	return sampled
+}
+
+func profileDataset(name string, set dataset, options Options) Dataset {
+	records := sample(set.records, options.Sample)
+	p := &profiler{untyped: set.untyped, fields: map[string]*fieldStats{}}
+	// The records are the objects of the root path
+	p.stats("").objects = len(records)
+	for _, record := range records {
+		if fields, ok := record.(map[string]interface{}); ok {
+			for key, value := range fields {
+				p.observe(join("", key), value)
+			}
+			continue
+		}
+		p.observe("value", record)
+	}
+
+	dataset := Dataset{Name: name, Records: len(set.records), Sampled: len(records)}
+	var paths []string
+	for path := range p.fields {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		field := p.field(path, options.Top)
+		dataset.Fields = append(dataset.Fields, field)
+		if !strings.ContainsAny(path, ".[") {
+			dataset.Rules = append(dataset.Rules, rules(field, p.fields[path])...)
+		}
+	}
+	return dataset
+}
+
+// join names field key of the object at path
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// parent returns the path of the object or array holding the value at path
+func parent(path string) string {
+	if strings.HasSuffix(path, "[]") {
+		return strings.TrimSuffix(path, "[]")
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+type profiler struct {
+	untyped bool
+	fields  map[string]*fieldStats
+}
+
+// fieldStats accumulates the values seen at a path
+type fieldStats struct {
+	observed int            // Values seen, nulls included
+	objects  int            // Values that are objects
+	elements int            // Elements of the values that are arrays
+	nulls    int            // Explicit nulls
+	empty    int            // Empty text, arrays and objects
+	types    map[string]int // Values by type
+	counts   map[string]int // Occurrences of the first maxTracked distinct values
+	sketch   sketch
+	minNum   float64
+	maxNum   float64
+	minText  string
+	maxText  string
+	numbers  int
+	texts    int
+	lengths  []int
+	keys     map[string]bool // Fields of the objects
+}
+
+func (p *profiler) stats(path string) *fieldStats {
+	stats, ok := p.fields[path]
+	if !ok {
+		stats = &fieldStats{types: map[string]int{}, counts: map[string]int{}, keys: map[string]bool{}}
+		p.fields[path] = stats
+	}
+	return stats
+}
+
+func (p *profiler) observe(path string, value interface{}) {
+	stats := p.stats(path)
+	stats.observed++
+	typ := p.typeOf(value)
+	stats.types[typ]++
+
+	switch typ {
+	case TypeNull:
+		stats.nulls++
+		return
+	case TypeObject:
+		fields := value.(map[string]interface{})
+		stats.objects++
+		if len(fields) == 0 {
+			stats.empty++
+		}
+		for key, fieldValue := range fields {
+			stats.keys[key] = true
+			p.observe(join(path, key), fieldValue)
+		}
+		return
+	case TypeArray:
+		elements := value.([]interface{})
+		stats.elements += len(elements)
+		stats.lengths = append(stats.lengths, len(elements))
+		if len(elements) == 0 {
+			stats.empty++
+		}
+		for _, element := range elements {
+			p.observe(path+"[]", element)
+		}
+		return
+	}
+
+	text := fmt.Sprint(value)
+	stats.sketch.add(text)
+	if _, ok := stats.counts[text]; ok || len(stats.counts) < maxTracked {
+		stats.counts[text]++
+	}
+	switch typ {
+	case TypeInt, TypeFloat:
+		number, _ := strconv.ParseFloat(text, 64)
+		if stats.numbers == 0 || number < stats.minNum {
+			stats.minNum = number
+		}
+		if stats.numbers == 0 || number > stats.maxNum {
+			stats.maxNum = number
+		}
+		stats.numbers++
+	case TypeString, TypeDate:
+		if text == "" {
+			stats.empty++
+		}
+		if stats.texts == 0 || text < stats.minText {
+			stats.minText = text
+		}
+		if stats.texts == 0 || text > stats.maxText {
+			stats.maxText = text
+		}
+		stats.texts++
+		stats.lengths = append(stats.lengths, len([]rune(text)))
+	}
+}
+
+// typeOf infers the type of a value. Text of untyped records may hold any scalar type.
+func (p *profiler) typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return TypeNull
+	case map[string]interface{}:
+		return TypeObject
+	case []interface{}:
+		return TypeArray
+	case bool:
+		return TypeBool
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return TypeInt
+		}
+		return TypeFloat
+	case string:
+		if p.untyped {
+			if v == "" {
+				return TypeNull
+			}
+			if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return TypeInt
+			}
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				return TypeFloat
+			}
+			if _, err := strconv.ParseBool(v); err == nil && len(v) > 1 {
+				return TypeBool
+			}
+		}
+		for _, layout := range dateLayouts {
+			if _, err := time.Parse(layout, v); err == nil {
+				return TypeDate
+			}
+		}
+		return TypeString
+	}
+	return TypeString
+}
+
+// field reports the statistics of path
+func (p *profiler) field(path string, top int) Field {
+	stats := p.fields[path]
+	holder := p.fields[parent(path)]
+	count := holder.objects
+	if strings.HasSuffix(path, "[]") {
+		count = holder.elements
+	}
+	missing := count - stats.observed
+	if missing < 0 {
+		missing = 0
+	}
+
+	field := Field{
+		Path:          path,
+		Type:          stats.mainType(),
+		Types:         map[string]int{},
+		Count:         count,
+		Nulls:         stats.nulls + missing,
+		Empty:         stats.empty,
+		Distinct:      stats.sketch.estimate(),
+		DistinctExact: stats.sketch.exact(),
+	}
+	for typ, n := range stats.types {
+		field.Types[typ] = n
+	}
+	if missing > 0 {
+		field.Types[TypeNull] += missing
+	}
+	if count > 0 {
+		field.NullRatio = round(float64(field.Nulls) / float64(count))
+		field.EmptyRatio = round(float64(field.Empty) / float64(count))
+	}
+	switch field.Type {
+	case TypeInt, TypeFloat:
+		if stats.numbers > 0 {
+			field.Min = strconv.FormatFloat(stats.minNum, 'f', -1, 64)
+			field.Max = strconv.FormatFloat(stats.maxNum, 'f', -1, 64)
+		}
+	case TypeString, TypeDate:
+		field.Min, field.Max = stats.minText, stats.maxText
+	}
+	field.Top = topValues(stats.counts, top)
+	field.Lengths = distribution(stats.lengths)
+	field.Shape = p.shape(path, stats)
+	return field
+}
+
+// mainType is the most frequent type of the non-null values
+func (s *fieldStats) mainType() string {
+	if s.types[TypeInt] > 0 && s.types[TypeFloat] > 0 {
+		return TypeFloat
+	}
+	best, bestCount := TypeNull, 0
+	for typ, count := range s.types {
+		if typ != TypeNull && (count > bestCount || count == bestCount && typ < best) {
+			best, bestCount = typ, count
+		}
+	}
+	return best
+}
+
+// shape describes the fields of objects or the element types of arrays
+func (p *profiler) shape(path string, stats *fieldStats) string {
+	if stats.objects > 0 {
+		var keys []string
+		for key := range stats.keys {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return "{" + strings.Join(keys, ", ") + "}"
+	}
+	if elements, ok := p.fields[path+"[]"]; ok {
+		var types []string
+		for typ := range elements.types {
+			types = append(types, typ)
+		}
+		sort.Strings(types)
+		return "[" + strings.Join(types, "|") + "]"
+	}
+	return ""
+}
+
+func topValues(counts map[string]int, n int) []Value {
+	values := make([]Value, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, Value{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > n {
+		values = values[:n]
+	}
+	return values
+}
+
+func distribution(lengths []int) *Lengths {
+	if len(lengths) == 0 {
+		return nil
+	}
+	sorted := append([]int(nil), lengths...)
+	sort.Ints(sorted)
+	total := 0
+	for _, length := range sorted {
+		total += length
+	}
+	percentile := func(p float64) int {
+		return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+	}
+	return &Lengths{
+		Min:  sorted[0],
+		Max:  sorted[len(sorted)-1],
+		Mean: round(float64(total) / float64(len(sorted))),
+		P50:  percentile(0.5),
+		P90:  percentile(0.9),
+		P99:  percentile(0.99),
+	}
+}
+
+func round(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
+
+// rules suggests the validation rules that every sampled value of a top-level field passes
+func rules(field Field, stats *fieldStats) []string {
+	var rules []string
+	name := strconv.Quote(field.Path)
+	if field.Nulls == 0 && field.Empty == 0 {
+		rules = append(rules, fmt.Sprintf("FIELD(%s) REQUIRED", name))
+	}
+	switch field.Type {
+	case TypeInt, TypeFloat:
+		if stats.types[TypeString]+stats.types[TypeBool]+stats.types[TypeDate] == 0 {
+			rules = append(rules, fmt.Sprintf("FIELD(%s) TYPE(%s) RANGE(%s, %s)", name, field.Type, field.Min, field.Max))
+		}
+	case TypeString, TypeBool, TypeDate:
+		if stats.types[field.Type]+stats.types[TypeNull] == stats.observed {
+			rules = append(rules, fmt.Sprintf("FIELD(%s) TYPE(%s)", name, field.Type))
+		}
+	}
+	if field.Type == TypeString && field.DistinctExact && field.Distinct > 0 && field.Distinct <= maxEnum &&
+		len(stats.counts) == field.Distinct && field.Count >= 2*field.Distinct {
+		var values []string
+		for value := range stats.counts {
+			values = append(values, strconv.Quote(value))
+		}
+		sort.Strings(values)
+		rules = append(rules, fmt.Sprintf("FIELD(%s) IN (%s)", name, strings.Join(values, ", ")))
+	}
+	return rules
+}
+
+// sketch counts distinct values: exactly up to sketchSize of them, then by keeping the
+// sketchSize smallest hashes (a k-minimum-values sketch)
+type sketch struct {
+	hashes []uint64 // Sorted ascending
+	full   bool     // More than sketchSize distinct values were seen
+}
+
+func (s *sketch) add(value string) {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	// FNV spreads similar values poorly over the high bits, which the estimate relies on
+	hash := mix(h.Sum64())
+	i := sort.Search(len(s.hashes), func(i int) bool { return s.hashes[i] >= hash })
+	if i < len(s.hashes) && s.hashes[i] == hash {
+		return
+	}
+	if len(s.hashes) == sketchSize {
+		if i == sketchSize {
+			s.full = true
+			return
+		}
+		s.hashes = s.hashes[:sketchSize-1]
+		s.full = true
+	}
+	s.hashes = append(s.hashes, 0)
+	copy(s.hashes[i+1:], s.hashes[i:])
+	s.hashes[i] = hash
+}
+
+// mix is the finalizer of MurmurHash3
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func (s *sketch) exact() bool {
+	return !s.full
+}
+
+func (s *sketch) estimate() int {
+	if !s.full {
+		return len(s.hashes)
+	}
+	largest := float64(s.hashes[len(s.hashes)-1]) / math.MaxUint64
+	return int(float64(sketchSize-1) / largest)
+}
+
+// Text renders reports for people to read: a table of the fields of every dataset,
+// followed by the suggested rules as a validations list to paste into a pipeline
+func Text(reports []Report) string {
+	var out strings.Builder
+	for _, report := range reports {
+		for _, dataset := range report.Datasets {
+			name := report.Source
+			if dataset.Name != "" {
+				name += " " + dataset.Name
+			}
+			fmt.Fprintf(&out, "%s: %d records, %d profiled\n", name, dataset.Records, dataset.Sampled)
+			w := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "FIELD\tTYPE\tNULL\tEMPTY\tDISTINCT\tMIN\tMAX\tLENGTH\tTOP")
+			for _, field := range dataset.Fields {
+				distinct := strconv.Itoa(field.Distinct)
+				if !field.DistinctExact {
+					distinct = "~" + distinct
+				}
+				length := ""
+				if field.Lengths != nil {
+					length = fmt.Sprintf("%d-%d (p50 %d)", field.Lengths.Min, field.Lengths.Max, field.Lengths.P50)
+				}
+				var top []string
+				for _, value := range field.Top {
+					top = append(top, fmt.Sprintf("%s (%d)", truncate(value.Value), value.Count))
+				}
+				typ := field.Type
+				if field.Shape != "" {
+					typ += " " + field.Shape
+				}
+				fmt.Fprintf(w, "%s\t%s\t%.0f%%\t%.0f%%\t%s\t%s\t%s\t%s\t%s\n", field.Path, typ,
+					field.NullRatio*100, field.EmptyRatio*100, distinct, truncate(field.Min), truncate(field.Max),
+					length, strings.Join(top, ", "))
+			}
+			w.Flush()
+			if len(dataset.Rules) > 0 {
+				out.WriteString("validations:\n")
+				for _, rule := range dataset.Rules {
+					fmt.Fprintf(&out, "  - '%s'\n", strings.ReplaceAll(rule, "'", "''"))
+				}
+			}
+			out.WriteString("\n")
+		}
+	}
+	return out.String()
+}
+
+// truncate shortens values for the table
+func truncate(value string) string {
+	const max = 24
+	if runes := []rune(value); len(runes) > max {
+		return string(runes[:max-1]) + "…"
+	}
+	return value
+}
@@ -0,0 +1,51 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const barWidth = 30
+
+// Render draws events as a single, continuously rewritten terminal line until the
+// channel is closed, then prints the final state on its own line
+func Render(w io.Writer, events <-chan Event) {
+	var last Event
+	for event := range events {
+		last = event
+		fmt.Fprintf(w, "\r\033[K%s", Line(event))
+	}
+	if last.JobID != "" {
+		fmt.Fprintf(w, "\r\033[K%s\n", Line(last))
+	}
+}
+
+// Line formats an event as a one-line progress summary
+func Line(event Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]", event.Phase)
+	if event.Total > 0 {
+		done := event.RecordsWritten
+		if done > event.Total {
+			done = event.Total
+		}
+		filled := barWidth * done / event.Total
+		fmt.Fprintf(&b, " [%s%s] %3d%%", strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled), 100*done/event.Total)
+	}
+	if event.Current != "" {
+		fmt.Fprintf(&b, " %s", event.Current)
+	}
+	fmt.Fprintf(&b, "  read %d (%.0f/s)  written %d (%.0f/s)", event.RecordsRead, event.ReadRate, event.RecordsWritten, event.WriteRate)
+	if event.Errors > 0 {
+		fmt.Fprintf(&b, "  errors %d", event.Errors)
+	}
+	if event.ETASeconds > 0 {
+		fmt.Fprintf(&b, "  ETA %s", (time.Duration(event.ETASeconds) * time.Second).Round(time.Second))
+	}
+	if event.Phase == PhaseFailed && event.LastError != "" {
+		fmt.Fprintf(&b, "  %s", event.LastError)
+	}
+	return b.String()
+}
@@ -0,0 +1,362 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Phase is the stage a job is currently in
+type Phase string
+
+const (
+	PhaseStarting  Phase = "starting"
+	PhaseFetching  Phase = "fetching"
+	PhaseSending   Phase = "sending"
+	PhaseVerifying Phase = "verifying"
+	PhaseDone      Phase = "done"
+	PhaseFailed    Phase = "failed"
+)
+
+// Kinds of errors counted by a Tracker
+const (
+	ErrorRead           = "read"
+	ErrorValidation     = "validation"
+	ErrorTransformation = "transformation"
+	ErrorWrite          = "write"
+	ErrorVerification   = "verification"
+	ErrorCancelled      = "cancelled"
+	ErrorTimeout        = "timeout"
+	ErrorOther          = "other"
+)
+
+// keepFinished is how many finished jobs stay available to late subscribers
+const keepFinished = 100
+
+// Event is a snapshot of a job's progress
+type Event struct {
+	JobID          string         `json:"job_id"`
+	Phase          Phase          `json:"phase"`
+	Current        string         `json:"current,omitempty"` // Table, collection, topic or file being processed
+	RecordsRead    int            `json:"records_read"`
+	RecordsWritten int            `json:"records_written"`
+	ReadRate       float64        `json:"read_rate"`  // Records read per second
+	WriteRate      float64        `json:"write_rate"` // Records written per second
+	Total          int            `json:"total,omitempty"`
+	ETASeconds     float64        `json:"eta_seconds,omitempty"`
+	Lag            int64          `json:"lag,omitempty"` // Messages a streaming source has yet to read
+	Errors         int            `json:"errors"`
+	ErrorsByKind   map[string]int `json:"errors_by_kind,omitempty"` // Errors by kind, such as read or validation
+	Quarantined    int            `json:"quarantined,omitempty"`    // Records set aside because they failed validation or transformation
+	LastError      string         `json:"last_error,omitempty"`
+	StartedAt      time.Time      `json:"started_at"`
+	Time           time.Time      `json:"time"`
+}
+
+// Finished reports whether the event is the last one of its job
+func (e Event) Finished() bool {
+	return e.Phase == PhaseDone || e.Phase == PhaseFailed
+}
+
+// Tracker collects the counters of a single job and fans them out to subscribers.
+// All methods are safe to call on a nil Tracker, so integrations can report progress
+// without checking whether anybody is listening.
+type Tracker struct {
+	mu          sync.Mutex
+	state       Event
+	readStart   time.Time
+	writeStart  time.Time
+	subscribers map[chan Event]struct{}
+}
+
+var (
+	trackersMu sync.Mutex
+	trackers   = map[string]*Tracker{}
+	finished   []string
+)
+
+// Start returns the tracker of jobID, creating it if needed
+func Start(jobID string) *Tracker {
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
+	if tracker, ok := trackers[jobID]; ok {
+		return tracker
+	}
+	now := time.Now().UTC()
+	tracker := &Tracker{
+		state:       Event{JobID: jobID, Phase: PhaseStarting, StartedAt: now, Time: now},
+		subscribers: map[chan Event]struct{}{},
+	}
+	trackers[jobID] = tracker
+	return tracker
+}
+
+// Get returns the tracker of a running or recently finished job
+func Get(jobID string) (*Tracker, bool) {
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
+	tracker, ok := trackers[jobID]
+	return tracker, ok
+}
+
+// Active returns a snapshot of every job that has not finished yet
+func Active() []Event {
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
+	events := []Event{}
+	for _, tracker := range trackers {
+		if snapshot := tracker.Snapshot(); !snapshot.Finished() {
+			events = append(events, snapshot)
+		}
+	}
+	return events
+}
+
+type contextKey struct{}
+
+// WithTracker returns a context that carries tracker
+func WithTracker(ctx context.Context, tracker *Tracker) context.Context {
+	return context.WithValue(ctx, contextKey{}, tracker)
+}
+
+// FromContext returns the tracker carried by ctx, or nil
+func FromContext(ctx context.Context) *Tracker {
+	tracker, _ := ctx.Value(contextKey{}).(*Tracker)
+	return tracker
+}
+
+// SetPhase moves the job to the next phase
+func (t *Tracker) SetPhase(phase Phase) {
+	t.update(func(now time.Time) {
+		t.state.Phase = phase
+		switch phase {
+		case PhaseFetching:
+			t.readStart = now
+		case PhaseSending:
+			t.writeStart = now
+		}
+	})
+}
+
+// SetCurrent records the table, collection, topic or file being processed
+func (t *Tracker) SetCurrent(name string) {
+	t.update(func(time.Time) { t.state.Current = name })
+}
+
+// SetTotal records how many records the job is expected to write
+func (t *Tracker) SetTotal(total int) {
+	t.update(func(time.Time) { t.state.Total = total })
+}
+
+// AddRead adds n records to the number read from the source
+func (t *Tracker) AddRead(n int) {
+	t.update(func(time.Time) { t.state.RecordsRead += n })
+}
+
+// AddWritten adds n records to the number written to the destination
+func (t *Tracker) AddWritten(n int) {
+	t.update(func(time.Time) { t.state.RecordsWritten += n })
+}
+
+// EnsureRead raises the read counter to at least n, for sources that do not report per record
+func (t *Tracker) EnsureRead(n int) {
+	t.update(func(time.Time) {
+		if t.state.RecordsRead < n {
+			t.state.RecordsRead = n
+		}
+	})
+}
+
+// EnsureWritten raises the written counter to at least n, for destinations that do not report per record
+func (t *Tracker) EnsureWritten(n int) {
+	t.update(func(time.Time) {
+		if t.state.RecordsWritten < n {
+			t.state.RecordsWritten = n
+		}
+	})
+}
+
+// SetLag records how many messages a streaming source, such as a Kafka topic or a
+// RabbitMQ queue, still holds for the job to read
+func (t *Tracker) SetLag(lag int64) {
+	t.update(func(time.Time) { t.state.Lag = lag })
+}
+
+// RecordError counts a failed record or operation without stopping the job
+func (t *Tracker) RecordError(err error) {
+	t.Fail(ErrorKind(err, ErrorOther), err)
+}
+
+// Fail counts an error of a kind, such as ErrorRead or ErrorValidation
+func (t *Tracker) Fail(kind string, err error) {
+	t.countError(kind, err, false)
+}
+
+// Quarantine counts a record that is left out of the job because it failed a stage
+// such as ErrorValidation, as an error of that kind
+func (t *Tracker) Quarantine(kind string, err error) {
+	t.countError(kind, err, true)
+}
+
+func (t *Tracker) countError(kind string, err error, quarantined bool) {
+	if err == nil {
+		return
+	}
+	t.update(func(time.Time) {
+		t.state.Errors++
+		if t.state.ErrorsByKind == nil {
+			t.state.ErrorsByKind = map[string]int{}
+		}
+		t.state.ErrorsByKind[kind]++
+		t.state.LastError = err.Error()
+		if quarantined {
+			t.state.Quarantined++
+		}
+	})
+}
+
+// ErrorKind returns ErrorCancelled or ErrorTimeout when err comes from a cancelled or
+// timed out context, and kind otherwise
+func ErrorKind(err error, kind string) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorCancelled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	}
+	return kind
+}
+
+// Finish publishes the final event and closes every subscription
+func (t *Tracker) Finish(err error) {
+	if t == nil {
+		return
+	}
+	t.update(func(time.Time) {
+		t.state.Phase = PhaseDone
+		if err != nil {
+			t.state.Phase = PhaseFailed
+			t.state.LastError = err.Error()
+		}
+	})
+
+	t.mu.Lock()
+	for ch := range t.subscribers {
+		close(ch)
+	}
+	t.subscribers = map[chan Event]struct{}{}
+	jobID := t.state.JobID
+	t.mu.Unlock()
+
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
+	finished = append(finished, jobID)
+	if len(finished) > keepFinished {
+		delete(trackers, finished[0])
+		finished = finished[1:]
+	}
+}
+
+// Snapshot returns the current state with rates and ETA filled in
+func (t *Tracker) Snapshot() Event {
+	if t == nil {
+		return Event{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshot(time.Now().UTC())
+}
+
+// Subscribe returns a channel that receives the current state followed by every update.
+// Slow subscribers only miss intermediate events, never the latest one. The channel is
+// closed when the job finishes; cancel stops the subscription early.
+func (t *Tracker) Subscribe() (events <-chan Event, cancel func()) {
+	ch := make(chan Event, 1)
+	if t == nil {
+		close(ch)
+		return ch, func() {}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snapshot := t.snapshot(time.Now().UTC())
+	ch <- snapshot
+	if snapshot.Finished() {
+		close(ch)
+		return ch, func() {}
+	}
+	t.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, ok := t.subscribers[ch]; ok {
+			delete(t.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (t *Tracker) update(fn func(now time.Time)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now().UTC()
+	fn(now)
+	snapshot := t.snapshot(now)
+	for ch := range t.subscribers {
+		publish(ch, snapshot)
+	}
+}
+
+func (t *Tracker) snapshot(now time.Time) Event {
+	event := t.state
+	event.Time = now
+	if t.state.ErrorsByKind != nil {
+		event.ErrorsByKind = make(map[string]int, len(t.state.ErrorsByKind))
+		for kind, n := range t.state.ErrorsByKind {
+			event.ErrorsByKind[kind] = n
+		}
+	}
+	if !t.readStart.IsZero() {
+		event.ReadRate = rate(event.RecordsRead, t.readStart, now)
+	}
+	if !t.writeStart.IsZero() {
+		event.WriteRate = rate(event.RecordsWritten, t.writeStart, now)
+	}
+	if event.Total > 0 && event.WriteRate > 0 && !event.Finished() {
+		if remaining := event.Total - event.RecordsWritten; remaining > 0 {
+			event.ETASeconds = float64(remaining) / event.WriteRate
+		}
+	}
+	return event
+}
+
+// publish replaces any event the subscriber has not consumed yet with the latest one
+func publish(ch chan Event, event Event) {
+	select {
+	case ch <- event:
+		return
+	default:
+	}
+	select {
+	case <-ch:
+	default:
+	}
+	select {
+	case ch <- event:
+	default:
+	}
+}
+
+func rate(count int, since, now time.Time) float64 {
+	elapsed := now.Sub(since).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(count) / elapsed
+}
@@ -0,0 +1,215 @@
+package rbac
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/SkySingh04/fractal/auth"
+	"github.com/SkySingh04/fractal/dsn"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/schema"
+	"github.com/spf13/viper"
+)
+
+// Role lists what the members of a team may touch. Every list holds names or
+// shell-style patterns such as "*.analytics.internal"; an empty list allows anything.
+type Role struct {
+	Sources      []string `mapstructure:"sources"`
+	Destinations []string `mapstructure:"destinations"`
+	Hosts        []string `mapstructure:"hosts"`     // Hosts of connection strings and URLs
+	Databases    []string `mapstructure:"databases"` // Databases named in connection strings or config
+	Pipelines    []string `mapstructure:"pipelines"`
+}
+
+// Policy maps role names to roles. A nil or empty policy allows everything.
+type Policy struct {
+	Roles map[string]Role `mapstructure:"roles"`
+	// OnDenied, if set, is called with every denial, such as to record it in the
+	// audit log
+	OnDenied func(Denial) `mapstructure:"-"`
+}
+
+// Denial is an action that no role of the caller allows
+type Denial struct {
+	Principal string
+	Roles     []string
+	Action    Action
+	Reason    string
+}
+
+// Action is a migration someone wants to run
+type Action struct {
+	Pipeline    string
+	Source      string
+	Destination string // Empty when the source is only read, as by a profile
+	Hosts       []string
+	Databases   []string
+}
+
+// DeniedError is returned when no role of the caller allows an action.
+// Its StatusCode is picked up by gofr when a handler returns it.
+type DeniedError struct {
+	Principal string
+	Reason    string
+}
+
+func (e DeniedError) Error() string {
+	return fmt.Sprintf("%s is not allowed to run this migration: %s", e.Principal, e.Reason)
+}
+
+// StatusCode implements gofr's status code responder
+func (e DeniedError) StatusCode() int {
+	return http.StatusForbidden
+}
+
+// Enabled reports whether the policy defines any role
+func (p *Policy) Enabled() bool {
+	return p != nil && len(p.Roles) > 0
+}
+
+// Check allows the action if at least one of roles allows all of it. Role names are
+// case-insensitive. Denials are logged and passed to OnDenied.
+func (p *Policy) Check(principal string, roles []string, action Action) error {
+	reason := p.denies(roles, action)
+	if reason == "" {
+		return nil
+	}
+
+	logger.Logf("[AUDIT] access denied principal=%q roles=%q pipeline=%q source=%q destination=%q hosts=%q databases=%q reason=%q",
+		principal, roles, action.Pipeline, action.Source, action.Destination, action.Hosts, action.Databases, reason)
+	if p.OnDenied != nil {
+		p.OnDenied(Denial{Principal: principal, Roles: roles, Action: action, Reason: reason})
+	}
+	return DeniedError{Principal: principal, Reason: reason}
+}
+
+// Allows reports whether at least one of roles allows all of the action, like Check,
+// without logging or recording a denial. It decides what a listing shows.
+func (p *Policy) Allows(roles []string, action Action) bool {
+	return p.denies(roles, action) == ""
+}
+
+// denies returns why no role of roles allows the action, or "" if one does
+func (p *Policy) denies(roles []string, action Action) string {
+	if !p.Enabled() {
+		return ""
+	}
+
+	reason := "no role assigned"
+	for _, name := range roles {
+		role, ok := p.Roles[strings.ToLower(name)]
+		if !ok {
+			reason = fmt.Sprintf("unknown role %s", name)
+			continue
+		}
+		if why := role.denies(action); why != "" {
+			reason = fmt.Sprintf("role %s does not allow %s", name, why)
+			continue
+		}
+		return ""
+	}
+	return reason
+}
+
+// denies returns what the role does not allow, or "" if it allows the whole action
+func (r Role) denies(action Action) string {
+	if !matchAny(r.Pipelines, action.Pipeline) {
+		return "pipeline " + action.Pipeline
+	}
+	if !matchAny(r.Sources, action.Source) {
+		return "source " + action.Source
+	}
+	if action.Destination != "" && !matchAny(r.Destinations, action.Destination) {
+		return "destination " + action.Destination
+	}
+	for _, host := range action.Hosts {
+		if !matchAny(r.Hosts, host) {
+			return "host " + host
+		}
+	}
+	for _, database := range action.Databases {
+		if !matchAny(r.Databases, database) {
+			return "database " + database
+		}
+	}
+	return ""
+}
+
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ActionFor describes a migration, collecting the hosts and databases from the
+// endpoint fields of the source and destination
+func ActionFor(pipeline, source, destination string, sourceReq, destinationReq interfaces.Request) Action {
+	action := Action{Pipeline: pipeline, Source: source, Destination: destination}
+	if integration, err := schema.ForSource(source); err == nil {
+		action.addEndpoints(integration, sourceReq)
+	}
+	if integration, err := schema.ForDestination(destination); err == nil {
+		action.addEndpoints(integration, destinationReq)
+	}
+	return action
+}
+
+func (a *Action) addEndpoints(integration schema.Integration, req interfaces.Request) {
+	for _, field := range integration.Fields {
+		value := schema.Value(req, field)
+		if value == "" {
+			continue
+		}
+		switch field.Endpoint {
+		case "url":
+			// Every host and database the driver may use must be allowed, including
+			// those that the query string of a URL overrides
+			endpoint := dsn.Parse(value)
+			a.Hosts = append(a.Hosts, endpoint.Hosts()...)
+			a.Databases = append(a.Databases, endpoint.Databases...)
+		case "database":
+			a.Databases = append(a.Databases, value)
+		}
+	}
+}
+
+// LoadConfig reads the rbac section of a YAML configuration file:
+//
+//	rbac:
+//	  roles:
+//	    analytics:
+//	      sources: [PostgreSQL, CSV]
+//	      destinations: [MongoDB]
+//	      hosts: ["*.analytics.internal"]
+//	      databases: [warehouse]
+//	      pipelines: ["analytics-*"]
+func LoadConfig(file string) (*Policy, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read rbac config %s: %w", file, err)
+	}
+	policy := &Policy{}
+	if err := v.UnmarshalKey("rbac", policy); err != nil {
+		return nil, fmt.Errorf("invalid rbac config in %s: %w", file, err)
+	}
+	return policy, nil
+}
+
+// LoadFromEnv reads the policy from the same file as the auth configuration
+func LoadFromEnv() (*Policy, error) {
+	file := auth.ConfigFile()
+	if file == "" {
+		return &Policy{}, nil
+	}
+	return LoadConfig(file)
+}
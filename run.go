@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SkySingh04/fractal/config"
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/metrics"
+	"github.com/SkySingh04/fractal/notify"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/SkySingh04/fractal/progress"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/schedule"
+	"github.com/SkySingh04/fractal/store"
+	"github.com/SkySingh04/fractal/verify"
+	"github.com/spf13/cobra"
+	"gofr.dev/pkg/gofr"
+	"golang.org/x/term"
+)
+
+type runOptions struct {
+	configFile  string
+	pipeline    string
+	once        bool
+	reload      bool
+	schedule    string
+	metricsPort string
+	verify      bool
+	summaryFile string
+	gracePeriod time.Duration
+	interactive bool // Fall back to the wizard and schedule prompt when something is missing
+}
+
+func runCommand() *cobra.Command {
+	var opts runOptions
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run the pipelines described by a config file",
+		Long: `Run the pipelines described by a config file.
+
+Every pipeline of the file runs independently, on its own schedule: the schedule of
+its entry in the pipelines list, or the cronjob section for the pipeline described by
+inputmethod and outputmethod. --schedule overrides them all. A pipeline without a
+schedule runs a single time, as does every pipeline with --once; the exit code then
+tells whether the runs succeeded. --pipeline runs a single pipeline of the file.
+
+While pipelines run on a schedule, saving the config file or sending the process
+SIGHUP applies the changes: new pipelines start, removed ones stop once their runs in
+progress finish, and changed ones switch over at their next scheduled run. A config
+that does not validate is rejected and the running pipelines are kept. Their metrics
+are served at /metrics on --metrics-port.
+
+--verify reads every destination back after each run and reconciles it with the
+source: record counts, checksums and a sample of records compared field by field. A
+destination that does not hold what was sent fails the run. Pipelines with a verify
+section in the config are verified without the flag.
+
+Every run ends with a summary: records read and written, duration, errors by kind and
+quarantined records. --summary-file appends it to a file as a JSON line, and the
+notifications section of the config sends failures, recoveries and threshold
+breaches to webhooks, Slack or email.
+
+SIGINT or SIGTERM stops scheduling runs and gives the runs in progress
+--grace-period to finish before they are cancelled; a second signal cancels them
+straight away. The exit code is 0 when every run finished and succeeded, 1 when a
+run failed, and 130 or 143 when runs were cancelled by SIGINT or SIGTERM.`,
+		Example: `  fractal run -c pipeline.yaml --once
+  fractal run -c pipelines.yaml --pipeline users
+  fractal run -c pipeline.yaml --schedule "0 2 * * *"
+  fractal run -c pipeline.yaml --once --verify`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(opts)
+		},
+	}
+	configFlag(cmd, &opts.configFile)
+	cmd.Flags().StringVarP(&opts.pipeline, "pipeline", "p", "", "run only the pipeline with this name")
+	bindEnv(cmd.Flags(), "pipeline", "FRACTAL_PIPELINE")
+	cmd.Flags().BoolVar(&opts.once, "once", false, "run a single time and exit, ignoring any schedule")
+	bindEnv(cmd.Flags(), "once", "FRACTAL_ONCE")
+	cmd.Flags().StringVar(&opts.schedule, "schedule", "", "cron expression, shortcut or interval to run on, overriding the schedules of the config")
+	bindEnv(cmd.Flags(), "schedule", "FRACTAL_SCHEDULE")
+	reloadFlag(cmd, &opts.reload)
+	cmd.Flags().StringVar(&opts.metricsPort, "metrics-port", "2121", "port to serve metrics on while pipelines run on a schedule, 0 to turn off")
+	bindEnv(cmd.Flags(), "metrics-port", "METRICS_PORT")
+	cmd.Flags().BoolVar(&opts.verify, "verify", false, "read the destinations back after each run and reconcile them with the source")
+	bindEnv(cmd.Flags(), "verify", "FRACTAL_VERIFY")
+	cmd.Flags().StringVar(&opts.summaryFile, "summary-file", "", "file to append the summary of every run to as a JSON line, overriding notifications.summary_file")
+	bindEnv(cmd.Flags(), "summary-file", "FRACTAL_SUMMARY_FILE")
+	gracePeriodFlag(cmd, &opts.gracePeriod)
+	cmd.MarkFlagsMutuallyExclusive("once", "schedule")
+	return cmd
+}
+
+// run executes the pipelines of a config file once or on their schedules
+func run(opts runOptions) error {
+	definitions, err := loadPipelines(opts)
+	if err != nil {
+		return err
+	}
+	if definitions, err = prepare(definitions, opts); err != nil {
+		return err
+	}
+	if len(definitions) == 1 && !definitions[0].Scheduled() && !opts.once && opts.interactive {
+		spec, err := config.AskForSchedule()
+		if err != nil {
+			return err
+		}
+		definitions[0].Schedule = spec.Expression
+	}
+
+	runStore, cleanup, err := setup()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	registerAudit()
+	notifications, err := registerNotifications(opts.configFile, opts.summaryFile, runStore)
+	if err != nil {
+		return err
+	}
+	defer notifications.Wait() // Deliver the notifications of the last runs
+	if err := registerLineage(opts.configFile); err != nil {
+		return err
+	}
+
+	// Runs in progress are drained on SIGINT and SIGTERM, see drain
+	signals := notifyShutdown()
+	defer signal.Stop(signals)
+
+	scheduled := false
+	for _, definition := range definitions {
+		scheduled = scheduled || definition.Scheduled()
+	}
+	if opts.once || !scheduled {
+		done := make(chan error, 1)
+		go func() { done <- runAll(definitions) }()
+		select {
+		case err := <-done:
+			return err
+		case sig := <-signals:
+			stopErr := drain(sig, signals, opts.gracePeriod)
+			if err := <-done; stopErr == nil {
+				return err
+			}
+			return stopErr
+		}
+	}
+
+	if opts.metricsPort != "0" {
+		if err := serveMetrics(opts.metricsPort); err != nil {
+			return err
+		}
+	}
+
+	// Scheduled pipelines run until the process is stopped, following changes to the
+	// config file. The schedule asked for in interactive mode is not in the file.
+	pipelines := &cliPipelines{runStore: runStore, progressBar: len(definitions) == 1, loops: map[string]context.CancelFunc{}}
+	pipelines.apply(definitions)
+	ctx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	if opts.reload && !opts.interactive {
+		rejected := func(err error) {
+			logger.Errorf("Reload of %s rejected, the previous pipelines keep running: %v", opts.configFile, err)
+		}
+		err := config.Watch(ctx, opts.configFile, func(definitions []*pipeline.Definition) {
+			definitions, err := prepare(definitions, opts)
+			if err != nil {
+				rejected(err)
+				return
+			}
+			logger.Infof("Reloaded %s: %s", opts.configFile, pipelines.apply(definitions))
+		}, rejected)
+		if err != nil {
+			return fmt.Errorf("failed to watch %s: %w", opts.configFile, err)
+		}
+	}
+
+	sig := <-signals
+	stopWatching()
+	pipelines.stopAll()
+	return drain(sig, signals, opts.gracePeriod)
+}
+
+// serveMetrics records the metrics of every run and serves them at /metrics on port,
+// like the metrics server of serve
+func serveMetrics(port string) error {
+	metrics.Register(gofr.New().Metrics())
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return fmt.Errorf("failed to serve metrics: %w", err)
+	}
+	logger.Infof("Serving metrics at http://localhost:%s/metrics", port)
+	go func() {
+		logger.Logf("Metrics server stopped: %v", http.Serve(listener, metrics.Handler()))
+	}()
+	return nil
+}
+
+// prepare picks the pipeline named by --pipeline, applies --schedule and checks the result
+func prepare(definitions []*pipeline.Definition, opts runOptions) ([]*pipeline.Definition, error) {
+	if opts.pipeline != "" {
+		var err error
+		if definitions, err = selectPipeline(definitions, opts.pipeline, opts.configFile); err != nil {
+			return nil, err
+		}
+	}
+	if len(definitions) == 0 {
+		return nil, fmt.Errorf("%s defines no pipelines", opts.configFile)
+	}
+	for _, definition := range definitions {
+		if opts.schedule != "" {
+			definition.Schedule = opts.schedule
+		}
+		if opts.verify && definition.Verify == nil {
+			definition.Verify = &verify.Options{}
+		}
+		if err := definition.Validate(); err != nil {
+			return nil, fmt.Errorf("pipeline %s: %w", definition.Name, err)
+		}
+	}
+	return definitions, nil
+}
+
+// runAll runs every pipeline a single time, all at once, and fails if any run failed
+func runAll(definitions []*pipeline.Definition) error {
+	if len(definitions) == 1 {
+		return runOnce(definitions[0], true)
+	}
+
+	// A failure is reported as it happens
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	for _, definition := range definitions {
+		wg.Add(1)
+		go func(definition *pipeline.Definition) {
+			defer wg.Done()
+			if err := runOnce(definition, false); err != nil {
+				logger.Errorf("Pipeline %s: %v", definition.Name, err)
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}(definition)
+	}
+	wg.Wait()
+	if failed > 0 {
+		return fmt.Errorf("%d of %d pipelines failed", failed, len(definitions))
+	}
+	return nil
+}
+
+// loadPipelines reads the pipelines of the config file, or sets up a pipeline with the
+// wizard when the file cannot be loaded in interactive mode
+func loadPipelines(opts runOptions) ([]*pipeline.Definition, error) {
+	definitions, err := config.LoadPipelines(opts.configFile)
+	if errors.Is(err, fs.ErrNotExist) && !opts.interactive {
+		return nil, fmt.Errorf("%s not found, create one with `fractal init`", opts.configFile)
+	}
+	if err == nil || !opts.interactive {
+		return definitions, err
+	}
+
+	logger.Infof("Config file not found. Setup interactively: %v", err)
+	configuration, err := config.SetupConfigInteractively(opts.configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up configuration interactively: %w", err)
+	}
+	definition, err := config.PipelineFromConfig(configuration)
+	if err != nil {
+		return nil, err
+	}
+	return []*pipeline.Definition{definition}, nil
+}
+
+// selectPipeline returns the pipeline called name
+func selectPipeline(definitions []*pipeline.Definition, name, configFile string) ([]*pipeline.Definition, error) {
+	var names []string
+	for _, definition := range definitions {
+		if definition.Name == name {
+			return []*pipeline.Definition{definition}, nil
+		}
+		names = append(names, definition.Name)
+	}
+	return nil, fmt.Errorf("%s has no pipeline %q, it defines: %s", configFile, name, strings.Join(names, ", "))
+}
+
+// cliPipelines keeps the pipelines of the CLI running on their schedules and applies
+// reloaded configs to them
+type cliPipelines struct {
+	runStore    *store.Store
+	progressBar bool
+
+	mu      sync.Mutex
+	current []*pipeline.Definition
+	loops   map[string]context.CancelFunc
+	stopped bool
+}
+
+// apply starts added pipelines, stops removed ones and moves changed ones to their new
+// definition. Runs in progress are left to finish.
+func (p *cliPipelines) apply(definitions []*pipeline.Definition) pipeline.Changes {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return pipeline.Changes{}
+	}
+	changes := pipeline.Diff(p.current, definitions)
+	p.current = definitions
+
+	for _, name := range changes.Removed {
+		p.stop(name)
+	}
+	for _, definition := range changes.Changed {
+		p.stop(definition.Name)
+		p.start(definition, false)
+	}
+	for _, definition := range changes.Added {
+		p.start(definition, true)
+	}
+	return changes
+}
+
+// stopAll stops every schedule and ignores later reloads. Runs in progress are left
+// to finish.
+func (p *cliPipelines) stopAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+	for name := range p.loops {
+		p.stop(name)
+	}
+}
+
+func (p *cliPipelines) stop(name string) {
+	if cancel, ok := p.loops[name]; ok {
+		cancel()
+		delete(p.loops, name)
+	}
+}
+
+// start schedules a pipeline, or runs it once if it has no schedule. A new pipeline
+// runs straight away unless it catches up on runs missed since its last recorded run;
+// a changed one waits for its next scheduled run.
+func (p *cliPipelines) start(definition *pipeline.Definition, added bool) {
+	logger.Infof("Pipeline %s: %s -> %s", definition.Name, definition.Source.Integration, strings.Join(definition.Spec("cli").DestinationNames(), ", "))
+	runLogged := func() {
+		if err := runOnce(definition, p.progressBar); err != nil {
+			logger.Errorf("Pipeline %s: %v", definition.Name, err)
+		}
+	}
+	if !definition.Scheduled() {
+		go runLogged()
+		return
+	}
+
+	spec, err := definition.ScheduleSpec()
+	if err != nil {
+		logger.Errorf("Pipeline %s not scheduled: %v", definition.Name, err)
+		return
+	}
+	loop, err := schedule.NewLoop("pipeline "+definition.Name, spec, func(time.Time) { runLogged() })
+	if err != nil {
+		logger.Errorf("Pipeline %s not scheduled: %v", definition.Name, err)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.loops[definition.Name] = cancel
+
+	go func() {
+		var last time.Time
+		if added && spec.CatchUp != "" {
+			if runs, err := p.runStore.ListRuns(store.RunFilter{Pipeline: definition.Name, Limit: 1}); err == nil && len(runs) > 0 {
+				last = runs[0].StartedAt
+			}
+		}
+		if added && last.IsZero() {
+			runLogged()
+		}
+		loop.Run(ctx, last)
+	}()
+}
+
+// runOnce moves the data of a pipeline from its source to its destinations
+func runOnce(definition *pipeline.Definition, progressBar bool) error {
+	// Create a root span for the entire task
+	ctx, span := opentele.CreateSpan(context.Background(), "cron-job")
+	defer span.End()
+
+	logger.Infof("Pipeline %s triggered at: %s", definition.Name, time.Now().Format(time.RFC3339))
+
+	// Secrets are resolved for every run, so rotated values are picked up
+	spec, err := definition.ResolvedSpec(ctx, "cli")
+	if err != nil {
+		return fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
+	spec.Caller = cliCaller()
+
+	// Draw a live progress bar when running in a terminal
+	spec.RunID = runner.NewID()
+	rendered := make(chan struct{})
+	if progressBar && term.IsTerminal(int(os.Stderr.Fd())) {
+		events, _ := progress.Start(spec.RunID).Subscribe()
+		go func() {
+			progress.Render(os.Stderr, events)
+			close(rendered)
+		}()
+	} else {
+		close(rendered)
+	}
+
+	run, err := runner.Execute(ctx, spec)
+	<-rendered
+	if len(run.Verification) > 0 {
+		fmt.Fprint(os.Stderr, verify.Text(run.Verification))
+	}
+	summary := notify.SummaryOf(run)
+	if err != nil {
+		span.RecordError(err)
+		logger.Warnf("%s", summary)
+		return fmt.Errorf("run %s failed: %w", run.ID, err)
+	}
+
+	logger.Infof("%s", summary)
+	return nil
+}
+
+// cliCaller names the user running the CLI in run records and the audit log
+func cliCaller() string {
+	name := os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	if name == "" {
+		name = "unknown"
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return "cli:" + name
+}
@@ -0,0 +1,173 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/SkySingh04/fractal/logger"
+)
+
+// Checkpoint is how far a run of a pipeline got before it was cancelled or failed:
+// the destinations that received all of its data. The next run of the pipeline with
+// the same configuration that reads the same data skips them.
+type Checkpoint struct {
+	Pipeline   string    `json:"pipeline"`
+	RunID      string    `json:"run_id"`
+	ConfigHash string    `json:"config_hash"`
+	DataHash   string    `json:"data_hash"` // Hash of the data as sent, after the rules
+	Delivered  []int     `json:"delivered"` // Indexes of the destinations that received the data
+	SavedAt    time.Time `json:"saved_at"`
+}
+
+// Checkpoints keep the checkpoint of every pipeline between runs and processes
+type Checkpoints interface {
+	// LoadCheckpoint returns the checkpoint of a pipeline, or nil if it has none
+	LoadCheckpoint(pipeline string) (*Checkpoint, error)
+	SaveCheckpoint(checkpoint *Checkpoint) error
+	DeleteCheckpoint(pipeline string) error
+}
+
+var (
+	checkpointsMu sync.RWMutex
+	checkpoints   Checkpoints
+)
+
+// UseCheckpoints makes runs commit their checkpoints to c and resume from them. The
+// returned function stops that again.
+func UseCheckpoints(c Checkpoints) (restore func()) {
+	checkpointsMu.Lock()
+	defer checkpointsMu.Unlock()
+	previous := checkpoints
+	checkpoints = c
+	return func() {
+		checkpointsMu.Lock()
+		defer checkpointsMu.Unlock()
+		checkpoints = previous
+	}
+}
+
+func checkpointStore() Checkpoints {
+	checkpointsMu.RLock()
+	defer checkpointsMu.RUnlock()
+	return checkpoints
+}
+
+// resumption tracks the destinations a run delivered to, starting from the
+// checkpoint of its pipeline
+type resumption struct {
+	store      Checkpoints
+	checkpoint *Checkpoint // The checkpoint the run started from, if any
+	delivered  []int
+	targets    int
+	dataHash   string
+}
+
+// resume loads the checkpoint of the pipeline of run, if it was made with the same
+// configuration
+func resume(log *logger.Logger, run *Run) *resumption {
+	r := &resumption{store: checkpointStore()}
+	if r.store == nil || run.Pipeline == "" {
+		return r
+	}
+	checkpoint, err := r.store.LoadCheckpoint(run.Pipeline)
+	if err != nil {
+		log.Warnf("Failed to load the checkpoint of pipeline %s, sending to every destination: %v", run.Pipeline, err)
+		return r
+	}
+	if checkpoint != nil && checkpoint.ConfigHash == run.ConfigHash {
+		r.checkpoint = checkpoint
+	}
+	return r
+}
+
+// read compares the data read with the data of the checkpoint. Destinations of the
+// checkpoint are only skipped when the data is the same, as they hold that data.
+func (r *resumption) read(log *logger.Logger, run *Run, data interface{}, targets int) {
+	if r.store == nil || run.Pipeline == "" {
+		return
+	}
+	r.dataHash, r.targets = dataHash(data), targets
+	if r.checkpoint == nil {
+		return
+	}
+	if r.checkpoint.DataHash != r.dataHash {
+		log.Infof("The data of pipeline %s changed since run %s, sending to every destination", run.Pipeline, r.checkpoint.RunID)
+		return
+	}
+	r.delivered = append(r.delivered, r.checkpoint.Delivered...)
+	run.ResumedFrom = r.checkpoint.RunID
+	log.Infof("Resuming run %s of pipeline %s, %d destinations already have the data", r.checkpoint.RunID, run.Pipeline, len(r.delivered))
+}
+
+// done tells whether destination i already has the data
+func (r *resumption) done(i int) bool {
+	return slices.Contains(r.delivered, i)
+}
+
+// deliver records that destination i received the data
+func (r *resumption) deliver(i int) {
+	if !r.done(i) {
+		r.delivered = append(r.delivered, i)
+	}
+}
+
+// commit saves the checkpoint of a run that did not finish, so the next run resumes
+// from it, and removes the checkpoint once a run of the configuration delivered to
+// every destination. A run that fails its verification then is not resumed either.
+func (r *resumption) commit(log *logger.Logger, run *Run, err error) {
+	if r.store == nil || run.Pipeline == "" {
+		return
+	}
+	if err == nil || r.targets > 0 && len(r.delivered) == r.targets {
+		if r.checkpoint != nil {
+			if err := r.store.DeleteCheckpoint(run.Pipeline); err != nil {
+				log.Warnf("Failed to remove the checkpoint of pipeline %s: %v", run.Pipeline, err)
+			}
+		}
+		return
+	}
+	if len(r.delivered) == 0 {
+		return // Nothing to skip next time; an older checkpoint still holds
+	}
+	slices.Sort(r.delivered)
+	checkpoint := &Checkpoint{
+		Pipeline:   run.Pipeline,
+		RunID:      run.ID,
+		ConfigHash: run.ConfigHash,
+		DataHash:   r.dataHash,
+		Delivered:  r.delivered,
+		SavedAt:    time.Now().UTC(),
+	}
+	if r.checkpoint != nil && r.checkpoint.DataHash == r.dataHash {
+		checkpoint.RunID = r.checkpoint.RunID // Resumed runs keep pointing at the first
+	}
+	if err := r.store.SaveCheckpoint(checkpoint); err != nil {
+		log.Errorf("Failed to save the checkpoint of pipeline %s: %v", run.Pipeline, err)
+		return
+	}
+	log.Infof("Saved the checkpoint of pipeline %s: %d of the destinations have the data", run.Pipeline, len(r.delivered))
+}
+
+// dataHash returns a hash of the data sent by a run
+func dataHash(data interface{}) string {
+	var payload []byte
+	switch v := data.(type) {
+	case string:
+		payload = []byte(v)
+	case []byte:
+		payload = v
+	default:
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			encoded = []byte(fmt.Sprintf("%#v", data))
+		}
+		payload = encoded
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
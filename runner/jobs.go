@@ -0,0 +1,166 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SkySingh04/fractal/progress"
+)
+
+// ErrShuttingDown is returned for runs started after Drain was called
+var ErrShuttingDown = errors.New("fractal is shutting down, no new runs are started")
+
+var (
+	activeMu sync.Mutex
+	active   = map[string]*job{} // Every run in progress, by id
+	inflight sync.WaitGroup      // Runs in progress, waited for by Drain
+	draining bool
+)
+
+// job is a run in progress
+type job struct {
+	cancel context.CancelFunc
+	run    Run // What the spec tells of the run, see Running
+}
+
+// begin registers a run in progress and returns its context, cancelled by Cancel and
+// Drain, and the function to call once the run is over
+func begin(ctx context.Context, spec Spec) (context.Context, func(), error) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	if draining {
+		return nil, nil, ErrShuttingDown
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	active[spec.RunID] = &job{cancel: cancel, run: Run{
+		ID:          spec.RunID,
+		Pipeline:    spec.Pipeline,
+		Source:      spec.Source,
+		Destination: strings.Join(spec.DestinationNames(), ","),
+		Trigger:     spec.Trigger,
+		Caller:      spec.Caller,
+		StartedAt:   time.Now().UTC(),
+		Status:      StatusRunning,
+	}}
+	inflight.Add(1)
+	return ctx, func() {
+		activeMu.Lock()
+		delete(active, spec.RunID)
+		activeMu.Unlock()
+		cancel()
+		inflight.Done()
+	}, nil
+}
+
+// Running returns what is known of a run in progress before its record is written:
+// its pipeline, source, destinations, trigger and caller. It reports false once the
+// run is over.
+func Running(id string) (Run, bool) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	j, ok := active[id]
+	if !ok {
+		return Run{}, false
+	}
+	return j.run, true
+}
+
+// refused is the record of a run that was not started because of err. It goes
+// through the hooks like any run, so it is kept in the run history and the audit log.
+func refused(spec Spec, err error) *Run {
+	now := time.Now().UTC()
+	run := &Run{
+		ID:          spec.RunID,
+		Pipeline:    spec.Pipeline,
+		Source:      spec.Source,
+		Destination: strings.Join(spec.DestinationNames(), ","),
+		Trigger:     spec.Trigger,
+		Caller:      spec.Caller,
+		StartedAt:   now,
+		FinishedAt:  now,
+		Status:      StatusCancelled,
+		Error:       err.Error(),
+		ConfigHash:  ConfigHash(spec),
+	}
+	notifyData(func(h DataHook) { h.Planned(run, spec) })
+	notify(func(h Hook) { h.RunStarted(run) })
+	progress.Start(run.ID).Finish(err)
+	notify(func(h Hook) { h.RunFinished(run) })
+	return run
+}
+
+// Start executes spec in the background and returns its run id straight away.
+// finished, if not nil, is called with the result once the run is over.
+func Start(spec Spec, finished func(*Run, error)) string {
+	if spec.RunID == "" {
+		spec.RunID = NewID()
+	}
+	progress.Start(spec.RunID)
+
+	// Registered before returning, so that the run can be cancelled straight away
+	ctx, end, err := begin(context.Background(), spec)
+	go func() {
+		var run *Run
+		if err != nil {
+			run = refused(spec, err)
+		} else {
+			defer end()
+			run, err = execute(ctx, spec)
+		}
+		if finished != nil {
+			finished(run, err)
+		}
+	}()
+	return spec.RunID
+}
+
+// Cancel stops a run in progress. It reports false if no such run is running.
+func Cancel(id string) bool {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	j, ok := active[id]
+	if ok {
+		j.cancel()
+	}
+	return ok
+}
+
+// Drain stops new runs from starting and waits for the runs in progress to finish.
+// Runs still going when ctx is done are cancelled and waited for, so that their
+// records are written and their checkpoints committed, see Checkpoint; Drain returns
+// how many had to be cancelled. A run cancelled after some of its destinations
+// received the data resumes from there when its pipeline runs again. Runs can start
+// again once Drain returns, so whatever starts them is to be stopped first.
+func Drain(ctx context.Context) int {
+	activeMu.Lock()
+	draining = true
+	activeMu.Unlock()
+	defer func() {
+		activeMu.Lock()
+		draining = false
+		activeMu.Unlock()
+	}()
+
+	idle := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+		return 0
+	case <-ctx.Done():
+	}
+
+	activeMu.Lock()
+	cancelled := len(active)
+	for _, j := range active {
+		j.cancel()
+	}
+	activeMu.Unlock()
+	<-idle
+	return cancelled
+}
@@ -0,0 +1,548 @@
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SkySingh04/fractal/factory"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/language"
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/SkySingh04/fractal/progress"
+	"github.com/SkySingh04/fractal/schema"
+	"github.com/SkySingh04/fractal/verify"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Status describes where a run is in its lifecycle
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Run is the record kept for every migration, whether it was started over HTTP or by the CLI
+type Run struct {
+	ID             string    `json:"id"`
+	Pipeline       string    `json:"pipeline"`
+	Source         string    `json:"source"`
+	Destination    string    `json:"destination"`
+	Trigger        string    `json:"trigger"`
+	Caller         string    `json:"caller,omitempty"` // Who started the run, see Spec.Caller
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	RecordsRead    int       `json:"records_read"`
+	RecordsWritten int       `json:"records_written"`
+	Bytes          int64     `json:"bytes"`
+	Status         Status    `json:"status"`
+	Error          string    `json:"error,omitempty"`
+	ConfigHash     string    `json:"config_hash"`
+	// Errors counts the errors of the run by kind, such as read, validation or write,
+	// including records that failed and were left out
+	Errors map[string]int `json:"errors,omitempty"`
+	// Quarantined is the number of records left out because they failed validation
+	// or transformation
+	Quarantined int `json:"quarantined,omitempty"`
+	// Verification reconciles every destination with the source, for pipelines
+	// that are verified
+	Verification []verify.Report `json:"verification,omitempty"`
+	// ResumedFrom is the run whose checkpoint this run resumed from, see Checkpoint
+	ResumedFrom string `json:"resumed_from,omitempty"`
+}
+
+// Destinations returns the names of the destinations of the run
+func (r *Run) Destinations() []string {
+	if r.Destination == "" {
+		return nil
+	}
+	return strings.Split(r.Destination, ",")
+}
+
+// Duration returns how long the run took, or how long it has been running so far
+func (r *Run) Duration() time.Duration {
+	if r.FinishedAt.IsZero() {
+		return time.Since(r.StartedAt)
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// Spec describes a single migration from one source to one or more destinations
+type Spec struct {
+	RunID              string // Optional, generated when empty
+	Pipeline           string
+	Source             string
+	Destination        string
+	SourceRequest      interfaces.Request
+	DestinationRequest interfaces.Request
+	Destinations       []Target        // Further destinations that receive the same data
+	Trigger            string          // "http", "cli", "schedule" or "manual"
+	Caller             string          // Who started the run, such as "jwt:alice", "cli:bob" or "scheduler"
+	OnError            string          // What to do when a destination fails, OnErrorStop when empty
+	Verify             *verify.Options // Reconcile the destinations with the source after sending when set
+	Validations        []string        // Validation rules of the pipeline
+	Transformations    []string        // Transformation rules of the pipeline
+}
+
+// Error strategies decide what a run does when sending to a destination fails
+const (
+	OnErrorStop     = "STOP"             // Fail the run and skip the remaining destinations
+	OnErrorContinue = "LOG_AND_CONTINUE" // Log the failure, send to the remaining destinations, then fail the run
+	OnErrorRetry    = "RETRY"            // Retry the destination before failing the run
+)
+
+// ErrorStrategies lists the values accepted for Spec.OnError
+var ErrorStrategies = []string{OnErrorStop, OnErrorContinue, OnErrorRetry}
+
+// retryAttempts and retryBackoff bound the retries of OnErrorRetry; the wait grows
+// with every attempt
+var (
+	retryAttempts = 3
+	retryBackoff  = time.Second
+)
+
+// Target is a destination together with its configuration
+type Target struct {
+	Destination string
+	Request     interfaces.Request
+}
+
+// targets returns every destination of the spec, in order
+func (s Spec) targets() []Target {
+	targets := []Target{{Destination: s.Destination, Request: s.DestinationRequest}}
+	return append(targets, s.Destinations...)
+}
+
+// DestinationNames returns the names of every destination of the spec
+func (s Spec) DestinationNames() []string {
+	var names []string
+	for _, target := range s.targets() {
+		names = append(names, target.Destination)
+	}
+	return names
+}
+
+// Hook is notified when a run starts and when it finishes
+type Hook interface {
+	RunStarted(run *Run)
+	RunFinished(run *Run)
+}
+
+// StepHook is a Hook that is also told how the fetch from the source and the send to
+// every destination of a run went. Sent is called once per destination, after any
+// retries; took includes the retries and the waits between them.
+type StepHook interface {
+	Hook
+	Fetched(run *Run, took time.Duration, err error)
+	Sent(run *Run, destination string, attempts int, took time.Duration, err error)
+}
+
+// DataHook is a Hook that is also shown the spec of every run just before it starts,
+// and the data read from the source once it has been read. Neither may be modified.
+type DataHook interface {
+	Hook
+	Planned(run *Run, spec Spec)
+	Read(run *Run, data interface{})
+}
+
+var (
+	hooksMu sync.RWMutex
+	hooks   []*registration
+)
+
+// registration is a registered hook. Its address tells apart registrations of
+// the same hook.
+type registration struct{ hook Hook }
+
+// RegisterHook adds a hook that is called for every run. The returned function
+// removes it again.
+func RegisterHook(hook Hook) (unregister func()) {
+	r := &registration{hook}
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks = append(hooks, r)
+	return func() {
+		hooksMu.Lock()
+		defer hooksMu.Unlock()
+		hooks = slices.DeleteFunc(hooks, func(registered *registration) bool { return registered == r })
+	}
+}
+
+func notify(fn func(Hook)) {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	for _, r := range hooks {
+		fn(r.hook)
+	}
+}
+
+func notifySteps(fn func(StepHook)) {
+	notify(func(h Hook) {
+		if step, ok := h.(StepHook); ok {
+			fn(step)
+		}
+	})
+}
+
+func notifyData(fn func(DataHook)) {
+	notify(func(h Hook) {
+		if data, ok := h.(DataHook); ok {
+			fn(data)
+		}
+	})
+}
+
+// Execute fetches data from the source described by spec, sends it to the destination
+// and returns the resulting run record. The record is returned even when the run fails.
+// The run can be cancelled with Cancel and is waited for by Drain.
+func Execute(ctx context.Context, spec Spec) (*Run, error) {
+	if spec.RunID == "" {
+		spec.RunID = NewID()
+	}
+	ctx, end, err := begin(ctx, spec)
+	if err != nil {
+		return refused(spec, err), err
+	}
+	defer end()
+	return execute(ctx, spec)
+}
+
+func execute(ctx context.Context, spec Spec) (*Run, error) {
+	run := &Run{
+		ID:          spec.RunID,
+		Pipeline:    spec.Pipeline,
+		Source:      spec.Source,
+		Destination: strings.Join(spec.DestinationNames(), ","),
+		Trigger:     spec.Trigger,
+		Caller:      spec.Caller,
+		StartedAt:   time.Now().UTC(),
+		Status:      StatusRunning,
+		ConfigHash:  ConfigHash(spec),
+	}
+	notifyData(func(h DataHook) { h.Planned(run, spec) })
+	notify(func(h Hook) { h.RunStarted(run) })
+
+	ctx, span := opentele.CreateSpan(ctx, "run",
+		attribute.String("fractal.pipeline", run.Pipeline),
+		attribute.String("fractal.run_id", run.ID),
+		attribute.String("fractal.trigger", run.Trigger),
+		attribute.String("fractal.source", run.Source),
+		attribute.String("fractal.destination", run.Destination),
+	)
+	ctx = logger.NewContext(ctx, logger.FromContext(ctx).With("pipeline", run.Pipeline, "run_id", run.ID))
+	tracker := progress.Start(run.ID)
+	err := transfer(progress.WithTracker(ctx, tracker), spec, run)
+	tracker.Finish(err)
+	final := tracker.Snapshot()
+	run.Errors, run.Quarantined = final.ErrorsByKind, final.Quarantined
+	span.SetAttributes(attribute.Int("fractal.records_read", run.RecordsRead), attribute.Int("fractal.records_written", run.RecordsWritten))
+	opentele.EndSpan(span, err)
+
+	run.FinishedAt = time.Now().UTC()
+	// Integrations do not all wrap the error of a cancelled context
+	if err != nil && (errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled)) {
+		run.Status = StatusCancelled
+		run.Error = err.Error()
+	} else if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+	} else {
+		run.Status = StatusSucceeded
+	}
+	notify(func(h Hook) { h.RunFinished(run) })
+
+	return run, err
+}
+
+// transfer reads the data of the source and sends it to every destination. Runs of
+// a pipeline skip the destinations its checkpoint holds, and commit a checkpoint when
+// they end before every destination received the data.
+func transfer(ctx context.Context, spec Spec, run *Run) (err error) {
+	tracker := progress.FromContext(ctx)
+	log := logger.FromContext(ctx)
+	resumed := resume(log, run)
+	defer func() { resumed.commit(log, run, err) }()
+	rules, err := language.Compile(spec.Validations, spec.Transformations)
+	if err != nil {
+		err = fmt.Errorf("invalid pipeline rules: %w", err)
+		tracker.Fail(progress.ErrorValidation, err)
+		return err
+	}
+
+	// Fetch data from the source
+	tracker.SetPhase(progress.PhaseFetching)
+	fetchStart := time.Now()
+	data, err := fetch(ctx, spec)
+	if err == nil {
+		run.RecordsRead = CountRecords(data)
+		run.Bytes = PayloadSize(data)
+	}
+	notifySteps(func(h StepHook) { h.Fetched(run, time.Since(fetchStart), err) })
+	if err != nil {
+		tracker.Fail(progress.ErrorKind(err, progress.ErrorRead), err)
+		return err
+	}
+	tracker.EnsureRead(run.RecordsRead)
+	notifyData(func(h DataHook) { h.Read(run, data) })
+
+	// Leave out the records that fail a rule, and send the others transformed
+	data, rejections, err := rules.Apply(data)
+	if err != nil {
+		err = fmt.Errorf("applying pipeline rules: %w", err)
+		tracker.Fail(progress.ErrorTransformation, err)
+		return err
+	}
+	for _, rejection := range rejections {
+		tracker.Quarantine(rejection.Kind, rejection.Err)
+		log.Warnf("record left out of run %s: %v", run.ID, rejection.Err)
+	}
+	sent := CountRecords(data)
+
+	// Send data to every destination that does not have it yet
+	targets := spec.targets()
+	resumed.read(log, run, data, len(targets))
+	pending := 0
+	for i := range targets {
+		if !resumed.done(i) {
+			pending++
+		}
+	}
+	tracker.SetTotal(sent * pending)
+	tracker.SetPhase(progress.PhaseSending)
+	var failed []error
+	var verified []Target
+	for i, target := range targets {
+		if resumed.done(i) {
+			log.Infof("Skipping %s, which received the data in run %s", target.Destination, run.ResumedFrom)
+			verified = append(verified, target)
+			continue
+		}
+		// Do not start writing if the run has been cancelled
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("run cancelled before sending data to %s: %w", target.Destination, err)
+		}
+		sendStart := time.Now()
+		attempts, err := sendWithStrategy(ctx, spec.OnError, target, data)
+		notifySteps(func(h StepHook) { h.Sent(run, target.Destination, attempts, time.Since(sendStart), err) })
+		if err != nil {
+			tracker.Fail(progress.ErrorKind(err, progress.ErrorWrite), err)
+			if spec.OnError != OnErrorContinue {
+				return err
+			}
+			log.Warnf("%v, continuing with the remaining destinations", err)
+			failed = append(failed, err)
+			continue
+		}
+		run.RecordsWritten += sent
+		tracker.EnsureWritten(run.RecordsWritten)
+		resumed.deliver(i)
+		verified = append(verified, target)
+	}
+	if len(failed) == 0 && spec.Verify != nil {
+		tracker.SetPhase(progress.PhaseVerifying)
+		if err := verifyTargets(ctx, *spec.Verify, verified, data, run); err != nil {
+			tracker.Fail(progress.ErrorKind(err, progress.ErrorVerification), err)
+			return err
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// verifyTargets reads back the destinations that received the data and reconciles
+// them with it. The run fails when a destination does not hold what was sent.
+func verifyTargets(ctx context.Context, options verify.Options, targets []Target, data interface{}, run *Run) error {
+	// Reading back is not progress of the run
+	ctx = progress.WithTracker(ctx, nil)
+	var mismatched []string
+	for _, target := range targets {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("run cancelled before verifying %s: %w", target.Destination, err)
+		}
+		req := target.Request
+		if integration, err := schema.ForDestination(target.Destination); err == nil {
+			schema.ApplyDefaults(integration, &req)
+		}
+		report := verify.Destination(ctx, target.Destination, req, data, options)
+		run.Verification = append(run.Verification, report)
+		if report.Status == verify.StatusFailed {
+			mismatched = append(mismatched, target.Destination)
+		}
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("verification failed for %s", strings.Join(mismatched, ", "))
+	}
+	return nil
+}
+
+// fetch reads the data of a run from its source
+func fetch(ctx context.Context, spec Spec) (data interface{}, err error) {
+	fetchCtx, fetchSpan := opentele.CreateSpan(ctx, "fetch-data", attribute.String("fractal.source", spec.Source))
+	defer func() { opentele.EndSpan(fetchSpan, err) }()
+	fetchCtx = logger.NewContext(fetchCtx, logger.FromContext(ctx).With("integration", spec.Source))
+
+	source, err := factory.CreateSource(spec.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create source for input method %s: %v", spec.Source, err)
+	}
+	if integration, err := schema.ForSource(spec.Source); err == nil {
+		schema.ApplyDefaults(integration, &spec.SourceRequest)
+		redactSecrets(integration, spec.SourceRequest)
+	}
+	data, err = source.FetchData(spec.SourceRequest.WithContext(fetchCtx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch data from %s: %v", spec.Source, err)
+	}
+	fetchSpan.SetAttributes(opentele.Records(CountRecords(data)))
+	return data, nil
+}
+
+// sendWithStrategy sends data to a target, retrying failures when the strategy is
+// OnErrorRetry. It returns how many times the data was sent.
+func sendWithStrategy(ctx context.Context, strategy string, target Target, data interface{}) (int, error) {
+	err := send(ctx, target, data)
+	if strategy != OnErrorRetry {
+		return 1, err
+	}
+	attempt := 1
+	for ; err != nil && attempt < retryAttempts; attempt++ {
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(time.Duration(attempt) * retryBackoff):
+		}
+		err = send(ctx, target, data)
+	}
+	return attempt, err
+}
+
+func send(ctx context.Context, target Target, data interface{}) (err error) {
+	sendCtx, sendSpan := opentele.CreateSpan(ctx, "send-data",
+		attribute.String("fractal.destination", target.Destination),
+		opentele.Records(CountRecords(data)),
+	)
+	defer func() { opentele.EndSpan(sendSpan, err) }()
+	sendCtx = logger.NewContext(sendCtx, logger.FromContext(ctx).With("integration", target.Destination))
+
+	destination, err := factory.CreateDestination(target.Destination)
+	if err != nil {
+		return fmt.Errorf("failed to create destination for output method %s: %v", target.Destination, err)
+	}
+	if integration, err := schema.ForDestination(target.Destination); err == nil {
+		schema.ApplyDefaults(integration, &target.Request)
+		redactSecrets(integration, target.Request)
+	}
+	if err := destination.SendData(data, target.Request.WithContext(sendCtx)); err != nil {
+		return fmt.Errorf("failed to send data to %s: %v", target.Destination, err)
+	}
+	return nil
+}
+
+// redactSecrets keeps the values of the secret fields of a request out of the logs
+func redactSecrets(integration schema.Integration, req interfaces.Request) {
+	for _, field := range integration.Fields {
+		if field.Secret {
+			logger.AddSecret(schema.Value(req, field))
+		}
+	}
+}
+
+// NewID returns a run identifier that sorts in creation order
+func NewID() string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return time.Now().UTC().Format("20060102T150405.000000000") + "-" + hex.EncodeToString(suffix)
+}
+
+// ConfigHash returns a stable hash of the integration names and requests of a spec,
+// so runs made with the same configuration can be grouped together.
+func ConfigHash(spec Spec) string {
+	payload, err := json.Marshal(struct {
+		Source             string
+		Destination        string
+		SourceRequest      interfaces.Request
+		DestinationRequest interfaces.Request
+		Destinations       []Target `json:",omitempty"`
+	}{spec.Source, spec.Destination, spec.SourceRequest, spec.DestinationRequest, spec.Destinations})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:8])
+}
+
+// CountRecords estimates the number of records in the data returned by a source.
+// Strings count non-empty lines, slices count elements and maps of slices (for example
+// rows keyed by table name) count the elements of every slice.
+func CountRecords(data interface{}) int {
+	switch v := data.(type) {
+	case nil:
+		return 0
+	case string:
+		count := 0
+		for _, line := range strings.Split(v, "\n") {
+			if strings.TrimSpace(line) != "" {
+				count++
+			}
+		}
+		return count
+	case []byte:
+		if len(v) == 0 {
+			return 0
+		}
+		return 1
+	}
+
+	val := reflect.ValueOf(data)
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		return val.Len()
+	case reflect.Map:
+		total := 0
+		iter := val.MapRange()
+		for iter.Next() {
+			elem := iter.Value()
+			if elem.Kind() == reflect.Interface {
+				elem = elem.Elem()
+			}
+			if elem.Kind() != reflect.Slice {
+				return 1 // A single document
+			}
+			total += elem.Len()
+		}
+		return total
+	default:
+		return 1
+	}
+}
+
+// PayloadSize returns the size in bytes of the data returned by a source
+func PayloadSize(data interface{}) int64 {
+	switch v := data.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return 0
+	}
+	return int64(len(encoded))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/store"
+	"github.com/spf13/cobra"
+)
+
+// runsCommand implements `fractal runs list` and `fractal runs show`
+func runsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "runs",
+		Short: "Inspect the run history",
+	}
+
+	var filter store.RunFilter
+	var status string
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List recent runs, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runStore, err := store.OpenFromEnv()
+			if err != nil {
+				return fmt.Errorf("failed to open run store: %w", err)
+			}
+			filter.Status = runner.Status(status)
+			runs, err := runStore.ListRuns(filter)
+			if err != nil {
+				return fmt.Errorf("failed to list runs: %w", err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tPIPELINE\tSOURCE\tDESTINATION\tSTARTED\tDURATION\tRECORDS\tSTATUS")
+			for _, run := range runs {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d/%d\t%s\n",
+					run.ID, run.Pipeline, run.Source, run.Destination,
+					run.StartedAt.Local().Format(time.DateTime), run.Duration().Round(time.Millisecond),
+					run.RecordsWritten, run.RecordsRead, run.Status)
+			}
+			return w.Flush()
+		},
+	}
+	list.Flags().StringVar(&filter.Pipeline, "pipeline", "", "only show runs of this pipeline")
+	list.Flags().StringVar(&status, "status", "", "only show runs with this status (running, succeeded, failed, cancelled)")
+	list.Flags().IntVar(&filter.Limit, "limit", 20, "maximum number of runs to show")
+
+	show := &cobra.Command{
+		Use:   "show RUN_ID",
+		Short: "Print a run as JSON",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runStore, err := store.OpenFromEnv()
+			if err != nil {
+				return fmt.Errorf("failed to open run store: %w", err)
+			}
+			run, err := runStore.GetRun(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to load run %s: %w", args[0], err)
+			}
+
+			encoded, err := json.MarshalIndent(run, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode run %s: %w", args[0], err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(encoded))
+			return nil
+		},
+	}
+
+	cmd.AddCommand(list, show)
+	return cmd
+}
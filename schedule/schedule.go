@@ -0,0 +1,230 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/robfig/cron/v3"
+)
+
+// Overlap decides what happens when a run is due while the previous one is still going
+type Overlap string
+
+const (
+	OverlapSkip       Overlap = "skip"       // Drop the new run (default)
+	OverlapQueue      Overlap = "queue"      // Start the new run as soon as the previous one finishes
+	OverlapConcurrent Overlap = "concurrent" // Start the new run straight away
+)
+
+// CatchUp decides what happens to runs that were missed while the process was down
+type CatchUp string
+
+const (
+	CatchUpNone CatchUp = "none" // Forget missed runs (default)
+	CatchUpOnce CatchUp = "once" // Make up for all missed runs with a single run
+	CatchUpAll  CatchUp = "all"  // Make up for every missed run, one after another
+)
+
+// maxCatchUp bounds the number of runs made up for with CatchUpAll
+const maxCatchUp = 100
+
+var parser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Spec describes when something runs. Expression is one of
+//
+//	a cron expression       "30 2 * * 1-5", optionally with a leading seconds field
+//	a shortcut              "@hourly", "@daily", "@weekly", "@monthly", "@yearly"
+//	an interval             "@every 15m", or just "15m"; a bare number means seconds
+type Spec struct {
+	Expression string
+	Timezone   string // IANA time zone of the cron expression, such as "Europe/Berlin"; local time when empty
+	Overlap    Overlap
+	CatchUp    CatchUp
+	Jitter     time.Duration // Each run is delayed by a random duration up to Jitter
+}
+
+// Parse returns the schedule described by the spec
+func (s Spec) Parse() (cron.Schedule, error) {
+	expression := strings.TrimSpace(s.Expression)
+	if expression == "" {
+		return nil, errors.New("schedule is empty")
+	}
+	if seconds, err := strconv.Atoi(expression); err == nil {
+		expression = fmt.Sprintf("@every %ds", seconds)
+	} else if interval, err := time.ParseDuration(expression); err == nil {
+		expression = "@every " + interval.String()
+	}
+	if strings.HasPrefix(expression, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimPrefix(expression, "@every "))
+		if err != nil || interval < time.Second {
+			return nil, fmt.Errorf("%q must be an interval of at least one second", s.Expression)
+		}
+	}
+
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", s.Timezone)
+		}
+		expression = "CRON_TZ=" + s.Timezone + " " + expression
+	}
+
+	schedule, err := parser.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %v", s.Expression, err)
+	}
+	return schedule, nil
+}
+
+// Validate checks every field of the spec
+func (s Spec) Validate() error {
+	if _, err := s.Parse(); err != nil {
+		return err
+	}
+	switch s.Overlap {
+	case "", OverlapSkip, OverlapQueue, OverlapConcurrent:
+	default:
+		return fmt.Errorf("overlap must be skip, queue or concurrent, not %q", s.Overlap)
+	}
+	switch s.CatchUp {
+	case "", CatchUpNone, CatchUpOnce, CatchUpAll:
+	default:
+		return fmt.Errorf("catch_up must be none, once or all, not %q", s.CatchUp)
+	}
+	if s.Jitter < 0 {
+		return errors.New("jitter cannot be negative")
+	}
+	return nil
+}
+
+// Missed returns the times the schedule fired after last and up to now
+func Missed(schedule cron.Schedule, last, now time.Time) []time.Time {
+	var missed []time.Time
+	for next := schedule.Next(last); !next.After(now); next = schedule.Next(next) {
+		missed = append(missed, next)
+		if len(missed) > maxCatchUp {
+			missed = missed[1:]
+		}
+	}
+	return missed
+}
+
+// Loop calls a function every time a schedule fires, applying the overlap,
+// catch-up and jitter settings of its spec
+type Loop struct {
+	name     string
+	spec     Spec
+	schedule cron.Schedule
+	fire     func(scheduled time.Time)
+
+	mu      sync.Mutex
+	next    time.Time
+	running int
+	queue   []time.Time
+}
+
+// NewLoop returns a loop that calls fire with the time each run was scheduled for.
+// fire should return once the run is over. name is only used in log messages.
+func NewLoop(name string, spec Spec, fire func(scheduled time.Time)) (*Loop, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	schedule, _ := spec.Parse()
+	return &Loop{name: name, spec: spec, schedule: schedule, fire: fire, next: schedule.Next(time.Now())}, nil
+}
+
+// Run fires the schedule until ctx is done. last is when the previous run happened;
+// runs missed since then are made up for according to the catch-up policy. A zero
+// last skips catch-up. Runs in progress are not waited for.
+func (l *Loop) Run(ctx context.Context, last time.Time) {
+	if !last.IsZero() {
+		missed := Missed(l.schedule, last, time.Now())
+		switch {
+		case len(missed) == 0:
+		case l.spec.CatchUp == CatchUpOnce:
+			logger.Infof("%s missed %d runs, catching up with one run", l.name, len(missed))
+			l.trigger(missed[len(missed)-1], true)
+		case l.spec.CatchUp == CatchUpAll:
+			logger.Infof("%s missed %d runs, catching up with every one", l.name, len(missed))
+			for _, scheduled := range missed {
+				l.trigger(scheduled, true)
+			}
+		}
+	}
+
+	for {
+		next := l.schedule.Next(time.Now())
+		at := next.Add(l.jitter())
+		l.mu.Lock()
+		l.next = at
+		l.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			l.trigger(next, false)
+		}
+	}
+}
+
+// Next returns when the loop fires next, jitter included
+func (l *Loop) Next() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.next
+}
+
+// trigger starts a run, or queues or skips it while another run is in progress
+func (l *Loop) trigger(scheduled time.Time, queue bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.running > 0 {
+		overlap := l.spec.Overlap
+		if queue {
+			overlap = OverlapQueue
+		}
+		switch overlap {
+		case OverlapConcurrent:
+		case OverlapQueue:
+			l.queue = append(l.queue, scheduled)
+			return
+		default:
+			logger.Logf("Skipping run of %s scheduled for %s: previous run still in progress", l.name, scheduled.Format(time.RFC3339))
+			return
+		}
+	}
+
+	l.running++
+	go l.execute(scheduled)
+}
+
+func (l *Loop) execute(scheduled time.Time) {
+	l.fire(scheduled)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running--
+	if len(l.queue) > 0 && l.running == 0 {
+		next := l.queue[0]
+		l.queue = l.queue[1:]
+		l.running++
+		go l.execute(next)
+	}
+}
+
+func (l *Loop) jitter() time.Duration {
+	if l.spec.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(l.spec.Jitter)))
+}
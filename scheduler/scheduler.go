@@ -0,0 +1,295 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/schedule"
+	"github.com/SkySingh04/fractal/store"
+)
+
+// ErrAlreadyRunning is returned when a pipeline is started while a run of it is in
+// progress and its overlap policy does not allow concurrent runs
+var ErrAlreadyRunning = errors.New("pipeline is already running")
+
+// Status is the scheduling state of a pipeline
+type Status struct {
+	Running   bool       `json:"running"`
+	RunIDs    []string   `json:"run_ids,omitempty"`     // Ids of the runs in progress
+	NextRunAt *time.Time `json:"next_run_at,omitempty"` // Unset for paused and manual-only pipelines
+}
+
+// Scheduler runs saved pipelines on their schedule inside the server process.
+// What happens to a run that is due while the previous one is still going, and to
+// runs missed while the server was down, follows the overlap and catch-up settings
+// of each pipeline.
+type Scheduler struct {
+	store *store.Store
+
+	mu      sync.Mutex
+	loops   map[string]*entry
+	running map[string][]string             // Pipeline name to the ids of its runs in progress
+	static  map[string]*pipeline.Definition // Pipelines of the config file, see Load
+	stopped bool
+}
+
+type entry struct {
+	loop   *schedule.Loop
+	cancel context.CancelFunc
+}
+
+// New returns a scheduler for the pipelines saved in s
+func New(s *store.Store) *Scheduler {
+	return &Scheduler{store: s, loops: map[string]*entry{}, running: map[string][]string{}, static: map[string]*pipeline.Definition{}}
+}
+
+// Start schedules every saved pipeline that is not paused and catches up on runs
+// missed since each pipeline last ran
+func (s *Scheduler) Start() error {
+	definitions, err := s.store.ListPipelines()
+	if err != nil {
+		return err
+	}
+	for i := range definitions {
+		s.schedule(&definitions[i], s.lastRun(definitions[i].Name))
+	}
+	logger.Infof("Scheduler started with %d pipelines", len(definitions))
+	return nil
+}
+
+// Load schedules the pipelines of a config file next to the saved ones. They are not
+// saved, and the secret references in their configs are resolved for every run. A
+// pipeline named like a saved one is skipped.
+//
+// Called again after the file changed, Load applies the difference: new pipelines are
+// scheduled, removed ones stop being scheduled while their runs in progress finish,
+// and changed ones switch to the new definition from their next scheduled run on.
+func (s *Scheduler) Load(definitions []*pipeline.Definition) pipeline.Changes {
+	var loaded []*pipeline.Definition
+	for _, definition := range definitions {
+		if _, err := s.store.GetPipeline(definition.Name); err == nil {
+			logger.Logf("Pipeline %s of the config file not scheduled: a saved pipeline has the same name", definition.Name)
+			continue
+		}
+		loaded = append(loaded, definition)
+	}
+
+	s.mu.Lock()
+	var previous []*pipeline.Definition
+	for _, definition := range s.static {
+		previous = append(previous, definition)
+	}
+	sort.Slice(previous, func(i, j int) bool { return previous[i].Name < previous[j].Name })
+	s.static = map[string]*pipeline.Definition{}
+	for _, definition := range loaded {
+		s.static[definition.Name] = definition
+	}
+	s.mu.Unlock()
+
+	changes := pipeline.Diff(previous, loaded)
+	for _, name := range changes.Removed {
+		s.Unschedule(name)
+	}
+	for _, definition := range changes.Changed {
+		s.schedule(definition, time.Time{})
+	}
+	for _, definition := range changes.Added {
+		s.schedule(definition, s.lastRun(definition.Name))
+	}
+	return changes
+}
+
+// Stop cancels every schedule. Runs in progress are left to finish.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	for name, e := range s.loops {
+		e.cancel()
+		delete(s.loops, name)
+	}
+}
+
+// Schedule (re)starts the schedule of a pipeline after it was created, changed or
+// resumed. Paused and manual-only pipelines are unscheduled.
+func (s *Scheduler) Schedule(definition *pipeline.Definition) {
+	s.schedule(definition, time.Time{})
+}
+
+func (s *Scheduler) schedule(definition *pipeline.Definition, last time.Time) {
+	s.Unschedule(definition.Name)
+	if !definition.Scheduled() || definition.Paused {
+		return
+	}
+
+	name := definition.Name
+	spec, err := definition.ScheduleSpec()
+	if err != nil {
+		logger.Errorf("Pipeline %s not scheduled: %v", name, err)
+		return
+	}
+	loop, err := schedule.NewLoop("pipeline "+name, spec, func(time.Time) { s.fire(name) })
+	if err != nil {
+		logger.Errorf("Pipeline %s not scheduled: %v", name, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.loops[name] = &entry{loop: loop, cancel: cancel}
+	go loop.Run(ctx, last)
+}
+
+// Unschedule stops the schedule of a pipeline
+func (s *Scheduler) Unschedule(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.loops[name]; ok {
+		e.cancel()
+		delete(s.loops, name)
+	}
+}
+
+// RunNow starts a run of the pipeline immediately on behalf of caller and returns its
+// run id
+func (s *Scheduler) RunNow(definition *pipeline.Definition, caller string) (string, error) {
+	id, _, err := s.launch(definition, "manual", caller, schedule.Overlap(definition.Overlap) != schedule.OverlapConcurrent)
+	return id, err
+}
+
+// Status returns the scheduling state of a pipeline
+func (s *Scheduler) Status(name string) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := Status{}
+	if ids := s.running[name]; len(ids) > 0 {
+		status.Running, status.RunIDs = true, append([]string(nil), ids...)
+	}
+	if e, ok := s.loops[name]; ok {
+		if next := e.loop.Next(); !next.IsZero() {
+			status.NextRunAt = &next
+		}
+	}
+	return status
+}
+
+// Static reports whether the pipeline called name comes from the config file rather
+// than the store. Only the secret references of those are resolved.
+func (s *Scheduler) Static(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.static[name]
+	return ok
+}
+
+// Pipelines returns the saved pipelines and those of the config file, by name
+func (s *Scheduler) Pipelines() ([]*pipeline.Definition, error) {
+	saved, err := s.store.ListPipelines()
+	if err != nil {
+		return nil, err
+	}
+	var definitions []*pipeline.Definition
+	for i := range saved {
+		definitions = append(definitions, &saved[i])
+	}
+	s.mu.Lock()
+	for _, definition := range s.static {
+		definitions = append(definitions, definition)
+	}
+	s.mu.Unlock()
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Name < definitions[j].Name })
+	return definitions, nil
+}
+
+// fire runs the latest definition of a pipeline and waits for the run to end.
+// The schedule loop has already applied the overlap policy.
+func (s *Scheduler) fire(name string) {
+	s.mu.Lock()
+	definition, ok := s.static[name]
+	s.mu.Unlock()
+	var err error
+	if !ok {
+		definition, err = s.store.GetPipeline(name)
+	}
+	if err != nil {
+		logger.Warnf("Scheduled run of pipeline %s skipped: %v", name, err)
+		s.Unschedule(name)
+		return
+	}
+
+	_, done, err := s.launch(definition, "schedule", "scheduler", false)
+	if err != nil {
+		logger.Warnf("Scheduled run of pipeline %s skipped: %v", name, err)
+		return
+	}
+	<-done
+}
+
+// launch starts a run in the background. With exclusive set it fails while another
+// run of the pipeline is in progress. done is closed when the run ends.
+func (s *Scheduler) launch(definition *pipeline.Definition, trigger, caller string, exclusive bool) (string, <-chan struct{}, error) {
+	s.mu.Lock()
+	_, static := s.static[definition.Name]
+	s.mu.Unlock()
+	spec := definition.Spec(trigger)
+	if static {
+		var err error
+		if spec, err = definition.ResolvedSpec(context.Background(), trigger); err != nil {
+			return "", nil, err
+		}
+	}
+	spec.Caller = caller
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if exclusive && len(s.running[definition.Name]) > 0 {
+		return "", nil, ErrAlreadyRunning
+	}
+
+	done := make(chan struct{})
+	id := runner.Start(spec, func(run *runner.Run, err error) {
+		s.finished(definition.Name, run.ID)
+		close(done)
+		if err != nil {
+			logger.Errorf("Run %s of pipeline %s failed: %v", run.ID, definition.Name, err)
+		}
+	})
+	s.running[definition.Name] = append(s.running[definition.Name], id)
+	return id, done, nil
+}
+
+func (s *Scheduler) finished(name, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := s.running[name]
+	for i := range ids {
+		if ids[i] == id {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(s.running, name)
+	} else {
+		s.running[name] = ids
+	}
+}
+
+// lastRun returns when the pipeline last ran, or the zero time if it never did
+func (s *Scheduler) lastRun(name string) time.Time {
+	runs, err := s.store.ListRuns(store.RunFilter{Pipeline: name, Limit: 1})
+	if err != nil || len(runs) == 0 {
+		return time.Time{}
+	}
+	return runs[0].StartedAt
+}
@@ -0,0 +1,292 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/registry"
+	"github.com/SkySingh04/fractal/secrets"
+)
+
+// Kind tells whether an integration reads or writes data
+type Kind string
+
+const (
+	KindSource      Kind = "source"
+	KindDestination Kind = "destination"
+)
+
+// Field describes a single configuration field of an integration.
+//
+// Fields are declared with struct tags on the integration's config struct:
+//
+//	ConnString string `json:"sql_source_conn_string" config:"connstring" required:"true" secret:"true" description:"PostgreSQL connection string"`
+//
+// The json tag is the key used in HTTP requests (it matches interfaces.Request),
+// the config tag is the key used under inputconfig/outputconfig in config.yaml.
+// Fields that say where the data lives are tagged endpoint:"url" (a URL or
+// connection string) or endpoint:"database" (a database name) so that access
+// policies can inspect them. Fields that only take a few values list them with
+// enum:"a,b,c"; the interactive setup offers them as a list to pick from.
+type Field struct {
+	Name        string   `json:"name"`
+	ConfigKey   string   `json:"config_key"`
+	Type        string   `json:"type"`
+	Required    bool     `json:"required"`
+	Default     string   `json:"default,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Secret      bool     `json:"secret"`
+	Endpoint    string   `json:"endpoint,omitempty"`
+	Description string   `json:"description"`
+}
+
+// Integration is the machine-readable description of a registered source or destination
+type Integration struct {
+	Name   string  `json:"name"`
+	Kind   Kind    `json:"kind"`
+	Fields []Field `json:"fields"`
+}
+
+// Catalog lists every registered integration with its config schema
+type Catalog struct {
+	Sources      []Integration `json:"sources"`
+	Destinations []Integration `json:"destinations"`
+}
+
+// Of derives the config fields of an integration from its struct tags
+func Of(integration interface{}) ([]Field, error) {
+	val := reflect.ValueOf(integration)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem() // Dereference if it's a pointer
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, errors.New("integration is not a struct")
+	}
+
+	var fields []Field
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		structField := typ.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		name := tagName(structField.Tag.Get("json"))
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = structField.Name
+		}
+		configKey := structField.Tag.Get("config")
+		if configKey == "" {
+			configKey = strings.ToLower(structField.Name)
+		}
+
+		fields = append(fields, Field{
+			Name:        name,
+			ConfigKey:   configKey,
+			Type:        typeName(structField.Type),
+			Required:    structField.Tag.Get("required") == "true",
+			Default:     structField.Tag.Get("default"),
+			Enum:        enumValues(structField.Tag.Get("enum")),
+			Secret:      structField.Tag.Get("secret") == "true",
+			Endpoint:    structField.Tag.Get("endpoint"),
+			Description: structField.Tag.Get("description"),
+		})
+	}
+	return fields, nil
+}
+
+// ForSource returns the schema of a registered source
+func ForSource(name string) (Integration, error) {
+	source, found := registry.GetSource(name)
+	if !found {
+		return Integration{}, fmt.Errorf("source %s not found", name)
+	}
+	return describe(name, KindSource, source)
+}
+
+// ForDestination returns the schema of a registered destination
+func ForDestination(name string) (Integration, error) {
+	destination, found := registry.GetDestination(name)
+	if !found {
+		return Integration{}, fmt.Errorf("destination %s not found", name)
+	}
+	return describe(name, KindDestination, destination)
+}
+
+// For returns the schema of a registered source or destination
+func For(name string, kind Kind) (Integration, error) {
+	if kind == KindSource {
+		return ForSource(name)
+	}
+	return ForDestination(name)
+}
+
+// All builds the catalog of every registered integration, sorted by name
+func All() (Catalog, error) {
+	catalog := Catalog{Sources: []Integration{}, Destinations: []Integration{}}
+	for name, source := range registry.GetSources() {
+		integration, err := describe(name, KindSource, source)
+		if err != nil {
+			return Catalog{}, err
+		}
+		catalog.Sources = append(catalog.Sources, integration)
+	}
+	for name, destination := range registry.GetDestinations() {
+		integration, err := describe(name, KindDestination, destination)
+		if err != nil {
+			return Catalog{}, err
+		}
+		catalog.Destinations = append(catalog.Destinations, integration)
+	}
+
+	sort.Slice(catalog.Sources, func(i, j int) bool { return catalog.Sources[i].Name < catalog.Sources[j].Name })
+	sort.Slice(catalog.Destinations, func(i, j int) bool { return catalog.Destinations[i].Name < catalog.Destinations[j].Name })
+	return catalog, nil
+}
+
+// Check validates a value entered for a field: a required field must be set, an enum
+// field must hold one of its values and integer, number and boolean fields must parse.
+// ${ENV} and secret:// references are accepted as they are, since they are resolved
+// when a pipeline runs.
+func Check(field Field, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if field.Required && field.Default == "" {
+			return errors.New("this field is required")
+		}
+		return nil
+	}
+	if secrets.IsReference(value) {
+		return nil
+	}
+	if len(field.Enum) > 0 && !slices.Contains(field.Enum, value) {
+		return fmt.Errorf("must be one of %s", strings.Join(field.Enum, ", "))
+	}
+	switch field.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return errors.New("must be a whole number")
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return errors.New("must be a number")
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return errors.New("must be true or false")
+		}
+	}
+	return nil
+}
+
+// ApplyDefaults fills empty request fields of an integration with their declared defaults
+func ApplyDefaults(integration Integration, req *interfaces.Request) {
+	val := reflect.ValueOf(req).Elem()
+	for _, field := range integration.Fields {
+		if field.Default == "" {
+			continue
+		}
+		target, ok := requestField(val, field.Name)
+		if ok && target.Kind() == reflect.String && target.String() == "" {
+			target.SetString(field.Default)
+		}
+	}
+}
+
+// Missing returns the required fields of an integration that are empty in req
+func Missing(integration Integration, req interfaces.Request) []Field {
+	val := reflect.ValueOf(req)
+	var missing []Field
+	for _, field := range integration.Fields {
+		if !field.Required {
+			continue
+		}
+		target, ok := requestField(val, field.Name)
+		if ok && target.IsZero() {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
+
+// Value returns the value of a field in req as a string
+func Value(req interfaces.Request, field Field) string {
+	target, ok := requestField(reflect.ValueOf(req), field.Name)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(target.Interface())
+}
+
+// SetValue sets a string field of an integration in req. Fields of other types are
+// left as they are.
+func SetValue(req *interfaces.Request, field Field, value string) {
+	target, ok := requestField(reflect.ValueOf(req).Elem(), field.Name)
+	if ok && target.Kind() == reflect.String {
+		target.SetString(value)
+	}
+}
+
+func describe(name string, kind Kind, integration interface{}) (Integration, error) {
+	fields, err := Of(integration)
+	if err != nil {
+		return Integration{}, fmt.Errorf("invalid schema for %s %s: %w", kind, name, err)
+	}
+	if fields == nil {
+		fields = []Field{}
+	}
+	return Integration{Name: name, Kind: kind, Fields: fields}, nil
+}
+
+// requestField finds the field of interfaces.Request that has the given json name
+func requestField(val reflect.Value, name string) (reflect.Value, bool) {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		if tagName(typ.Field(i).Tag.Get("json")) == name {
+			return val.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func enumValues(tag string) []string {
+	var values []string
+	for _, value := range strings.Split(tag, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	return name
+}
+
+func typeName(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "string"
+	}
+}
@@ -0,0 +1,111 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// EnvProvider resolves secret://env/NAME to the environment variable NAME
+type EnvProvider struct{}
+
+func (EnvProvider) Resolve(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// FileProvider resolves secret://file/PATH to the contents of a file, such as a
+// Docker or Kubernetes secret. PATH is absolute, so secret://file/run/secrets/db
+// reads /run/secrets/db. A single trailing newline is dropped.
+type FileProvider struct{}
+
+func (FileProvider) Resolve(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile("/" + strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+}
+
+// VaultProvider resolves secret://vault/PATH#KEY with the Vault HTTP API, reading
+// KEY from the secret at PATH, such as secret://vault/secret/data/fractal#db_password.
+// Both KV version 2 and version 1 responses are understood. The server and token
+// come from Addr and Token, or VAULT_ADDR and VAULT_TOKEN when those are empty, so
+// any server that speaks the same API can stand in for Vault.
+type VaultProvider struct {
+	Addr   string
+	Token  string
+	Client *http.Client
+}
+
+// vaultResponse covers KV v2 ({"data":{"data":{...}}}) and KV v1 ({"data":{...}})
+type vaultResponse struct {
+	Data map[string]json.RawMessage `json:"data"`
+}
+
+func (v *VaultProvider) Resolve(ctx context.Context, reference string) (string, error) {
+	addr, token := v.Addr, v.Token
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	path, key, found := strings.Cut(reference, "#")
+	if !found || key == "" {
+		return "", fmt.Errorf("vault reference %q must name a key as PATH#KEY", reference)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	client := v.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault answered %s for %s", resp.Status, path)
+	}
+
+	var body vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid vault response for %s: %w", path, err)
+	}
+	data := body.Data
+	if nested, ok := data["data"]; ok {
+		var kv2 map[string]json.RawMessage
+		if err := json.Unmarshal(nested, &kv2); err == nil {
+			data = kv2
+		}
+	}
+
+	raw, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no key %s", path, key)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return strings.TrimSpace(string(raw)), nil // Numbers and booleans
+	}
+	return value, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/SkySingh04/fractal/audit"
+	"github.com/SkySingh04/fractal/auth"
+	"github.com/SkySingh04/fractal/config"
+	"github.com/SkySingh04/fractal/controller"
+	"github.com/SkySingh04/fractal/health"
+	"github.com/SkySingh04/fractal/lineage"
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/metrics"
+	"github.com/SkySingh04/fractal/notify"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/SkySingh04/fractal/rbac"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/scheduler"
+	"github.com/SkySingh04/fractal/store"
+	"github.com/spf13/cobra"
+	"gofr.dev/pkg/gofr"
+)
+
+func serveCommand() *cobra.Command {
+	var configFile string
+	var reload bool
+	var gracePeriod time.Duration
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Start the HTTP API and the pipeline scheduler",
+		Long: `Start the HTTP API and the pipeline scheduler.
+
+The scheduler runs the pipelines saved over the API. With --config it also runs every
+pipeline of a config file, each on its own schedule, and applies changes to the file
+without a restart.
+
+SIGINT or SIGTERM stops the HTTP server and the scheduler and gives the runs in
+progress --grace-period to finish before they are cancelled, as fractal run does.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serve(configFile, reload, gracePeriod)
+		},
+	}
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file with pipelines for the scheduler to run")
+	bindEnv(cmd.Flags(), "config", "FRACTAL_CONFIG")
+	reloadFlag(cmd, &reload)
+	gracePeriodFlag(cmd, &gracePeriod)
+	cmd.Flags().String("port", "8000", "port of the HTTP API")
+	bindEnv(cmd.Flags(), "port", "HTTP_PORT")
+	cmd.Flags().String("metrics-port", "2121", "port of the /metrics endpoint")
+	bindEnv(cmd.Flags(), "metrics-port", "METRICS_PORT")
+	cmd.Flags().String("auth-config", "", "file with the auth and rbac sections, config.yaml when it exists")
+	bindEnv(cmd.Flags(), "auth-config", "FRACTAL_AUTH_CONFIG")
+	return cmd
+}
+
+// setup initializes tracing and the run store shared by serve and run, which also
+// keeps the checkpoints of pipelines. The returned function flushes pending traces.
+func setup() (*store.Store, func(), error) {
+	cleanup, err := opentele.InitTracing()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize OpenTelemetry: %w", err)
+	}
+
+	// Persist a record of every run
+	runStore, err := store.OpenFromEnv()
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to open run store: %w", err)
+	}
+	runner.RegisterHook(runStore)
+	// Runs cut short, such as at the end of the grace period, resume from their checkpoints
+	runner.UseCheckpoints(runStore)
+	return runStore, cleanup, nil
+}
+
+// registerAudit records the start and the end of every run in the audit log at
+// FRACTAL_AUDIT_FILE
+func registerAudit() *audit.Log {
+	auditLog := audit.OpenFromEnv()
+	runner.RegisterHook(audit.NewHook(auditLog))
+	return auditLog
+}
+
+// registerNotifications writes run summaries and sends the notifications configured
+// in the notifications section of configFile, if it exists. summaryFile, when set,
+// overrides the summary file of the section.
+func registerNotifications(configFile, summaryFile string, runStore *store.Store) (*notify.Hook, error) {
+	var settings notify.Config
+	if _, err := os.Stat(configFile); configFile != "" && err == nil {
+		if settings, err = notify.LoadConfig(configFile); err != nil {
+			return nil, err
+		}
+	}
+	if summaryFile != "" {
+		settings.SummaryFile = summaryFile
+	}
+	hook, err := notify.New(settings, runStore)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notifications in %s: %w", configFile, err)
+	}
+	runner.RegisterHook(hook)
+	return hook, nil
+}
+
+// registerLineage emits the lineage events configured in the lineage section of
+// configFile, if it exists, or by the OPENLINEAGE_* environment variables
+func registerLineage(configFile string) error {
+	var settings lineage.Config
+	if _, err := os.Stat(configFile); configFile != "" && err == nil {
+		if settings, err = lineage.LoadConfig(configFile); err != nil {
+			return err
+		}
+	}
+	settings = lineage.FromEnv(settings)
+	if !settings.Enabled() {
+		return nil
+	}
+	hook, err := lineage.New(settings)
+	if err != nil {
+		return fmt.Errorf("invalid lineage in %s: %w", configFile, err)
+	}
+	runner.RegisterHook(hook)
+	return nil
+}
+
+// serve runs the HTTP API until the process is stopped. The pipelines of configFile,
+// if set, are scheduled next to the saved ones and, with reload, follow its changes.
+// The runs in progress when it is stopped get gracePeriod to finish.
+func serve(configFile string, reload bool, gracePeriod time.Duration) error {
+	var definitions []*pipeline.Definition
+	if configFile != "" {
+		var err error
+		if definitions, err = config.LoadPipelines(configFile); err != nil {
+			return err
+		}
+	}
+
+	runStore, cleanup, err := setup()
+	if err != nil {
+		return err
+	}
+	defer cleanup() // Ensure resources are flushed on exit
+	auditLog := registerAudit()
+	notifications, err := registerNotifications(configFile, "", runStore)
+	if err != nil {
+		return err
+	}
+	defer notifications.Wait() // Deliver the notifications of the last runs
+	if err := registerLineage(configFile); err != nil {
+		return err
+	}
+
+	app := gofr.New()
+	logger.Infof("Starting HTTP Server... Welcome to the Fractal API!")
+
+	// gofr serves the metrics of every run at /metrics on its metrics port
+	metrics.Register(app.Metrics())
+
+	// Authenticate callers before any other middleware sees the request
+	authenticator, err := auth.LoadFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to configure authentication: %w", err)
+	}
+	if len(authenticator) == 0 {
+		logger.Logf("No API keys, HMAC keys or JWKS configured: the HTTP API is open to anyone who can reach it")
+	} else {
+		app.UseMiddleware(controller.AuthMiddleware(authenticator))
+	}
+
+	// Restrict which integrations, endpoints and pipelines each role may run
+	policy, err := rbac.LoadFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to load access policy: %w", err)
+	}
+	policy.OnDenied = auditLog.RecordDenial
+	if policy.Enabled() && len(authenticator) == 0 {
+		logger.Warnf("RBAC roles are configured but authentication is not, so they cannot be enforced")
+	}
+
+	// Run saved pipelines on their schedules
+	pipelines := scheduler.New(runStore)
+	if err := pipelines.Start(); err != nil {
+		return fmt.Errorf("failed to start pipeline scheduler: %w", err)
+	}
+	defer pipelines.Stop()
+	if configFile != "" {
+		logger.Infof("Loaded pipelines from %s: %s", configFile, pipelines.Load(definitions))
+	}
+	ctx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	if configFile != "" && reload {
+		err := config.Watch(ctx, configFile, func(definitions []*pipeline.Definition) {
+			logger.Infof("Reloaded %s: %s", configFile, pipelines.Load(definitions))
+		}, func(err error) {
+			logger.Errorf("Reload of %s rejected, the previous pipelines keep running: %v", configFile, err)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to watch %s: %w", configFile, err)
+		}
+	}
+
+	// Register every route of the controller's route table, and stream job progress
+	// as Server-Sent Events
+	deps := controller.Dependencies{Runs: runStore, Policy: policy, Scheduler: pipelines, Audit: auditLog, Health: health.NewCache(health.DefaultCacheTTL)}
+	app.UseMiddleware(controller.EventStreamMiddleware(deps))
+	app.UseMiddleware(controller.HealthMiddleware(deps))
+	controller.RegisterRoutes(app, deps)
+
+	// The document is generated from the route table rather than read from static/,
+	// which go generate refreshes and which may be read-only or missing
+	if doc, err := controller.OpenAPI(); err != nil {
+		logger.Errorf("Failed to generate OpenAPI document: %v", err)
+	} else {
+		controller.RegisterOpenAPI(app, doc)
+	}
+
+	// Runs in progress are drained on SIGINT and SIGTERM, which gofr also shuts its
+	// servers down on
+	signals := notifyShutdown()
+	defer signal.Stop(signals)
+	stopped := make(chan struct{})
+	go func() {
+		// Default port 8000
+		app.Run()
+		close(stopped)
+	}()
+
+	var sig os.Signal
+	select {
+	case sig = <-signals:
+	case <-stopped:
+		sig = syscall.SIGTERM
+	}
+	stopWatching()
+	pipelines.Stop()
+	return drain(sig, signals, gracePeriod)
+}
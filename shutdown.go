@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/spf13/cobra"
+)
+
+// defaultGracePeriod is how long runs in progress get to finish once fractal is asked
+// to stop
+const defaultGracePeriod = 30 * time.Second
+
+// exitError is an error that ends the process with a specific exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// gracePeriodFlag adds --grace-period to a command that runs pipelines until it is stopped
+func gracePeriodFlag(cmd *cobra.Command, gracePeriod *time.Duration) {
+	cmd.Flags().DurationVar(gracePeriod, "grace-period", defaultGracePeriod, "how long runs in progress get to finish after SIGINT or SIGTERM before they are cancelled")
+	bindEnv(cmd.Flags(), "grace-period", "FRACTAL_GRACE_PERIOD")
+}
+
+// notifyShutdown delivers SIGINT and SIGTERM instead of letting them kill the process
+func notifyShutdown() chan os.Signal {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	return signals
+}
+
+// drain lets the runs in progress finish once sig was received, for up to gracePeriod,
+// and then cancels the rest. Another signal cancels them straight away, and a third
+// exits without waiting for them. Once no run is left every run record has been
+// written, so a restart catches up from there. The error carries the exit code of a
+// process killed by sig if runs had to be cancelled.
+func drain(sig os.Signal, signals <-chan os.Signal, gracePeriod time.Duration) error {
+	code := 128 + int(sig.(syscall.Signal))
+	logger.Warnf("Received %s, no new runs are started; runs in progress get %s to finish, signal again to cancel them", sig, gracePeriod)
+
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	go func() {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+			return
+		}
+		cancel()
+		logger.Warnf("Cancelling the runs in progress, signal again to exit without waiting for them")
+		if _, ok := <-signals; ok {
+			os.Exit(code)
+		}
+	}()
+
+	if cancelled := runner.Drain(ctx); cancelled > 0 {
+		return &exitError{code: code, err: fmt.Errorf("stopped by %s: %d runs in progress were cancelled", sig, cancelled)}
+	}
+	logger.Infof("Every run in progress finished, stopping")
+	return nil
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/SkySingh04/fractal/runner"
+	bolt "go.etcd.io/bbolt"
+)
+
+// LoadCheckpoint implements runner.Checkpoints. It returns nil if the pipeline has
+// no checkpoint.
+func (s *Store) LoadCheckpoint(pipeline string) (*runner.Checkpoint, error) {
+	var checkpoint *runner.Checkpoint
+	err := s.view(func(tx *bolt.Tx) error {
+		encoded := tx.Bucket(checkpointsBucket).Get([]byte(pipeline))
+		if encoded == nil {
+			return nil
+		}
+		checkpoint = &runner.Checkpoint{}
+		return json.Unmarshal(encoded, checkpoint)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// SaveCheckpoint implements runner.Checkpoints and replaces the checkpoint of the
+// pipeline
+func (s *Store) SaveCheckpoint(checkpoint *runner.Checkpoint) error {
+	encoded, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(checkpointsBucket).Put([]byte(checkpoint.Pipeline), encoded)
+	})
+}
+
+// DeleteCheckpoint implements runner.Checkpoints
+func (s *Store) DeleteCheckpoint(pipeline string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(checkpointsBucket).Delete([]byte(pipeline))
+	})
+}
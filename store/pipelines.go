@@ -0,0 +1,108 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/SkySingh04/fractal/pipeline"
+	bolt "go.etcd.io/bbolt"
+)
+
+// CreatePipeline saves a new pipeline definition, or returns ErrExists. The secret
+// fields of its integrations are encrypted, see secretKey.
+func (s *Store) CreatePipeline(definition *pipeline.Definition) error {
+	encoded, err := s.encodePipeline(definition)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pipelinesBucket)
+		if bucket.Get([]byte(definition.Name)) != nil {
+			return ErrExists
+		}
+		return bucket.Put([]byte(definition.Name), encoded)
+	})
+}
+
+// SavePipeline replaces an existing pipeline definition, or returns ErrNotFound
+func (s *Store) SavePipeline(definition *pipeline.Definition) error {
+	encoded, err := s.encodePipeline(definition)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pipelinesBucket)
+		if bucket.Get([]byte(definition.Name)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Put([]byte(definition.Name), encoded)
+	})
+}
+
+// GetPipeline returns the pipeline definition with the given name, or ErrNotFound
+func (s *Store) GetPipeline(name string) (*pipeline.Definition, error) {
+	var definition *pipeline.Definition
+	err := s.view(func(tx *bolt.Tx) error {
+		encoded := tx.Bucket(pipelinesBucket).Get([]byte(name))
+		if encoded == nil {
+			return ErrNotFound
+		}
+		var err error
+		definition, err = s.decodePipeline(encoded)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return definition, nil
+}
+
+// ListPipelines returns every pipeline definition, sorted by name
+func (s *Store) ListPipelines() ([]pipeline.Definition, error) {
+	definitions := []pipeline.Definition{}
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(pipelinesBucket).ForEach(func(key, value []byte) error {
+			definition, err := s.decodePipeline(value)
+			if err != nil {
+				return fmt.Errorf("pipeline %s: %w", key, err)
+			}
+			definitions = append(definitions, *definition)
+			return nil
+		})
+	})
+	return definitions, err
+}
+
+// DeletePipeline removes a pipeline definition, or returns ErrNotFound. Its runs are kept.
+func (s *Store) DeletePipeline(name string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pipelinesBucket)
+		if bucket.Get([]byte(name)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(name))
+	})
+}
+
+// encodePipeline encodes a definition with its secret fields encrypted
+func (s *Store) encodePipeline(definition *pipeline.Definition) ([]byte, error) {
+	encrypted := *definition
+	encrypted.Destinations = slices.Clone(definition.Destinations)
+	if err := encrypted.MapSecrets(s.encryptSecret); err != nil {
+		return nil, err
+	}
+	return json.Marshal(&encrypted)
+}
+
+// decodePipeline decodes a definition and decrypts its secret fields
+func (s *Store) decodePipeline(encoded []byte) (*pipeline.Definition, error) {
+	var definition pipeline.Definition
+	if err := json.Unmarshal(encoded, &definition); err != nil {
+		return nil, fmt.Errorf("corrupt pipeline: %w", err)
+	}
+	if err := definition.MapSecrets(s.decryptSecret); err != nil {
+		return nil, err
+	}
+	return &definition, nil
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// encryptedPrefix marks a secret field that the store encrypted. Values without it
+// were saved before secrets were encrypted and are read as they are.
+const encryptedPrefix = "encrypted:v1:"
+
+// keySize is the size of the AES-256 key that encrypts the secret fields of pipelines
+const keySize = 32
+
+// secretKey returns the key that encrypts the secret fields of saved pipelines:
+// FRACTAL_SECRET_KEY, base64 encoded, or else the key file next to the database,
+// which is created on first use
+func (s *Store) secretKey() ([]byte, error) {
+	s.keyOnce.Do(func() {
+		if value := os.Getenv("FRACTAL_SECRET_KEY"); value != "" {
+			s.key, s.keyErr = decodeKey(value, "FRACTAL_SECRET_KEY")
+			return
+		}
+		s.key, s.keyErr = loadKeyFile(s.path + ".key")
+	})
+	return s.key, s.keyErr
+}
+
+func loadKeyFile(path string) ([]byte, error) {
+	encoded, err := os.ReadFile(path)
+	if err == nil {
+		return decodeKey(strings.TrimSpace(string(encoded)), path)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read secret key %s: %w", path, err)
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate a secret key: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		// Another process created it first
+		return loadKeyFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret key %s: %w", path, err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(base64.StdEncoding.EncodeToString(key) + "\n"); err != nil {
+		return nil, fmt.Errorf("failed to write secret key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+func decodeKey(encoded, origin string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("invalid secret key in %s: must be %d bytes, base64 encoded", origin, keySize)
+	}
+	return key, nil
+}
+
+// encryptSecret seals value with AES-GCM under a random nonce
+func (s *Store) encryptSecret(value string) (string, error) {
+	aead, err := s.cipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret opens a value sealed by encryptSecret. Other values are returned as they are.
+func (s *Store) decryptSecret(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+	aead, err := s.cipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("corrupt encrypted secret")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("failed to decrypt a secret: the secret key has changed")
+	}
+	return string(plain), nil
+}
+
+func (s *Store) cipher() (cipher.AEAD, error) {
+	key, err := s.secretKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
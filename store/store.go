@@ -0,0 +1,248 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/runner"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultPath     = "fractal.db"
+	defaultMaxAge   = 30 * 24 * time.Hour
+	defaultMaxRuns  = 1000
+	defaultListSize = 50
+	lockTimeout     = 5 * time.Second
+)
+
+var (
+	runsBucket        = []byte("runs")
+	pipelinesBucket   = []byte("pipelines")
+	checkpointsBucket = []byte("checkpoints")
+)
+
+var (
+	// ErrNotFound is returned when a record does not exist in the store
+	ErrNotFound = errors.New("record not found")
+	// ErrExists is returned when creating a record whose key is already taken
+	ErrExists = errors.New("record already exists")
+)
+
+// Retention controls how long run records are kept. A zero value disables that limit.
+type Retention struct {
+	MaxAge  time.Duration
+	MaxRuns int
+}
+
+// Store persists run records in an embedded BoltDB file.
+// The file is only opened for the duration of each operation so that the CLI can
+// read the history while a server or cron process is writing to it.
+type Store struct {
+	path      string
+	retention Retention
+
+	keyOnce sync.Once
+	key     []byte
+	keyErr  error
+}
+
+// RunFilter narrows down the runs returned by ListRuns
+type RunFilter struct {
+	Pipeline string
+	Status   runner.Status
+	Limit    int
+	Visible  func(runner.Run) bool // If set, runs it rejects are left out before the limit applies
+}
+
+// Open prepares the store at path, creating the file if it does not exist yet
+func Open(path string, retention Retention) (*Store, error) {
+	s := &Store{path: path, retention: retention}
+	err := s.update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{runsBucket, pipelinesBucket, checkpointsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open run store %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// OpenFromEnv opens the store using FRACTAL_DB_PATH, FRACTAL_RUN_RETENTION and FRACTAL_RUN_MAX_COUNT
+func OpenFromEnv() (*Store, error) {
+	path := os.Getenv("FRACTAL_DB_PATH")
+	if path == "" {
+		path = defaultPath
+	}
+
+	retention := Retention{MaxAge: defaultMaxAge, MaxRuns: defaultMaxRuns}
+	if value := os.Getenv("FRACTAL_RUN_RETENTION"); value != "" {
+		maxAge, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FRACTAL_RUN_RETENTION %q: %w", value, err)
+		}
+		retention.MaxAge = maxAge
+	}
+	if value := os.Getenv("FRACTAL_RUN_MAX_COUNT"); value != "" {
+		maxRuns, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FRACTAL_RUN_MAX_COUNT %q: %w", value, err)
+		}
+		retention.MaxRuns = maxRuns
+	}
+
+	return Open(path, retention)
+}
+
+// Path returns the location of the database file
+func (s *Store) Path() string {
+	return s.path
+}
+
+func (s *Store) open(readOnly bool) (*bolt.DB, error) {
+	return bolt.Open(s.path, 0600, &bolt.Options{Timeout: lockTimeout, ReadOnly: readOnly})
+}
+
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// SaveRun inserts or replaces a run record
+func (s *Store) SaveRun(run *runner.Run) error {
+	encoded, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).Put([]byte(run.ID), encoded)
+	})
+}
+
+// GetRun returns the run with the given id, or ErrNotFound
+func (s *Store) GetRun(id string) (*runner.Run, error) {
+	var run runner.Run
+	err := s.view(func(tx *bolt.Tx) error {
+		encoded := tx.Bucket(runsBucket).Get([]byte(id))
+		if encoded == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(encoded, &run)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListRuns returns the runs matching filter, newest first
+func (s *Store) ListRuns(filter RunFilter) ([]runner.Run, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListSize
+	}
+
+	runs := []runner.Run{}
+	err := s.view(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(runsBucket).Cursor()
+		// Run ids sort in creation order, so walk the bucket backwards
+		for key, value := cursor.Last(); key != nil && len(runs) < limit; key, value = cursor.Prev() {
+			var run runner.Run
+			if err := json.Unmarshal(value, &run); err != nil {
+				return fmt.Errorf("corrupt run record %s: %w", key, err)
+			}
+			if filter.Pipeline != "" && run.Pipeline != filter.Pipeline {
+				continue
+			}
+			if filter.Status != "" && run.Status != filter.Status {
+				continue
+			}
+			if filter.Visible != nil && !filter.Visible(run) {
+				continue
+			}
+			runs = append(runs, run)
+		}
+		return nil
+	})
+	return runs, err
+}
+
+// PruneRuns deletes runs that fall outside the retention policy and returns how many were removed
+func (s *Store) PruneRuns(retention Retention) (int, error) {
+	if retention.MaxAge <= 0 && retention.MaxRuns <= 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().Add(-retention.MaxAge)
+	pruned := 0
+	err := s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(runsBucket)
+		var stale [][]byte
+		kept := 0
+
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			var run runner.Run
+			if err := json.Unmarshal(value, &run); err != nil {
+				return fmt.Errorf("corrupt run record %s: %w", key, err)
+			}
+			tooOld := retention.MaxAge > 0 && run.StartedAt.Before(cutoff)
+			tooMany := retention.MaxRuns > 0 && kept >= retention.MaxRuns
+			if run.Status != runner.StatusRunning && (tooOld || tooMany) {
+				stale = append(stale, append([]byte(nil), key...))
+				continue
+			}
+			kept++
+		}
+
+		for _, key := range stale {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		pruned = len(stale)
+		return nil
+	})
+	return pruned, err
+}
+
+// RunStarted implements runner.Hook
+func (s *Store) RunStarted(run *runner.Run) {
+	if err := s.SaveRun(run); err != nil {
+		logger.Errorf("Failed to record start of run %s: %v", run.ID, err)
+	}
+}
+
+// RunFinished implements runner.Hook and applies the retention policy
+func (s *Store) RunFinished(run *runner.Run) {
+	if err := s.SaveRun(run); err != nil {
+		logger.Errorf("Failed to record result of run %s: %v", run.ID, err)
+		return
+	}
+	if _, err := s.PruneRuns(s.retention); err != nil {
+		logger.Errorf("Failed to prune run history: %v", err)
+	}
+}
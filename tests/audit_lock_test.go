@@ -0,0 +1,49 @@
+//go:build unix
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/SkySingh04/fractal/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLock(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log := audit.Open(path)
+	_, err := log.Append(audit.Entry{Event: audit.EventStarted})
+	assert.NoError(t, err)
+
+	// Another process holds the lock, as while it appends
+	other, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0600)
+	assert.NoError(t, err)
+	defer other.Close()
+	assert.NoError(t, syscall.Flock(int(other.Fd()), syscall.LOCK_EX))
+
+	appended := make(chan audit.Entry)
+	go func() {
+		entry, err := log.Append(audit.Entry{Event: audit.EventFinished})
+		assert.NoError(t, err)
+		appended <- entry
+	}()
+	select {
+	case <-appended:
+		t.Fatal("Append did not wait for the lock")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.NoError(t, syscall.Flock(int(other.Fd()), syscall.LOCK_UN))
+	select {
+	case entry := <-appended:
+		assert.Equal(t, int64(2), entry.Seq)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Append did not go on once the lock was released")
+	}
+	t.Logf("%s Appends wait for the lock other processes hold on the log", greenTick)
+}
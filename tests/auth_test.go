@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SkySingh04/fractal/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthentication(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	// API keys
+	keys, err := auth.ParseAPIKeys("ci:ci-secret:run read integration:CSV, viewer:viewer-secret:read")
+	assert.NoError(t, err)
+	apiKeys, err := auth.NewAPIKeys(keys)
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/jobs", nil)
+	req.Header.Set(auth.APIKeyHeader, "ci-secret")
+	principal, err := apiKeys.Authenticate(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", principal.Name)
+	assert.NoError(t, principal.Authorize(auth.OperationRun, "CSV", "CSV"))
+	assert.Error(t, principal.Authorize(auth.OperationRun, "CSV", "PostgreSQL"))
+	assert.Error(t, principal.Authorize(auth.OperationCancel))
+
+	req.Header.Set(auth.APIKeyHeader, "wrong")
+	_, err = apiKeys.Authenticate(req)
+	assert.Error(t, err)
+	t.Logf("%s API keys grant only their scopes", greenTick)
+
+	// HMAC signed requests
+	hmacKeys, err := auth.NewHMAC([]auth.HMACKey{{ID: "partner", Secret: "shared", Scopes: []string{"*"}}}, 0)
+	assert.NoError(t, err)
+
+	body := `{"input":"CSV","output":"CSV"}`
+	now := time.Now().Unix()
+	signed := func(signature string, timestamp int64) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/migration?dry=1", strings.NewReader(body))
+		r.Header.Set(auth.HMACKeyHeader, "partner")
+		r.Header.Set(auth.HMACTimestampHeader, strconv.FormatInt(timestamp, 10))
+		r.Header.Set(auth.HMACSignatureHeader, signature)
+		return r
+	}
+
+	r := signed(auth.Sign("shared", http.MethodPost, "/api/migration?dry=1", now, []byte(body)), now)
+	principal, err = hmacKeys.Authenticate(r)
+	assert.NoError(t, err)
+	assert.True(t, principal.CanUse("PostgreSQL"))
+	remaining, _ := io.ReadAll(r.Body)
+	assert.Equal(t, body, string(remaining), "the body must still be readable by the handler")
+
+	_, err = hmacKeys.Authenticate(signed(auth.Sign("other", http.MethodPost, "/api/migration?dry=1", now, []byte(body)), now))
+	assert.Error(t, err)
+	old := now - 3600
+	_, err = hmacKeys.Authenticate(signed(auth.Sign("shared", http.MethodPost, "/api/migration?dry=1", old, []byte(body)), old))
+	assert.Error(t, err)
+	t.Logf("%s HMAC signatures are verified and replays outside the window are refused", greenTick)
+
+	// JWT bearer tokens verified against a local JWKS file
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA", "kid": "test", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(jwksFile, jwks, 0644))
+
+	tokens, err := auth.NewJWT(auth.JWTConfig{JWKSFile: jwksFile, Audience: "fractal"})
+	assert.NoError(t, err)
+
+	issue := func(audience string, expires time.Time) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"sub": "alice", "aud": audience, "exp": expires.Unix(), "scope": "read cancel",
+		})
+		token.Header["kid"] = "test"
+		signedToken, err := token.SignedString(key)
+		assert.NoError(t, err)
+		return signedToken
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/runs", nil)
+	req.Header.Set("Authorization", "Bearer "+issue("fractal", time.Now().Add(time.Hour)))
+	principal, err = tokens.Authenticate(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", principal.Name)
+	assert.True(t, principal.Can(auth.OperationCancel))
+	assert.False(t, principal.Can(auth.OperationRun))
+
+	req.Header.Set("Authorization", "Bearer "+issue("someone-else", time.Now().Add(time.Hour)))
+	_, err = tokens.Authenticate(req)
+	assert.Error(t, err)
+	req.Header.Set("Authorization", "Bearer "+issue("fractal", time.Now().Add(-time.Hour)))
+	_, err = tokens.Authenticate(req)
+	assert.Error(t, err)
+	t.Logf("%s JWTs are checked for signature, audience and expiry", greenTick)
+
+	// Middleware
+	handler := auth.Middleware(auth.Chain{apiKeys, hmacKeys, tokens}, func(r *http.Request) (auth.Operation, bool) {
+		if r.URL.Path == "/greet" {
+			return "", false
+		}
+		return auth.OperationRun, true
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth.FromContext(r.Context()) == nil && r.URL.Path != "/greet" {
+			t.Error("protected handler ran without a principal")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	status := func(path, apiKey string) int {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		if apiKey != "" {
+			r.Header.Set(auth.APIKeyHeader, apiKey)
+		}
+		handler.ServeHTTP(rec, r)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusNoContent, status("/greet", ""))
+	assert.Equal(t, http.StatusUnauthorized, status("/jobs", ""))
+	assert.Equal(t, http.StatusUnauthorized, status("/jobs", "wrong"))
+	assert.Equal(t, http.StatusForbidden, status("/jobs", "viewer-secret"))
+	assert.Equal(t, http.StatusNoContent, status("/jobs", "ci-secret"))
+	t.Logf("%s Middleware answers 401 without credentials and 403 without scope", greenTick)
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoints(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	dir := t.TempDir()
+	checkpoints, err := store.Open(filepath.Join(dir, "checkpoints.db"), store.Retention{})
+	assert.NoError(t, err)
+	t.Cleanup(runner.UseCheckpoints(checkpoints))
+
+	input := filepath.Join(dir, "input.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("name,age\nJohn,25\nJane,30"), 0644))
+	output := filepath.Join(dir, "output.csv")
+	// The second destination fails until its directory exists
+	downDir := filepath.Join(dir, "down")
+	second := filepath.Join(downDir, "second.csv")
+	spec := runner.Spec{
+		Pipeline:           "checkpointed",
+		Source:             "CSV",
+		SourceRequest:      interfaces.Request{CSVSourceFileName: input},
+		Destination:        "CSV",
+		DestinationRequest: interfaces.Request{CSVDestinationFileName: output},
+		Destinations:       []runner.Target{{Destination: "CSV", Request: interfaces.Request{CSVDestinationFileName: second}}},
+		Trigger:            "test",
+	}
+
+	first, err := runner.Execute(context.Background(), spec)
+	assert.Error(t, err)
+	assert.FileExists(t, output)
+	checkpoint, err := checkpoints.LoadCheckpoint(spec.Pipeline)
+	assert.NoError(t, err)
+	if assert.NotNil(t, checkpoint) {
+		assert.Equal(t, first.ID, checkpoint.RunID)
+		assert.Equal(t, first.ConfigHash, checkpoint.ConfigHash)
+		assert.Equal(t, []int{0}, checkpoint.Delivered)
+	}
+	t.Logf("%s A run that fails after some destinations commits a checkpoint", greenTick)
+
+	// The next run only sends to the destination that is missing the data
+	assert.NoError(t, os.Remove(output))
+	assert.NoError(t, os.Mkdir(downDir, 0755))
+	resumed, err := runner.Execute(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, resumed.ResumedFrom)
+	assert.NoFileExists(t, output)
+	assert.FileExists(t, second)
+	checkpoint, err = checkpoints.LoadCheckpoint(spec.Pipeline)
+	assert.NoError(t, err)
+	assert.Nil(t, checkpoint)
+	t.Logf("%s The next run resumes from the checkpoint, which is removed once it succeeds", greenTick)
+
+	// Checkpoints of other data or of another configuration are not resumed from
+	assert.NoError(t, os.RemoveAll(downDir))
+	_, err = runner.Execute(context.Background(), spec)
+	assert.Error(t, err)
+	assert.NoError(t, os.WriteFile(input, []byte("name,age\nJohn,26\nJane,30"), 0644))
+	assert.NoError(t, os.Remove(output))
+	assert.NoError(t, os.Mkdir(downDir, 0755))
+	changed, err := runner.Execute(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.Empty(t, changed.ResumedFrom)
+	assert.FileExists(t, output)
+
+	assert.NoError(t, os.RemoveAll(downDir))
+	_, err = runner.Execute(context.Background(), spec)
+	assert.Error(t, err)
+	assert.NoError(t, os.Mkdir(downDir, 0755))
+	other := spec
+	other.DestinationRequest.CSVDestinationFileName = filepath.Join(dir, "other.csv")
+	reconfigured, err := runner.Execute(context.Background(), other)
+	assert.NoError(t, err)
+	assert.Empty(t, reconfigured.ResumedFrom)
+	assert.FileExists(t, other.DestinationRequest.CSVDestinationFileName)
+	t.Logf("%s Runs send to every destination when the data or the configuration changed", greenTick)
+}
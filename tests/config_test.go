@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SkySingh04/fractal/config"
+	_ "github.com/SkySingh04/fractal/integrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidation(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.yaml")
+	assert.NoError(t, os.WriteFile(valid, []byte(`inputMethod: CSV
+inputconfig:
+  csvsourcefilename: in.csv
+outputmethod: MongoDB
+outputconfig:
+  connstring: mongodb://localhost:27017
+  database: app
+  collection: users
+cronjob:
+  repetition_interval: 1h
+`), 0644))
+	assert.NoError(t, config.Validate(valid))
+	configuration, err := config.LoadConfig(valid)
+	assert.NoError(t, err)
+	assert.Equal(t, "CSV", configuration["inputMethod"], "keys are matched without regard to case")
+	t.Logf("%s A valid config loads", greenTick)
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	assert.NoError(t, os.WriteFile(invalid, []byte(`inputMetod: CSV
+inputmethod: CSV
+inputconfig:
+  csvsourcefilname: in.csv
+outputmethod: Postgres
+cronjob:
+  schedule: "61 * * * *"
+`), 0644))
+	err = config.Validate(invalid)
+	var problems *config.ValidationError
+	if assert.True(t, errors.As(err, &problems)) {
+		messages := map[int]string{}
+		for _, problem := range problems.Problems {
+			messages[problem.Line] += problem.Message + "\n"
+		}
+		assert.Contains(t, messages[1], `unknown key "inputMetod", did you mean "inputmethod"?`)
+		assert.Contains(t, messages[3], "inputconfig.csvsourcefilename is required by CSV")
+		assert.Contains(t, messages[4], `did you mean "csvsourcefilename"?`)
+		assert.Contains(t, messages[5], `unknown destination "Postgres", did you mean "PostgreSQL"?`)
+		assert.Contains(t, messages[7], "invalid schedule")
+		assert.Contains(t, problems.Error(), "invalid.yaml:1:1: ")
+	}
+	t.Logf("%s Unknown keys, integrations, missing fields and schedules are reported with their line", greenTick)
+
+	_, err = config.LoadConfig(invalid)
+	assert.ErrorAs(t, err, &problems)
+	t.Logf("%s LoadConfig refuses an invalid config", greenTick)
+}
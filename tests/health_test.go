@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SkySingh04/fractal/auth"
+	"github.com/SkySingh04/fractal/controller"
+	"github.com/SkySingh04/fractal/health"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/SkySingh04/fractal/rbac"
+	"github.com/SkySingh04/fractal/scheduler"
+	"github.com/SkySingh04/fractal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("name,age\nJohn,25"), 0644))
+	csv := pipeline.Endpoint{Integration: "CSV", Config: interfaces.Request{CSVSourceFileName: input}}
+	output := pipeline.Endpoint{Integration: "CSV", Config: interfaces.Request{CSVDestinationFileName: filepath.Join(dir, "output.csv")}}
+
+	// A server that accepts connections and never answers
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer silent.Close()
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	definitions := []*pipeline.Definition{
+		{Name: "files", Source: csv, Destinations: []pipeline.Endpoint{output}},
+		{Name: "copy", Source: csv, Destinations: []pipeline.Endpoint{
+			{Integration: "DynamoDB", Config: interfaces.Request{DynamoDBTargetTable: "people"}},
+			{Integration: "PostgreSQL", Config: interfaces.Request{SQLTargetConnString: "postgres://app@" + silent.Addr().String() + "/shop?sslmode=disable"}},
+		}},
+	}
+	start := time.Now()
+	report := health.Probe(context.Background(), definitions, 300*time.Millisecond, health.ResolveAll)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, health.StatusFailed, report.Status)
+	assert.False(t, report.Ready())
+	assert.Len(t, report.Checks, 5)
+	statuses := map[string]health.Status{}
+	for _, check := range report.Checks {
+		statuses[check.Pipeline+" "+check.Role+" "+check.Integration] = check.Status
+	}
+	assert.Equal(t, map[string]health.Status{
+		"files source CSV":            health.StatusOK,
+		"files destination CSV":       health.StatusOK,
+		"copy source CSV":             health.StatusOK,
+		"copy destination DynamoDB":   health.StatusSkipped,
+		"copy destination PostgreSQL": health.StatusFailed,
+	}, statuses)
+	assert.Equal(t, "no answer within 300ms", report.Checks[4].Error)
+	t.Logf("%s Every source and destination is probed, with a timeout", greenTick)
+
+	runStore, err := store.Open(filepath.Join(dir, "runs.db"), store.Retention{})
+	assert.NoError(t, err)
+	pipelines := scheduler.New(runStore)
+	pipelines.Load(definitions)
+	defer pipelines.Stop()
+	deps := controller.Dependencies{Runs: runStore, Scheduler: pipelines, Health: health.NewCache(time.Minute)}
+	server := httptest.NewServer(controller.HealthMiddleware(deps)(http.NotFoundHandler()))
+	defer server.Close()
+
+	get := func(path string, data interface{}) int {
+		resp, err := http.Get(server.URL + path)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		body := struct {
+			Data interface{} `json:"data"`
+		}{data}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode
+	}
+	var details health.Report
+	status := get("/health/ready/details?timeout=300ms", &details)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Len(t, details.Checks, 5)
+	details = health.Report{}
+	status = get("/health/ready/details?pipeline=files", &details)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, health.StatusOK, details.Status)
+	assert.Len(t, details.Checks, 2)
+	status = get("/health/ready/details?pipeline=missing", &map[string]interface{}{})
+	assert.Equal(t, http.StatusNotFound, status)
+	status = get("/health/ready/details?timeout=forever", &map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, status)
+	t.Logf("%s /health/ready/details answers 503 when a check fails", greenTick)
+
+	var summary map[string]interface{}
+	status = get("/health/ready?pipeline=files", &summary)
+	assert.Equal(t, http.StatusServiceUnavailable, status, "query parameters are ignored")
+	assert.Equal(t, "failed", summary["status"])
+	assert.NotContains(t, summary, "checks")
+	var again map[string]interface{}
+	get("/health/ready", &again)
+	assert.Equal(t, summary["checked_at"], again["checked_at"], "the report is reused")
+	t.Logf("%s /health/ready only tells whether every check passed, and reuses its report", greenTick)
+
+	policy := &rbac.Policy{Roles: map[string]rbac.Role{"files": {Pipelines: []string{"files"}}}}
+	restricted := controller.HealthMiddleware(controller.Dependencies{Runs: runStore, Policy: policy, Scheduler: pipelines})(http.NotFoundHandler())
+	request := httptest.NewRequest(http.MethodGet, "/health/ready/details", nil)
+	request = request.WithContext(auth.WithPrincipal(request.Context(), &auth.Principal{Method: "api_key", Name: "viewer", Scopes: []string{"read", "integration:*", "role:files"}}))
+	recorder := httptest.NewRecorder()
+	restricted.ServeHTTP(recorder, request)
+	var body struct {
+		Data health.Report `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&body))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Len(t, body.Data.Checks, 2)
+	for _, check := range body.Data.Checks {
+		assert.Equal(t, "files", check.Pipeline)
+	}
+	t.Logf("%s Details only cover the pipelines the caller may read", greenTick)
+
+	t.Setenv("FRACTAL_HEALTH_INPUT", input)
+	referenced := pipeline.Endpoint{Integration: "CSV", Config: interfaces.Request{CSVSourceFileName: "${FRACTAL_HEALTH_INPUT}"}}
+	assert.NoError(t, runStore.CreatePipeline(&pipeline.Definition{Name: "saved", Source: referenced, Destinations: []pipeline.Endpoint{output}}))
+	pipelines.Load(append(definitions, &pipeline.Definition{Name: "static", Source: referenced, Destinations: []pipeline.Endpoint{output}}))
+	var saved, static health.Report
+	get("/health/ready/details?pipeline=saved", &saved)
+	assert.Equal(t, health.StatusFailed, saved.Checks[0].Status)
+	assert.Contains(t, saved.Checks[0].Error, "${FRACTAL_HEALTH_INPUT}")
+	get("/health/ready/details?pipeline=static", &static)
+	assert.Equal(t, health.StatusOK, static.Checks[0].Status)
+	t.Logf("%s Only pipelines of the config file have their secret references resolved", greenTick)
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/SkySingh04/fractal/config"
+	_ "github.com/SkySingh04/fractal/integrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigTemplate(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	template, err := config.Template("PostgreSQL", "CSV", "@daily")
+	assert.NoError(t, err)
+
+	// Secret fields reference the environment, the other required fields are left for
+	// the user to fill in
+	assert.Equal(t, "${FRACTAL_INPUT_CONNSTRING}", template["inputconfig"].(map[string]interface{})["connstring"])
+	file := filepath.Join(t.TempDir(), "pipeline.yaml")
+	_, err = config.WriteConfig(file, template)
+	assert.NoError(t, err)
+	var problems *config.ValidationError
+	if assert.ErrorAs(t, config.Validate(file), &problems) {
+		assert.Len(t, problems.Problems, 1)
+	}
+
+	template["outputconfig"].(map[string]interface{})["csvdestinationfilename"] = "users.csv"
+	_, err = config.WriteConfig(file, template)
+	assert.NoError(t, err)
+	loaded, err := config.LoadConfig(file)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "PostgreSQL", loaded["inputMethod"])
+	assert.Equal(t, "CSV", loaded["outputMethod"])
+	assert.Contains(t, loaded["inputconfig"], "connstring")
+	assert.Contains(t, loaded["outputconfig"], "csvdestinationfilename")
+	t.Logf("%s Templates list every field of the chosen integrations and load back", greenTick)
+
+	spec, ok, err := config.ScheduleFromConfig(loaded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "@daily", spec.Expression)
+	t.Logf("%s The template schedule is written to the cronjob section", greenTick)
+
+	_, err = config.Template("Carrier Pigeon", "CSV", "")
+	assert.Error(t, err)
+	t.Logf("%s Unknown integrations are rejected", greenTick)
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SkySingh04/fractal/logger"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/stretchr/testify/assert"
+)
+
+// logLines decodes the JSON lines written by a logger
+func logLines(t *testing.T, output *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var fields map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &fields))
+		lines = append(lines, fields)
+	}
+	output.Reset()
+	return lines
+}
+
+func TestLogger(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	var output bytes.Buffer
+	log := logger.New(&output, logger.Options{Level: slog.LevelInfo, JSON: true, MaxLength: 64})
+
+	log.Debugf("Validating data: %s", "John,25")
+	log.Infof("Reading data")
+	log.Warnf("Slow destination")
+	log.Errorf("Destination failed")
+	lines := logLines(t, &output)
+	assert.Len(t, lines, 3)
+	assert.Equal(t, "INFO", lines[0]["level"])
+	assert.Equal(t, "WARN", lines[1]["level"])
+	assert.Equal(t, "ERROR", lines[2]["level"])
+	t.Logf("%s Lines below the level are dropped and errors do not stop the process", greenTick)
+
+	ctx, span := opentele.CreateSpan(context.Background(), "run")
+	ctx = logger.NewContext(ctx, log.With("pipeline", "orders", "run_id", "run-1"))
+	logger.FromContext(ctx).With("integration", "PostgreSQL").Infof("Connecting")
+	span.End()
+	lines = logLines(t, &output)
+	assert.Equal(t, "Connecting", lines[0]["msg"])
+	assert.Equal(t, "orders", lines[0]["pipeline"])
+	assert.Equal(t, "run-1", lines[0]["run_id"])
+	assert.Equal(t, "PostgreSQL", lines[0]["integration"])
+	if span.SpanContext().IsValid() {
+		assert.Equal(t, span.SpanContext().TraceID().String(), lines[0]["trace_id"])
+	}
+	t.Logf("%s Fields of the context are added to every line", greenTick)
+
+	logger.AddSecret("s3cr3t-token")
+	log.Infof("Connecting to postgres://app:hunter2@db/app")
+	log.Infof("Using host=db password=hunter2 sslmode=disable")
+	log.Infof("Calling the API with s3cr3t-token")
+	log.With("db_password", "hunter2").Infof("Connected")
+	lines = logLines(t, &output)
+	assert.Equal(t, "Connecting to postgres://app:***@db/app", lines[0]["msg"])
+	assert.Equal(t, "Using host=db password=*** sslmode=disable", lines[1]["msg"])
+	assert.Equal(t, "Calling the API with ***", lines[2]["msg"])
+	assert.Equal(t, "***", lines[3]["db_password"])
+	t.Logf("%s Passwords, connection strings and resolved secrets are redacted", greenTick)
+
+	log.Infof("Data fetched: %s", strings.Repeat("x", 100))
+	lines = logLines(t, &output)
+	assert.Equal(t, "Data fetched: "+strings.Repeat("x", 50)+"... (50 more bytes)", lines[0]["msg"])
+	debug := logger.New(&output, logger.Options{Level: slog.LevelDebug, JSON: true})
+	for i := 0; i < 200; i++ {
+		debug.Debugf("Transforming record %d", i)
+	}
+	assert.Len(t, logLines(t, &output), 11)
+	for i := 0; i < 200; i++ {
+		debug.Infof("Wrote batch %d", i)
+		debug.Logf("[AUDIT] access denied principal=%q", "jwt:bob")
+	}
+	assert.Len(t, logLines(t, &output), 400, "only debug lines are sampled")
+	t.Logf("%s Long payloads are truncated and repeated debug lines are sampled", greenTick)
+
+	previous := logger.Default()
+	t.Cleanup(func() { logger.SetDefault(previous) })
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); logger.SetDefault(debug) }()
+		go func() { defer wg.Done(); _ = logger.Default() }()
+	}
+	wg.Wait()
+	assert.Same(t, debug, logger.Default())
+	output.Reset()
+	t.Logf("%s The default logger can be replaced while it is in use", greenTick)
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	_, err := logger.OptionsFromEnv()
+	assert.ErrorContains(t, err, "LOG_LEVEL")
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("LOG_FORMAT", "json")
+	t.Setenv("LOG_MAX_LENGTH", "0")
+	options, err := logger.OptionsFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, logger.Options{Level: slog.LevelDebug, JSON: true}, options)
+	t.Logf("%s Logging is configured from the environment", greenTick)
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/metrics"
+	"github.com/SkySingh04/fractal/progress"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingManager adds up what is recorded for every series, keyed by name and
+// labels; gauges keep their last value
+type recordingManager struct {
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func (m *recordingManager) key(name string, labels []string) string {
+	return name + "{" + strings.Join(labels, ",") + "}"
+}
+
+func (m *recordingManager) add(name string, value float64, labels []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[m.key(name, labels)] += value
+}
+
+func (m *recordingManager) get(name string, labels ...string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[m.key(name, labels)]
+}
+
+func (m *recordingManager) NewCounter(string, string)               {}
+func (m *recordingManager) NewUpDownCounter(string, string)         {}
+func (m *recordingManager) NewHistogram(string, string, ...float64) {}
+func (m *recordingManager) NewGauge(string, string)                 {}
+func (m *recordingManager) IncrementCounter(_ context.Context, name string, labels ...string) {
+	m.add(name, 1, labels)
+}
+func (m *recordingManager) DeltaUpDownCounter(_ context.Context, name string, value float64, labels ...string) {
+	m.add(name, value, labels)
+}
+
+// RecordHistogram counts observations, as the _count series of a histogram does
+func (m *recordingManager) RecordHistogram(_ context.Context, name string, _ float64, labels ...string) {
+	m.add(name, 1, labels)
+}
+func (m *recordingManager) SetGauge(name string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[m.key(name, labels)] = value
+}
+
+func TestRunMetrics(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	dir := t.TempDir()
+	manager := &recordingManager{values: map[string]float64{}}
+	hook := metrics.Register(manager)
+
+	input := filepath.Join(dir, "input.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("name,age\nJohn,25\nJane,30"), 0644))
+	spec := runner.Spec{
+		Pipeline:      "metrics",
+		Source:        "CSV",
+		SourceRequest: interfaces.Request{CSVSourceFileName: input},
+		Destination:   "CSV",
+		DestinationRequest: interfaces.Request{
+			CSVDestinationFileName: filepath.Join(dir, "output.csv"),
+		},
+		Destinations: []runner.Target{{Destination: "DoesNotExist"}},
+		OnError:      runner.OnErrorContinue,
+		Trigger:      "cli",
+	}
+	_, err := runner.Execute(context.Background(), spec)
+	assert.Error(t, err)
+
+	assert.Equal(t, 3.0, manager.get(metrics.RecordsRead, "pipeline", "metrics", "source", "CSV"))
+	assert.Equal(t, 3.0, manager.get(metrics.RecordsWritten, "pipeline", "metrics", "source", "CSV", "destination", "CSV"))
+	assert.Equal(t, 3.0, manager.get(metrics.RecordsFailed, "pipeline", "metrics", "source", "CSV", "destination", "DoesNotExist"))
+	assert.Equal(t, 1.0, manager.get(metrics.FetchDuration, "pipeline", "metrics", "source", "CSV", "status", "ok"))
+	assert.Equal(t, 1.0, manager.get(metrics.SendDuration, "pipeline", "metrics", "source", "CSV", "destination", "DoesNotExist", "status", "error"))
+	assert.Equal(t, 1.0, manager.get(metrics.RunsTotal, "pipeline", "metrics", "status", "failed"))
+	assert.Equal(t, 0.0, manager.get(metrics.JobsInFlight, "pipeline", "metrics"))
+	assert.Zero(t, manager.get(metrics.LastSuccess, "pipeline", "metrics"))
+	t.Logf("%s Records are counted per source and destination, failures included", greenTick)
+
+	spec.Destinations = nil
+	_, err = runner.Execute(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.Equal(t, 6.0, manager.get(metrics.RecordsWritten, "pipeline", "metrics", "source", "CSV", "destination", "CSV"))
+	assert.InDelta(t, float64(time.Now().Unix()), manager.get(metrics.LastSuccess, "pipeline", "metrics"), 5)
+	t.Logf("%s A successful run sets the last success time", greenTick)
+
+	streaming := &runner.Run{ID: "metrics-lag", Pipeline: "events", Source: "Kafka"}
+	hook.RunStarted(streaming)
+	tracker := progress.Start(streaming.ID)
+	tracker.SetLag(42)
+	assert.Eventually(t, func() bool {
+		return manager.get(metrics.SourceLag, "pipeline", "events", "source", "Kafka") == 42
+	}, time.Second, 10*time.Millisecond)
+	tracker.Finish(nil)
+	hook.RunFinished(streaming)
+	t.Logf("%s The lag reported by a streaming source is exported", greenTick)
+}
@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SkySingh04/fractal/config"
+	_ "github.com/SkySingh04/fractal/integrations"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/pipeline"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigPipelines(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "pipelines.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(`inputMethod: CSV
+inputconfig:
+  csvsourcefilename: in.csv
+outputMethod: CSV
+outputconfig:
+  csvdestinationfilename: out.csv
+pipelines:
+  - name: users
+    source:
+      integration: PostgreSQL
+      config:
+        connstring: ${DATABASE_URL}
+    destinations:
+      - integration: CSV
+        config:
+          csvdestinationfilename: users.csv
+      - integration: MongoDB
+        config:
+          connstring: mongodb://localhost:27017
+          database: app
+          collection: users
+    Schedule: "0 2 * * *"
+    timezone: Europe/Berlin
+    error_strategy: LOG_AND_CONTINUE
+  - name: orders
+    source:
+      integration: CSV
+      config:
+        csvsourcefilename: orders.csv
+    destinations:
+      - integration: YAML
+        config:
+          filepath: orders.yaml
+`), 0644))
+
+	definitions, err := config.LoadPipelines(file)
+	assert.NoError(t, err)
+	if assert.Len(t, definitions, 3) {
+		assert.Equal(t, "default", definitions[0].Name)
+		assert.False(t, definitions[0].Scheduled())
+
+		users := definitions[1]
+		assert.Equal(t, "users", users.Name)
+		assert.Equal(t, "0 2 * * *", users.Schedule, "keys are matched without regard to case")
+		assert.Equal(t, "Europe/Berlin", users.Timezone)
+		assert.Equal(t, "${DATABASE_URL}", users.Source.Config.SQLSourceConnString, "references are kept until a run")
+		spec := users.Spec("cli")
+		assert.Equal(t, []string{"CSV", "MongoDB"}, spec.DestinationNames())
+		assert.Equal(t, runner.OnErrorContinue, spec.OnError)
+
+		t.Setenv("DATABASE_URL", "postgres://app:secret@db/app")
+		resolved, err := users.ResolvedSpec(context.Background(), "cli")
+		assert.NoError(t, err)
+		assert.Equal(t, "postgres://app:secret@db/app", resolved.SourceRequest.SQLSourceConnString)
+
+		assert.Equal(t, "orders.yaml", definitions[2].Destinations[0].Config.YAMLDestinationFilePath)
+	}
+	t.Logf("%s Every pipeline of a file is loaded, next to the one of inputmethod", greenTick)
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	assert.NoError(t, os.WriteFile(invalid, []byte(`pipelines:
+  - name: users
+    source:
+      integration: CSV
+    destinations:
+      - integration: CVS
+        config:
+          csvdestinationfilename: out.csv
+    error_strategy: RETYR
+  - name: users
+    source:
+      integration: CSV
+      config:
+        csvsourcefilename: in.csv
+    destinations: []
+    shedule: "@daily"
+    validations: ['FIELD("age") REQUIRED', 'FIELD("age") RANGE(18)']
+`), 0644))
+	_, err = config.LoadPipelines(invalid)
+	var problems *config.ValidationError
+	if assert.True(t, errors.As(err, &problems)) {
+		messages := map[int]string{}
+		for _, problem := range problems.Problems {
+			messages[problem.Line] += problem.Message + "\n"
+		}
+		assert.Contains(t, messages[4], "pipelines[0].source.config is required for CSV")
+		assert.Contains(t, messages[6], `unknown destination "CVS", did you mean "CSV"?`)
+		assert.Contains(t, messages[9], `unknown error_strategy "RETYR", did you mean "RETRY"?`)
+		assert.Contains(t, messages[10], `pipeline "users" is already defined on line 2`)
+		assert.Contains(t, messages[15], "destinations must be a list of at least one destination")
+		assert.Contains(t, messages[16], `did you mean "schedule"?`)
+		assert.Contains(t, messages[17], "pipelines[1].validations[1] expected ,")
+		assert.NotContains(t, messages[17], "validations[0]")
+	}
+	withRules := *definitions[2]
+	withRules.Validations = []string{`FIELD("age") TYPE(INT) RANGE(18, 65)`}
+	withRules.Transformations = []string{`RENAME("age", "years")`}
+	assert.NoError(t, withRules.Validate())
+	withRules.Transformations = []string{`RENAME("age")`}
+	assert.ErrorContains(t, withRules.Validate(), "invalid pipeline transformations[0]: expected ,")
+	t.Logf("%s Mistakes in the pipelines list are reported with their line", greenTick)
+}
+
+func TestErrorStrategy(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	dir := t.TempDir()
+
+	input := filepath.Join(dir, "input.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("name,age\nJohn,25\nJane,30"), 0644))
+	output := filepath.Join(dir, "output.csv")
+	spec := runner.Spec{
+		Pipeline:      "strategy",
+		Source:        "CSV",
+		SourceRequest: interfaces.Request{CSVSourceFileName: input},
+		Destination:   "DoesNotExist",
+		Destinations:  []runner.Target{{Destination: "CSV", Request: interfaces.Request{CSVDestinationFileName: output}}},
+		Trigger:       "cli",
+	}
+
+	run, err := runner.Execute(context.Background(), spec)
+	assert.Error(t, err)
+	assert.Equal(t, 0, run.RecordsWritten)
+	assert.NoFileExists(t, output)
+	t.Logf("%s By default a failed destination stops the run", greenTick)
+
+	spec.OnError = runner.OnErrorContinue
+	run, err = runner.Execute(context.Background(), spec)
+	assert.ErrorContains(t, err, "DoesNotExist")
+	assert.Equal(t, runner.StatusFailed, run.Status)
+	assert.Equal(t, 3, run.RecordsWritten)
+	assert.FileExists(t, output)
+	t.Logf("%s With LOG_AND_CONTINUE the other destinations still receive the data", greenTick)
+}
+
+func TestConfigReload(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	dir := t.TempDir()
+
+	pipelineYAML := func(name, schedule string) string {
+		return `  - name: ` + name + `
+    source:
+      integration: CSV
+      config:
+        csvsourcefilename: in.csv
+    destinations:
+      - integration: CSV
+        config:
+          csvdestinationfilename: ` + name + `.csv
+    schedule: ` + schedule + `
+`
+	}
+	file := filepath.Join(dir, "pipelines.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("pipelines:\n"+pipelineYAML("a", "1h")+pipelineYAML("b", "1h")), 0644))
+	before, err := config.LoadPipelines(file)
+	assert.NoError(t, err)
+
+	reloaded := make(chan []*pipeline.Definition, 1)
+	rejected := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, config.Watch(ctx, file, func(definitions []*pipeline.Definition) { reloaded <- definitions }, func(err error) { rejected <- err }))
+
+	assert.NoError(t, os.WriteFile(file, []byte("pipelines:\n"+pipelineYAML("a", "30m")+pipelineYAML("c", "1h")), 0644))
+	select {
+	case after := <-reloaded:
+		changes := pipeline.Diff(before, after)
+		assert.Equal(t, "added c; changed a; removed b", changes.String())
+		assert.True(t, pipeline.Diff(after, after).Empty())
+	case err := <-rejected:
+		t.Fatalf("valid config rejected: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config change not picked up")
+	}
+	t.Logf("%s Saving the config file reloads it, and Diff tells what changed", greenTick)
+
+	assert.NoError(t, os.WriteFile(file, []byte("pipelines:\n  - name: broken\n"), 0644))
+	select {
+	case <-reloaded:
+		t.Fatal("invalid config applied")
+	case err := <-rejected:
+		var problems *config.ValidationError
+		assert.ErrorAs(t, err, &problems)
+	case <-time.After(5 * time.Second):
+		t.Fatal("invalid config not reported")
+	}
+	t.Logf("%s An invalid config is rejected instead of applied", greenTick)
+}
+
+func TestConnectionChecks(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	input := filepath.Join(dir, "input.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("name\nJohn"), 0644))
+	source := pipeline.Endpoint{Integration: "CSV", Config: interfaces.Request{CSVSourceFileName: input}}
+	assert.NoError(t, source.TestConnection(ctx, schema.KindSource, true))
+	source.Config.CSVSourceFileName = filepath.Join(dir, "missing.csv")
+	assert.Error(t, source.TestConnection(ctx, schema.KindSource, true))
+
+	destination := pipeline.Endpoint{Integration: "CSV", Config: interfaces.Request{CSVDestinationFileName: filepath.Join(dir, "out.csv")}}
+	assert.NoError(t, destination.TestConnection(ctx, schema.KindDestination, true))
+	assert.NoFileExists(t, filepath.Join(dir, "out.csv"), "checks do not write")
+	destination.Config.CSVDestinationFileName = filepath.Join(dir, "missing", "out.csv")
+	assert.Error(t, destination.TestConnection(ctx, schema.KindDestination, true))
+	t.Logf("%s File integrations check their paths", greenTick)
+
+	t.Setenv("FRACTAL_TEST_DB", "postgres://fractal@127.0.0.1:1/app?sslmode=disable&connect_timeout=1")
+	postgres := pipeline.Endpoint{Integration: "PostgreSQL", Config: interfaces.Request{SQLSourceConnString: "${FRACTAL_TEST_DB}"}}
+	assert.ErrorContains(t, postgres.TestConnection(ctx, schema.KindSource, true), "127.0.0.1:1", "references are resolved before connecting")
+	assert.NotContains(t, postgres.TestConnection(ctx, schema.KindSource, false).Error(), "127.0.0.1:1", "unless asked not to")
+
+	dynamo := pipeline.Endpoint{Integration: "DynamoDB", Config: interfaces.Request{DynamoDBSourceTable: "users"}}
+	assert.ErrorIs(t, dynamo.TestConnection(ctx, schema.KindSource, true), pipeline.ErrNotTestable)
+	t.Logf("%s Network integrations connect, others say they cannot be tested", greenTick)
+}
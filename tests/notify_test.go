@@ -0,0 +1,259 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SkySingh04/fractal/config"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/notify"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+// smtpStandIn accepts SMTP sessions on a local port and passes on the message of each
+func smtpStandIn(t *testing.T) (port int, messages chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	messages = make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				io.WriteString(conn, "220 localhost ESMTP\r\n")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch command := strings.ToUpper(strings.Fields(line + " x")[0]); command {
+					case "DATA":
+						io.WriteString(conn, "354 go ahead\r\n")
+						var message strings.Builder
+						for {
+							line, err := reader.ReadString('\n')
+							if err != nil || line == ".\r\n" {
+								break
+							}
+							message.WriteString(line)
+						}
+						messages <- message.String()
+						io.WriteString(conn, "250 queued\r\n")
+					case "QUIT":
+						io.WriteString(conn, "221 bye\r\n")
+						return
+					default:
+						io.WriteString(conn, "250 ok\r\n")
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, messages
+}
+
+func TestNotifications(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("name,age\nJohn,25\nJane,30"), 0644))
+	spec := func(output string) runner.Spec {
+		return runner.Spec{
+			Pipeline:           "notified",
+			Source:             "CSV",
+			SourceRequest:      interfaces.Request{CSVSourceFileName: input},
+			Destination:        "CSV",
+			DestinationRequest: interfaces.Request{CSVDestinationFileName: output},
+			Trigger:            "cli",
+		}
+	}
+
+	failed, err := runner.Execute(context.Background(), spec(filepath.Join(dir, "missing", "output.csv")))
+	assert.Error(t, err)
+	assert.Equal(t, map[string]int{"write": 1}, failed.Errors)
+	summary := notify.SummaryOf(failed)
+	assert.Equal(t, 1, summary.ErrorCount())
+	assert.Contains(t, summary.String(), "Pipeline notified run "+failed.ID+" failed in ")
+	assert.Contains(t, summary.String(), "3 records read, 0 written, 1 errors (write: 1), 0 quarantined")
+	t.Logf("%s A run counts its errors by kind and sums up on one line", greenTick)
+
+	webhooks := make(chan string, 10)
+	slackMessages := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/slack" {
+			slackMessages <- string(body)
+			return
+		}
+		webhooks <- r.Header.Get("X-Token") + " " + string(body)
+	}))
+	defer server.Close()
+	port, mails := smtpStandIn(t)
+	t.Setenv("NOTIFY_TOKEN", "s3cr3t")
+
+	configFile := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte(`
+notifications:
+  summary_file: `+filepath.Join(dir, "runs.jsonl")+`
+  thresholds:
+    max_errors: 0
+  notifiers:
+    - type: webhook
+      url: `+server.URL+`/hook
+      headers:
+        X-Token: ${NOTIFY_TOKEN}
+      body: '{"event": "{{.Event}}", "pipeline": "{{.Pipeline}}", "errors": {{json .Errors}}}'
+      on: [failure, recovery]
+    - type: slack
+      url: `+server.URL+`/slack
+      on: threshold
+    - type: email
+      host: 127.0.0.1
+      port: `+strconv.Itoa(port)+`
+      from: fractal@example.com
+      to: [oncall@example.com]
+      on: [recovery]
+`), 0644))
+	settings, err := notify.LoadConfig(configFile)
+	assert.NoError(t, err)
+	hook, err := notify.New(settings, nil)
+	assert.NoError(t, err)
+
+	hook.RunFinished(failed)
+	assert.Equal(t, `s3cr3t {"event": "failure", "pipeline": "notified", "errors": {"write":1}}`, <-webhooks)
+	var slackMessage map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(<-slackMessages), &slackMessage))
+	assert.Contains(t, slackMessage["text"], ":warning: Pipeline notified breached its thresholds: 1 errors, more than 0")
+	t.Logf("%s A failure is posted to the webhook with its templated body and the breach to Slack", greenTick)
+
+	succeeded, err := runner.Execute(context.Background(), spec(filepath.Join(dir, "output.csv")))
+	assert.NoError(t, err)
+	hook.RunFinished(succeeded)
+	assert.Contains(t, <-webhooks, `"event": "recovery"`)
+	mail := <-mails
+	assert.Contains(t, mail, "Subject: [fractal] notified recovery")
+	assert.Contains(t, mail, "Pipeline notified recovered")
+	hook.RunFinished(succeeded)
+	hook.Wait()
+	assert.Empty(t, webhooks)
+	t.Logf("%s Recovery is sent once, by webhook and email", greenTick)
+
+	summaries, err := os.ReadFile(filepath.Join(dir, "runs.jsonl"))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(summaries)), "\n")
+	assert.Len(t, lines, 3)
+	var written notify.Summary
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &written))
+	assert.Equal(t, failed.ID, written.RunID)
+	assert.Equal(t, runner.StatusFailed, written.Status)
+	assert.Equal(t, 3, written.RecordsRead)
+	t.Logf("%s Every summary is appended to the summary file", greenTick)
+
+	assert.NoError(t, os.WriteFile(configFile, []byte(`
+inputmethod: CSV
+inputconfig:
+  csvsourcefilename: input.csv
+outputmethod: CSV
+outputconfig:
+  csvdestinationfilename: output.csv
+notifications:
+  thresholds:
+    max_duration: soon
+  notifiers:
+    - type: pager
+    - type: slack
+      on: [failed]
+`), 0644))
+	err = config.Validate(configFile)
+	assert.ErrorContains(t, err, `notifications.thresholds.max_duration "soon" is not a duration`)
+	assert.ErrorContains(t, err, `unknown notifier type "pager"`)
+	assert.ErrorContains(t, err, `unknown event "failed"`)
+	_, err = notify.New(notify.Config{Notifiers: []notify.NotifierConfig{{Type: "slack"}}}, nil)
+	assert.ErrorContains(t, err, "url is required")
+	t.Logf("%s Invalid notifications are reported", greenTick)
+
+	_, err = notify.New(notify.Config{Notifiers: []notify.NotifierConfig{{
+		Type: "email", Host: "127.0.0.1", From: "fractal@example.com\r\nBcc: everyone@example.com", To: []string{"oncall@example.com"},
+	}}}, nil)
+	assert.ErrorContains(t, err, "line breaks are not allowed in mail headers")
+	_, err = notify.New(notify.Config{Notifiers: []notify.NotifierConfig{{
+		Type: "email", Host: "127.0.0.1", From: "fractal@example.com", To: []string{"oncall@example.com\nBcc: everyone@example.com"},
+	}}}, nil)
+	assert.ErrorContains(t, err, "line breaks are not allowed in mail headers")
+	hook, err = notify.New(notify.Config{Notifiers: []notify.NotifierConfig{{
+		Type: "email", Host: "127.0.0.1", Port: port, From: "fractal@example.com", To: []string{"oncall@example.com"},
+		Subject: "{{.Pipeline}}\r\nBcc: everyone@example.com",
+	}}}, nil)
+	assert.NoError(t, err)
+	hook.RunFinished(failed)
+	hook.Wait()
+	assert.Empty(t, mails)
+	t.Logf("%s Line breaks in the addresses or the subject of an email are rejected", greenTick)
+}
+
+// slowNotifier delivers a notification once it is released
+type slowNotifier struct{ release, delivered chan struct{} }
+
+func (n slowNotifier) Notify(ctx context.Context, notification notify.Notification) error {
+	select {
+	case <-n.release:
+		n.delivered <- struct{}{}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestNotificationsInBackground(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "input.csv"), []byte("id\n1\n"), 0644))
+	run, err := runner.Execute(context.Background(), runner.Spec{
+		Pipeline:           "slow",
+		Source:             "CSV",
+		SourceRequest:      interfaces.Request{CSVSourceFileName: filepath.Join(dir, "input.csv")},
+		Destination:        "CSV",
+		DestinationRequest: interfaces.Request{CSVDestinationFileName: filepath.Join(dir, "missing", "output.csv")},
+		Trigger:            "cli",
+	})
+	assert.Error(t, err)
+
+	notifier := slowNotifier{release: make(chan struct{}), delivered: make(chan struct{}, 2)}
+	notify.Register("slow", func(notify.NotifierConfig) (notify.Notifier, error) { return notifier, nil })
+	hook, err := notify.New(notify.Config{Notifiers: []notify.NotifierConfig{{Type: "slow"}, {Type: "slow"}}}, nil)
+	assert.NoError(t, err)
+
+	finished := make(chan struct{})
+	go func() {
+		hook.RunFinished(run)
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("RunFinished waited for the notifiers")
+	}
+	t.Logf("%s The end of a run does not wait for its notifications", greenTick)
+
+	close(notifier.release)
+	hook.Wait()
+	assert.Len(t, notifier.delivered, 2)
+	t.Logf("%s Wait returns once the notifications are delivered", greenTick)
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SkySingh04/fractal/controller"
+	_ "github.com/SkySingh04/fractal/integrations"
+	"github.com/stretchr/testify/assert"
+	"gofr.dev/pkg/gofr/http/response"
+)
+
+// TestOpenAPIUpToDate fails when the committed document no longer matches the
+// route table. Regenerate it with `go generate` from the repository root.
+func TestOpenAPIUpToDate(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	redCross := "\033[31m✘\033[0m"
+
+	generated, err := controller.OpenAPI()
+	assert.NoError(t, err)
+
+	committed, err := os.ReadFile("../" + controller.OpenAPIFile)
+	assert.NoError(t, err)
+
+	if assert.JSONEq(t, string(committed), string(generated), "static/openapi.json is out of date, run `go generate`") {
+		t.Logf("%s Committed OpenAPI document matches the handlers", greenTick)
+	} else {
+		t.Logf("%s Committed OpenAPI document drifted from the handlers", redCross)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(generated, &doc))
+
+	seen := map[string]bool{}
+	for _, route := range controller.Routes(controller.Dependencies{}) {
+		key := route.Method + " " + route.Path
+		assert.False(t, seen[key], "route %s registered twice", key)
+		seen[key] = true
+
+		assert.NotNil(t, route.Handler, "route %s has no handler", key)
+		method := route.Method
+		if method == controller.MethodWebSocket {
+			method = "GET"
+		}
+		assert.Contains(t, doc.Paths[route.Path], strings.ToLower(method), "route %s is not documented", key)
+	}
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	doc, err := controller.OpenAPI()
+	assert.NoError(t, err)
+	result, err := controller.OpenAPIHandler(doc)(nil)
+	assert.NoError(t, err)
+	if assert.IsType(t, response.File{}, result) {
+		file := result.(response.File)
+		assert.Equal(t, "application/json", file.ContentType)
+		assert.JSONEq(t, string(doc), string(file.Content))
+	}
+	assert.Equal(t, "/.well-known/openapi.json", controller.OpenAPIPath)
+	t.Logf("%s The generated document is served as it is, without reading static/", greenTick)
+}
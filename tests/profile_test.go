@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SkySingh04/fractal/config"
+	"github.com/SkySingh04/fractal/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+// profiledField returns the profile of the field at path
+func profiledField(t *testing.T, dataset profile.Dataset, path string) profile.Field {
+	for _, field := range dataset.Fields {
+		if field.Path == path {
+			return field
+		}
+	}
+	t.Fatalf("field %s was not profiled", path)
+	return profile.Field{}
+}
+
+func TestProfile(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	report, err := profile.Data("id,status,score,joined\n1,active,2.5,2024-01-05\n2,inactive,3,2024-02-11\n3,active,,2023-12-30\n4,active,4,2024-03-01", profile.Options{})
+	assert.NoError(t, err)
+	dataset := report.Datasets[0]
+	assert.Equal(t, 4, dataset.Records)
+	id := profiledField(t, dataset, "id")
+	assert.Equal(t, profile.TypeInt, id.Type)
+	assert.Equal(t, "1", id.Min)
+	assert.Equal(t, "4", id.Max)
+	assert.Equal(t, 4, id.Distinct)
+	assert.True(t, id.DistinctExact)
+	score := profiledField(t, dataset, "score")
+	assert.Equal(t, profile.TypeFloat, score.Type)
+	assert.Equal(t, 1, score.Nulls)
+	assert.Equal(t, 0.25, score.NullRatio)
+	assert.Equal(t, profile.TypeDate, profiledField(t, dataset, "joined").Type)
+	status := profiledField(t, dataset, "status")
+	assert.Equal(t, []profile.Value{{Value: "active", Count: 3}, {Value: "inactive", Count: 1}}, status.Top)
+	assert.Equal(t, &profile.Lengths{Min: 6, Max: 8, Mean: 6.5, P50: 6, P90: 8, P99: 8}, status.Lengths)
+	t.Logf("%s Types, nulls, ranges, top values and lengths are inferred from CSV text", greenTick)
+
+	assert.Contains(t, dataset.Rules, `FIELD("id") REQUIRED`)
+	assert.Contains(t, dataset.Rules, `FIELD("id") TYPE(INT) RANGE(1, 4)`)
+	assert.Contains(t, dataset.Rules, `FIELD("score") TYPE(FLOAT) RANGE(2.5, 4)`)
+	assert.Contains(t, dataset.Rules, `FIELD("status") IN ("active", "inactive")`)
+	assert.NotContains(t, dataset.Rules, `FIELD("score") REQUIRED`)
+	t.Logf("%s Validation rules that every record passes are suggested", greenTick)
+
+	report, err = profile.Data(map[string]interface{}{"orders": []interface{}{
+		map[string]interface{}{"id": 1, "customer": map[string]interface{}{"name": "John", "city": "Pune"}, "items": []interface{}{
+			map[string]interface{}{"sku": "A1", "qty": 2}, map[string]interface{}{"sku": "B2", "qty": 1},
+		}},
+		map[string]interface{}{"id": 2, "customer": map[string]interface{}{"name": "Jane"}, "items": []interface{}{}},
+	}}, profile.Options{})
+	assert.NoError(t, err)
+	dataset = report.Datasets[0]
+	assert.Equal(t, "orders", dataset.Name)
+	customer := profiledField(t, dataset, "customer")
+	assert.Equal(t, profile.TypeObject, customer.Type)
+	assert.Equal(t, "{city, name}", customer.Shape)
+	city := profiledField(t, dataset, "customer.city")
+	assert.Equal(t, 2, city.Count)
+	assert.Equal(t, 1, city.Nulls)
+	items := profiledField(t, dataset, "items")
+	assert.Equal(t, profile.TypeArray, items.Type)
+	assert.Equal(t, "[OBJECT]", items.Shape)
+	assert.Equal(t, 1, items.Empty)
+	assert.Equal(t, 2, items.Lengths.Max)
+	qty := profiledField(t, dataset, "items[].qty")
+	assert.Equal(t, 2, qty.Count)
+	assert.Equal(t, profile.TypeInt, qty.Type)
+	t.Logf("%s Nested objects and arrays are profiled by path with their shape", greenTick)
+
+	var rows []interface{}
+	for i := 0; i < 5000; i++ {
+		rows = append(rows, map[string]interface{}{"id": fmt.Sprintf("user-%d", i)})
+	}
+	report, err = profile.Data(rows, profile.Options{Sample: 4000})
+	assert.NoError(t, err)
+	id = report.Datasets[0].Fields[0]
+	assert.Equal(t, 5000, report.Datasets[0].Records)
+	assert.Equal(t, 4000, report.Datasets[0].Sampled)
+	assert.False(t, id.DistinctExact)
+	assert.InDelta(t, 4000, id.Distinct, 400)
+	t.Logf("%s Large inputs are sampled and distinct values estimated", greenTick)
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("name,age\nJohn,25\nJane,30"), 0644))
+	configFile := filepath.Join(dir, "source.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte("inputmethod: CSV\ninputconfig:\n  csvsourcefilename: "+input+"\n"), 0644))
+	sources, err := config.LoadSources(configFile)
+	assert.NoError(t, err)
+	assert.Len(t, sources, 1)
+	report, err = profile.Source(context.Background(), sources[0].Integration, sources[0].Config, profile.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "CSV", report.Source)
+	assert.Equal(t, 2, report.Datasets[0].Records)
+	assert.Contains(t, profile.Text([]profile.Report{report}), "validations:")
+	_, err = config.LoadPipelines(configFile)
+	assert.ErrorContains(t, err, "outputmethod is required")
+	t.Logf("%s The sources of a config file without destinations can be profiled", greenTick)
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/SkySingh04/fractal/auth"
+	"github.com/SkySingh04/fractal/controller"
+	"github.com/SkySingh04/fractal/progress"
+	"github.com/SkySingh04/fractal/rbac"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressTracker(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	tracker := progress.Start("progress-test")
+	same, ok := progress.Get("progress-test")
+	assert.True(t, ok)
+	assert.Same(t, tracker, same)
+
+	events, cancel := tracker.Subscribe()
+	defer cancel()
+	first := <-events
+	assert.Equal(t, progress.PhaseStarting, first.Phase)
+	t.Logf("%s Subscribers receive the current state first", greenTick)
+
+	tracker.SetPhase(progress.PhaseFetching)
+	tracker.SetCurrent("users")
+	tracker.AddRead(3)
+	tracker.SetTotal(3)
+	tracker.SetPhase(progress.PhaseSending)
+	tracker.AddWritten(1)
+	tracker.RecordError(errors.New("duplicate key"))
+
+	// Intermediate events may be dropped, but the latest one is always delivered
+	latest := <-events
+	assert.Equal(t, progress.PhaseSending, latest.Phase)
+	assert.Equal(t, "users", latest.Current)
+	assert.Equal(t, 3, latest.RecordsRead)
+	assert.Equal(t, 1, latest.RecordsWritten)
+	assert.Equal(t, 1, latest.Errors)
+	assert.Equal(t, "duplicate key", latest.LastError)
+	t.Logf("%s Slow subscribers receive the latest state", greenTick)
+
+	assert.Contains(t, progress.Line(latest), "users")
+	var active []string
+	for _, event := range progress.Active() {
+		active = append(active, event.JobID)
+	}
+	assert.Contains(t, active, "progress-test")
+
+	tracker.Finish(nil)
+	final, open := <-events
+	assert.True(t, open)
+	assert.Equal(t, progress.PhaseDone, final.Phase)
+	_, open = <-events
+	assert.False(t, open)
+	t.Logf("%s Subscriptions are closed when the job finishes", greenTick)
+
+	late, _ := tracker.Subscribe()
+	assert.Equal(t, progress.PhaseDone, (<-late).Phase)
+
+	// Nil trackers are used when nobody is listening and must be harmless
+	var none *progress.Tracker
+	none.AddRead(1)
+	none.Finish(errors.New("ignored"))
+	assert.Equal(t, progress.Event{}, none.Snapshot())
+}
+
+func TestEventStreamNotFound(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	server := httptest.NewServer(controller.EventStreamMiddleware(controller.Dependencies{})(http.NotFoundHandler()))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/jobs/" + url.PathEscape(`x","injected":"yes`) + "/events")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	var body map[string]map[string]interface{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Len(t, body, 1)
+	assert.Len(t, body["error"], 1)
+	assert.Contains(t, body["error"]["message"], `x","injected":"yes`)
+	t.Logf("%s Unknown job ids are escaped in the error body", greenTick)
+}
+
+func TestJobAccess(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	runStore, err := store.Open(filepath.Join(t.TempDir(), "runs.db"), store.Retention{})
+	assert.NoError(t, err)
+	run := &runner.Run{ID: runner.NewID(), Pipeline: "orders", Source: "CSV", Destination: "PostgreSQL", Status: runner.StatusSucceeded}
+	assert.NoError(t, runStore.SaveRun(run))
+	progress.Start(run.ID).Finish(nil)
+
+	var principal *auth.Principal
+	deps := controller.Dependencies{Runs: runStore}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+		controller.EventStreamMiddleware(deps)(http.NotFoundHandler()).ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	status := func(id string) int {
+		resp, err := http.Get(server.URL + "/jobs/" + id + "/events")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		_, _ = io.ReadAll(resp.Body)
+		return resp.StatusCode
+	}
+
+	principal = &auth.Principal{Method: "api_key", Name: "csv", Scopes: []string{"read", "integration:CSV"}}
+	assert.Equal(t, http.StatusForbidden, status(run.ID))
+	principal = &auth.Principal{Method: "api_key", Name: "all", Scopes: []string{"read", "integration:*"}}
+	assert.Equal(t, http.StatusOK, status(run.ID))
+	assert.Equal(t, http.StatusNotFound, status(runner.NewID()))
+	t.Logf("%s The progress of a job needs the integration scopes of its run", greenTick)
+
+	deps.Policy = &rbac.Policy{Roles: map[string]rbac.Role{"files": {Destinations: []string{"CSV"}}}}
+	principal = &auth.Principal{Method: "api_key", Name: "files", Scopes: []string{"read", "integration:*", "role:files"}}
+	assert.Equal(t, http.StatusForbidden, status(run.ID))
+	t.Logf("%s Jobs are also checked against the roles of the caller", greenTick)
+
+	csvOnly := func(run runner.Run) bool { return run.Destination == "CSV" }
+	runs, err := runStore.ListRuns(store.RunFilter{Visible: csvOnly})
+	assert.NoError(t, err)
+	assert.Empty(t, runs)
+	runs, err = runStore.ListRuns(store.RunFilter{Visible: func(runner.Run) bool { return true }})
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	t.Logf("%s Runs can be listed for only what the caller may read", greenTick)
+}
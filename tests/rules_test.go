@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/language"
+	"github.com/SkySingh04/fractal/profile"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRules(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	rules, err := language.Compile(nil, nil)
+	assert.NoError(t, err)
+	data, rejections, err := rules.Apply("A\n1")
+	assert.NoError(t, err)
+	assert.Equal(t, "A\n1", data)
+	assert.Empty(t, rejections)
+	t.Logf("%s A pipeline without rules sends its data as read", greenTick)
+
+	for _, invalid := range []struct{ validations, transformations []string }{
+		{[]string{`FIELD("age")`}, nil},
+		{[]string{`FIELD("age") TYPE(NUMBER)`}, nil},
+		{[]string{`FIELD("age") RANGE(18)`}, nil},
+		{[]string{`FIELD("email") MATCHES("[")`}, nil},
+		{nil, []string{`RENAME("age")`}},
+		{nil, []string{`DROP("age")`}},
+		{nil, []string{`IF FIELD("age") > 50 ADD_FIELD("senior", TRUE)`}},
+	} {
+		_, err := language.Compile(invalid.validations, invalid.transformations)
+		assert.Error(t, err, "%v %v", invalid.validations, invalid.transformations)
+	}
+	t.Logf("%s Rules that do not parse are rejected", greenTick)
+
+	rules, err = language.Compile(
+		[]string{`FIELD("age") REQUIRED TYPE(INT) RANGE(18, 65)`, `FIELD("email") MATCHES(EMAIL_REGEX)`, `FIELD("status") IN ("active", "inactive")`},
+		[]string{`RENAME("status", "state")`, `MAP("state", {"active": "A", "inactive": "I"})`, `IF FIELD("age") > 50 THEN ADD_FIELD("senior", TRUE)`},
+	)
+	assert.NoError(t, err)
+
+	// CSV headers are upper-cased when read, and fields match regardless of case
+	data, rejections, err = rules.Apply("NAME,AGE,EMAIL,STATUS\nJohn,25,john@example.com,active\nJane,17,jane@example.com,active\nBob,60,bob@example.com,inactive\nEve,30,eve,active\nAl,,al@example.com,active")
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME,AGE,EMAIL,state,senior\nJohn,25,john@example.com,A,\nBob,60,bob@example.com,I,true", data)
+	if assert.Len(t, rejections, 3) {
+		assert.Equal(t, language.RejectedValidation, rejections[0].Kind)
+		assert.ErrorContains(t, rejections[0].Err, `"17" does not pass RANGE(18, 65)`)
+		assert.ErrorContains(t, rejections[1].Err, "MATCHES(EMAIL_REGEX)")
+		assert.ErrorContains(t, rejections[2].Err, "REQUIRED")
+	}
+	t.Logf("%s CSV records are validated and transformed and stay CSV", greenTick)
+
+	tables := map[string][]map[string]interface{}{
+		"users": {
+			{"age": int64(40), "email": "a@example.com", "status": "active"},
+			{"age": 12.5, "email": "b@example.com", "status": "active"},
+			{"age": int64(70), "email": "c@example.com", "status": "banned"},
+		},
+	}
+	data, rejections, err = rules.Apply(tables)
+	assert.NoError(t, err)
+	if assert.IsType(t, map[string][]map[string]interface{}{}, data) {
+		users := data.(map[string][]map[string]interface{})["users"]
+		assert.Equal(t, []map[string]interface{}{{"age": int64(40), "email": "a@example.com", "state": "A"}}, users)
+	}
+	assert.Len(t, rejections, 2)
+	t.Logf("%s Tables of rows keep their type", greenTick)
+
+	nested, err := language.Compile([]string{`FIELD("$.user.age") > 20`}, []string{`ADD_FIELD("$.user.checked", TRUE)`})
+	assert.NoError(t, err)
+	data, rejections, err = nested.Apply([]byte(`[{"user":{"age":30}},{"user":{"age":10}},{"id":1}]`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"user":{"age":30,"checked":true}},{"id":1,"user.checked":true}]`, string(data.([]byte)))
+	assert.Len(t, rejections, 1)
+	data, _, err = nested.Apply([]interface{}{"not a record"})
+	assert.Error(t, err)
+	assert.Nil(t, data)
+	t.Logf("%s JSON paths reach nested fields, and data that holds no records fails", greenTick)
+
+	report, err := profile.Data("id,status,score\n1,active,2.5\n2,inactive,3\n3,active,4", profile.Options{})
+	assert.NoError(t, err)
+	profiled, err := language.Compile(report.Datasets[0].Rules, nil)
+	assert.NoError(t, err)
+	_, rejections, err = profiled.Apply("ID,STATUS,SCORE\n1,active,2.5\n2,inactive,3\n3,active,4")
+	assert.NoError(t, err)
+	assert.Empty(t, rejections)
+	t.Logf("%s The rules of a profile pass the data they describe", greenTick)
+}
+
+func TestRunRules(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	dir := t.TempDir()
+
+	input := filepath.Join(dir, "input.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("name,age\nJohn,25\nJane,17\nBob,40"), 0644))
+	output := filepath.Join(dir, "output.csv")
+	spec := runner.Spec{
+		Pipeline:           "rules",
+		Source:             "CSV",
+		SourceRequest:      interfaces.Request{CSVSourceFileName: input},
+		Destination:        "CSV",
+		DestinationRequest: interfaces.Request{CSVDestinationFileName: output},
+		Trigger:            "cli",
+		Validations:        []string{`FIELD("age") RANGE(18, 99)`},
+		Transformations:    []string{`RENAME("age", "years")`},
+	}
+
+	run, err := runner.Execute(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.Equal(t, run.RecordsRead-1, run.RecordsWritten)
+	assert.Equal(t, 1, run.Quarantined)
+	assert.Equal(t, 1, run.Errors["validation"])
+	written, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Contains(t, string(written), "NAME,years\nJOHN,25\nBOB,40")
+	assert.NotContains(t, string(written), "JANE")
+	t.Logf("%s Runs leave out the records that fail a validation and send the others transformed", greenTick)
+
+	spec.Validations = []string{`FIELD("age") BETWEEN(18, 99)`}
+	run, err = runner.Execute(context.Background(), spec)
+	assert.ErrorContains(t, err, "invalid pipeline rules: validations[0]")
+	assert.Equal(t, runner.StatusFailed, run.Status)
+	assert.Equal(t, 0, run.RecordsRead)
+	t.Logf("%s A run with a rule that does not parse fails before reading", greenTick)
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/SkySingh04/fractal/integrations"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunHistory(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	redCross := "\033[31m✘\033[0m"
+
+	logTestStatus := func(description string, err error) {
+		if err == nil {
+			t.Logf("%s %s", greenTick, description)
+		} else {
+			t.Logf("%s %s: %v", redCross, description, err)
+		}
+	}
+
+	dir := t.TempDir()
+	runStore, err := store.Open(filepath.Join(dir, "runs.db"), store.Retention{})
+	logTestStatus("Open run store", err)
+	assert.NoError(t, err)
+
+	inputFileName := filepath.Join(dir, "input.csv")
+	outputFileName := filepath.Join(dir, "output.csv")
+	err = os.WriteFile(inputFileName, []byte("name,age\nJohn,25\nJane,30"), 0644)
+	assert.NoError(t, err)
+
+	req := interfaces.Request{
+		CSVSourceFileName:      inputFileName,
+		CSVDestinationFileName: outputFileName,
+	}
+
+	t.Cleanup(runner.RegisterHook(runStore))
+
+	t.Run("Execute records a successful run", func(t *testing.T) {
+		run, err := runner.Execute(context.Background(), runner.Spec{
+			Pipeline:           "csv-copy",
+			Source:             "CSV",
+			Destination:        "CSV",
+			SourceRequest:      req,
+			DestinationRequest: req,
+			Trigger:            "cli",
+		})
+		logTestStatus("Execute CSV to CSV run", err)
+		assert.NoError(t, err)
+		assert.Equal(t, runner.StatusSucceeded, run.Status)
+		assert.Equal(t, 3, run.RecordsRead)
+		assert.Equal(t, 3, run.RecordsWritten)
+		assert.NotEmpty(t, run.ConfigHash)
+
+		stored, err := runStore.GetRun(run.ID)
+		logTestStatus("Read run back from store", err)
+		assert.NoError(t, err)
+		assert.Equal(t, runner.StatusSucceeded, stored.Status)
+		assert.Equal(t, run.ConfigHash, stored.ConfigHash)
+	})
+
+	t.Run("Execute records a failed run", func(t *testing.T) {
+		run, err := runner.Execute(context.Background(), runner.Spec{
+			Pipeline:    "broken",
+			Source:      "DoesNotExist",
+			Destination: "CSV",
+			Trigger:     "http",
+		})
+		logTestStatus("Execute run with unknown source fails", nil)
+		assert.Error(t, err)
+		assert.Equal(t, runner.StatusFailed, run.Status)
+
+		failed, err := runStore.ListRuns(store.RunFilter{Status: runner.StatusFailed})
+		assert.NoError(t, err)
+		assert.Len(t, failed, 1)
+		assert.Equal(t, "broken", failed[0].Pipeline)
+	})
+
+	t.Run("Retention prunes old runs", func(t *testing.T) {
+		old := &runner.Run{
+			ID:        "19990101T000000.000000000-000000",
+			Pipeline:  "csv-copy",
+			StartedAt: time.Now().Add(-48 * time.Hour),
+			Status:    runner.StatusSucceeded,
+		}
+		assert.NoError(t, runStore.SaveRun(old))
+
+		pruned, err := runStore.PruneRuns(store.Retention{MaxAge: 24 * time.Hour})
+		logTestStatus("Prune runs older than a day", err)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, pruned)
+
+		_, err = runStore.GetRun(old.ID)
+		assert.ErrorIs(t, err, store.ErrNotFound)
+
+		pruned, err = runStore.PruneRuns(store.Retention{MaxRuns: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, pruned)
+
+		remaining, err := runStore.ListRuns(store.RunFilter{})
+		assert.NoError(t, err)
+		assert.Len(t, remaining, 1)
+	})
+}
+
+// countingHook counts the runs it is told about
+type countingHook struct{ finished *atomic.Int32 }
+
+func (h countingHook) RunStarted(*runner.Run)  {}
+func (h countingHook) RunFinished(*runner.Run) { h.finished.Add(1) }
+
+func TestUnregisterHook(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("name,age\nJohn,25"), 0644))
+	execute := func() {
+		_, err := runner.Execute(context.Background(), runner.Spec{
+			Pipeline:           "unhooked",
+			Source:             "CSV",
+			Destination:        "CSV",
+			SourceRequest:      interfaces.Request{CSVSourceFileName: input},
+			DestinationRequest: interfaces.Request{CSVDestinationFileName: filepath.Join(dir, "output.csv")},
+			Trigger:            "cli",
+		})
+		assert.NoError(t, err)
+	}
+
+	hook := countingHook{finished: &atomic.Int32{}}
+	unregister := runner.RegisterHook(hook)
+	execute()
+	assert.EqualValues(t, 1, hook.finished.Load())
+
+	unregister()
+	unregister()
+	execute()
+	assert.EqualValues(t, 1, hook.finished.Load())
+	t.Logf("%s An unregistered hook is no longer called", greenTick)
+}
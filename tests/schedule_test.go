@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SkySingh04/fractal/config"
+	"github.com/SkySingh04/fractal/schedule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleParsing(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	redCross := "\033[31m✘\033[0m"
+
+	start := time.Date(2024, 3, 1, 10, 17, 0, 0, time.UTC)
+	cases := []struct {
+		spec schedule.Spec
+		next time.Time
+	}{
+		{schedule.Spec{Expression: "30 2 * * *"}, time.Date(2024, 3, 2, 2, 30, 0, 0, time.UTC)},
+		{schedule.Spec{Expression: "@hourly"}, time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{schedule.Spec{Expression: "15m"}, start.Add(15 * time.Minute)},
+		{schedule.Spec{Expression: "90"}, start.Add(90 * time.Second)},
+		{schedule.Spec{Expression: "@every 2h"}, start.Add(2 * time.Hour)},
+		{schedule.Spec{Expression: "0 9 * * *", Timezone: "Asia/Kolkata"}, time.Date(2024, 3, 2, 3, 30, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		parsed, err := c.spec.Parse()
+		if !assert.NoError(t, err) {
+			t.Logf("%s %q did not parse", redCross, c.spec.Expression)
+			continue
+		}
+		assert.True(t, c.next.Equal(parsed.Next(start)), "%q: expected %s, got %s", c.spec.Expression, c.next, parsed.Next(start))
+	}
+	t.Logf("%s Cron expressions, shortcuts, intervals and time zones are parsed", greenTick)
+
+	for _, spec := range []schedule.Spec{
+		{Expression: ""},
+		{Expression: "every now and then"},
+		{Expression: "500ms"},
+		{Expression: "@hourly", Timezone: "Mars/Olympus_Mons"},
+		{Expression: "@hourly", Overlap: "sometimes"},
+		{Expression: "@hourly", CatchUp: "maybe"},
+	} {
+		assert.Error(t, spec.Validate(), "%+v should be invalid", spec)
+	}
+	t.Logf("%s Invalid schedules are rejected", greenTick)
+
+	hourly, _ := schedule.Spec{Expression: "@hourly"}.Parse()
+	missed := schedule.Missed(hourly, start.Add(-3*time.Hour), start)
+	assert.Len(t, missed, 3)
+	t.Logf("%s Missed runs are counted from the last run", greenTick)
+
+	spec, ok, err := config.ScheduleFromConfig(map[string]interface{}{
+		"cronjob": map[string]interface{}{"repetition_interval": "1h", "overlap": "queue", "jitter": "30s"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, schedule.Spec{Expression: "1h", Overlap: schedule.OverlapQueue, Jitter: 30 * time.Second}, spec)
+	_, ok, err = config.ScheduleFromConfig(map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	t.Logf("%s cronjob.repetition_interval from config.yaml is honoured", greenTick)
+}
+
+func TestScheduleOverlapAndCatchUp(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	// Every run blocks until release is closed, so the second tick finds the first still running
+	overlap := func(policy schedule.Overlap) (calls *int32, release chan struct{}, stop context.CancelFunc) {
+		calls, release = new(int32), make(chan struct{})
+		loop, err := schedule.NewLoop("test", schedule.Spec{Expression: "1s", Overlap: policy}, func(time.Time) {
+			atomic.AddInt32(calls, 1)
+			<-release
+		})
+		assert.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		go loop.Run(ctx, time.Time{})
+		return calls, release, cancel
+	}
+
+	skipCalls, skipRelease, stopSkip := overlap(schedule.OverlapSkip)
+	queueCalls, queueRelease, stopQueue := overlap(schedule.OverlapQueue)
+	concurrentCalls, concurrentRelease, stopConcurrent := overlap(schedule.OverlapConcurrent)
+	defer func() {
+		stopSkip()
+		stopQueue()
+		stopConcurrent()
+		close(skipRelease)
+		close(concurrentRelease)
+	}()
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(concurrentCalls) >= 2 }, 4*time.Second, 20*time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(skipCalls))
+	assert.Equal(t, int32(1), atomic.LoadInt32(queueCalls))
+	t.Logf("%s Overlapping runs are skipped, held back or run concurrently", greenTick)
+
+	close(queueRelease)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(queueCalls) >= 2 }, time.Second, 20*time.Millisecond)
+	t.Logf("%s Queued runs start once the previous run finishes", greenTick)
+
+	catchUp := func(policy schedule.CatchUp) int32 {
+		var calls int32
+		loop, err := schedule.NewLoop("test", schedule.Spec{Expression: "@hourly", CatchUp: policy}, func(time.Time) {
+			atomic.AddInt32(&calls, 1)
+		})
+		assert.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go loop.Run(ctx, time.Now().Add(-3*time.Hour))
+		time.Sleep(200 * time.Millisecond)
+		return atomic.LoadInt32(&calls)
+	}
+	assert.Equal(t, int32(0), catchUp(schedule.CatchUpNone))
+	assert.Equal(t, int32(1), catchUp(schedule.CatchUpOnce))
+	assert.Equal(t, int32(3), catchUp(schedule.CatchUpAll))
+	t.Logf("%s Runs missed during downtime are caught up according to policy", greenTick)
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "github.com/SkySingh04/fractal/integrations"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegrationCatalog(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	catalog, err := schema.All()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, catalog.Sources)
+	assert.NotEmpty(t, catalog.Destinations)
+
+	// Every schema field must be a field of interfaces.Request, otherwise HTTP
+	// callers would be told to send a key the server ignores
+	requestFields := map[string]bool{}
+	requestType := reflect.TypeOf(interfaces.Request{})
+	for i := 0; i < requestType.NumField(); i++ {
+		name, _, _ := strings.Cut(requestType.Field(i).Tag.Get("json"), ",")
+		requestFields[name] = true
+	}
+
+	for _, integration := range append(catalog.Sources, catalog.Destinations...) {
+		assert.NotEmpty(t, integration.Fields, "%s %s has no config fields", integration.Kind, integration.Name)
+		for _, field := range integration.Fields {
+			assert.True(t, requestFields[field.Name], "%s %s declares unknown request field %s", integration.Kind, integration.Name, field.Name)
+			assert.NotEmpty(t, field.ConfigKey)
+			assert.NotEmpty(t, field.Description, "%s %s field %s has no description", integration.Kind, integration.Name, field.Name)
+		}
+	}
+	t.Logf("%s Every catalog field maps to a request field", greenTick)
+
+	postgres, err := schema.ForSource("PostgreSQL")
+	assert.NoError(t, err)
+	assert.Equal(t, []schema.Field{{
+		Name:        "sql_source_conn_string",
+		ConfigKey:   "connstring",
+		Type:        "string",
+		Required:    true,
+		Secret:      true,
+		Endpoint:    "url",
+		Description: "PostgreSQL connection string of the source database",
+	}}, postgres.Fields)
+
+	missing := schema.Missing(postgres, interfaces.Request{})
+	assert.Len(t, missing, 1)
+	assert.Empty(t, schema.Missing(postgres, interfaces.Request{SQLSourceConnString: "postgres://localhost"}))
+
+	dynamo, err := schema.ForDestination("DynamoDB")
+	assert.NoError(t, err)
+	req := interfaces.Request{DynamoDBTargetTable: "output"}
+	schema.ApplyDefaults(dynamo, &req)
+	assert.Equal(t, "us-east-1", req.DynamoDBTargetRegion)
+	t.Logf("%s Defaults are applied to empty request fields", greenTick)
+}
+
+func TestFieldChecks(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	fields, err := schema.Of(struct {
+		Mode    string `json:"mode" enum:"append, replace" default:"append"`
+		Retries int    `json:"retries" required:"true"`
+		Verbose bool   `json:"verbose"`
+	}{})
+	assert.NoError(t, err)
+	mode, retries, verbose := fields[0], fields[1], fields[2]
+	assert.Equal(t, []string{"append", "replace"}, mode.Enum)
+
+	assert.NoError(t, schema.Check(mode, "replace"))
+	assert.ErrorContains(t, schema.Check(mode, "merge"), "must be one of append, replace")
+	assert.NoError(t, schema.Check(retries, "3"))
+	assert.Error(t, schema.Check(retries, "three"))
+	assert.ErrorContains(t, schema.Check(retries, ""), "required")
+	assert.NoError(t, schema.Check(retries, "${RETRIES}"), "references are checked when they are resolved")
+	assert.NoError(t, schema.Check(verbose, "true"))
+	assert.Error(t, schema.Check(verbose, "sometimes"))
+	assert.NoError(t, schema.Check(verbose, ""))
+	t.Logf("%s Entered values are checked against the field schema", greenTick)
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/registry"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowDestination takes delay to write, unless the run is cancelled first
+type slowDestination struct {
+	delay time.Duration
+}
+
+func (d slowDestination) SendData(data interface{}, req interfaces.Request) error {
+	select {
+	case <-time.After(d.delay):
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	registry.RegisterDestination("SlowTest", slowDestination{delay: 300 * time.Millisecond})
+	registry.RegisterDestination("StuckTest", slowDestination{delay: time.Hour})
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("name,age\nJohn,25"), 0644))
+	spec := func(destination string) runner.Spec {
+		return runner.Spec{Pipeline: "drain", Source: "CSV", SourceRequest: interfaces.Request{CSVSourceFileName: input}, Destination: destination, Trigger: "test"}
+	}
+	runStore, err := store.Open(filepath.Join(dir, "runs.db"), store.Retention{})
+	assert.NoError(t, err)
+	t.Cleanup(runner.RegisterHook(runStore))
+	results := make(chan *runner.Run, 1)
+	finished := func(run *runner.Run, err error) { results <- run }
+
+	runner.Start(spec("SlowTest"), finished)
+	drained := make(chan int)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		drained <- runner.Drain(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	refused, err := runner.Execute(context.Background(), spec("CSV"))
+	assert.ErrorIs(t, err, runner.ErrShuttingDown)
+	assert.Equal(t, runner.StatusCancelled, refused.Status)
+	recorded, err := runStore.GetRun(refused.ID)
+	if assert.NoError(t, err, "refused runs are recorded") {
+		assert.Equal(t, runner.StatusCancelled, recorded.Status)
+		assert.Equal(t, runner.ErrShuttingDown.Error(), recorded.Error)
+	}
+	assert.Equal(t, 0, <-drained)
+	assert.Equal(t, runner.StatusSucceeded, (<-results).Status)
+	t.Logf("%s Draining refuses new runs, records them, and waits for the runs in progress to finish", greenTick)
+
+	id := runner.Start(spec("StuckTest"), finished)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, 1, runner.Drain(ctx))
+	stuck := <-results
+	assert.Equal(t, id, stuck.ID)
+	assert.Equal(t, runner.StatusCancelled, stuck.Status)
+	assert.False(t, runner.Cancel(id))
+	t.Logf("%s Runs still going after the grace period are cancelled and recorded", greenTick)
+
+	run, err := runner.Execute(context.Background(), spec("SlowTest"))
+	assert.NoError(t, err)
+	assert.Equal(t, runner.StatusSucceeded, run.Status)
+	t.Logf("%s Runs start again once draining is over", greenTick)
+
+	// A run cancelled at the deadline commits the destinations it delivered to
+	t.Cleanup(runner.UseCheckpoints(runStore))
+	output := filepath.Join(dir, "output.csv")
+	partial := spec("CSV")
+	partial.DestinationRequest = interfaces.Request{CSVDestinationFileName: output}
+	partial.Destinations = []runner.Target{{Destination: "StuckTest"}}
+	id = runner.Start(partial, finished)
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(output)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, 1, runner.Drain(ctx))
+	assert.Equal(t, runner.StatusCancelled, (<-results).Status)
+	checkpoint, err := runStore.LoadCheckpoint("drain")
+	assert.NoError(t, err)
+	if assert.NotNil(t, checkpoint) {
+		assert.Equal(t, id, checkpoint.RunID)
+		assert.Equal(t, []int{0}, checkpoint.Delivered)
+	}
+	t.Logf("%s Runs cancelled at the deadline commit a checkpoint before Drain returns", greenTick)
+}
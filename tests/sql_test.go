@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SkySingh04/fractal/integrations"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -154,3 +155,23 @@ func TestAdd(t *testing.T) {
 		})
 	}
 }
+
+func TestEnsureTableExistsInTransaction(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT to_regclass\('public.users'\)`).WillReturnRows(sqlmock.NewRows([]string{"to_regclass"}).AddRow(nil))
+	mock.ExpectExec("CREATE TABLE users").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, integrations.EnsureTableExists(tx, "users", map[string]interface{}{"age": int64(25)}))
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+	t.Logf("%s Tables are created in the transaction of the rows, so a rollback drops them", greenTick)
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/opentele"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracingConfig(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	traces := filepath.Join(t.TempDir(), "traces.json")
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "console")
+	t.Setenv("FRACTAL_TRACES_FILE", traces)
+	t.Setenv("OTEL_SERVICE_NAME", "fractal-test")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=ci")
+	t.Setenv("OTEL_TRACES_SAMPLER", "traceidratio")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "1")
+	cleanup, err := opentele.InitTracing()
+	assert.NoError(t, err)
+	_, span := opentele.CreateSpan(context.Background(), "fetch-data")
+	span.End()
+	cleanup()
+
+	exported, err := os.ReadFile(traces)
+	assert.NoError(t, err)
+	assert.Contains(t, string(exported), `"Name":"fetch-data"`)
+	assert.Contains(t, string(exported), `"Value":"fractal-test"`)
+	assert.Contains(t, string(exported), `"Key":"deployment.environment","Value":{"Type":"STRING","Value":"ci"}`)
+	t.Logf("%s The console exporter writes spans with the configured resource to a file", greenTick)
+
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "2")
+	_, err = opentele.InitTracing()
+	assert.ErrorContains(t, err, "OTEL_TRACES_SAMPLER_ARG")
+	t.Setenv("OTEL_TRACES_SAMPLER", "")
+	t.Setenv("OTEL_TRACES_EXPORTER", "jaeger")
+	_, err = opentele.InitTracing()
+	assert.ErrorContains(t, err, `unsupported OTEL_TRACES_EXPORTER "jaeger"`)
+	t.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json")
+	_, err = opentele.InitTracing()
+	assert.ErrorContains(t, err, "http/json")
+	t.Logf("%s Unsupported exporters, protocols and sampler arguments are errors", greenTick)
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	cleanup, err = opentele.InitTracing()
+	assert.NoError(t, err)
+	cleanup()
+	t.Logf("%s Tracing can be turned off", greenTick)
+}
+
+func TestIntegrationSpans(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	dir := t.TempDir()
+	traces := filepath.Join(dir, "traces.json")
+	t.Setenv("OTEL_TRACES_EXPORTER", "console")
+	t.Setenv("FRACTAL_TRACES_FILE", traces)
+	cleanup, err := opentele.InitTracing()
+	assert.NoError(t, err)
+
+	input := filepath.Join(dir, "input.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("name,age\nJohn,25\nJane,30"), 0644))
+	_, err = runner.Execute(context.Background(), runner.Spec{
+		Pipeline:           "traced",
+		Source:             "CSV",
+		SourceRequest:      interfaces.Request{CSVSourceFileName: input},
+		Destination:        "CSV",
+		DestinationRequest: interfaces.Request{CSVDestinationFileName: filepath.Join(dir, "output.csv")},
+		Trigger:            "cli",
+	})
+	assert.NoError(t, err)
+
+	// The handshake of a WebSocket connection carries the trace context
+	traceparent := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("traceparent")
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+		conn.Close()
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	_, err = runner.Execute(context.Background(), runner.Spec{
+		Pipeline:           "streamed",
+		Source:             "WebSocket",
+		SourceRequest:      interfaces.Request{WebSocketSourceURL: url},
+		Destination:        "WebSocket",
+		DestinationRequest: interfaces.Request{WebSocketDestURL: url},
+		Trigger:            "cli",
+	})
+	assert.NoError(t, err)
+	cleanup()
+
+	exported, err := os.ReadFile(traces)
+	assert.NoError(t, err)
+	for _, name := range []string{"run", "fetch-data", "csv-read", "csv-validate", "csv-transform", "send-data", "csv-write"} {
+		assert.Contains(t, string(exported), `"Name":"`+name+`"`)
+	}
+	assert.Contains(t, string(exported), `"Key":"fractal.records","Value":{"Type":"INT64","Value":3}`)
+	t.Logf("%s Reading, rule stages and writing of an integration are traced within the run", greenTick)
+
+	read, written := <-traceparent, <-traceparent
+	assert.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, read)
+	assert.Equal(t, read[3:35], written[3:35], "both connections belong to the trace of the run")
+	assert.Contains(t, string(exported), `"Name":"websocket-connect"`)
+	assert.Contains(t, string(exported), `"Name":"websocket-transform"`)
+	assert.Contains(t, string(exported), `"Name":"websocket-write"`)
+	t.Logf("%s Trace context is passed on to the systems fractal connects to", greenTick)
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	cleanup, err = opentele.InitTracing()
+	assert.NoError(t, err)
+	cleanup()
+}
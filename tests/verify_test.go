@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/runner"
+	"github.com/SkySingh04/fractal/verify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	greenTick := "\033[32m✔\033[0m"
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("id,name,age\n1,John,25\n2,Jane,30"), 0644))
+
+	run, err := runner.Execute(context.Background(), runner.Spec{
+		Pipeline:           "verified",
+		Source:             "CSV",
+		SourceRequest:      interfaces.Request{CSVSourceFileName: input},
+		Destination:        "CSV",
+		DestinationRequest: interfaces.Request{CSVDestinationFileName: filepath.Join(dir, "output.csv")},
+		Trigger:            "cli",
+		Verify:             &verify.Options{KeyFields: []string{"id"}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, run.Verification, 1)
+	assert.Equal(t, verify.StatusPassed, run.Verification[0].Status)
+	dataset := run.Verification[0].Datasets[0]
+	assert.Equal(t, 2, dataset.SourceCount)
+	assert.Equal(t, 2, dataset.DestinationCount)
+	assert.Equal(t, dataset.SourceChecksum, dataset.DestinationChecksum)
+	assert.Equal(t, 2, dataset.Sampled)
+	t.Logf("%s A destination holding what was sent passes", greenTick)
+
+	source := map[string]interface{}{"users": []interface{}{
+		map[string]interface{}{"id": 1, "name": "John", "age": 25},
+		map[string]interface{}{"id": 2, "name": "Jane", "age": 30},
+		map[string]interface{}{"id": 3, "name": "Jim", "age": 35},
+	}}
+	written := map[string]interface{}{"users": []interface{}{
+		map[string]interface{}{"id": "1", "name": "John", "age": "25"},
+		map[string]interface{}{"id": "2", "name": "Jane", "age": "31"},
+	}}
+	datasets, err := verify.Compare(source, written, verify.Options{KeyFields: []string{"id"}})
+	assert.NoError(t, err)
+	assert.Len(t, datasets, 1)
+	assert.Equal(t, "users", datasets[0].Name)
+	assert.False(t, datasets[0].Passed)
+	assert.Equal(t, 3, datasets[0].SourceCount)
+	assert.Equal(t, 2, datasets[0].DestinationCount)
+	assert.NotEqual(t, datasets[0].SourceChecksum, datasets[0].DestinationChecksum)
+	assert.Equal(t, []verify.Mismatch{
+		{Key: "id=2", Fields: []verify.FieldDiff{{Field: "age", Source: "30", Destination: "31"}}},
+		{Key: "id=3", Missing: true},
+	}, datasets[0].Mismatches)
+	t.Logf("%s Missing records and changed fields are reported by key", greenTick)
+
+	reports := []verify.Report{{Destination: "PostgreSQL", Status: verify.StatusFailed, Datasets: datasets}}
+	assert.False(t, verify.Passed(reports))
+	assert.Contains(t, verify.Text(reports), `id=2: age is "30" in the source, "31" in the destination`)
+	assert.Contains(t, verify.Text(reports), "id=3: missing from the destination")
+	t.Logf("%s The report tells what differs", greenTick)
+}
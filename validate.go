@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SkySingh04/fractal/config"
+	"github.com/spf13/cobra"
+)
+
+func validateCommand() *cobra.Command {
+	var configFile string
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check a config file without running it",
+		Long: `Check a config file without running it. Unknown keys, integrations that are
+not registered, missing required fields and invalid cronjob settings are reported
+with their file:line location. The exit code is 1 if any problem is found.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			definitions, err := config.LoadPipelines(configFile)
+			var invalid *config.ValidationError
+			if errors.As(err, &invalid) {
+				for _, problem := range invalid.Problems {
+					fmt.Fprintln(cmd.ErrOrStderr(), problem)
+				}
+				return fmt.Errorf("%s has %d problem(s)", configFile, len(invalid.Problems))
+			}
+			if err != nil {
+				return err
+			}
+			var names []string
+			for _, definition := range definitions {
+				names = append(names, definition.Name)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s is valid, pipelines: %s\n", configFile, strings.Join(names, ", "))
+			return nil
+		},
+	}
+	configFlag(cmd, &configFile)
+	return cmd
+}
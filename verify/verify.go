@@ -0,0 +1,439 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SkySingh04/fractal/factory"
+	"github.com/SkySingh04/fractal/interfaces"
+	"github.com/SkySingh04/fractal/opentele"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// defaultSample is the number of records compared field by field when Options.Sample
+// is not set
+const defaultSample = 10
+
+// maxKeyLength shortens the keys of mismatched records in reports
+const maxKeyLength = 120
+
+// Options configure the verification of a run. A pipeline is verified after every
+// run when it has options, even empty ones.
+type Options struct {
+	// KeyFields identify a record. Checksums are computed over them and sampled
+	// records are matched by them. By default, every field found on both sides is used.
+	KeyFields []string `json:"key_fields,omitempty" yaml:"key_fields"`
+	// Sample is the number of records compared field by field, 10 by default
+	Sample int `json:"sample,omitempty" yaml:"sample"`
+}
+
+// Status is the outcome of the verification of a destination
+type Status string
+
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped" // The destination cannot be read back
+)
+
+// Report is the reconciliation of what a destination holds with what the source returned
+type Report struct {
+	Destination string    `json:"destination"`
+	Status      Status    `json:"status"`
+	Reason      string    `json:"reason,omitempty"`
+	Datasets    []Dataset `json:"datasets,omitempty"`
+}
+
+// Dataset reconciles one table, collection or file
+type Dataset struct {
+	Name                string     `json:"name"`
+	SourceCount         int        `json:"source_count"`
+	DestinationCount    int        `json:"destination_count"`
+	KeyFields           []string   `json:"key_fields"`
+	SourceChecksum      string     `json:"source_checksum"`
+	DestinationChecksum string     `json:"destination_checksum"`
+	Sampled             int        `json:"sampled"`
+	Mismatches          []Mismatch `json:"mismatches,omitempty"`
+	Passed              bool       `json:"passed"`
+}
+
+// Mismatch is a sampled source record that the destination lacks or holds differently
+type Mismatch struct {
+	Key     string      `json:"key"`
+	Missing bool        `json:"missing,omitempty"`
+	Fields  []FieldDiff `json:"fields,omitempty"`
+}
+
+// FieldDiff is a field whose value differs between source and destination
+type FieldDiff struct {
+	Field       string `json:"field"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// record is a record reduced to the text of each of its fields, so that values read
+// back from a different system, such as numbers written to a CSV file, compare equal
+type record map[string]string
+
+// Destination reads back what a destination holds and reconciles it with the data
+// the source returned. Destinations that do not implement interfaces.ReadBacker are
+// skipped.
+func Destination(ctx context.Context, name string, req interfaces.Request, data interface{}, options Options) (report Report) {
+	report = Report{Destination: name, Status: StatusFailed}
+	ctx, span := opentele.CreateSpan(ctx, "verify", attribute.String("fractal.destination", name))
+	defer func() {
+		span.SetAttributes(attribute.String("fractal.verification", string(report.Status)))
+		span.End()
+	}()
+
+	destination, err := factory.CreateDestination(name)
+	if err != nil {
+		report.Reason = err.Error()
+		return report
+	}
+	reader, ok := destination.(interfaces.ReadBacker)
+	if !ok {
+		report.Status = StatusSkipped
+		report.Reason = "the destination cannot be read back"
+		return report
+	}
+	written, err := reader.ReadBack(req.WithContext(ctx))
+	if err != nil {
+		report.Reason = fmt.Sprintf("failed to read back: %v", err)
+		return report
+	}
+	report.Datasets, err = Compare(data, written, options)
+	if err != nil {
+		report.Reason = err.Error()
+		return report
+	}
+	report.Status = StatusPassed
+	for _, dataset := range report.Datasets {
+		if !dataset.Passed {
+			report.Status = StatusFailed
+		}
+	}
+	return report
+}
+
+// Compare reconciles the data returned by a source with the data read back from a
+// destination. Tables and collections are paired by name; when both sides hold a
+// single one, they are paired whatever their names. Datasets that only the
+// destination holds are ignored.
+func Compare(source, destination interface{}, options Options) ([]Dataset, error) {
+	sourceSets, err := datasets(source)
+	if err != nil {
+		return nil, fmt.Errorf("source data: %w", err)
+	}
+	destinationSets, err := datasets(destination)
+	if err != nil {
+		return nil, fmt.Errorf("destination data: %w", err)
+	}
+
+	var names []string
+	for name := range sourceSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var results []Dataset
+	for _, name := range names {
+		written, ok := destinationSets[name]
+		if !ok && len(sourceSets) == 1 && len(destinationSets) == 1 {
+			for _, only := range destinationSets {
+				written = only
+			}
+		}
+		results = append(results, compareDataset(name, sourceSets[name], written, options))
+	}
+	return results, nil
+}
+
+func compareDataset(name string, source, destination []record, options Options) Dataset {
+	keys := options.KeyFields
+	common := commonFields(source, destination)
+	if len(keys) == 0 {
+		keys = common
+	}
+	dataset := Dataset{
+		Name:                name,
+		SourceCount:         len(source),
+		DestinationCount:    len(destination),
+		KeyFields:           keys,
+		SourceChecksum:      checksum(source, keys),
+		DestinationChecksum: checksum(destination, keys),
+	}
+
+	byKey := map[string][]record{}
+	for _, r := range destination {
+		byKey[r.key(keys)] = append(byKey[r.key(keys)], r)
+	}
+	for _, r := range sample(source, options.Sample) {
+		dataset.Sampled++
+		candidates := byKey[r.key(keys)]
+		if len(candidates) == 0 {
+			dataset.Mismatches = append(dataset.Mismatches, Mismatch{Key: r.label(keys), Missing: true})
+			continue
+		}
+		// Of the destination records with the same key, report the closest one
+		var diffs []FieldDiff
+		for i, candidate := range candidates {
+			if candidateDiffs := diff(r, candidate, common); i == 0 || len(candidateDiffs) < len(diffs) {
+				diffs = candidateDiffs
+			}
+		}
+		if len(diffs) > 0 {
+			dataset.Mismatches = append(dataset.Mismatches, Mismatch{Key: r.label(keys), Fields: diffs})
+		}
+	}
+
+	dataset.Passed = dataset.SourceCount == dataset.DestinationCount &&
+		dataset.SourceChecksum == dataset.DestinationChecksum &&
+		len(dataset.Mismatches) == 0
+	return dataset
+}
+
+// datasets splits data into records by table or collection. Text is read as CSV with
+// a header line; maps of lists, such as rows by table name, hold one dataset per key;
+// anything else is a single dataset named "".
+func datasets(data interface{}) (map[string][]record, error) {
+	switch v := data.(type) {
+	case nil:
+		return map[string][]record{"": nil}, nil
+	case string:
+		return csvDataset(v)
+	case []byte:
+		var decoded interface{}
+		if err := json.Unmarshal(v, &decoded); err != nil {
+			return csvDataset(string(v))
+		}
+		data = decoded
+	}
+
+	// Encoding to JSON and back turns documents, rows and structs into plain values
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	if tables, ok := value.(map[string]interface{}); ok && len(tables) > 0 && allLists(tables) {
+		sets := map[string][]record{}
+		for name, rows := range tables {
+			sets[name] = toRecords(rows.([]interface{}))
+		}
+		return sets, nil
+	}
+	if rows, ok := value.([]interface{}); ok {
+		return map[string][]record{"": toRecords(rows)}, nil
+	}
+	return map[string][]record{"": toRecords([]interface{}{value})}, nil
+}
+
+func csvDataset(text string) (map[string][]record, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var records []record
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		r := record{}
+		for j, value := range row {
+			column := fmt.Sprintf("column_%d", j+1)
+			if j < len(rows[0]) {
+				column = rows[0][j]
+			}
+			r[column] = value
+		}
+		records = append(records, r)
+	}
+	return map[string][]record{"": records}, nil
+}
+
+func allLists(values map[string]interface{}) bool {
+	for _, value := range values {
+		if _, ok := value.([]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func toRecords(values []interface{}) []record {
+	records := make([]record, 0, len(values))
+	for _, value := range values {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			records = append(records, record{"value": text(value)})
+			continue
+		}
+		r := record{}
+		for field, fieldValue := range fields {
+			r[field] = text(fieldValue)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// text is the form in which a value is compared
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// commonFields returns the fields found in records on both sides, sorted
+func commonFields(source, destination []record) []string {
+	inDestination := map[string]bool{}
+	for _, r := range destination {
+		for field := range r {
+			inDestination[field] = true
+		}
+	}
+	seen := map[string]bool{}
+	var fields []string
+	for _, r := range source {
+		for field := range r {
+			if inDestination[field] && !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func (r record) key(fields []string) string {
+	var key strings.Builder
+	for _, field := range fields {
+		value, ok := r[field]
+		if ok {
+			key.WriteString(value)
+		}
+		// Tell a missing field from an empty one
+		fmt.Fprintf(&key, "\x1f%t\x1e", ok)
+	}
+	return key.String()
+}
+
+func (r record) label(fields []string) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field + "=" + r[field]
+	}
+	label := strings.Join(parts, ", ")
+	if len(label) > maxKeyLength {
+		label = label[:maxKeyLength] + "..."
+	}
+	return label
+}
+
+// checksum sums the hashes of the key fields of every record, so it does not depend
+// on the order in which records were read
+func checksum(records []record, fields []string) string {
+	var sum uint64
+	for _, r := range records {
+		hash := sha256.Sum256([]byte(r.key(fields)))
+		sum += binary.BigEndian.Uint64(hash[:8])
+	}
+	return fmt.Sprintf("%016x", sum)
+}
+
+// sample picks up to n records spread evenly over records
+func sample(records []record, n int) []record {
+	if n <= 0 {
+		n = defaultSample
+	}
+	if len(records) <= n {
+		return records
+	}
+	picked := make([]record, n)
+	for i := range picked {
+		picked[i] = records[i*len(records)/n]
+	}
+	return picked
+}
+
+func diff(source, destination record, fields []string) []FieldDiff {
+	var diffs []FieldDiff
+	for _, field := range fields {
+		if source[field] != destination[field] {
+			diffs = append(diffs, FieldDiff{Field: field, Source: source[field], Destination: destination[field]})
+		}
+	}
+	return diffs
+}
+
+// Passed reports whether no destination of a run failed its verification
+func Passed(reports []Report) bool {
+	for _, report := range reports {
+		if report.Status == StatusFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// Text renders reports for people to read
+func Text(reports []Report) string {
+	var out strings.Builder
+	for _, report := range reports {
+		fmt.Fprintf(&out, "%s: %s", report.Destination, report.Status)
+		if report.Reason != "" {
+			fmt.Fprintf(&out, " (%s)", report.Reason)
+		}
+		out.WriteString("\n")
+		for _, dataset := range report.Datasets {
+			name := dataset.Name
+			if name == "" {
+				name = "records"
+			}
+			checksums := "checksums match"
+			if dataset.SourceChecksum != dataset.DestinationChecksum {
+				checksums = fmt.Sprintf("checksums differ (%s, %s)", dataset.SourceChecksum, dataset.DestinationChecksum)
+			}
+			fmt.Fprintf(&out, "  %s: %d in the source, %d in the destination, %s over %s; %d of %d sampled records differ\n",
+				name, dataset.SourceCount, dataset.DestinationCount, checksums, strings.Join(dataset.KeyFields, ", "),
+				len(dataset.Mismatches), dataset.Sampled)
+			for _, mismatch := range dataset.Mismatches {
+				if mismatch.Missing {
+					fmt.Fprintf(&out, "    %s: missing from the destination\n", mismatch.Key)
+					continue
+				}
+				for _, field := range mismatch.Fields {
+					fmt.Fprintf(&out, "    %s: %s is %q in the source, %q in the destination\n", mismatch.Key, field.Field, field.Source, field.Destination)
+				}
+			}
+		}
+	}
+	return out.String()
+}